@@ -0,0 +1,701 @@
+// Package apijson decodes the game API's JSON responses straight into the
+// apigame protobuf structs without going through protojson.
+//
+// The decoder is specialised for SessionResponse and PlayerBonesResponse. It
+// accepts everything protojson accepts for those messages and produces the
+// same result: fields may use their JSON or proto names, 64-bit integers may
+// be numbers or strings, and numbers may use exponent notation. Unknown
+// fields are skipped, as with protojson's DiscardUnknown.
+//
+// Unlike protojson, a field given more than once, possibly under both of
+// its names, is not an error; the last value wins.
+package apijson
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// SyntaxError describes malformed or mistyped JSON
+type SyntaxError struct {
+	// Offset is the byte offset in the input where the error was found
+	Offset int
+	msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("apijson: %s at offset %d", e.msg, e.Offset)
+}
+
+// maxDepth bounds nesting in skipped values so hostile input cannot exhaust the stack
+const maxDepth = 1000
+
+// decoder is a single-pass JSON scanner over a byte slice
+type decoder struct {
+	data []byte
+	pos  int
+
+	// Scratch space for unescaped strings and integers rewritten from
+	// exponent notation
+	buf  []byte
+	ibuf []byte
+
+	// Strings already decoded, keyed by their contents
+	strs map[string]string
+}
+
+// maxInterned bounds the strings a decoder keeps for reuse. Most strings
+// repeat from one response to the next, but a few, such as the game clock
+// display, change every frame.
+const maxInterned = 1024
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return &SyntaxError{Offset: d.pos, msg: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace advances past whitespace and returns the next byte, or 0 at the end of input
+func (d *decoder) skipSpace() byte {
+	for d.pos < len(d.data) {
+		switch c := d.data[d.pos]; c {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return c
+		}
+	}
+	return 0
+}
+
+// end checks that only whitespace remains
+func (d *decoder) end() error {
+	if d.skipSpace(); d.pos != len(d.data) {
+		return d.errorf("unexpected data after top-level value")
+	}
+	return nil
+}
+
+// consumeNull consumes a null literal if one is next
+func (d *decoder) consumeNull() bool {
+	if d.skipSpace() == 'n' && hasLiteral(d.data[d.pos:], "null") {
+		d.pos += 4
+		return true
+	}
+	return false
+}
+
+// enterObject consumes the opening brace of an object. It returns false if
+// the value is null instead.
+func (d *decoder) enterObject() (bool, error) {
+	if d.consumeNull() {
+		return false, nil
+	}
+	if d.skipSpace() != '{' {
+		return false, d.errorf("expected object")
+	}
+	d.pos++
+	return true, nil
+}
+
+// nextKey returns the next key of the object being decoded, consuming the
+// colon after it. It returns false at the closing brace.
+func (d *decoder) nextKey(first bool) ([]byte, bool, error) {
+	c := d.skipSpace()
+	if c == '}' {
+		d.pos++
+		return nil, false, nil
+	}
+	// A comma must be followed by another key, so trailing commas are rejected
+	if !first {
+		if c != ',' {
+			return nil, false, d.errorf("expected ',' or '}'")
+		}
+		d.pos++
+		c = d.skipSpace()
+	}
+	if c != '"' {
+		return nil, false, d.errorf("expected object key")
+	}
+	key, err := d.rawString()
+	if err != nil {
+		return nil, false, err
+	}
+	if d.skipSpace() != ':' {
+		return nil, false, d.errorf("expected ':'")
+	}
+	d.pos++
+	return key, true, nil
+}
+
+// enterArray consumes the opening bracket of an array. It returns false if
+// the value is null instead.
+func (d *decoder) enterArray() (bool, error) {
+	if d.consumeNull() {
+		return false, nil
+	}
+	if d.skipSpace() != '[' {
+		return false, d.errorf("expected array")
+	}
+	d.pos++
+	return true, nil
+}
+
+// nextElem reports whether another array element follows, consuming the
+// comma before it or the closing bracket. A comma must be followed by an
+// element, so trailing commas are rejected by the element's decoder.
+func (d *decoder) nextElem(first bool) (bool, error) {
+	c := d.skipSpace()
+	if c == ']' {
+		d.pos++
+		return false, nil
+	}
+	if first {
+		return true, nil
+	}
+	if c != ',' {
+		return false, d.errorf("expected ',' or ']'")
+	}
+	d.pos++
+	return true, nil
+}
+
+// rawString consumes a string and returns its contents, unescaped. The
+// result aliases the input or the scratch buffer, so it is only valid until
+// the next call.
+func (d *decoder) rawString() ([]byte, error) {
+	d.pos++ // opening quote
+	start := d.pos
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		switch {
+		case c == '"':
+			s := d.data[start:d.pos]
+			d.pos++
+			if !utf8.Valid(s) {
+				return nil, d.errorf("invalid UTF-8 in string")
+			}
+			return s, nil
+		case c == '\\':
+			return d.unescapeString(start)
+		case c < 0x20:
+			return nil, d.errorf("invalid character in string")
+		}
+		d.pos++
+	}
+	return nil, d.errorf("unterminated string")
+}
+
+// unescapeString finishes a string containing escapes, starting from its first character
+func (d *decoder) unescapeString(start int) ([]byte, error) {
+	d.buf = append(d.buf[:0], d.data[start:d.pos]...)
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		switch {
+		case c == '"':
+			d.pos++
+			if !utf8.Valid(d.buf) {
+				return nil, d.errorf("invalid UTF-8 in string")
+			}
+			return d.buf, nil
+		case c < 0x20:
+			return nil, d.errorf("invalid character in string")
+		case c != '\\':
+			d.buf = append(d.buf, c)
+			d.pos++
+			continue
+		}
+
+		if d.pos+1 >= len(d.data) {
+			break
+		}
+		esc := d.data[d.pos+1]
+		d.pos += 2
+		switch esc {
+		case '"', '\\', '/':
+			d.buf = append(d.buf, esc)
+		case 'b':
+			d.buf = append(d.buf, '\b')
+		case 'f':
+			d.buf = append(d.buf, '\f')
+		case 'n':
+			d.buf = append(d.buf, '\n')
+		case 'r':
+			d.buf = append(d.buf, '\r')
+		case 't':
+			d.buf = append(d.buf, '\t')
+		case 'u':
+			r, ok := d.hex4()
+			if !ok {
+				return nil, d.errorf("invalid escape code")
+			}
+			if utf16.IsSurrogate(r) {
+				if len(d.data)-d.pos < 2 || d.data[d.pos] != '\\' || d.data[d.pos+1] != 'u' {
+					return nil, d.errorf("invalid escape code")
+				}
+				d.pos += 2
+				r2, ok := d.hex4()
+				if r = utf16.DecodeRune(r, r2); !ok || r == utf8.RuneError {
+					return nil, d.errorf("invalid escape code")
+				}
+			}
+			d.buf = utf8.AppendRune(d.buf, r)
+		default:
+			return nil, d.errorf("invalid escape code")
+		}
+	}
+	return nil, d.errorf("unterminated string")
+}
+
+// hex4 consumes the four hex digits of a \u escape
+func (d *decoder) hex4() (rune, bool) {
+	if len(d.data)-d.pos < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range d.data[d.pos : d.pos+4] {
+		switch {
+		case '0' <= c && c <= '9':
+			r = r<<4 | rune(c-'0')
+		case 'a' <= c && c <= 'f':
+			r = r<<4 | rune(c-'a'+10)
+		case 'A' <= c && c <= 'F':
+			r = r<<4 | rune(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	d.pos += 4
+	return r, true
+}
+
+// scanNumber consumes a number and returns its text
+func (d *decoder) scanNumber() ([]byte, error) {
+	n := numberLen(d.data[d.pos:])
+	if n == 0 {
+		return nil, d.errorf("invalid number")
+	}
+	num := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return num, nil
+}
+
+// numberLen returns the length of the JSON number at the start of b, or 0 if there is none
+func numberLen(b []byte) int {
+	i := 0
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(b) && b[i] == '0':
+		i++
+	case i < len(b) && '1' <= b[i] && b[i] <= '9':
+		for i < len(b) && '0' <= b[i] && b[i] <= '9' {
+			i++
+		}
+	default:
+		return 0
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		digits := i
+		for i < len(b) && '0' <= b[i] && b[i] <= '9' {
+			i++
+		}
+		if i == digits {
+			return 0
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		digits := i
+		for i < len(b) && '0' <= b[i] && b[i] <= '9' {
+			i++
+		}
+		if i == digits {
+			return 0
+		}
+	}
+	// A number must not run into a following letter, e.g. 1x
+	if i < len(b) && (b[i] == '_' || 'a' <= b[i]|0x20 && b[i]|0x20 <= 'z' || '0' <= b[i] && b[i] <= '9') {
+		return 0
+	}
+	return i
+}
+
+// numberToken returns the text of a number, which may be quoted
+func (d *decoder) numberToken() ([]byte, error) {
+	if d.skipSpace() != '"' {
+		return d.scanNumber()
+	}
+	s, err := d.rawString()
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 0 || numberLen(s) != len(s) {
+		return nil, d.errorf("invalid number %q", s)
+	}
+	return s, nil
+}
+
+// str decodes a string, or null as ""
+func (d *decoder) str() (string, error) {
+	if d.consumeNull() {
+		return "", nil
+	}
+	if d.skipSpace() != '"' {
+		return "", d.errorf("expected string")
+	}
+	s, err := d.rawString()
+	if err != nil {
+		return "", err
+	}
+	return d.intern(s), nil
+}
+
+// intern returns b as a string, reusing an earlier string with the same
+// contents instead of allocating one
+func (d *decoder) intern(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if s, ok := d.strs[string(b)]; ok {
+		return s
+	}
+	if d.strs == nil {
+		d.strs = make(map[string]string)
+	} else if len(d.strs) >= maxInterned {
+		clear(d.strs)
+	}
+	s := string(b)
+	d.strs[s] = s
+	return s
+}
+
+// boolean decodes true, false, or null as false
+func (d *decoder) boolean() (bool, error) {
+	switch d.skipSpace() {
+	case 't':
+		if hasLiteral(d.data[d.pos:], "true") {
+			d.pos += 4
+			return true, nil
+		}
+	case 'f':
+		if hasLiteral(d.data[d.pos:], "false") {
+			d.pos += 5
+			return false, nil
+		}
+	case 'n':
+		if d.consumeNull() {
+			return false, nil
+		}
+	}
+	return false, d.errorf("expected boolean")
+}
+
+// hasLiteral reports whether b starts with lit
+func hasLiteral(b []byte, lit string) bool {
+	return len(b) >= len(lit) && string(b[:len(lit)]) == lit
+}
+
+// int32Value decodes an int32, or null as 0
+func (d *decoder) int32Value() (int32, error) {
+	if d.consumeNull() {
+		return 0, nil
+	}
+	v, err := d.integer(32)
+	return int32(v), err
+}
+
+// integer decodes a signed integer with the given bit size
+func (d *decoder) integer(bitSize int) (int64, error) {
+	num, err := d.numberToken()
+	if err != nil {
+		return 0, err
+	}
+	digits, ok := d.integerDigits(num)
+	if !ok {
+		return 0, d.errorf("invalid integer %s", num)
+	}
+	v, err := strconv.ParseInt(bytesToString(digits), 10, bitSize)
+	if err != nil {
+		return 0, d.errorf("invalid integer %s", num)
+	}
+	return v, nil
+}
+
+// uint64Value decodes a uint64, or null as 0
+func (d *decoder) uint64Value() (uint64, error) {
+	if d.consumeNull() {
+		return 0, nil
+	}
+	num, err := d.numberToken()
+	if err != nil {
+		return 0, err
+	}
+	digits, ok := d.integerDigits(num)
+	if !ok {
+		return 0, d.errorf("invalid integer %s", num)
+	}
+	v, err := strconv.ParseUint(bytesToString(digits), 10, 64)
+	if err != nil {
+		return 0, d.errorf("invalid integer %s", num)
+	}
+	return v, nil
+}
+
+// integerDigits rewrites a number with a fraction or exponent, such as 1.5e1,
+// as plain integer digits. It reports false if the number is not an integer.
+func (d *decoder) integerDigits(num []byte) ([]byte, bool) {
+	var i int
+	for i < len(num) && num[i] != '.' && num[i] != 'e' && num[i] != 'E' {
+		i++
+	}
+	if i == len(num) {
+		return num, true
+	}
+
+	neg := num[0] == '-'
+	intPart := num[:i]
+	if neg {
+		intPart = intPart[1:]
+	}
+	var frac []byte
+	if num[i] == '.' {
+		j := i + 1
+		for j < len(num) && num[j] != 'e' && num[j] != 'E' {
+			j++
+		}
+		frac = num[i+1 : j]
+		i = j
+	}
+	if allZero(intPart) && allZero(frac) {
+		return []byte("0"), true
+	}
+	exp := 0
+	if i < len(num) {
+		e, err := strconv.Atoi(bytesToString(num[i+1:]))
+		if err != nil || e > 100 || e < -100 {
+			// Far too large or too small to be a 64-bit integer
+			return nil, false
+		}
+		exp = e
+	}
+
+	// Place the decimal point exp digits to the right and require zeros after
+	// it. num may alias buf, so the digits are assembled in ibuf.
+	d.ibuf = append(append(d.ibuf[:0], intPart...), frac...)
+	point := len(intPart) + exp
+	switch {
+	case point <= 0:
+		return nil, false
+	case point >= len(d.ibuf):
+		for len(d.ibuf) < point {
+			d.ibuf = append(d.ibuf, '0')
+		}
+	default:
+		if !allZero(d.ibuf[point:]) {
+			return nil, false
+		}
+		d.ibuf = d.ibuf[:point]
+	}
+	if neg {
+		d.ibuf = append(d.ibuf, 0)
+		copy(d.ibuf[1:], d.ibuf)
+		d.ibuf[0] = '-'
+	}
+	return d.ibuf, true
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// float64Value decodes a double, or null as 0
+func (d *decoder) float64Value() (float64, error) {
+	if d.consumeNull() {
+		return 0, nil
+	}
+	return d.float(64)
+}
+
+// float decodes a number with the given bit size. Strings may hold a
+// number, NaN, Infinity or -Infinity.
+func (d *decoder) float(bitSize int) (float64, error) {
+	var num []byte
+	if d.skipSpace() == '"' {
+		s, err := d.rawString()
+		if err != nil {
+			return 0, err
+		}
+		switch string(s) {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		if len(s) == 0 || numberLen(s) != len(s) {
+			return 0, d.errorf("invalid number %q", s)
+		}
+		num = s
+	} else {
+		var err error
+		if num, err = d.scanNumber(); err != nil {
+			return 0, err
+		}
+	}
+	v, err := strconv.ParseFloat(bytesToString(num), bitSize)
+	if err != nil {
+		return 0, d.errorf("invalid number %s", num)
+	}
+	return v, nil
+}
+
+// float64List decodes an array of doubles into dst's storage, or null as nil
+func (d *decoder) float64List(dst []float64) ([]float64, error) {
+	ok, err := d.enterArray()
+	if !ok || err != nil {
+		return nil, err
+	}
+	dst = dst[:0]
+	for first := true; ; first = false {
+		if more, err := d.nextElem(first); err != nil {
+			return nil, err
+		} else if !more {
+			break
+		}
+		v, err := d.float(64)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, v)
+	}
+	if len(dst) == 0 {
+		return nil, nil
+	}
+	return dst, nil
+}
+
+// float32List decodes an array of floats into dst's storage, or null as nil
+func (d *decoder) float32List(dst []float32) ([]float32, error) {
+	ok, err := d.enterArray()
+	if !ok || err != nil {
+		return nil, err
+	}
+	dst = dst[:0]
+	for first := true; ; first = false {
+		if more, err := d.nextElem(first); err != nil {
+			return nil, err
+		} else if !more {
+			break
+		}
+		v, err := d.float(32)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, float32(v))
+	}
+	if len(dst) == 0 {
+		return nil, nil
+	}
+	return dst, nil
+}
+
+// int32List decodes an array of int32s into dst's storage, or null as nil
+func (d *decoder) int32List(dst []int32) ([]int32, error) {
+	ok, err := d.enterArray()
+	if !ok || err != nil {
+		return nil, err
+	}
+	dst = dst[:0]
+	for first := true; ; first = false {
+		if more, err := d.nextElem(first); err != nil {
+			return nil, err
+		} else if !more {
+			break
+		}
+		v, err := d.integer(32)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, int32(v))
+	}
+	if len(dst) == 0 {
+		return nil, nil
+	}
+	return dst, nil
+}
+
+// skipValue consumes any value, checking that it is well formed
+func (d *decoder) skipValue(depth int) error {
+	if depth > maxDepth {
+		return d.errorf("exceeded maximum nesting depth")
+	}
+	switch d.skipSpace() {
+	case '{':
+		d.pos++
+		for first := true; ; first = false {
+			_, ok, err := d.nextKey(first)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+			if err := d.skipValue(depth + 1); err != nil {
+				return err
+			}
+		}
+	case '[':
+		d.pos++
+		for first := true; ; first = false {
+			more, err := d.nextElem(first)
+			if err != nil {
+				return err
+			}
+			if !more {
+				return nil
+			}
+			if err := d.skipValue(depth + 1); err != nil {
+				return err
+			}
+		}
+	case '"':
+		_, err := d.rawString()
+		return err
+	case 't', 'f':
+		_, err := d.boolean()
+		return err
+	case 'n':
+		if d.consumeNull() {
+			return nil
+		}
+		return d.errorf("invalid literal")
+	case 0:
+		return d.errorf("unexpected end of input")
+	default:
+		_, err := d.scanNumber()
+		return err
+	}
+}
+
+// bytesToString views b as a string without copying. The string must not
+// outlive the call it is passed to, since b may be reused.
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(&b[0], len(b))
+}
@@ -0,0 +1,260 @@
+package apijson
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// protojsonSession decodes data the way the EchoReplay reader does
+func protojsonSession(data []byte) (*apigame.SessionResponse, error) {
+	m := &apigame.SessionResponse{}
+	err := protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, m)
+	return m, err
+}
+
+// checkSessionMatchesProtojson decodes input with both decoders and requires
+// the same outcome
+func checkSessionMatchesProtojson(t *testing.T, input string) {
+	t.Helper()
+	want, wantErr := protojsonSession([]byte(input))
+	got := &apigame.SessionResponse{}
+	gotErr := UnmarshalSession([]byte(input), got)
+
+	switch {
+	case wantErr != nil && gotErr == nil:
+		t.Fatalf("protojson rejected %s (%v) but apijson accepted it: %v", input, wantErr, got)
+	case wantErr == nil && gotErr != nil:
+		t.Fatalf("apijson rejected %s: %v", input, gotErr)
+	case wantErr == nil && !proto.Equal(got, want):
+		t.Fatalf("decoded %s differently\napijson:   %v\nprotojson: %v", input, got, want)
+	}
+}
+
+func TestDecoder_AcceptsWhatProtojsonAccepts(t *testing.T) {
+	inputs := []string{
+		`{}`,
+		` { } `,
+		"\t{\r\n\"game_status\" : \"playing\" }\n",
+
+		// Field names
+		`{"sessionid":"abc"}`,
+		`{"session_id":"abc"}`,
+		`{"teams":[{"team":"BLUE TEAM","possession":true}]}`,
+		`{"teams":[{"team_name":"BLUE TEAM","has_possession":true}]}`,
+		`{"teams":[{"players":[{"name":"a","playerid":3,"number":7,"userid":42,"stunned":true,"lhand":{"pos":[1,2,3]}}]}]}`,
+		`{"teams":[{"players":[{"display_name":"a","slot_number":3,"jersey_number":7,"account_number":"42","is_stunned":true,"left_hand":{"pos":[1,2,3]}}]}]}`,
+
+		// Integers
+		`{"blue_points":-12}`,
+		`{"blue_points":"7"}`,
+		`{"blue_points":1e1}`,
+		`{"blue_points":1.0}`,
+		`{"blue_points":-2.50e1}`,
+		`{"blue_points":"1.5e1"}`,
+		`{"blue_points":0.0e5}`,
+		`{"blue_points":-0}`,
+		`{"blue_points":2147483647}`,
+		`{"blue_points":-2147483648}`,
+		`{"blue_points":1000e-3}`,
+		`{"possession":[0,1,"1",1e0]}`,
+
+		// The game writes uint64 fields as numbers, protojson as strings
+		`{"rules_changed_at":1737330654}`,
+		`{"rules_changed_at":"1737330654"}`,
+		`{"rules_changed_at":18446744073709551615}`,
+		`{"rules_changed_at":1.8446744073709551615e19}`,
+		`{"teams":[{"players":[{"userid":4355631244523776}]}]}`,
+
+		// Floats
+		`{"game_clock":123.456}`,
+		`{"game_clock":-0.0}`,
+		`{"game_clock":1E-5}`,
+		`{"game_clock":"12.5"}`,
+		`{"game_clock":"NaN"}`,
+		`{"game_clock":"Infinity"}`,
+		`{"game_clock":"-Infinity"}`,
+		`{"game_clock":1.7976931348623157e308}`,
+		`{"game_clock":5e-324}`,
+		`{"disc":{"position":[0.1,-0.2,3e2],"velocity":["1",2,"NaN"]}}`,
+		`{"disc":{"position":[]}}`,
+
+		// Strings
+		`{"map_name":""}`,
+		`{"map_name":"mpl_arena_a"}`,
+		`{"map_name":"a\"b\\c\/d\be\ff\ng\rh\ti"}`,
+		`{"map_name":"é中😀"}`,
+		`{"map_name":"héllo 世界 😀"}`,
+		`{"map_names":"x"}`,
+
+		// Nulls mean unset
+		`{"map_name":null,"blue_points":null,"game_clock":null,"private_match":null}`,
+		`{"disc":null,"teams":null,"possession":null,"rules_changed_at":null}`,
+		`{"teams":[{"players":null,"stats":null}]}`,
+
+		// Booleans
+		`{"private_match":true,"tournament_match":false}`,
+
+		// Unknown fields of every shape are skipped
+		`{"unknown":1}`,
+		`{"unknown":{"a":[1,{"b":null}],"c":"d"},"game_status":"playing"}`,
+		`{"disc":{"spin":[1,2],"bounce_count":3}}`,
+		`{"unknown":[true,false,null,-1.5e3,"x"]}`,
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			checkSessionMatchesProtojson(t, input)
+		})
+	}
+}
+
+func TestDecoder_RejectsWhatProtojsonRejects(t *testing.T) {
+	inputs := []string{
+		``,
+		`null`,
+		`[]`,
+		`"x"`,
+		`{`,
+		`{"map_name":"x"`,
+		`{"map_name":"x",}`,
+		`{,"map_name":"x"}`,
+		`{"map_name":"x" "game_status":"y"}`,
+		`{"map_name" "x"}`,
+		`{map_name:"x"}`,
+		`{} {}`,
+		`{}x`,
+
+		// Integers
+		`{"blue_points":1.5}`,
+		`{"blue_points":1e-1}`,
+		`{"blue_points":2147483648}`,
+		`{"blue_points":true}`,
+		`{"blue_points":"x"}`,
+		`{"blue_points":""}`,
+		`{"blue_points":" 1"}`,
+		`{"blue_points":01}`,
+		`{"blue_points":+1}`,
+		`{"blue_points":1.}`,
+		`{"blue_points":.5}`,
+		`{"blue_points":1x}`,
+		`{"blue_points":1e400}`,
+		`{"rules_changed_at":-1}`,
+		`{"rules_changed_at":18446744073709551616}`,
+		`{"possession":[1,]}`,
+		`{"possession":[,1]}`,
+		`{"possession":[null]}`,
+		`{"possession":1}`,
+
+		// Floats
+		`{"game_clock":"nan"}`,
+		`{"game_clock":"+Infinity"}`,
+		`{"game_clock":1e400}`,
+		`{"game_clock":NaN}`,
+		`{"game_clock":[1]}`,
+		`{"disc":{"position":[1 2]}}`,
+		`{"disc":{"position":[1,null]}}`,
+
+		// Strings
+		`{"map_name":1}`,
+		`{"map_name":"abc}`,
+		"{\"map_name\":\"a\tb\"}",
+		`{"map_name":"\x"}`,
+		`{"map_name":"\u12"}`,
+		`{"map_name":"\uD83D"}`,
+		`{"map_name":"\uD83Dx"}`,
+		`{"map_name":"\uDE00\uD83D"}`,
+		"{\"map_name\":\"\xff\"}",
+
+		// Literals
+		`{"private_match":1}`,
+		`{"private_match":"true"}`,
+		`{"private_match":tru}`,
+		`{"private_match":truex}`,
+		`{"map_name":nul}`,
+
+		// Messages
+		`{"disc":[]}`,
+		`{"disc":1}`,
+		`{"teams":{}}`,
+		`{"teams":[null]}`,
+		`{"teams":[1]}`,
+		`{"unknown":[1,]}`,
+		`{"unknown":{"a":1,}}`,
+		`{"unknown":}`,
+		`{"unknown":nulls}`,
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			checkSessionMatchesProtojson(t, input)
+		})
+	}
+}
+
+func TestDecoder_RejectsEmptyExponent(t *testing.T) {
+	// protojson accepts an empty exponent for unquoted integers, but not for
+	// doubles or quoted integers; it is not valid JSON, so it is rejected
+	for _, input := range []string{`{"blue_points":1e}`, `{"game_clock":1e}`, `{"blue_points":"1e"}`} {
+		if err := UnmarshalSession([]byte(input), &apigame.SessionResponse{}); err == nil {
+			t.Errorf("expected an error for %s", input)
+		}
+	}
+}
+
+func TestDecoder_SyntaxErrorOffset(t *testing.T) {
+	err := UnmarshalSession([]byte(`{"map_name":"x","blue_points":true}`), &apigame.SessionResponse{})
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected *SyntaxError, got %v", err)
+	}
+	if syntaxErr.Offset != 30 {
+		t.Errorf("expected offset 30, got %d", syntaxErr.Offset)
+	}
+	if !strings.HasPrefix(err.Error(), "apijson: ") {
+		t.Errorf("unexpected error text %q", err)
+	}
+}
+
+func TestDecoder_MaxDepth(t *testing.T) {
+	deep := `{"unknown":` + strings.Repeat("[", maxDepth+2) + strings.Repeat("]", maxDepth+2) + `}`
+	if err := UnmarshalSession([]byte(deep), &apigame.SessionResponse{}); err == nil {
+		t.Fatal("expected an error for deeply nested input")
+	}
+
+	shallow := `{"unknown":` + strings.Repeat("[", 100) + strings.Repeat("]", 100) + `}`
+	if err := UnmarshalSession([]byte(shallow), &apigame.SessionResponse{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestIntegerDigits(t *testing.T) {
+	tests := []struct {
+		num  string
+		want string
+		ok   bool
+	}{
+		{"123", "123", true},
+		{"-123", "-123", true},
+		{"1e3", "1000", true},
+		{"1.5e1", "15", true},
+		{"-1.25e2", "-125", true},
+		{"120e-1", "12", true},
+		{"1.000", "1", true},
+		{"0.0", "0", true},
+		{"-0e10", "0", true},
+		{"0.5", "", false},
+		{"15e-1", "", false},
+		{"1e101", "", false},
+		{"1e-101", "", false},
+	}
+	var d decoder
+	for _, tt := range tests {
+		got, ok := d.integerDigits([]byte(tt.num))
+		if ok != tt.ok || (ok && string(got) != tt.want) {
+			t.Errorf("integerDigits(%s) = %q, %v; want %q, %v", tt.num, got, ok, tt.want, tt.ok)
+		}
+	}
+}
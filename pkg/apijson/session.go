@@ -0,0 +1,600 @@
+package apijson
+
+import (
+	"sync"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+)
+
+// Decoder decodes game API responses, reusing its scratch buffers between
+// calls. A Decoder must not be used concurrently; the package-level
+// functions draw decoders from a pool instead.
+type Decoder struct {
+	d decoder
+}
+
+var decoderPool = sync.Pool{New: func() any { return new(Decoder) }}
+
+// UnmarshalSession decodes a /session response into m, replacing its contents
+func UnmarshalSession(data []byte, m *apigame.SessionResponse) error {
+	dec := decoderPool.Get().(*Decoder)
+	err := dec.UnmarshalSession(data, m)
+	decoderPool.Put(dec)
+	return err
+}
+
+// UnmarshalPlayerBones decodes a /player_bones response into m, replacing its contents
+func UnmarshalPlayerBones(data []byte, m *apigame.PlayerBonesResponse) error {
+	dec := decoderPool.Get().(*Decoder)
+	err := dec.UnmarshalPlayerBones(data, m)
+	decoderPool.Put(dec)
+	return err
+}
+
+// UnmarshalSession decodes a /session response into m, replacing its
+// contents. Nested messages and lists already in m are decoded into rather
+// than allocated again, so m must not share them with anything else.
+func (dec *Decoder) UnmarshalSession(data []byte, m *apigame.SessionResponse) error {
+	d := dec.start(data)
+	if ok, err := d.enterObject(); err != nil {
+		return err
+	} else if !ok {
+		return d.errorf("expected object")
+	}
+	if err := d.sessionFields(m); err != nil {
+		return err
+	}
+	return d.end()
+}
+
+// UnmarshalPlayerBones decodes a /player_bones response into m, replacing
+// its contents. As with UnmarshalSession, m's nested messages and lists are
+// decoded into.
+func (dec *Decoder) UnmarshalPlayerBones(data []byte, m *apigame.PlayerBonesResponse) error {
+	userBones := m.UserBones
+	m.Reset()
+	d := dec.start(data)
+	if ok, err := d.enterObject(); err != nil {
+		return err
+	} else if !ok {
+		return d.errorf("expected object")
+	}
+	err := d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "user_bones":
+			m.UserBones, err = listOf(d, userBones, (*decoder).userBones)
+		case "err_code":
+			m.ErrCode, err = d.int32Value()
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return d.end()
+}
+
+// start points the decoder at new input
+func (dec *Decoder) start(data []byte) *decoder {
+	dec.d.data = data
+	dec.d.pos = 0
+	return &dec.d
+}
+
+// object decodes the fields of an object whose opening brace has been
+// consumed, calling field with each key positioned at its value
+func (d *decoder) object(field func(key []byte) error) error {
+	for first := true; ; first = false {
+		key, more, err := d.nextKey(first)
+		if err != nil || !more {
+			return err
+		}
+		if err := field(key); err != nil {
+			return err
+		}
+	}
+}
+
+// listOf decodes an array of messages with elem, or null as nil, decoding
+// into the messages of prev, including any past its length, in turn.
+// Elements must not be null.
+func listOf[T any](d *decoder, prev []*T, elem func(*decoder, *T) (*T, error)) ([]*T, error) {
+	ok, err := d.enterArray()
+	if !ok || err != nil {
+		return nil, err
+	}
+	list := prev[:0]
+	for first := true; ; first = false {
+		if more, err := d.nextElem(first); err != nil {
+			return nil, err
+		} else if !more {
+			break
+		}
+		if d.skipSpace() != '{' {
+			return nil, d.errorf("expected object")
+		}
+		var old *T
+		if len(list) < cap(list) {
+			old = list[:len(list)+1][len(list)]
+		}
+		m, err := elem(d, old)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list, nil
+}
+
+// reuse resets m to decode into, or allocates a message if m is nil
+func reuse[T any, P interface {
+	*T
+	Reset()
+}](m P) P {
+	if m == nil {
+		return new(T)
+	}
+	m.Reset()
+	return m
+}
+
+// sessionFields decodes the fields of a session into m, replacing its contents
+func (d *decoder) sessionFields(m *apigame.SessionResponse) error {
+	disc, lastThrow, player, pause, lastScore := m.Disc, m.LastThrow, m.Player, m.Pause, m.LastScore
+	teams, possession := m.Teams, m.Possession
+	m.Reset()
+	return d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "orange_team_restart_request":
+			m.OrangeTeamRestartRequest, err = d.int32Value()
+		case "sessionid", "session_id":
+			m.SessionId, err = d.str()
+		case "game_clock_display":
+			m.GameClockDisplay, err = d.str()
+		case "game_status":
+			m.GameStatus, err = d.str()
+		case "sessionip", "session_ip":
+			m.SessionIp, err = d.str()
+		case "match_type":
+			m.MatchType, err = d.str()
+		case "map_name":
+			m.MapName, err = d.str()
+		case "disc":
+			m.Disc, err = d.disc(disc)
+		case "blue_round_score":
+			m.BlueRoundScore, err = d.int32Value()
+		case "orange_points":
+			m.OrangePoints, err = d.int32Value()
+		case "private_match":
+			m.PrivateMatch, err = d.boolean()
+		case "blue_team_restart_request":
+			m.BlueTeamRestartRequest, err = d.int32Value()
+		case "tournament_match":
+			m.TournamentMatch, err = d.boolean()
+		case "orange_round_score":
+			m.OrangeRoundScore, err = d.int32Value()
+		case "total_round_count":
+			m.TotalRoundCount, err = d.int32Value()
+		case "blue_points":
+			m.BluePoints, err = d.int32Value()
+		case "last_throw":
+			m.LastThrow, err = d.lastThrow(lastThrow)
+		case "player":
+			m.Player, err = d.playerRoot(player)
+		case "pause":
+			m.Pause, err = d.pause(pause)
+		case "possession":
+			m.Possession, err = d.int32List(possession)
+		case "left_shoulder_pressed":
+			m.LeftShoulderPressed, err = d.float64Value()
+		case "right_shoulder_pressed":
+			m.RightShoulderPressed, err = d.float64Value()
+		case "left_shoulder_pressed2":
+			m.LeftShoulderPressed2, err = d.float64Value()
+		case "right_shoulder_pressed2":
+			m.RightShoulderPressed2, err = d.float64Value()
+		case "rules_changed_by":
+			m.RulesChangedBy, err = d.str()
+		case "rules_changed_at":
+			m.RulesChangedAt, err = d.uint64Value()
+		case "client_name":
+			m.ClientName, err = d.str()
+		case "last_score":
+			m.LastScore, err = d.lastScore(lastScore)
+		case "teams":
+			m.Teams, err = listOf(d, teams, (*decoder).team)
+		case "payload_multiplier":
+			m.PayloadMultiplier, err = d.float64Value()
+		case "payload_checkpoint":
+			m.PayloadCheckpoint, err = d.int32Value()
+		case "payload_distance":
+			m.PayloadDistance, err = d.float64Value()
+		case "payload_defenders":
+			m.PayloadDefenders, err = d.int32Value()
+		case "payload_speed":
+			m.PayloadSpeed, err = d.float64Value()
+		case "game_clock":
+			m.GameClock, err = d.float64Value()
+		case "err_code":
+			m.ErrCode, err = d.int32Value()
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) disc(m *apigame.Disc) (*apigame.Disc, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	position, forward, left, up, velocity := m.GetPosition(), m.GetForward(), m.GetLeft(), m.GetUp(), m.GetVelocity()
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "position":
+			m.Position, err = d.float64List(position)
+		case "forward":
+			m.Forward, err = d.float64List(forward)
+		case "left":
+			m.Left, err = d.float64List(left)
+		case "up":
+			m.Up, err = d.float64List(up)
+		case "velocity":
+			m.Velocity, err = d.float64List(velocity)
+		case "bounce_count":
+			m.BounceCount, err = d.int32Value()
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) lastThrow(m *apigame.LastThrowInfo) (*apigame.LastThrowInfo, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "arm_speed":
+			m.ArmSpeed, err = d.float64Value()
+		case "total_speed":
+			m.TotalSpeed, err = d.float64Value()
+		case "off_axis_spin_deg":
+			m.OffAxisSpinDeg, err = d.float64Value()
+		case "wrist_throw_penalty":
+			m.WristThrowPenalty, err = d.float64Value()
+		case "rot_per_sec":
+			m.RotPerSec, err = d.float64Value()
+		case "pot_speed_from_rot":
+			m.PotSpeedFromRot, err = d.float64Value()
+		case "speed_from_arm":
+			m.SpeedFromArm, err = d.float64Value()
+		case "speed_from_movement":
+			m.SpeedFromMovement, err = d.float64Value()
+		case "speed_from_wrist":
+			m.SpeedFromWrist, err = d.float64Value()
+		case "wrist_align_to_throw_deg":
+			m.WristAlignToThrowDeg, err = d.float64Value()
+		case "throw_align_to_movement_deg":
+			m.ThrowAlignToMovementDeg, err = d.float64Value()
+		case "off_axis_penalty":
+			m.OffAxisPenalty, err = d.float64Value()
+		case "throw_move_penalty":
+			m.ThrowMovePenalty, err = d.float64Value()
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) playerRoot(m *apigame.PlayerRoot) (*apigame.PlayerRoot, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	vrLeft, vrPosition, vrForward, vrUp := m.GetVrLeft(), m.GetVrPosition(), m.GetVrForward(), m.GetVrUp()
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "vr_left":
+			m.VrLeft, err = d.float64List(vrLeft)
+		case "vr_position":
+			m.VrPosition, err = d.float64List(vrPosition)
+		case "vr_forward":
+			m.VrForward, err = d.float64List(vrForward)
+		case "vr_up":
+			m.VrUp, err = d.float64List(vrUp)
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) pause(m *apigame.PauseState) (*apigame.PauseState, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "paused_state":
+			m.PausedState, err = d.str()
+		case "unpaused_team":
+			m.UnpausedTeam, err = d.str()
+		case "paused_requested_team":
+			m.PausedRequestedTeam, err = d.str()
+		case "unpaused_timer":
+			m.UnpausedTimer, err = d.float64Value()
+		case "paused_timer":
+			m.PausedTimer, err = d.float64Value()
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) lastScore(m *apigame.LastScore) (*apigame.LastScore, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "disc_speed":
+			m.DiscSpeed, err = d.float64Value()
+		case "team":
+			m.Team, err = d.str()
+		case "goal_type":
+			m.GoalType, err = d.str()
+		case "point_amount":
+			m.PointAmount, err = d.int32Value()
+		case "distance_thrown":
+			m.DistanceThrown, err = d.float64Value()
+		case "person_scored":
+			m.PersonScored, err = d.str()
+		case "assist_scored":
+			m.AssistScored, err = d.str()
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) team(m *apigame.Team) (*apigame.Team, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	players, stats := m.GetPlayers(), m.GetStats()
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "players":
+			m.Players, err = listOf(d, players, (*decoder).teamMember)
+		case "team", "team_name":
+			m.TeamName, err = d.str()
+		case "possession", "has_possession":
+			m.HasPossession, err = d.boolean()
+		case "stats":
+			m.Stats, err = d.teamStats(stats)
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) teamStats(m *apigame.TeamStats) (*apigame.TeamStats, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "possession_time":
+			m.PossessionTime, err = d.float64Value()
+		case "points":
+			m.Points, err = d.int32Value()
+		case "saves":
+			m.Saves, err = d.int32Value()
+		case "goals":
+			m.Goals, err = d.int32Value()
+		case "stuns":
+			m.Stuns, err = d.int32Value()
+		case "passes":
+			m.Passes, err = d.int32Value()
+		case "catches":
+			m.Catches, err = d.int32Value()
+		case "steals":
+			m.Steals, err = d.int32Value()
+		case "blocks":
+			m.Blocks, err = d.int32Value()
+		case "interceptions":
+			m.Interceptions, err = d.int32Value()
+		case "assists":
+			m.Assists, err = d.int32Value()
+		case "shots_taken":
+			m.ShotsTaken, err = d.int32Value()
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) teamMember(m *apigame.TeamMember) (*apigame.TeamMember, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	head, body, leftHand, rightHand := m.GetHead(), m.GetBody(), m.GetLeftHand(), m.GetRightHand()
+	velocity, stats := m.GetVelocity(), m.GetStats()
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "Weapon", "weapon":
+			m.Weapon, err = d.str()
+		case "Ordnance", "ordnance":
+			m.Ordnance, err = d.str()
+		case "TacMod", "tac_mod":
+			m.TacMod, err = d.str()
+		case "head":
+			m.Head, err = d.bodyPart(head)
+		case "body":
+			m.Body, err = d.bodyPart(body)
+		case "userid", "account_number":
+			m.AccountNumber, err = d.uint64Value()
+		case "name", "display_name":
+			m.DisplayName, err = d.str()
+		case "playerid", "slot_number":
+			m.SlotNumber, err = d.int32Value()
+		case "number", "jersey_number":
+			m.JerseyNumber, err = d.int32Value()
+		case "level":
+			m.Level, err = d.int32Value()
+		case "ping":
+			m.Ping, err = d.int32Value()
+		case "packetlossratio", "packet_loss_ratio":
+			m.PacketLossRatio, err = d.float64Value()
+		case "stunned", "is_stunned":
+			m.IsStunned, err = d.boolean()
+		case "invulnerable", "is_invulnerable":
+			m.IsInvulnerable, err = d.boolean()
+		case "holding_left", "left_holding_onto":
+			m.LeftHoldingOnto, err = d.str()
+		case "holding_right", "right_holding_onto":
+			m.RightHoldingOnto, err = d.str()
+		case "blocking", "is_blocking":
+			m.IsBlocking, err = d.boolean()
+		case "is_emote_playing":
+			m.IsEmotePlaying, err = d.boolean()
+		case "possession", "has_possession":
+			m.HasPossession, err = d.boolean()
+		case "lhand", "left_hand":
+			m.LeftHand, err = d.handPart(leftHand)
+		case "rhand", "right_hand":
+			m.RightHand, err = d.handPart(rightHand)
+		case "velocity":
+			m.Velocity, err = d.float64List(velocity)
+		case "stats":
+			m.Stats, err = d.playerStats(stats)
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) playerStats(m *apigame.PlayerStats) (*apigame.PlayerStats, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "possession_time":
+			m.PossessionTime, err = d.float64Value()
+		case "points":
+			m.Points, err = d.int32Value()
+		case "saves":
+			m.Saves, err = d.int32Value()
+		case "goals":
+			m.Goals, err = d.int32Value()
+		case "stuns":
+			m.Stuns, err = d.int32Value()
+		case "passes":
+			m.Passes, err = d.int32Value()
+		case "catches":
+			m.Catches, err = d.int32Value()
+		case "steals":
+			m.Steals, err = d.int32Value()
+		case "blocks":
+			m.Blocks, err = d.int32Value()
+		case "interceptions":
+			m.Interceptions, err = d.int32Value()
+		case "assists":
+			m.Assists, err = d.int32Value()
+		case "shots_taken":
+			m.ShotsTaken, err = d.int32Value()
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) bodyPart(m *apigame.BodyPart) (*apigame.BodyPart, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	position, forward, left, up := m.GetPosition(), m.GetForward(), m.GetLeft(), m.GetUp()
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "position":
+			m.Position, err = d.float64List(position)
+		case "forward":
+			m.Forward, err = d.float64List(forward)
+		case "left":
+			m.Left, err = d.float64List(left)
+		case "up":
+			m.Up, err = d.float64List(up)
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) handPart(m *apigame.HandPart) (*apigame.HandPart, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	pos, forward, left, up := m.GetPos(), m.GetForward(), m.GetLeft(), m.GetUp()
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "pos":
+			m.Pos, err = d.float64List(pos)
+		case "forward":
+			m.Forward, err = d.float64List(forward)
+		case "left":
+			m.Left, err = d.float64List(left)
+		case "up":
+			m.Up, err = d.float64List(up)
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
+
+func (d *decoder) userBones(m *apigame.UserBones) (*apigame.UserBones, error) {
+	if ok, err := d.enterObject(); !ok || err != nil {
+		return nil, err
+	}
+	boneT, boneO := m.GetBoneT(), m.GetBoneO()
+	m = reuse(m)
+	return m, d.object(func(key []byte) (err error) {
+		switch string(key) {
+		case "bone_t":
+			m.BoneT, err = d.float32List(boneT)
+		case "playerid", "player_index":
+			m.PlayerIndex, err = d.int32Value()
+		case "bone_o":
+			m.BoneO, err = d.float32List(boneO)
+		default:
+			err = d.skipValue(0)
+		}
+		return err
+	})
+}
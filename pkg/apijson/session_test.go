@@ -0,0 +1,512 @@
+package apijson
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// gameSessionJSON is a /session response in the game's own format, with
+// numeric uint64 fields and fields protojson does not know
+const gameSessionJSON = `{"disc":{"position":[0.0,1.5,-2.25],"forward":[0.0,0.0,1.0],"left":[1.0,0.0,0.0],"up":[0.0,1.0,0.0],"velocity":[3.5,0.0,-1.125],"bounce_count":2},
+"orange_team_restart_request":0,"sessionid":"A1B2C3D4-E5F6-4A5B-8C7D-9E0F1A2B3C4D","game_clock_display":"04:12.34","game_status":"playing",
+"sessionip":"10.0.0.1","match_type":"Echo_Arena","map_name":"mpl_arena_a","right_shoulder_pressed2":0.0,"teams":[
+{"players":[{"name":"blue_one","rhand":{"pos":[0.1,1.2,3.4],"forward":[0,0,1],"left":[1,0,0],"up":[0,1,0]},"playerid":0,"stats":{"possession_time":12.5,"points":2,"saves":1,"goals":0,"stuns":3,"passes":0,"catches":1,"steals":0,"blocks":0,"interceptions":0,"assists":1,"shots_taken":4},
+"userid":4355631244523776,"number":19,"level":50,"stunned":false,"ping":32,"packetlossratio":0.001,"invulnerable":false,"holding_left":"none","possession":true,
+"head":{"position":[0.5,1.7,2.0],"forward":[0,0,1],"left":[1,0,0],"up":[0,1,0]},"body":{"position":[0.5,1.2,2.0],"forward":[0,0,1],"left":[1,0,0],"up":[0,1,0]},
+"holding_right":"disc","lhand":{"pos":[0.3,1.1,2.1],"forward":[0,0,1],"left":[1,0,0],"up":[0,1,0]},"blocking":false,"velocity":[1.0,0.0,-0.5],"is_emote_playing":false}],
+"team":"BLUE TEAM","possession":true,"stats":{"points":2,"possession_time":20.25,"interceptions":0,"blocks":0,"steals":0,"catches":1,"passes":0,"saves":1,"goals":0,"stuns":3,"assists":1,"shots_taken":4}},
+{"players":[{"name":"orange_one","playerid":5,"userid":3963667097037078,"number":0,"level":12,"ping":64,"packetlossratio":0.0,"holding_left":"none","holding_right":"none","velocity":[0,0,0],
+"head":{"position":[0,1.7,-2],"forward":[0,0,-1],"left":[-1,0,0],"up":[0,1,0]},"body":{"position":[0,1.2,-2],"forward":[0,0,-1],"left":[-1,0,0],"up":[0,1,0]},
+"lhand":{"pos":[0,1,-2],"forward":[0,0,-1],"left":[-1,0,0],"up":[0,1,0]},"rhand":{"pos":[0,1,-2],"forward":[0,0,-1],"left":[-1,0,0],"up":[0,1,0]},"stats":{"possession_time":0.0,"points":0}}],
+"team":"ORANGE TEAM","possession":false,"stats":{"points":0}},
+{"team":"SPECTATORS","possession":false}],
+"blue_round_score":1,"orange_points":0,"player":{"vr_left":[1,0,0],"vr_position":[0,1.6,0],"vr_forward":[0,0,1],"vr_up":[0,1,0]},"private_match":true,"blue_team_restart_request":0,
+"tournament_match":false,"orange_round_score":0,"total_round_count":3,"left_shoulder_pressed2":0.0,"left_shoulder_pressed":0.0,
+"pause":{"paused_state":"unpaused","unpaused_team":"none","paused_requested_team":"none","unpaused_timer":0.0,"paused_timer":0.0},"right_shoulder_pressed":0.0,"blue_points":2,
+"last_throw":{"arm_speed":5.5,"total_speed":12.25,"off_axis_spin_deg":3.0,"wrist_throw_penalty":0.1,"rot_per_sec":2.0,"pot_speed_from_rot":1.5,"speed_from_arm":4.0,"speed_from_movement":6.0,"speed_from_wrist":0.75,"wrist_align_to_throw_deg":10.0,"throw_align_to_movement_deg":20.0,"off_axis_penalty":0.2,"throw_move_penalty":0.3},
+"client_name":"blue_one","game_clock":252.34,"possession":[0,0],"last_score":{"disc_speed":18.5,"team":"blue","goal_type":"INSIDE SHOT","point_amount":2,"distance_thrown":9.75,"person_scored":"blue_one","assist_scored":"[INVALID]"},
+"rules_changed_by":"blue_one","rules_changed_at":1737330654,"err_code":0,"payload_multiplier":1.0,"payload_checkpoint":0,"payload_distance":0.0,"payload_defenders":0,"payload_speed":0.0,"future_field":{"a":[1,2]}}`
+
+// gameBonesJSON is a /player_bones response in the game's own format
+const gameBonesJSON = `{"user_bones":[{"bone_t":[0.1,0.2,0.3,-1.5,2.25,1e-3],"bone_o":[0,0,0,1,0.70710677,0,0.70710677,0],"playerid":0},{"bone_t":[1,2,3],"bone_o":[0,0,0,1],"playerid":5}],"err_code":0}`
+
+// fullSession returns a SessionResponse with every field populated
+func fullSession() *apigame.SessionResponse {
+	v3 := func(x, y, z float64) []float64 { return []float64{x, y, z} }
+	stats := &apigame.PlayerStats{PossessionTime: 1.5, Points: 2, Saves: 3, Goals: 4, Stuns: 5, Passes: 6, Catches: 7, Steals: 8, Blocks: 9, Interceptions: 10, Assists: 11, ShotsTaken: 12}
+	return &apigame.SessionResponse{
+		OrangeTeamRestartRequest: 1,
+		SessionId:                "session-id",
+		GameClockDisplay:         "01:02.03",
+		GameStatus:               "playing",
+		SessionIp:                "10.0.0.1",
+		MatchType:                "Echo_Arena",
+		MapName:                  "mpl_arena_a",
+		Disc:                     &apigame.Disc{Position: v3(1, 2, 3), Forward: v3(0, 0, 1), Left: v3(1, 0, 0), Up: v3(0, 1, 0), Velocity: v3(-1, 0.5, 2), BounceCount: 3},
+		BlueRoundScore:           2,
+		OrangePoints:             7,
+		PrivateMatch:             true,
+		BlueTeamRestartRequest:   1,
+		TournamentMatch:          true,
+		OrangeRoundScore:         1,
+		TotalRoundCount:          3,
+		BluePoints:               9,
+		LastThrow: &apigame.LastThrowInfo{ArmSpeed: 1, TotalSpeed: 2, OffAxisSpinDeg: 3, WristThrowPenalty: 4, RotPerSec: 5, PotSpeedFromRot: 6, SpeedFromArm: 7,
+			SpeedFromMovement: 8, SpeedFromWrist: 9, WristAlignToThrowDeg: 10, ThrowAlignToMovementDeg: 11, OffAxisPenalty: 12, ThrowMovePenalty: 13},
+		Player:                &apigame.PlayerRoot{VrLeft: v3(1, 0, 0), VrPosition: v3(0, 1.6, 0), VrForward: v3(0, 0, 1), VrUp: v3(0, 1, 0)},
+		Pause:                 &apigame.PauseState{PausedState: "paused", UnpausedTeam: "blue", PausedRequestedTeam: "orange", UnpausedTimer: 1.25, PausedTimer: 2.5},
+		Possession:            []int32{1, 0},
+		LeftShoulderPressed:   0.1,
+		RightShoulderPressed:  0.2,
+		LeftShoulderPressed2:  0.3,
+		RightShoulderPressed2: 0.4,
+		RulesChangedBy:        "player \"one\"\n",
+		RulesChangedAt:        18446744073709551615,
+		ClientName:            "client ✓",
+		LastScore:             &apigame.LastScore{DiscSpeed: 20.5, Team: "orange", GoalType: "SLAM DUNK", PointAmount: 3, DistanceThrown: 1.5, PersonScored: "a", AssistScored: "b"},
+		Teams: []*apigame.Team{
+			{
+				TeamName:      "BLUE TEAM",
+				HasPossession: true,
+				Stats:         &apigame.TeamStats{PossessionTime: 1.5, Points: 2, Saves: 3, Goals: 4, Stuns: 5, Passes: 6, Catches: 7, Steals: 8, Blocks: 9, Interceptions: 10, Assists: 11, ShotsTaken: 12},
+				Players: []*apigame.TeamMember{{
+					Weapon:           "assault",
+					Ordnance:         "detonator",
+					TacMod:           "heal",
+					Head:             &apigame.BodyPart{Position: v3(0, 1.7, 0), Forward: v3(0, 0, 1), Left: v3(1, 0, 0), Up: v3(0, 1, 0)},
+					Body:             &apigame.BodyPart{Position: v3(0, 1.2, 0), Forward: v3(0, 0, 1), Left: v3(1, 0, 0), Up: v3(0, 1, 0)},
+					AccountNumber:    4355631244523776,
+					DisplayName:      "blue_one",
+					SlotNumber:       1,
+					JerseyNumber:     19,
+					Level:            50,
+					Ping:             40,
+					PacketLossRatio:  0.01,
+					IsStunned:        true,
+					IsInvulnerable:   true,
+					LeftHoldingOnto:  "none",
+					RightHoldingOnto: "disc",
+					IsBlocking:       true,
+					IsEmotePlaying:   true,
+					HasPossession:    true,
+					LeftHand:         &apigame.HandPart{Pos: v3(0.1, 1, 0), Forward: v3(0, 0, 1), Left: v3(1, 0, 0), Up: v3(0, 1, 0)},
+					RightHand:        &apigame.HandPart{Pos: v3(-0.1, 1, 0), Forward: v3(0, 0, 1), Left: v3(1, 0, 0), Up: v3(0, 1, 0)},
+					Velocity:         v3(1e-7, -3.5e10, 0),
+					Stats:            stats,
+				}},
+			},
+			{TeamName: "ORANGE TEAM"},
+		},
+		PayloadMultiplier: 1.5,
+		PayloadCheckpoint: 2,
+		PayloadDistance:   3.5,
+		PayloadDefenders:  4,
+		PayloadSpeed:      5.5,
+		GameClock:         62.03,
+		ErrCode:           -6,
+	}
+}
+
+// fullPlayerBones returns a PlayerBonesResponse with every field populated
+func fullPlayerBones() *apigame.PlayerBonesResponse {
+	return &apigame.PlayerBonesResponse{
+		UserBones: []*apigame.UserBones{
+			{BoneT: []float32{0.1, -0.2, 3.4028235e38}, BoneO: []float32{0, 0, 0, 1}, PlayerIndex: 0},
+			{BoneT: []float32{1e-45}, BoneO: []float32{0.70710677, 0, 0.70710677, 0}, PlayerIndex: 5},
+		},
+		ErrCode: 1,
+	}
+}
+
+// numericUint64 rewrites protojson's quoted uint64 values as numbers, as the
+// game and the EchoReplay writer produce them
+var numericUint64 = regexp.MustCompile(`"(userid|account_number|rules_changed_at)":\s*"(\d+)"`)
+
+func TestUnmarshalSession_MatchesProtojson(t *testing.T) {
+	inputs := map[string][]byte{
+		"game format": []byte(gameSessionJSON),
+	}
+	for _, protoNames := range []bool{false, true} {
+		for _, emitUnpopulated := range []bool{false, true} {
+			data, err := protojson.MarshalOptions{UseProtoNames: protoNames, EmitUnpopulated: emitUnpopulated, Multiline: emitUnpopulated}.Marshal(fullSession())
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := "protojson"
+			if protoNames {
+				name += " proto names"
+			}
+			if emitUnpopulated {
+				name += " unpopulated"
+			}
+			inputs[name] = data
+			inputs[name+" numeric uint64"] = numericUint64.ReplaceAll(data, []byte(`"$1":$2`))
+		}
+	}
+
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			want, err := protojsonSession(data)
+			if err != nil {
+				t.Fatalf("protojson: %v", err)
+			}
+			got := &apigame.SessionResponse{}
+			if err := UnmarshalSession(data, got); err != nil {
+				t.Fatalf("UnmarshalSession: %v", err)
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("decoded differently\napijson:   %v\nprotojson: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalSession_RoundTrip(t *testing.T) {
+	data, err := protojson.Marshal(fullSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &apigame.SessionResponse{}
+	if err := UnmarshalSession(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, fullSession()) {
+		t.Errorf("round trip mismatch\ngot:  %v\nwant: %v", got, fullSession())
+	}
+}
+
+// populate sets every field of m, recursively, to a distinct non-zero value,
+// so a field the decoder does not know is left unset and shows up in
+// missingFields. Lists get two elements and maps one entry.
+func populate(m protoreflect.Message, n *int) {
+	fields := m.Descriptor().Fields()
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		switch {
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			for range 2 {
+				if fd.Kind() == protoreflect.MessageKind {
+					elem := list.NewElement()
+					populate(elem.Message(), n)
+					list.Append(elem)
+				} else {
+					list.Append(scalarValue(fd, n))
+				}
+			}
+		case fd.IsMap():
+			key := scalarValue(fd.MapKey(), n).MapKey()
+			if fd.MapValue().Kind() == protoreflect.MessageKind {
+				value := m.Mutable(fd).Map().NewValue()
+				populate(value.Message(), n)
+				m.Mutable(fd).Map().Set(key, value)
+			} else {
+				m.Mutable(fd).Map().Set(key, scalarValue(fd.MapValue(), n))
+			}
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			populate(m.Mutable(fd).Message(), n)
+		default:
+			m.Set(fd, scalarValue(fd, n))
+		}
+	}
+}
+
+// scalarValue returns the next distinct non-zero value for a scalar field
+func scalarValue(fd protoreflect.FieldDescriptor, n *int) protoreflect.Value {
+	*n++
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(*n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(int64(*n))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(*n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// Beyond float64 precision, as account numbers can be
+		return protoreflect.ValueOfUint64(1<<60 + uint64(*n))
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(*n) + 0.5)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(float64(*n) + 0.25)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(fmt.Sprintf("value %d", *n))
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte{byte(*n)})
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(values.Len() - 1).Number())
+	}
+	panic(fmt.Sprintf("unhandled kind %v of %s", fd.Kind(), fd.FullName()))
+}
+
+// missingFields returns the fields set in want, recursively, that are not
+// set in got
+func missingFields(want, got protoreflect.Message) []protoreflect.FullName {
+	var missing []protoreflect.FullName
+	want.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if !got.Has(fd) {
+			missing = append(missing, fd.FullName())
+			return true
+		}
+		switch {
+		case fd.IsList() && fd.Kind() == protoreflect.MessageKind:
+			wantList, gotList := v.List(), got.Get(fd).List()
+			for i := range min(wantList.Len(), gotList.Len()) {
+				missing = append(missing, missingFields(wantList.Get(i).Message(), gotList.Get(i).Message())...)
+			}
+		case fd.IsMap():
+		case fd.Kind() == protoreflect.MessageKind:
+			missing = append(missing, missingFields(v.Message(), got.Get(fd).Message())...)
+		}
+		return true
+	})
+	return missing
+}
+
+// TestUnmarshal_DecodesEveryField walks the message descriptors, so a field
+// added to the apigame protos fails here until the decoder handles it
+func TestUnmarshal_DecodesEveryField(t *testing.T) {
+	tests := []struct {
+		name      string
+		want      proto.Message
+		unmarshal func([]byte, proto.Message) error
+	}{
+		{
+			name: "session",
+			want: &apigame.SessionResponse{},
+			unmarshal: func(data []byte, m proto.Message) error {
+				return UnmarshalSession(data, m.(*apigame.SessionResponse))
+			},
+		},
+		{
+			name: "player bones",
+			want: &apigame.PlayerBonesResponse{},
+			unmarshal: func(data []byte, m proto.Message) error {
+				return UnmarshalPlayerBones(data, m.(*apigame.PlayerBonesResponse))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var n int
+		populate(tt.want.ProtoReflect(), &n)
+		for _, protoNames := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s proto names %v", tt.name, protoNames), func(t *testing.T) {
+				data, err := protojson.MarshalOptions{UseProtoNames: protoNames}.Marshal(tt.want)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := tt.want.ProtoReflect().New().Interface()
+				if err := tt.unmarshal(data, got); err != nil {
+					t.Fatal(err)
+				}
+				for _, name := range missingFields(tt.want.ProtoReflect(), got.ProtoReflect()) {
+					t.Errorf("%s was not decoded", name)
+				}
+				if !proto.Equal(got, tt.want) {
+					t.Errorf("decoded differently\ngot:  %v\nwant: %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestUnmarshalSession_GameFormat(t *testing.T) {
+	got := &apigame.SessionResponse{}
+	if err := UnmarshalSession([]byte(gameSessionJSON), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RulesChangedAt != 1737330654 {
+		t.Errorf("rules_changed_at = %d", got.RulesChangedAt)
+	}
+	player := got.GetTeams()[0].GetPlayers()[0]
+	if player.GetAccountNumber() != 4355631244523776 || player.GetDisplayName() != "blue_one" || player.GetJerseyNumber() != 19 {
+		t.Errorf("unexpected player %v", player)
+	}
+	if got.GetTeams()[2].GetTeamName() != "SPECTATORS" || len(got.GetTeams()[2].GetPlayers()) != 0 {
+		t.Errorf("unexpected spectator team %v", got.GetTeams()[2])
+	}
+}
+
+func TestUnmarshalSession_ReplacesContents(t *testing.T) {
+	m := fullSession()
+	if err := UnmarshalSession([]byte(`{"game_status":"pre_match"}`), m); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(m, &apigame.SessionResponse{GameStatus: "pre_match"}) {
+		t.Errorf("expected only game_status to be set, got %v", m)
+	}
+}
+
+func TestUnmarshalSession_ReusesMessages(t *testing.T) {
+	data := []byte(gameSessionJSON)
+	want, err := protojsonSession(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoding over a message with a different shape still replaces it
+	m := fullSession()
+	if err := UnmarshalSession(data, m); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(m, want) {
+		t.Fatalf("decoded differently\napijson:   %v\nprotojson: %v", m, want)
+	}
+
+	player, head := m.GetTeams()[0].GetPlayers()[0], m.GetTeams()[0].GetPlayers()[0].GetHead()
+	var dec Decoder
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := dec.UnmarshalSession(data, m); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected decoding into a used message not to allocate, got %v allocs", allocs)
+	}
+	if m.GetTeams()[0].GetPlayers()[0] != player || m.GetTeams()[0].GetPlayers()[0].GetHead() != head {
+		t.Error("expected nested messages to be reused")
+	}
+	if !proto.Equal(m, want) {
+		t.Errorf("decoded differently after reuse\napijson:   %v\nprotojson: %v", m, want)
+	}
+}
+
+func TestUnmarshalPlayerBones_MatchesProtojson(t *testing.T) {
+	inputs := map[string][]byte{
+		"game format": []byte(gameBonesJSON),
+		"empty":       []byte(`{}`),
+		"null bones":  []byte(`{"user_bones":null,"err_code":-2}`),
+		"proto names": []byte(`{"user_bones":[{"bone_t":["0.5","NaN"],"player_index":3}]}`),
+	}
+	for _, protoNames := range []bool{false, true} {
+		data, err := protojson.MarshalOptions{UseProtoNames: protoNames}.Marshal(fullPlayerBones())
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[string(data)] = data
+	}
+
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			want := &apigame.PlayerBonesResponse{}
+			if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, want); err != nil {
+				t.Fatalf("protojson: %v", err)
+			}
+			got := &apigame.PlayerBonesResponse{}
+			if err := UnmarshalPlayerBones(data, got); err != nil {
+				t.Fatalf("UnmarshalPlayerBones: %v", err)
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("decoded differently\napijson:   %v\nprotojson: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalPlayerBones_Errors(t *testing.T) {
+	inputs := []string{
+		`null`,
+		`{"user_bones":[null]}`,
+		`{"user_bones":[{"bone_t":[1e39]}]}`,
+		`{"user_bones":[{"bone_t":[1,]}]}`,
+		`{"user_bones":[{"playerid":1.5}]}`,
+		`{"err_code":0}]`,
+	}
+	for _, input := range inputs {
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal([]byte(input), &apigame.PlayerBonesResponse{}); err == nil {
+			t.Fatalf("protojson accepted %s", input)
+		}
+		if err := UnmarshalPlayerBones([]byte(input), &apigame.PlayerBonesResponse{}); err == nil {
+			t.Errorf("expected an error for %s", input)
+		}
+	}
+}
+
+func TestDecoder_Reuse(t *testing.T) {
+	var dec Decoder
+	for i := 0; i < 3; i++ {
+		got := &apigame.SessionResponse{}
+		if err := dec.UnmarshalSession([]byte(gameSessionJSON), got); err != nil {
+			t.Fatal(err)
+		}
+		want, _ := protojsonSession([]byte(gameSessionJSON))
+		if !proto.Equal(got, want) {
+			t.Fatalf("decode %d differs from protojson", i)
+		}
+
+		// A failed decode must not affect the next one
+		if err := dec.UnmarshalSession([]byte(`{"map_name":"é`), got); err == nil {
+			t.Fatal("expected an error for a truncated input")
+		}
+		bones := &apigame.PlayerBonesResponse{}
+		if err := dec.UnmarshalPlayerBones([]byte(gameBonesJSON), bones); err != nil {
+			t.Fatal(err)
+		}
+		if len(bones.GetUserBones()) != 2 || len(bones.GetUserBones()[0].GetBoneO()) != 8 {
+			t.Fatalf("unexpected bones %v", bones)
+		}
+	}
+}
+
+func BenchmarkUnmarshalSession(b *testing.B) {
+	data := []byte(gameSessionJSON)
+
+	b.Run("protojson", func(b *testing.B) {
+		opts := protojson.UnmarshalOptions{DiscardUnknown: true}
+		m := &apigame.SessionResponse{}
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := opts.Unmarshal(data, m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("apijson", func(b *testing.B) {
+		m := &apigame.SessionResponse{}
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := UnmarshalSession(data, m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUnmarshalPlayerBones(b *testing.B) {
+	data := []byte(gameBonesJSON)
+
+	b.Run("protojson", func(b *testing.B) {
+		opts := protojson.UnmarshalOptions{DiscardUnknown: true}
+		m := &apigame.PlayerBonesResponse{}
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := opts.Unmarshal(data, m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("apijson", func(b *testing.B) {
+		m := &apigame.PlayerBonesResponse{}
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := UnmarshalPlayerBones(data, m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
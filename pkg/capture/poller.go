@@ -0,0 +1,330 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/processing"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Default Poller settings
+const (
+	DefaultBaseURL        = "http://127.0.0.1:6721"
+	DefaultPollRate       = 60
+	DefaultIdleInterval   = time.Second
+	DefaultRequestTimeout = 500 * time.Millisecond
+)
+
+// Paths of the game's local HTTP API
+const (
+	SessionPath     = "/session"
+	PlayerBonesPath = "/player_bones"
+)
+
+// PollerState describes what the game API reported on the last poll
+type PollerState int
+
+const (
+	// StateDisconnected means the API could not be reached, usually because the game is not running
+	StateDisconnected PollerState = iota
+	// StateNotInMatch means the game is running but the player is not in a match
+	StateNotInMatch
+	// StateInMatch means the game is returning session data
+	StateInMatch
+)
+
+// String returns the state name
+func (s PollerState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateNotInMatch:
+		return "not_in_match"
+	case StateInMatch:
+		return "in_match"
+	default:
+		return fmt.Sprintf("PollerState(%d)", int(s))
+	}
+}
+
+// FrameWriter receives every frame the Poller captures. Both codecs.NevrCap
+// and codecs.EchoReplay implement it.
+type FrameWriter interface {
+	WriteFrame(frame *telemetry.LobbySessionStateFrame) error
+}
+
+// PollerStats are running totals kept by a Poller
+type PollerStats struct {
+	// Frames is the number of frames captured
+	Frames uint64
+	// Errors is the number of polls that failed with an unexpected response or undecodable data
+	Errors uint64
+	// Missed is the number of poll slots skipped because a poll overran its interval
+	Missed uint64
+}
+
+// PollerOption configures a Poller
+type PollerOption func(*Poller)
+
+// WithPollRate sets the target number of polls per second while in a match
+func WithPollRate(hz int) PollerOption {
+	return func(p *Poller) {
+		if hz > 0 {
+			p.interval = time.Second / time.Duration(hz)
+		}
+	}
+}
+
+// WithIdleInterval sets how often to poll while the game is unreachable or not in a match
+func WithIdleInterval(d time.Duration) PollerOption {
+	return func(p *Poller) {
+		p.idleInterval = d
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to poll the API
+func WithHTTPClient(client *http.Client) PollerOption {
+	return func(p *Poller) {
+		p.client = client
+	}
+}
+
+// WithPlayerBones sets whether to poll /player_bones alongside /session
+func WithPlayerBones(enabled bool) PollerOption {
+	return func(p *Poller) {
+		p.bones = enabled
+	}
+}
+
+// WithFrameWriter writes every captured frame to w
+func WithFrameWriter(w FrameWriter) PollerOption {
+	return func(p *Poller) {
+		p.writer = w
+	}
+}
+
+// WithFrameHandler calls fn with every captured frame, after it has been written.
+// If the processor pools frames, the frame is only valid during the call.
+func WithFrameHandler(fn func(*telemetry.LobbySessionStateFrame)) PollerOption {
+	return func(p *Poller) {
+		p.onFrame = fn
+	}
+}
+
+// WithStateHandler calls fn whenever the poller state changes
+func WithStateHandler(fn func(from, to PollerState)) PollerOption {
+	return func(p *Poller) {
+		p.onState = fn
+	}
+}
+
+// Poller polls the game's local HTTP API and feeds each session response,
+// with the matching player bones, to a processing.Processor and an optional
+// FrameWriter. Frames are released after the writer and frame handler have
+// seen them, so a processor created with processing.NewWithFramePool can be
+// used; writers must not keep the frame. Frames dropped by the processor's
+// middleware are not written. Frames are written through the processor's
+// Write, so its UseBeforeWrite middleware runs first; frames it drops are
+// neither counted nor given to the frame handler.
+//
+// Polls are scheduled against a fixed timeline rather than slept between,
+// so request latency does not lower the rate. A poll that overruns its
+// slot skips the slots it missed instead of bursting to catch up. While
+// the game is unreachable or not in a match the poller backs off to the
+// idle interval.
+type Poller struct {
+	baseURL   string
+	processor *processing.Processor
+
+	client       *http.Client
+	interval     time.Duration
+	idleInterval time.Duration
+	bones        bool
+	writer       FrameWriter
+	onFrame      func(*telemetry.LobbySessionStateFrame)
+	onState      func(from, to PollerState)
+
+	// Response buffers reused between polls
+	sessionBuf bytes.Buffer
+	bonesBuf   bytes.Buffer
+
+	mu    sync.Mutex
+	state PollerState
+	stats PollerStats
+}
+
+// NewPoller creates a Poller for the API at baseURL, such as DefaultBaseURL,
+// that feeds processor. The poller does not start until Run is called.
+func NewPoller(baseURL string, processor *processing.Processor, opts ...PollerOption) *Poller {
+	p := &Poller{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		processor:    processor,
+		client:       &http.Client{Timeout: DefaultRequestTimeout},
+		interval:     time.Second / DefaultPollRate,
+		idleInterval: DefaultIdleInterval,
+		bones:        true,
+		state:        StateDisconnected,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// State returns the state reported by the last poll
+func (p *Poller) State() PollerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Stats returns the poller's running totals
+func (p *Poller) Stats() PollerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Run polls until ctx is cancelled or the frame writer fails. It returns nil
+// when ctx is cancelled and the writer's error otherwise. Run must not be
+// called concurrently.
+func (p *Poller) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	next := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		if err := p.Poll(ctx); err != nil {
+			return err
+		}
+
+		interval := p.interval
+		if p.State() != StateInMatch {
+			interval = p.idleInterval
+		}
+
+		next = next.Add(interval)
+		now := time.Now()
+		if behind := now.Sub(next); behind > 0 {
+			missed := uint64(behind/interval) + 1
+			next = next.Add(time.Duration(missed) * interval)
+			p.mu.Lock()
+			p.stats.Missed += missed
+			p.mu.Unlock()
+		}
+		timer.Reset(next.Sub(now))
+	}
+}
+
+// Poll performs a single poll. It returns an error only if the frame writer
+// fails; API and decoding failures are reflected in State and Stats.
+func (p *Poller) Poll(ctx context.Context) error {
+	timestamp := time.Now()
+
+	status, err := p.fetch(ctx, SessionPath, &p.sessionBuf)
+	switch {
+	case ctx.Err() != nil:
+		return nil
+	case err != nil:
+		p.setState(StateDisconnected)
+		return nil
+	case status == http.StatusNotFound:
+		// The game answers 404 while in the lobby or loading
+		p.setState(StateNotInMatch)
+		return nil
+	case status != http.StatusOK:
+		p.countError()
+		return nil
+	}
+
+	var bonesData []byte
+	if p.bones {
+		// Bones are optional; a failed bones poll still produces a frame
+		if status, err := p.fetch(ctx, PlayerBonesPath, &p.bonesBuf); err == nil && status == http.StatusOK {
+			bonesData = p.bonesBuf.Bytes()
+		}
+	}
+
+	frame, err := p.processor.ProcessAndDetectEvents(p.sessionBuf.Bytes(), bonesData, timestamp)
+	if errors.Is(err, processing.ErrFrameDropped) {
+		// Processor middleware filtered the frame out
+		p.setState(StateInMatch)
+		return nil
+	}
+	if err != nil {
+		p.countError()
+		return nil
+	}
+	p.setState(StateInMatch)
+	defer p.processor.Release(frame)
+
+	if p.writer != nil {
+		err := p.processor.Write(frame, p.writer.WriteFrame)
+		if errors.Is(err, processing.ErrFrameDropped) {
+			// Processor write middleware kept the frame out of the recording
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("write frame: %w", err)
+		}
+	}
+	p.mu.Lock()
+	p.stats.Frames++
+	p.mu.Unlock()
+
+	if p.onFrame != nil {
+		p.onFrame(frame)
+	}
+	return nil
+}
+
+// fetch reads the body of a GET request to path into buf and returns the status code
+func (p *Poller) fetch(ctx context.Context, path string, buf *bytes.Buffer) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	buf.Reset()
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// setState records the state and calls the state handler if it changed
+func (p *Poller) setState(state PollerState) {
+	p.mu.Lock()
+	prev := p.state
+	p.state = state
+	p.mu.Unlock()
+
+	if prev != state && p.onState != nil {
+		p.onState(prev, state)
+	}
+}
+
+// countError records a failed poll
+func (p *Poller) countError() {
+	p.mu.Lock()
+	p.stats.Errors++
+	p.mu.Unlock()
+}
@@ -0,0 +1,279 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/processing"
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// fakeGameAPI is an httptest stand-in for the game's local HTTP API
+type fakeGameAPI struct {
+	inMatch  atomic.Bool
+	sessions atomic.Int32
+	bones    atomic.Int32
+	session  []byte
+}
+
+func newFakeGameAPI(t *testing.T) *fakeGameAPI {
+	t.Helper()
+	session, err := protojson.Marshal(&apigame.SessionResponse{SessionId: "test-session", GameStatus: "playing"})
+	if err != nil {
+		t.Fatalf("Failed to marshal session: %v", err)
+	}
+	api := &fakeGameAPI{session: session}
+	api.inMatch.Store(true)
+	return api
+}
+
+func (a *fakeGameAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case SessionPath:
+		a.sessions.Add(1)
+		if !a.inMatch.Load() {
+			http.Error(w, `{"err_code":-6,"err_description":"not in a match"}`, http.StatusNotFound)
+			return
+		}
+		w.Write(a.session)
+	case PlayerBonesPath:
+		a.bones.Add(1)
+		w.Write([]byte(`{"user_bones":[]}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// recordingWriter is a FrameWriter that keeps every frame
+type recordingWriter struct {
+	mu     sync.Mutex
+	frames []*telemetry.LobbySessionStateFrame
+	err    error
+}
+
+func (w *recordingWriter) WriteFrame(frame *telemetry.LobbySessionStateFrame) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.frames = append(w.frames, frame)
+	return nil
+}
+
+func newTestProcessor(t *testing.T) *processing.Processor {
+	t.Helper()
+	processor := processing.New()
+	t.Cleanup(processor.Stop)
+	return processor
+}
+
+func TestPoller_PollInMatch(t *testing.T) {
+	api := newFakeGameAPI(t)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	writer := &recordingWriter{}
+	var handled int
+	poller := NewPoller(server.URL, newTestProcessor(t), WithFrameWriter(writer), WithFrameHandler(func(*telemetry.LobbySessionStateFrame) { handled++ }))
+
+	for i := 0; i < 3; i++ {
+		if err := poller.Poll(context.Background()); err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+	}
+
+	if poller.State() != StateInMatch {
+		t.Errorf("expected in_match, got %v", poller.State())
+	}
+	if len(writer.frames) != 3 || handled != 3 || poller.Stats().Frames != 3 {
+		t.Fatalf("expected 3 frames, got %d written, %d handled, stats %+v", len(writer.frames), handled, poller.Stats())
+	}
+	if writer.frames[2].GetFrameIndex() != 2 || writer.frames[0].GetSession().GetSessionId() != "test-session" {
+		t.Errorf("unexpected frame: %v", writer.frames[2])
+	}
+	if api.bones.Load() != 3 {
+		t.Errorf("expected player bones to be polled with every session, got %d", api.bones.Load())
+	}
+}
+
+func TestPoller_WithoutPlayerBones(t *testing.T) {
+	api := newFakeGameAPI(t)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	poller := NewPoller(server.URL, newTestProcessor(t), WithPlayerBones(false))
+	if err := poller.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if api.bones.Load() != 0 || poller.Stats().Frames != 1 {
+		t.Errorf("expected a frame without polling bones, got %d bones polls", api.bones.Load())
+	}
+}
+
+func TestPoller_SkipsFramesDroppedByMiddleware(t *testing.T) {
+	api := newFakeGameAPI(t)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	processor := newTestProcessor(t)
+	var polls int
+	processor.Use(processing.Filter(func(*telemetry.LobbySessionStateFrame) bool {
+		polls++
+		return polls%2 == 1
+	}))
+
+	writer := &recordingWriter{}
+	poller := NewPoller(server.URL, processor, WithFrameWriter(writer))
+	for i := 0; i < 4; i++ {
+		if err := poller.Poll(context.Background()); err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+	}
+
+	if poller.State() != StateInMatch {
+		t.Errorf("expected in_match, got %v", poller.State())
+	}
+	stats := poller.Stats()
+	if len(writer.frames) != 2 || stats.Frames != 2 || stats.Errors != 0 {
+		t.Errorf("expected 2 frames written and no errors, got %d written, stats %+v", len(writer.frames), stats)
+	}
+}
+
+func TestPoller_SkipsFramesDroppedBeforeWrite(t *testing.T) {
+	api := newFakeGameAPI(t)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	processor := newTestProcessor(t)
+	var polls int
+	processor.UseBeforeWrite(processing.Filter(func(*telemetry.LobbySessionStateFrame) bool {
+		polls++
+		return polls%2 == 0
+	}))
+
+	writer := &recordingWriter{}
+	var handled int
+	poller := NewPoller(server.URL, processor, WithFrameWriter(writer), WithFrameHandler(func(*telemetry.LobbySessionStateFrame) { handled++ }))
+	for i := 0; i < 4; i++ {
+		if err := poller.Poll(context.Background()); err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+	}
+
+	if stats := poller.Stats(); len(writer.frames) != 2 || stats.Frames != 2 || handled != 2 {
+		t.Errorf("expected 2 frames written and handled, got %d written, %d handled, stats %+v", len(writer.frames), handled, stats)
+	}
+}
+
+func TestPoller_NotInMatchAndDisconnected(t *testing.T) {
+	api := newFakeGameAPI(t)
+	api.inMatch.Store(false)
+	server := httptest.NewServer(api)
+
+	var transitions []PollerState
+	poller := NewPoller(server.URL, newTestProcessor(t), WithStateHandler(func(_, to PollerState) { transitions = append(transitions, to) }))
+
+	poller.Poll(context.Background())
+	if poller.State() != StateNotInMatch || poller.Stats().Frames != 0 {
+		t.Errorf("expected not_in_match without frames, got %v %+v", poller.State(), poller.Stats())
+	}
+
+	api.inMatch.Store(true)
+	poller.Poll(context.Background())
+
+	// A closed server refuses connections like a game that is not running
+	server.Close()
+	poller.Poll(context.Background())
+
+	want := []PollerState{StateNotInMatch, StateInMatch, StateDisconnected}
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("expected transitions %v, got %v", want, transitions)
+		}
+	}
+	if poller.Stats().Errors != 0 {
+		t.Errorf("expected these states not to count as errors, got %+v", poller.Stats())
+	}
+}
+
+func TestPoller_CountsBadResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == SessionPath {
+			w.Write([]byte("not json"))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	poller := NewPoller(server.URL, newTestProcessor(t))
+	if err := poller.Poll(context.Background()); err != nil {
+		t.Fatalf("expected undecodable data not to fail Poll, got %v", err)
+	}
+	if poller.Stats().Errors != 1 || poller.Stats().Frames != 0 {
+		t.Errorf("expected one error, got %+v", poller.Stats())
+	}
+}
+
+func TestPoller_RunAtRate(t *testing.T) {
+	api := newFakeGameAPI(t)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	poller := NewPoller(server.URL, newTestProcessor(t), WithPollRate(100), WithPlayerBones(false))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := poller.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// About 50 polls in 500ms; allow generous slack for slow test machines
+	if n := api.sessions.Load(); n < 20 || n > 55 {
+		t.Errorf("expected about 50 polls at 100 Hz, got %d", n)
+	}
+}
+
+func TestPoller_RunBacksOffWhenNotInMatch(t *testing.T) {
+	api := newFakeGameAPI(t)
+	api.inMatch.Store(false)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	poller := NewPoller(server.URL, newTestProcessor(t), WithPollRate(100), WithIdleInterval(100*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 350*time.Millisecond)
+	defer cancel()
+	poller.Run(ctx)
+
+	if n := api.sessions.Load(); n > 5 {
+		t.Errorf("expected the idle interval while not in a match, got %d polls", n)
+	}
+}
+
+func TestPoller_RunStopsOnWriterError(t *testing.T) {
+	api := newFakeGameAPI(t)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	errDiskFull := errors.New("disk full")
+	poller := NewPoller(server.URL, newTestProcessor(t), WithFrameWriter(&recordingWriter{err: errDiskFull}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := poller.Run(ctx); !errors.Is(err, errDiskFull) {
+		t.Errorf("expected the writer error, got %v", err)
+	}
+}
@@ -0,0 +1,403 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/codecs"
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"github.com/gofrs/uuid/v5"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Format is a recording file format
+type Format string
+
+const (
+	FormatNevrCap    Format = ".nevrcap"
+	FormatEchoReplay Format = ".echoreplay"
+)
+
+// Default Recorder settings
+const (
+	DefaultFileNameLayout    = "rec_2006-01-02_15-04-05"
+	DefaultRecorderIdleLimit = 30 * time.Second
+)
+
+// Reasons a recording was finalized
+const (
+	EndPostMatch      = "post_match"
+	EndSessionChanged = "session_changed"
+	EndIdleTimeout    = "idle_timeout"
+	EndClosed         = "closed"
+)
+
+// ErrRecorderClosed is returned when writing to a closed Recorder
+var ErrRecorderClosed = errors.New("recorder is closed")
+
+// Recording describes a recording file
+type Recording struct {
+	Path      string
+	SessionID string
+	Format    Format
+	StartedAt time.Time
+	// EndedAt is the timestamp of the last frame written
+	EndedAt time.Time
+	Frames  int
+	// EndReason is why the recording was finalized, such as EndPostMatch.
+	// It is empty while the recording is in progress.
+	EndReason string
+}
+
+// recordingFile is the codec writing a recording
+type recordingFile interface {
+	WriteFrame(frame *telemetry.LobbySessionStateFrame) error
+	Close() error
+}
+
+// RecorderOption configures a Recorder
+type RecorderOption func(*Recorder)
+
+// WithFormat sets the file format of new recordings
+func WithFormat(format Format) RecorderOption {
+	return func(r *Recorder) {
+		r.format = format
+	}
+}
+
+// WithFileNameLayout sets the time layout used to name recordings. It is
+// formatted with the local time of the first frame; the format's extension
+// is appended.
+func WithFileNameLayout(layout string) RecorderOption {
+	return func(r *Recorder) {
+		r.layout = layout
+	}
+}
+
+// WithRecorderIdleTimeout finalizes the current recording when no frame has
+// been written for d. Zero disables the timeout.
+func WithRecorderIdleTimeout(d time.Duration) RecorderOption {
+	return func(r *Recorder) {
+		r.idleTimeout = d
+	}
+}
+
+// WithRetention limits the finished recordings kept in the directory to
+// maxFiles files and maxBytes bytes in total, deleting the oldest first.
+// Zero disables a limit.
+func WithRetention(maxFiles int, maxBytes int64) RecorderOption {
+	return func(r *Recorder) {
+		r.maxFiles = maxFiles
+		r.maxBytes = maxBytes
+	}
+}
+
+// WithHeaderMetadata adds metadata to the header of every .nevrcap recording
+func WithHeaderMetadata(metadata map[string]string) RecorderOption {
+	return func(r *Recorder) {
+		r.metadata = metadata
+	}
+}
+
+// WithRecordingHandler calls fn with every finalized recording
+func WithRecordingHandler(fn func(Recording)) RecorderOption {
+	return func(r *Recorder) {
+		r.onRecording = fn
+	}
+}
+
+// Recorder writes frames to one recording file per match. It starts a new
+// file when a frame with a new session ID arrives and finalizes the current
+// one on post_match, when the session changes, or after the idle timeout.
+// Frames without a session ID are dropped.
+//
+// Recorder implements FrameWriter, so it can be passed to a Poller with
+// WithFrameWriter. Retention limits apply to every file in the directory
+// with the recorder's extension, so the directory should be dedicated to
+// the recorder.
+type Recorder struct {
+	dir         string
+	format      Format
+	layout      string
+	idleTimeout time.Duration
+	maxFiles    int
+	maxBytes    int64
+	metadata    map[string]string
+	onRecording func(Recording)
+
+	mu      sync.Mutex
+	file    recordingFile
+	current Recording
+	// finishedSession is the session whose recording ended on post_match;
+	// its remaining post_match frames are not recorded again
+	finishedSession string
+	lastWrite       time.Time
+	idleTimer       *time.Timer
+	closed          bool
+}
+
+// NewRecorder creates a Recorder that writes to dir, creating it if needed
+func NewRecorder(dir string, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		dir:         dir,
+		format:      FormatNevrCap,
+		layout:      DefaultFileNameLayout,
+		idleTimeout: DefaultRecorderIdleLimit,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.format != FormatNevrCap && r.format != FormatEchoReplay {
+		return nil, fmt.Errorf("unsupported recording format %q", r.format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Current returns the recording in progress, if any
+func (r *Recorder) Current() (Recording, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current, r.file != nil
+}
+
+// WriteFrame writes a frame to the recording of its session, rotating files as needed
+func (r *Recorder) WriteFrame(frame *telemetry.LobbySessionStateFrame) error {
+	sessionID := frame.GetSession().GetSessionId()
+	postMatch := frame.GetSession().GetGameStatus() == events.GameStatusPostMatch
+
+	r.mu.Lock()
+	var finished []Recording
+	err := r.writeFrame(frame, sessionID, postMatch, &finished)
+	r.mu.Unlock()
+
+	r.notify(finished)
+	return err
+}
+
+// writeFrame writes a frame with the lock held, appending any finalized recordings to finished
+func (r *Recorder) writeFrame(frame *telemetry.LobbySessionStateFrame, sessionID string, postMatch bool, finished *[]Recording) error {
+	if r.closed {
+		return ErrRecorderClosed
+	}
+	if sessionID == "" {
+		return nil
+	}
+
+	if r.file != nil && r.current.SessionID != sessionID {
+		if err := r.finalize(EndSessionChanged, finished); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		if sessionID == r.finishedSession && postMatch {
+			return nil
+		}
+		if err := r.start(frame, sessionID); err != nil {
+			return err
+		}
+	}
+
+	if err := r.file.WriteFrame(frame); err != nil {
+		return err
+	}
+	r.current.Frames++
+	r.current.EndedAt = frame.GetTimestamp().AsTime()
+	r.lastWrite = time.Now()
+
+	if postMatch {
+		if err := r.finalize(EndPostMatch, finished); err != nil {
+			return err
+		}
+		r.finishedSession = sessionID
+	}
+	return nil
+}
+
+// Close finalizes the recording in progress. Frames written after Close are rejected.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	var finished []Recording
+	var err error
+	if !r.closed {
+		r.closed = true
+		err = r.finalize(EndClosed, &finished)
+	}
+	if r.idleTimer != nil {
+		r.idleTimer.Stop()
+	}
+	r.mu.Unlock()
+
+	r.notify(finished)
+	return err
+}
+
+// start opens a recording file for the session
+func (r *Recorder) start(frame *telemetry.LobbySessionStateFrame, sessionID string) error {
+	startedAt := frame.GetTimestamp().AsTime()
+	path := r.nextPath(startedAt)
+
+	var file recordingFile
+	switch r.format {
+	case FormatEchoReplay:
+		w, err := codecs.NewEchoReplayWriter(path)
+		if err != nil {
+			return err
+		}
+		file = w
+	default:
+		w, err := codecs.NewNevrCapWriter(path)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteHeader(r.header(frame, startedAt)); err != nil {
+			w.Close()
+			os.Remove(path)
+			return err
+		}
+		file = w
+	}
+
+	r.file = file
+	r.current = Recording{Path: path, SessionID: sessionID, Format: r.format, StartedAt: startedAt}
+	r.finishedSession = ""
+	if r.idleTimeout > 0 {
+		if r.idleTimer == nil {
+			r.idleTimer = time.AfterFunc(r.idleTimeout, r.checkIdle)
+		} else {
+			r.idleTimer.Reset(r.idleTimeout)
+		}
+	}
+	return nil
+}
+
+// header builds the .nevrcap header for a recording starting with frame
+func (r *Recorder) header(frame *telemetry.LobbySessionStateFrame, startedAt time.Time) *telemetry.TelemetryHeader {
+	session := frame.GetSession()
+	metadata := map[string]string{
+		"source":     "capture",
+		"session_id": session.GetSessionId(),
+		"map_name":   session.GetMapName(),
+		"match_type": session.GetMatchType(),
+	}
+	for k, v := range r.metadata {
+		metadata[k] = v
+	}
+	return &telemetry.TelemetryHeader{
+		CaptureId: uuid.Must(uuid.NewV4()).String(),
+		CreatedAt: timestamppb.New(startedAt),
+		Metadata:  metadata,
+	}
+}
+
+// nextPath returns an unused file path for a recording starting at t
+func (r *Recorder) nextPath(t time.Time) string {
+	name := t.Local().Format(r.layout)
+	path := filepath.Join(r.dir, name+string(r.format))
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		path = filepath.Join(r.dir, fmt.Sprintf("%s_%d%s", name, i, r.format))
+	}
+}
+
+// finalize closes the recording in progress, if any, and applies retention
+func (r *Recorder) finalize(reason string, finished *[]Recording) error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	r.current.EndReason = reason
+	*finished = append(*finished, r.current)
+	r.current = Recording{}
+
+	if retErr := r.enforceRetention(); retErr != nil && err == nil {
+		err = retErr
+	}
+	return err
+}
+
+// checkIdle runs on the idle timer and finalizes a recording that has not been written to
+func (r *Recorder) checkIdle() {
+	r.mu.Lock()
+	var finished []Recording
+	if r.file != nil {
+		if idle := time.Since(r.lastWrite); idle < r.idleTimeout {
+			r.idleTimer.Reset(r.idleTimeout - idle)
+		} else {
+			r.finalize(EndIdleTimeout, &finished)
+		}
+	}
+	r.mu.Unlock()
+
+	r.notify(finished)
+}
+
+// enforceRetention deletes the oldest recordings beyond the retention limits
+func (r *Recorder) enforceRetention() error {
+	if r.maxFiles <= 0 && r.maxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+	type recordingEntry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []recordingEntry
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != string(r.format) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, recordingEntry{filepath.Join(r.dir, entry.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+
+	// Oldest first; names break ties since the layout sorts by time
+	slices.SortFunc(files, func(a, b recordingEntry) int {
+		if c := a.modTime.Compare(b.modTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a.path, b.path)
+	})
+
+	var errs []error
+	for len(files) > 0 && ((r.maxFiles > 0 && len(files) > r.maxFiles) || (r.maxBytes > 0 && total > r.maxBytes)) {
+		if err := os.Remove(files[0].path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		total -= files[0].size
+		files = files[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// notify calls the recording handler, without the lock held, for each finished recording
+func (r *Recorder) notify(finished []Recording) {
+	if r.onRecording == nil {
+		return
+	}
+	for _, rec := range finished {
+		r.onRecording(rec)
+	}
+}
@@ -0,0 +1,263 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/codecs"
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// recorderTestStart is the timestamp of the first test frame
+var recorderTestStart = time.Date(2026, 1, 19, 22, 50, 54, 0, time.Local)
+
+// Helper to create a frame for a session at an offset from recorderTestStart
+func createRecorderFrame(sessionID, status string, at time.Duration) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(recorderTestStart.Add(at)),
+		Session: &apigame.SessionResponse{
+			SessionId:  sessionID,
+			GameStatus: status,
+			MapName:    "mpl_arena_a",
+		},
+	}
+}
+
+// Helper to write frames, failing the test on error
+func writeRecorderFrames(t *testing.T, r *Recorder, frames ...*telemetry.LobbySessionStateFrame) {
+	t.Helper()
+	for _, frame := range frames {
+		if err := r.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+}
+
+// recordingCollector collects finalized recordings
+type recordingCollector struct {
+	mu         sync.Mutex
+	recordings []Recording
+}
+
+func (c *recordingCollector) add(rec Recording) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recordings = append(c.recordings, rec)
+}
+
+func (c *recordingCollector) get() []Recording {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Recording(nil), c.recordings...)
+}
+
+func TestRecorder_FinalizesOnPostMatch(t *testing.T) {
+	dir := t.TempDir()
+	collector := &recordingCollector{}
+	recorder, err := NewRecorder(dir, WithRecordingHandler(collector.add), WithHeaderMetadata(map[string]string{"league": "test"}))
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+
+	writeRecorderFrames(t, recorder,
+		createRecorderFrame("", "", 0),
+		createRecorderFrame("session-a", "playing", time.Second),
+		createRecorderFrame("session-a", "playing", 2*time.Second),
+		createRecorderFrame("session-a", "post_match", 3*time.Second),
+		// The game keeps reporting post_match until the lobby moves on
+		createRecorderFrame("session-a", "post_match", 4*time.Second),
+	)
+
+	recordings := collector.get()
+	if len(recordings) != 1 {
+		t.Fatalf("expected one recording, got %v", recordings)
+	}
+	rec := recordings[0]
+	if rec.EndReason != EndPostMatch || rec.Frames != 3 || rec.SessionID != "session-a" {
+		t.Errorf("unexpected recording: %+v", rec)
+	}
+	if want := filepath.Join(dir, "rec_2026-01-19_22-50-55.nevrcap"); rec.Path != want {
+		t.Errorf("expected path %s, got %s", want, rec.Path)
+	}
+	if _, ok := recorder.Current(); ok {
+		t.Error("expected no recording in progress after post_match")
+	}
+
+	reader, err := codecs.NewNevrCapReader(rec.Path)
+	if err != nil {
+		t.Fatalf("failed to open recording: %v", err)
+	}
+	defer reader.Close()
+	header, err := reader.ReadHeader()
+	if err != nil {
+		t.Fatalf("failed to read header: %v", err)
+	}
+	if header.GetCaptureId() == "" || header.GetMetadata()["session_id"] != "session-a" || header.GetMetadata()["league"] != "test" {
+		t.Errorf("unexpected header: %v", header)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := reader.ReadFrame(); err != nil {
+			t.Fatalf("failed to read frame %d: %v", i, err)
+		}
+	}
+}
+
+func TestRecorder_RotatesOnSessionChange(t *testing.T) {
+	dir := t.TempDir()
+	collector := &recordingCollector{}
+	recorder, err := NewRecorder(dir, WithFormat(FormatEchoReplay), WithRecordingHandler(collector.add))
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+
+	writeRecorderFrames(t, recorder,
+		createRecorderFrame("session-a", "playing", 0),
+		createRecorderFrame("session-b", "playing", time.Second),
+		createRecorderFrame("session-b", "playing", 2*time.Second),
+	)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recordings := collector.get()
+	if len(recordings) != 2 || recordings[0].EndReason != EndSessionChanged || recordings[1].EndReason != EndClosed {
+		t.Fatalf("expected a rotated and a closed recording, got %+v", recordings)
+	}
+	if !strings.HasSuffix(recordings[1].Path, ".echoreplay") || recordings[1].Frames != 2 {
+		t.Errorf("unexpected second recording: %+v", recordings[1])
+	}
+
+	reader, err := codecs.NewEchoReplayReader(recordings[1].Path)
+	if err != nil {
+		t.Fatalf("failed to open recording: %v", err)
+	}
+	defer reader.Close()
+	frames, err := reader.ReadFrames()
+	if err != nil || len(frames) != 2 {
+		t.Errorf("expected 2 frames, got %d (%v)", len(frames), err)
+	}
+
+	if err := recorder.WriteFrame(createRecorderFrame("session-b", "playing", 3*time.Second)); err != ErrRecorderClosed {
+		t.Errorf("expected ErrRecorderClosed, got %v", err)
+	}
+}
+
+func TestRecorder_AvoidsNameCollisions(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+
+	writeRecorderFrames(t, recorder,
+		createRecorderFrame("session-a", "playing", 0),
+		createRecorderFrame("session-b", "playing", 0),
+	)
+	rec, _ := recorder.Current()
+	if want := filepath.Join(dir, "rec_2026-01-19_22-50-54_1.nevrcap"); rec.Path != want {
+		t.Errorf("expected path %s, got %s", want, rec.Path)
+	}
+}
+
+func TestRecorder_IdleTimeout(t *testing.T) {
+	collector := &recordingCollector{}
+	recorder, err := NewRecorder(t.TempDir(), WithRecorderIdleTimeout(50*time.Millisecond), WithRecordingHandler(collector.add))
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+
+	writeRecorderFrames(t, recorder, createRecorderFrame("session-a", "playing", 0))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(collector.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	recordings := collector.get()
+	if len(recordings) != 1 || recordings[0].EndReason != EndIdleTimeout {
+		t.Fatalf("expected the recording to end on the idle timeout, got %+v", recordings)
+	}
+
+	// The same session resumes in a new recording
+	writeRecorderFrames(t, recorder, createRecorderFrame("session-a", "playing", time.Minute))
+	if rec, ok := recorder.Current(); !ok || rec.SessionID != "session-a" {
+		t.Errorf("expected a new recording for the resumed session, got %+v", rec)
+	}
+}
+
+func TestRecorder_Retention(t *testing.T) {
+	dir := t.TempDir()
+
+	// An old recording and an unrelated file
+	old := filepath.Join(dir, "rec_2020-01-01_00-00-00.nevrcap")
+	if err := os.WriteFile(old, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+	notes := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewRecorder(dir, WithRetention(2, 0))
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+
+	writeRecorderFrames(t, recorder,
+		createRecorderFrame("session-a", "post_match", 0),
+		createRecorderFrame("session-b", "post_match", time.Minute),
+	)
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("expected the oldest recording to be deleted")
+	}
+	if _, err := os.Stat(notes); err != nil {
+		t.Error("expected files of other types to be kept")
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.nevrcap"))
+	if len(matches) != 2 {
+		t.Errorf("expected 2 recordings to be kept, got %v", matches)
+	}
+}
+
+func TestRecorder_RetentionBySize(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"rec_a.nevrcap", "rec_b.nevrcap"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 1000), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(time.Duration(i-3) * time.Hour)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	recorder, err := NewRecorder(dir, WithRetention(0, 1500))
+	if err != nil {
+		t.Fatalf("NewRecorder failed: %v", err)
+	}
+	defer recorder.Close()
+	writeRecorderFrames(t, recorder, createRecorderFrame("session-a", "post_match", 0))
+
+	if _, err := os.Stat(filepath.Join(dir, "rec_a.nevrcap")); !os.IsNotExist(err) {
+		t.Error("expected the oldest recording to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "rec_b.nevrcap")); err != nil {
+		t.Error("expected the newer recording to be kept")
+	}
+}
+
+func TestNewRecorder_UnsupportedFormat(t *testing.T) {
+	if _, err := NewRecorder(t.TempDir(), WithFormat(".zip")); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
@@ -11,9 +11,11 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/echotools/nevr-capture/v3/pkg/apijson"
 	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -47,10 +49,10 @@ type EchoReplay struct {
 	frameBuffer *bytes.Buffer
 
 	// Streaming state
-	scanner     *bufio.Scanner
-	frameIndex  uint32
-	replayFile  io.ReadCloser
-	unmarshaler *protojson.UnmarshalOptions
+	scanner    *bufio.Scanner
+	frameIndex uint32
+	replayFile io.ReadCloser
+	decoder    apijson.Decoder
 	// Reusable buffer for timestamp parsing to avoid allocations
 	timestampBuf [len(EchoReplayTimeFormat)]byte
 	// Scratch buffer for marshaling
@@ -94,9 +96,6 @@ func NewEchoReplayReader(filename string) (*EchoReplay, error) {
 	codec := &EchoReplay{
 		filename:  filename,
 		zipReader: zipReader,
-		unmarshaler: &protojson.UnmarshalOptions{
-			DiscardUnknown: true,
-		},
 	}
 
 	// Initialize the scanner for streaming
@@ -211,28 +210,22 @@ func (e *EchoReplay) WriteReplayFrame(dst *bytes.Buffer, frame *telemetry.LobbyS
 
 	// 3. Session - marshal and fix uint64 string encoding
 	var err error
-	e.scratchBuf = e.scratchBuf[:0]
-	e.scratchBuf, err = echoReplayerMarshaler.MarshalAppend(e.scratchBuf, frame.GetSession())
+	e.scratchBuf, err = AppendEchoReplayJSON(e.scratchBuf[:0], frame.GetSession())
 	if err != nil {
 		return 0
 	}
-	e.scratchBuf = FixProtojsonUint64Encoding(e.scratchBuf)
-	e.scratchBuf = FixExponentNotation(e.scratchBuf)
 	dst.Write(e.scratchBuf)
 
 	// 4. Player Bones (optional) - only write if present and non-empty
 	if frame.GetPlayerBones() != nil {
 		// Check if PlayerBones has any actual data
-		e.scratchBuf = e.scratchBuf[:0]
-		e.scratchBuf, err = echoReplayerMarshaler.MarshalAppend(e.scratchBuf, frame.GetPlayerBones())
+		e.scratchBuf, err = AppendEchoReplayJSON(e.scratchBuf[:0], frame.GetPlayerBones())
 		if err == nil && len(e.scratchBuf) > 2 { // More than just "{}"
 			// Separator and Space
 			dst.WriteByte('\t')
 			dst.WriteByte(' ')
 
 			// Write Player Bones
-			e.scratchBuf = FixProtojsonUint64Encoding(e.scratchBuf)
-			e.scratchBuf = FixExponentNotation(e.scratchBuf)
 			dst.Write(e.scratchBuf)
 		}
 	}
@@ -243,6 +236,20 @@ func (e *EchoReplay) WriteReplayFrame(dst *bytes.Buffer, frame *telemetry.LobbyS
 	return dst.Len() - startLen
 }
 
+// AppendEchoReplayJSON appends the JSON encoding of m used in .echoreplay
+// files to dst: the game API's field names, enums as numbers, unpopulated
+// fields included, and uint64s and floats written as the game writes them
+func AppendEchoReplayJSON(dst []byte, m proto.Message) ([]byte, error) {
+	start := len(dst)
+	dst, err := echoReplayerMarshaler.MarshalAppend(dst, m)
+	if err != nil {
+		return dst[:start], err
+	}
+	fixed := FixProtojsonUint64Encoding(dst[start:])
+	fixed = FixExponentNotation(fixed)
+	return append(dst[:start], fixed...), nil
+}
+
 // FixProtojsonUint64Encoding converts protojson string-encoded uint64 fields back to raw numbers.
 // protojson encodes uint64 as JSON strings per proto3 spec (e.g., "userid":"123"),
 // but the original game engine outputs them as numbers (e.g., "userid":123).
@@ -443,7 +450,7 @@ func (e *EchoReplay) ReadFrame() (*telemetry.LobbySessionStateFrame, error) {
 			continue
 		}
 
-		frame, err := e.parseFrameLine(line)
+		frame, err := parseFrameLine(&e.decoder, line)
 		if err != nil {
 			continue // Skip invalid lines
 		}
@@ -465,8 +472,8 @@ func (e *EchoReplay) HasNext() bool {
 	return e.scanner != nil && e.scanner.Err() == nil
 }
 
-// parseFrameLine parses a single line into a frame
-func (e *EchoReplay) parseFrameLine(line []byte) (*telemetry.LobbySessionStateFrame, error) {
+// parseFrameLine parses a single line into a frame using dec
+func parseFrameLine(dec *apijson.Decoder, line []byte) (*telemetry.LobbySessionStateFrame, error) {
 	parts := bytes.Split(line, []byte("\t"))
 	if len(parts) < 2 {
 		return nil, fmt.Errorf("invalid line format")
@@ -480,7 +487,7 @@ func (e *EchoReplay) parseFrameLine(line []byte) (*telemetry.LobbySessionStateFr
 
 	// Parse session data
 	sessionResponse := &apigame.SessionResponse{}
-	if err := e.unmarshaler.Unmarshal(parts[1], sessionResponse); err != nil {
+	if err := dec.UnmarshalSession(parts[1], sessionResponse); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal session data: %w", err)
 	}
 
@@ -500,7 +507,7 @@ func (e *EchoReplay) parseFrameLine(line []byte) (*telemetry.LobbySessionStateFr
 
 		if len(bonesData) > 0 {
 			userBones := &apigame.PlayerBonesResponse{}
-			if err := e.unmarshaler.Unmarshal(bonesData, userBones); err == nil {
+			if err := dec.UnmarshalPlayerBones(bonesData, userBones); err == nil {
 				frame.PlayerBones = userBones
 			}
 		}
@@ -658,7 +665,7 @@ func (e *EchoReplay) parseFrameLineTo(line []byte, frame *telemetry.LobbySession
 	if frame.Session == nil {
 		frame.Session = &apigame.SessionResponse{}
 	}
-	if err := e.unmarshaler.Unmarshal(sessionBytes, frame.Session); err != nil {
+	if err := e.decoder.UnmarshalSession(sessionBytes, frame.Session); err != nil {
 		return fmt.Errorf("failed to unmarshal session data: %w", err)
 	}
 
@@ -667,7 +674,7 @@ func (e *EchoReplay) parseFrameLineTo(line []byte, frame *telemetry.LobbySession
 		if frame.PlayerBones == nil {
 			frame.PlayerBones = &apigame.PlayerBonesResponse{}
 		}
-		if err := e.unmarshaler.Unmarshal(bonesBytes, frame.PlayerBones); err != nil {
+		if err := e.decoder.UnmarshalPlayerBones(bonesBytes, frame.PlayerBones); err != nil {
 			return fmt.Errorf("failed to unmarshal player bones data: %w", err)
 		}
 	} else {
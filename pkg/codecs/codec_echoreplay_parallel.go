@@ -0,0 +1,230 @@
+package codecs
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/echotools/nevr-capture/v3/pkg/apijson"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// DefaultParallelBatchSize is the number of lines a parallel reader hands to a worker at a time
+const DefaultParallelBatchSize = 256
+
+// ParallelReaderOption configures a ParallelEchoReplayReader
+type ParallelReaderOption func(*ParallelEchoReplayReader)
+
+// WithWorkers sets the number of goroutines parsing lines. It defaults to GOMAXPROCS.
+func WithWorkers(n int) ParallelReaderOption {
+	return func(r *ParallelEchoReplayReader) {
+		if n > 0 {
+			r.workers = n
+		}
+	}
+}
+
+// WithBatchSize sets the number of lines parsed per batch
+func WithBatchSize(n int) ParallelReaderOption {
+	return func(r *ParallelEchoReplayReader) {
+		if n > 0 {
+			r.batchSize = n
+		}
+	}
+}
+
+// ParallelEchoReplayReader reads an .echoreplay file through a pipeline:
+// one goroutine splits the file into batches of lines and a pool of workers
+// parses the batches concurrently. Frames come out in file order with the
+// same FrameIndex values EchoReplay.ReadFrame assigns, and invalid lines are
+// skipped the same way.
+//
+// Each frame returned is newly allocated and owned by the caller. A
+// ParallelEchoReplayReader must be closed to stop its goroutines.
+type ParallelEchoReplayReader struct {
+	codec     *EchoReplay
+	workers   int
+	batchSize int
+
+	// ordered carries batches in file order; each is parsed once its done
+	// channel is closed. Its capacity bounds the batches in flight.
+	ordered chan *lineBatch
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	batches sync.Pool
+
+	current    *lineBatch
+	next       int
+	frameIndex uint32
+	err        error
+	closeOnce  sync.Once
+}
+
+// lineBatch is a run of lines copied out of the scanner and the frames parsed from them
+type lineBatch struct {
+	data []byte
+	// ends holds the end offset of each line in data
+	ends   []int
+	frames []*telemetry.LobbySessionStateFrame
+	// err is the scanner error that ended the file after this batch
+	err  error
+	done chan struct{}
+}
+
+// NewParallelEchoReplayReader opens an .echoreplay file for pipelined reading
+func NewParallelEchoReplayReader(filename string, opts ...ParallelReaderOption) (*ParallelEchoReplayReader, error) {
+	codec, err := NewEchoReplayReader(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ParallelEchoReplayReader{
+		codec:     codec,
+		workers:   runtime.GOMAXPROCS(0),
+		batchSize: DefaultParallelBatchSize,
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.ordered = make(chan *lineBatch, r.workers)
+	r.batches.New = func() any { return &lineBatch{} }
+
+	work := make(chan *lineBatch, r.workers)
+	r.wg.Add(r.workers + 1)
+	go r.split(work)
+	for range r.workers {
+		go r.parse(work)
+	}
+	return r, nil
+}
+
+// split reads lines into batches and queues each batch both for parsing and,
+// in order, for ReadFrame
+func (r *ParallelEchoReplayReader) split(work chan<- *lineBatch) {
+	defer r.wg.Done()
+	defer close(r.ordered)
+	defer close(work)
+
+	scanner := r.codec.scanner
+	for {
+		b := r.newBatch()
+		for len(b.ends) < r.batchSize && scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+			b.data = append(b.data, line...)
+			b.ends = append(b.ends, len(b.data))
+		}
+		eof := len(b.ends) < r.batchSize
+		if eof {
+			b.err = scanner.Err()
+			if len(b.ends) == 0 && b.err == nil {
+				return
+			}
+		}
+
+		select {
+		case r.ordered <- b:
+		case <-r.stop:
+			return
+		}
+		select {
+		case work <- b:
+		case <-r.stop:
+			return
+		}
+		if eof {
+			return
+		}
+	}
+}
+
+// parse parses batches until the work channel is closed
+func (r *ParallelEchoReplayReader) parse(work <-chan *lineBatch) {
+	defer r.wg.Done()
+
+	var dec apijson.Decoder
+	for b := range work {
+		start := 0
+		for _, end := range b.ends {
+			if frame, err := parseFrameLine(&dec, b.data[start:end]); err == nil {
+				b.frames = append(b.frames, frame)
+			}
+			start = end
+		}
+		close(b.done)
+	}
+}
+
+// newBatch returns an empty batch, reusing the buffers of a consumed one
+func (r *ParallelEchoReplayReader) newBatch() *lineBatch {
+	b := r.batches.Get().(*lineBatch)
+	b.data = b.data[:0]
+	b.ends = b.ends[:0]
+	b.frames = b.frames[:0]
+	b.err = nil
+	b.done = make(chan struct{})
+	return b
+}
+
+// ReadFrame returns the next frame, or io.EOF at the end of the file
+func (r *ParallelEchoReplayReader) ReadFrame() (*telemetry.LobbySessionStateFrame, error) {
+	for r.err == nil {
+		if b := r.current; b != nil {
+			if r.next < len(b.frames) {
+				frame := b.frames[r.next]
+				b.frames[r.next] = nil
+				r.next++
+				frame.FrameIndex = r.frameIndex
+				r.frameIndex++
+				return frame, nil
+			}
+			if b.err != nil {
+				r.err = fmt.Errorf("scanner error: %w", b.err)
+				break
+			}
+			r.current = nil
+			r.batches.Put(b)
+		}
+
+		b, ok := <-r.ordered
+		if !ok {
+			r.err = io.EOF
+			break
+		}
+		<-b.done
+		r.current, r.next = b, 0
+	}
+	return nil, r.err
+}
+
+// ReadFrames reads all remaining frames
+func (r *ParallelEchoReplayReader) ReadFrames() ([]*telemetry.LobbySessionStateFrame, error) {
+	var frames []*telemetry.LobbySessionStateFrame
+	for {
+		frame, err := r.ReadFrame()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+}
+
+// Close stops the pipeline and closes the file. Reads after Close fail.
+func (r *ParallelEchoReplayReader) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		r.wg.Wait()
+		err = r.codec.Close()
+		r.current = nil
+		r.err = fmt.Errorf("codec not configured for reading or already closed")
+	})
+	return err
+}
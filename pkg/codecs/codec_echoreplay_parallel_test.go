@@ -0,0 +1,279 @@
+package codecs
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// writeRawEchoReplay writes lines verbatim into an .echoreplay archive
+func writeRawEchoReplay(t testing.TB, path string, lines []string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("replay.echoreplay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestReplay writes n frames with distinct content, returning the path
+func writeTestReplay(t testing.TB, n int) string {
+	t.Helper()
+	path := t.TempDir() + "/parallel.echoreplay"
+	writer, err := NewEchoReplayWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 19, 22, 50, 54, 0, time.UTC)
+	for i := range n {
+		frame := &telemetry.LobbySessionStateFrame{
+			Timestamp: timestamppb.New(start.Add(time.Duration(i) * 16 * time.Millisecond)),
+			Session: &apigame.SessionResponse{
+				SessionId:  "session",
+				GameStatus: "playing",
+				GameClock:  300 - float64(i)/60,
+				BluePoints: int32(i / 100),
+				Disc:       &apigame.Disc{Position: []float64{float64(i), 1, -1}},
+				Teams: []*apigame.Team{{
+					TeamName: "BLUE TEAM",
+					Players:  []*apigame.TeamMember{{DisplayName: fmt.Sprintf("player%d", i%8), AccountNumber: uint64(1000 + i)}},
+				}},
+			},
+			PlayerBones: &apigame.PlayerBonesResponse{
+				UserBones: []*apigame.UserBones{{PlayerIndex: int32(i % 8), BoneT: []float32{float32(i), 0.5}}},
+			},
+		}
+		if err := writer.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readSequential reads every frame with the single-goroutine reader
+func readSequential(t testing.TB, path string) []*telemetry.LobbySessionStateFrame {
+	t.Helper()
+	reader, err := NewEchoReplayReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	frames, err := reader.ReadFrames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frames
+}
+
+func TestParallelEchoReplayReader_MatchesSequential(t *testing.T) {
+	path := writeTestReplay(t, 1000)
+	want := readSequential(t, path)
+	if len(want) != 1000 {
+		t.Fatalf("expected 1000 frames, got %d", len(want))
+	}
+
+	configs := []struct{ workers, batch int }{
+		{1, 1},
+		{1, DefaultParallelBatchSize},
+		{4, 7},
+		{8, 1000},
+		{3, 5000},
+	}
+	for _, cfg := range configs {
+		t.Run(fmt.Sprintf("workers=%d/batch=%d", cfg.workers, cfg.batch), func(t *testing.T) {
+			reader, err := NewParallelEchoReplayReader(path, WithWorkers(cfg.workers), WithBatchSize(cfg.batch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+
+			got, err := reader.ReadFrames()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("expected %d frames, got %d", len(want), len(got))
+			}
+			for i := range want {
+				if !proto.Equal(got[i], want[i]) {
+					t.Fatalf("frame %d differs\nparallel:   %v\nsequential: %v", i, got[i], want[i])
+				}
+			}
+
+			// The reader stays at EOF
+			if _, err := reader.ReadFrame(); err != io.EOF {
+				t.Errorf("expected io.EOF after the last frame, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParallelEchoReplayReader_SkipsInvalidLines(t *testing.T) {
+	path := t.TempDir() + "/mixed.echoreplay"
+	writeRawEchoReplay(t, path, []string{
+		"2023/01/01 12:00:00.000\t{\"session_id\":\"1\"}\t{\"user_bones\":[]}",
+		"",
+		"BAD_TIMESTAMP\t{\"session_id\":\"2\"}",
+		"2023/01/01 12:00:01.000\t{bad_json}",
+		"2023/01/01 12:00:02.000\t{\"session_id\":\"4\"}",
+		"",
+		"2023/01/01 12:00:03.000\t{\"session_id\":\"5\"}\t {\"user_bones\":[{\"playerid\":1}]}",
+	})
+	want := readSequential(t, path)
+
+	for _, batch := range []int{1, 2, 100} {
+		reader, err := NewParallelEchoReplayReader(path, WithWorkers(2), WithBatchSize(batch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := reader.ReadFrames()
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 3 || len(got) != len(want) {
+			t.Fatalf("batch %d: expected 3 frames, got %d", batch, len(got))
+		}
+		for i := range got {
+			if got[i].FrameIndex != uint32(i) {
+				t.Errorf("batch %d: frame %d has index %d", batch, i, got[i].FrameIndex)
+			}
+			if !proto.Equal(got[i], want[i]) {
+				t.Errorf("batch %d: frame %d differs from the sequential reader", batch, i)
+			}
+		}
+	}
+}
+
+func TestParallelEchoReplayReader_ScannerError(t *testing.T) {
+	path := t.TempDir() + "/long_line.echoreplay"
+	writeRawEchoReplay(t, path, []string{
+		"2023/01/01 12:00:00.000\t{\"session_id\":\"1\"}",
+		"2023/01/01 12:00:01.000\t{\"session_id\":\"" + strings.Repeat("x", 11*1024*1024) + "\"}",
+		"2023/01/01 12:00:02.000\t{\"session_id\":\"3\"}",
+	})
+
+	reader, err := NewParallelEchoReplayReader(path, WithBatchSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	frame, err := reader.ReadFrame()
+	if err != nil || frame.GetSession().GetSessionId() != "1" {
+		t.Fatalf("expected the first frame, got %v, %v", frame, err)
+	}
+	if _, err := reader.ReadFrame(); !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatalf("expected bufio.ErrTooLong, got %v", err)
+	}
+	if _, err := reader.ReadFrame(); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected the error to persist, got %v", err)
+	}
+}
+
+func TestParallelEchoReplayReader_CloseEarly(t *testing.T) {
+	path := writeTestReplay(t, 2000)
+
+	reader, err := NewParallelEchoReplayReader(path, WithWorkers(4), WithBatchSize(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		if _, err := reader.ReadFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error)
+	go func() { done <- reader.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	if _, err := reader.ReadFrame(); err == nil || err == io.EOF {
+		t.Errorf("expected an error reading after Close, got %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestParallelEchoReplayReader_EmptyFile(t *testing.T) {
+	path := t.TempDir() + "/empty.echoreplay"
+	writeRawEchoReplay(t, path, nil)
+
+	reader, err := NewParallelEchoReplayReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.ReadFrame(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func BenchmarkEchoReplayRead(b *testing.B) {
+	path := writeTestReplay(b, 5000)
+
+	b.Run("Sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			reader, err := NewEchoReplayReader(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := reader.ReadFrames(); err != nil {
+				b.Fatal(err)
+			}
+			reader.Close()
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			reader, err := NewParallelEchoReplayReader(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := reader.ReadFrames(); err != nil {
+				b.Fatal(err)
+			}
+			reader.Close()
+		}
+	})
+}
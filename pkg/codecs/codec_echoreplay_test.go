@@ -2,8 +2,12 @@ package codecs
 
 import (
 	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"os"
 	"testing"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
 )
 
 // TestEchoReplayCodec tests the EchoReplay codec
@@ -247,3 +251,48 @@ func TestFixExponentNotation(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendEchoReplayJSON(t *testing.T) {
+	session := &apigame.SessionResponse{
+		SessionId: "5A3E2E2B-0F5E-4C7B-9D1A-7E6F1B2C3D4E",
+		Teams: []*apigame.Team{{
+			Players: []*apigame.TeamMember{{AccountNumber: 4814054792376258, Velocity: []float64{1e-7, 0, 2}}},
+		}},
+	}
+
+	prefix := []byte("prefix\t")
+	data, err := AppendEchoReplayJSON(prefix, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, prefix) {
+		t.Fatalf("expected the prefix to be kept, got %q", data)
+	}
+
+	// protojson varies its whitespace between builds, so compare decoded
+	// values; json.Number keeps each number as written
+	decoder := json.NewDecoder(bytes.NewReader(data[len(prefix):]))
+	decoder.UseNumber()
+	var decoded struct {
+		GameClock json.Number `json:"game_clock"`
+		Teams     []struct {
+			Players []struct {
+				UserID   any           `json:"userid"`
+				Velocity []json.Number `json:"velocity"`
+			} `json:"players"`
+		} `json:"teams"`
+	}
+	if err := decoder.Decode(&decoded); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data[len(prefix):], err)
+	}
+	if decoded.GameClock != "0" {
+		t.Errorf("expected unpopulated game_clock to be written as 0, got %q", decoded.GameClock)
+	}
+	player := decoded.Teams[0].Players[0]
+	if userID, ok := player.UserID.(json.Number); !ok || userID != "4814054792376258" {
+		t.Errorf("expected userid as a bare number, got %#v", player.UserID)
+	}
+	if v := player.Velocity; len(v) != 3 || v[0] != "0.0000001" || v[1] != "0" || v[2] != "2" {
+		t.Errorf("expected velocity without exponent notation, got %v", v)
+	}
+}
@@ -4,18 +4,100 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/echotools/nevr-capture/v3/pkg/codecs"
 	"github.com/echotools/nevr-capture/v3/pkg/events"
 	"github.com/echotools/nevr-capture/v3/pkg/processing"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// ConvertEchoReplayToNevrcap converts a .echoreplay file to a .nevrcap file
-func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
+// Option configures a conversion
+type Option func(*options)
+
+type options struct {
+	middleware  processing.Chain
+	beforeWrite processing.Chain
+	reorder     bool
+	window      time.Duration
+	reorderOps  []processing.ReorderOption
+	gaps        bool
+	gapOps      []processing.GapOption
+}
+
+// WithMiddleware passes every frame through mw before it is written, as
+// processing.Processor.Use does for live capture. Frames dropped by the
+// middleware are not written.
+func WithMiddleware(mw ...processing.Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, mw...)
+	}
+}
+
+// WithBeforeWrite passes every frame through mw just before it is written,
+// after event detection, as processing.Processor.UseBeforeWrite does for
+// live capture. Frames dropped by the middleware are not written.
+func WithBeforeWrite(mw ...processing.Middleware) Option {
+	return func(o *options) {
+		o.beforeWrite = append(o.beforeWrite, mw...)
+	}
+}
+
+// WithReorder puts frames back in order with a processing.ReorderBuffer
+// before anything else sees them, for files merged from several sources or
+// captured over relays. Pass processing.WithDedup(true) to drop duplicates.
+func WithReorder(window time.Duration, opts ...processing.ReorderOption) Option {
+	return func(o *options) {
+		o.reorder = true
+		o.window = window
+		o.reorderOps = opts
+	}
+}
+
+// WithGapDetection finds stalls in the capture with a processing.GapDetector,
+// after any reordering. Each gap adds a capture_gap event to the frame after
+// it and is recorded in the .nevrcap header; with
+// processing.WithInterpolation, short gaps are filled with synthetic frames.
+func WithGapDetection(opts ...processing.GapOption) Option {
+	return func(o *options) {
+		o.gaps = true
+		o.gapOps = opts
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// input returns the handler frames read from a file are given, which passes
+// them to h, a function to call once every frame has been read, and the gap
+// detector if gap detection is enabled
+func (o *options) input(h processing.Handler) (processing.Handler, func() error, *processing.GapDetector) {
+	var gaps *processing.GapDetector
+	if o.gaps {
+		gaps = processing.NewGapDetector(h, o.gapOps...)
+		h = gaps.Push
+	}
+	if !o.reorder {
+		return h, func() error { return nil }, gaps
+	}
+	buf := processing.NewReorderBuffer(o.window, h, o.reorderOps...)
+	return buf.Push, buf.Flush, gaps
+}
+
+// ConvertEchoReplayToNevrcap converts a .echoreplay file to a .nevrcap file,
+// detecting events on the way. Frames that already carry events keep them
+// and skip detection, but still pass through the middleware.
+func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string, opts ...Option) error {
+	o := newOptions(opts)
+
 	// Read the .echoreplay file
 	echoReader, err := codecs.NewEchoReplayReader(echoReplayPath)
 	if err != nil {
@@ -28,6 +110,56 @@ func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
 		return fmt.Errorf("failed to read frames from echoreplay: %w", err)
 	}
 
+	// Process frames with event detection
+	// Use synchronous processing to ensure events are captured immediately
+	frameProcessor := processing.NewWithDetector(events.New(events.WithSynchronousProcessing()))
+	defer frameProcessor.Stop()
+	frameProcessor.Use(o.middleware...)
+	frameProcessor.UseBeforeWrite(o.beforeWrite...)
+
+	// Frames from the file with events of their own are not detected on again
+	detected := make(map[*telemetry.LobbySessionStateFrame]bool, len(frames))
+	for _, frame := range frames {
+		if len(frame.Events) > 0 {
+			detected[frame] = true
+		}
+	}
+	processed := make([]*telemetry.LobbySessionStateFrame, 0, len(frames))
+	keep := o.middleware.Then(func(frame *telemetry.LobbySessionStateFrame) error {
+		processed = append(processed, frame)
+		return nil
+	})
+
+	push, flush, gaps := o.input(func(frame *telemetry.LobbySessionStateFrame) error {
+		if detected[frame] {
+			return keep(frame)
+		}
+		if err := frameProcessor.Process(frame); errors.Is(err, processing.ErrFrameDropped) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to process frame %d: %w", frame.FrameIndex, err)
+		}
+
+		// Check for events immediately (synchronous processing ensures they are ready)
+		select {
+		case events := <-frameProcessor.EventsChan():
+			frame.Events = append(frame.Events, events...)
+		default:
+			// No events
+		}
+		processed = append(processed, frame)
+		return nil
+	})
+
+	for _, frame := range frames {
+		if err := push(frame); err != nil {
+			return err
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
 	// Create the .nevrcap file
 	nevrcapWriter, err := codecs.NewNevrCapWriter(nevrcapPath)
 	if err != nil {
@@ -35,7 +167,7 @@ func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
 	}
 	defer nevrcapWriter.Close()
 
-	// Write header
+	// Write header, once the gaps are known
 	header := &telemetry.TelemetryHeader{
 		CaptureId: fmt.Sprintf("converted-%d", time.Now().Unix()),
 		CreatedAt: timestamppb.Now(),
@@ -45,56 +177,18 @@ func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
 			"converted":   "true",
 		},
 	}
+	if gaps != nil {
+		maps.Copy(header.Metadata, gaps.Metadata())
+	}
 
 	if err := nevrcapWriter.WriteHeader(header); err != nil {
 		return fmt.Errorf("failed to write header: %w", err)
 	}
 
-	// Process frames with event detection
-	// Use synchronous processing to ensure events are captured immediately
-	frameProcessor := processing.NewWithDetector(events.New(events.WithSynchronousProcessing()))
-	for i, frame := range frames {
-		// Re-process the frame to generate events if not already present
-		if len(frame.Events) == 0 && frame.Session != nil {
-			// Convert to raw data and back to generate events
-			marshaler := &protojson.MarshalOptions{
-				UseProtoNames:   false,
-				UseEnumNumbers:  true,
-				EmitUnpopulated: false,
-			}
-
-			sessionData, err := marshaler.Marshal(frame.Session)
-			if err != nil {
-				return fmt.Errorf("failed to marshal session data for frame %d: %w", i, err)
-			}
-
-			var userBonesData []byte
-			if frame.GetPlayerBones() != nil {
-				userBonesData, err = marshaler.Marshal(frame.GetPlayerBones())
-				if err != nil {
-					return fmt.Errorf("failed to marshal user bones data for frame %d: %w", i, err)
-				}
-			}
-
-			processedFrame, err := frameProcessor.ProcessAndDetectEvents(sessionData, userBonesData, frame.Timestamp.AsTime())
-			if err != nil {
-				return fmt.Errorf("failed to process frame %d: %w", i, err)
-			}
-
-			// Check for events immediately (synchronous processing ensures they are ready)
-			select {
-			case events := <-frameProcessor.EventsChan():
-				processedFrame.Events = append(processedFrame.Events, events...)
-			default:
-				// No events
-			}
-
-			// Use the processed frame with events
-			frame = processedFrame
-		}
-
-		if err := nevrcapWriter.WriteFrame(frame); err != nil {
-			return fmt.Errorf("failed to write frame %d: %w", i, err)
+	for _, frame := range processed {
+		err := frameProcessor.Write(frame, nevrcapWriter.WriteFrame)
+		if err != nil && !errors.Is(err, processing.ErrFrameDropped) {
+			return fmt.Errorf("failed to write frame %d: %w", frame.FrameIndex, err)
 		}
 	}
 
@@ -102,7 +196,9 @@ func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
 }
 
 // ConvertNevrcapToEchoReplay converts a .nevrcap file to a .echoreplay file
-func ConvertNevrcapToEchoReplay(nevrcapPath, echoReplayPath string) error {
+func ConvertNevrcapToEchoReplay(nevrcapPath, echoReplayPath string, opts ...Option) error {
+	o := newOptions(opts)
+
 	// Read the .nevrcap file
 	nevrcapReader, err := codecs.NewNevrCapReader(nevrcapPath)
 	if err != nil {
@@ -123,6 +219,15 @@ func ConvertNevrcapToEchoReplay(nevrcapPath, echoReplayPath string) error {
 	}
 	defer echoWriter.Close()
 
+	// Write in legacy echoreplay format (timestamp + session JSON)
+	chain := append(slices.Clone(o.middleware), o.beforeWrite...)
+	write, flush, _ := o.input(chain.Then(func(frame *telemetry.LobbySessionStateFrame) error {
+		if err := echoWriter.WriteFrame(frame); err != nil {
+			return fmt.Errorf("failed to write frame to echoreplay: %w", err)
+		}
+		return nil
+	}))
+
 	// Convert frames
 	for {
 		frame, err := nevrcapReader.ReadFrame()
@@ -133,14 +238,17 @@ func ConvertNevrcapToEchoReplay(nevrcapPath, echoReplayPath string) error {
 			return fmt.Errorf("failed to read frame: %w", err)
 		}
 
-		// Write in legacy echoreplay format (timestamp + session JSON)
 		if frame.Session != nil {
-			if err := echoWriter.WriteFrame(frame); err != nil {
-				return fmt.Errorf("failed to write frame to echoreplay: %w", err)
+			if err := write(frame); err != nil {
+				return err
 			}
 		}
 	}
 
+	if err := flush(); err != nil {
+		return err
+	}
+
 	// Finalize the echoreplay file
 	if err := echoWriter.Finalize(); err != nil {
 		return fmt.Errorf("failed to finalize echoreplay file: %w", err)
@@ -155,10 +263,10 @@ func ConvertNevrcapToEchoReplay(nevrcapPath, echoReplayPath string) error {
 }
 
 // ConvertUncompressedEchoReplayToNevrcap converts with optimizations for benchmarking
-func ConvertUncompressedEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
+func ConvertUncompressedEchoReplayToNevrcap(echoReplayPath, nevrcapPath string, opts ...Option) error {
 	// This is an optimized version for benchmarking that skips compression
 	// and uses more efficient processing
-	return ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath)
+	return ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath, opts...)
 }
 
 // BatchConvert converts multiple files
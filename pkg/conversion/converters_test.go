@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/echotools/nevr-capture/v3/pkg/codecs"
+	"github.com/echotools/nevr-capture/v3/pkg/processing"
 	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -152,3 +153,233 @@ func TestConversionGeneratesEvents(t *testing.T) {
 		t.Errorf("Expected 1 event in frame 2, got %d", len(rf2.Events))
 	}
 }
+
+func TestConversionAppliesMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	echoReplayFile := dir + "/middleware.echoreplay"
+	nevrcapFile := dir + "/middleware.nevrcap"
+	backToEchoFile := dir + "/middleware_back.echoreplay"
+
+	writer, err := codecs.NewEchoReplayWriter(echoReplayFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i, status := range []string{"pre_match", "playing", "playing", "post_match"} {
+		frame := createTestFrame(t)
+		frame.Session.GameStatus = status
+		frame.Timestamp = timestamppb.New(start.Add(time.Duration(i) * 100 * time.Millisecond))
+		writer.WriteFrame(frame)
+	}
+	writer.Close()
+
+	chain := []processing.Middleware{
+		processing.Filter(func(frame *telemetry.LobbySessionStateFrame) bool {
+			return frame.GetSession().GetGameStatus() != "pre_match"
+		}),
+		processing.Transform(func(frame *telemetry.LobbySessionStateFrame) {
+			frame.Session.MapName = "tagged"
+		}),
+	}
+
+	if err := ConvertEchoReplayToNevrcap(echoReplayFile, nevrcapFile, WithMiddleware(chain...)); err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	reader, err := codecs.NewNevrCapReader(nevrcapFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var frames []*telemetry.LobbySessionStateFrame
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			break
+		}
+		frames = append(frames, frame)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames after filtering, got %d", len(frames))
+	}
+	for i, frame := range frames {
+		if frame.GetFrameIndex() != uint32(i) || frame.GetSession().GetMapName() != "tagged" {
+			t.Errorf("unexpected frame %d: index %d, map %q", i, frame.GetFrameIndex(), frame.GetSession().GetMapName())
+		}
+	}
+
+	// The offline path in the other direction applies the same chain
+	drop := WithMiddleware(processing.Filter(func(frame *telemetry.LobbySessionStateFrame) bool {
+		return frame.GetSession().GetGameStatus() != "post_match"
+	}))
+	if err := ConvertNevrcapToEchoReplay(nevrcapFile, backToEchoFile, drop); err != nil {
+		t.Fatalf("Conversion back failed: %v", err)
+	}
+	echoReader, err := codecs.NewEchoReplayReader(backToEchoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echoReader.Close()
+	back, err := echoReader.ReadFrames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 2 {
+		t.Errorf("expected 2 frames after filtering, got %d", len(back))
+	}
+}
+
+func TestConversionRunsMiddlewareBeforeWrite(t *testing.T) {
+	dir := t.TempDir()
+	echoReplayFile := dir + "/events.echoreplay"
+	nevrcapFile := dir + "/events.nevrcap"
+
+	writer, err := codecs.NewEchoReplayWriter(echoReplayFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i, status := range []string{"playing", "round_over", "round_over"} {
+		frame := createTestFrame(t)
+		frame.Session.GameStatus = status
+		frame.Timestamp = timestamppb.New(start.Add(time.Duration(i) * 100 * time.Millisecond))
+		writer.WriteFrame(frame)
+	}
+	writer.Close()
+
+	// Before-write middleware runs after detection, so it sees the events
+	withEvents := WithBeforeWrite(processing.Filter(func(frame *telemetry.LobbySessionStateFrame) bool {
+		return len(frame.Events) > 0
+	}))
+	if err := ConvertEchoReplayToNevrcap(echoReplayFile, nevrcapFile, withEvents); err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	reader, err := codecs.NewNevrCapReader(nevrcapFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			break
+		}
+		statuses = append(statuses, frame.GetSession().GetGameStatus())
+	}
+	if len(statuses) != 1 || statuses[0] != "round_over" {
+		t.Errorf("expected only the round_over frame with events, got %v", statuses)
+	}
+}
+
+func TestConversionReordersFrames(t *testing.T) {
+	dir := t.TempDir()
+	echoReplayFile := dir + "/merged.echoreplay"
+	nevrcapFile := dir + "/merged.nevrcap"
+
+	writer, err := codecs.NewEchoReplayWriter(echoReplayFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for _, ms := range []int{0, 32, 16, 16, 48} {
+		frame := createTestFrame(t)
+		frame.Session.GameClock = float64(ms)
+		frame.Timestamp = timestamppb.New(start.Add(time.Duration(ms) * time.Millisecond))
+		writer.WriteFrame(frame)
+	}
+	writer.Close()
+
+	if err := ConvertEchoReplayToNevrcap(echoReplayFile, nevrcapFile, WithReorder(100*time.Millisecond, processing.WithDedup(true))); err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	reader, err := codecs.NewNevrCapReader(nevrcapFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	var clocks []float64
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			break
+		}
+		clocks = append(clocks, frame.GetSession().GetGameClock())
+	}
+
+	want := []float64{0, 16, 32, 48}
+	if len(clocks) != len(want) {
+		t.Fatalf("expected frames %v, got %v", want, clocks)
+	}
+	for i := range want {
+		if clocks[i] != want[i] {
+			t.Fatalf("expected frames %v, got %v", want, clocks)
+		}
+	}
+}
+
+func TestConversionRecordsGaps(t *testing.T) {
+	dir := t.TempDir()
+	echoReplayFile := dir + "/stalled.echoreplay"
+	nevrcapFile := dir + "/stalled.nevrcap"
+
+	writer, err := codecs.NewEchoReplayWriter(echoReplayFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for _, ms := range []int{0, 100, 600, 700} {
+		frame := createTestFrame(t)
+		frame.Timestamp = timestamppb.New(start.Add(time.Duration(ms) * time.Millisecond))
+		writer.WriteFrame(frame)
+	}
+	writer.Close()
+
+	err = ConvertEchoReplayToNevrcap(echoReplayFile, nevrcapFile, WithGapDetection(
+		processing.WithExpectedRate(10),
+		processing.WithGapThreshold(150*time.Millisecond),
+		processing.WithInterpolation(time.Second),
+	))
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	reader, err := codecs.NewNevrCapReader(nevrcapFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	header, err := reader.ReadHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Metadata[processing.MetadataCaptureGapCount] != "1" || header.Metadata[processing.MetadataCaptureGapSeconds] != "0.500" {
+		t.Errorf("expected the gap in the header, got %v", header.Metadata)
+	}
+
+	var frames, synthetic int
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			break
+		}
+		frames++
+		if processing.IsSynthetic(frame) {
+			synthetic++
+		}
+	}
+	if frames != 8 || synthetic != 4 {
+		t.Errorf("expected 8 frames with 4 synthetic, got %d with %d synthetic", frames, synthetic)
+	}
+}
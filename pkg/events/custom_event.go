@@ -0,0 +1,189 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// CustomEventFieldNumber is the LobbySessionEvent field number that carries custom events.
+// The telemetry protobufs have no generic event message, so custom events are stored as
+// an unknown field. They pass through every channel, sink and snapshot unchanged and
+// survive proto marshaling, e.g. in .nevrcap files.
+const CustomEventFieldNumber protowire.Number = 1000
+
+// CustomEventTypePrefix prefixes the name of custom events in EventType
+const CustomEventTypePrefix = "custom:"
+
+// Field numbers within the encoded custom event payload
+const (
+	customEventNameField   protowire.Number = 1
+	customEventFieldsField protowire.Number = 2
+)
+
+var ErrNotCustomEvent = errors.New("event is not a custom event")
+
+// CustomEvent is an event with no dedicated message in the telemetry protobufs,
+// identified by name and carrying arbitrary key/values. Field values are the
+// JSON-like types accepted by structpb: nil, bool, float64, string, []any and
+// map[string]any. Integers are stored as float64.
+type CustomEvent struct {
+	Name   string
+	Fields map[string]any
+}
+
+// NewCustomEvent wraps a custom event in a LobbySessionEvent
+func NewCustomEvent(name string, fields map[string]any) (*telemetry.LobbySessionEvent, error) {
+	s, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid custom event fields: %w", err)
+	}
+	data, err := proto.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	payload = protowire.AppendTag(payload, customEventNameField, protowire.BytesType)
+	payload = protowire.AppendString(payload, name)
+	payload = protowire.AppendTag(payload, customEventFieldsField, protowire.BytesType)
+	payload = protowire.AppendBytes(payload, data)
+
+	var raw []byte
+	raw = protowire.AppendTag(raw, CustomEventFieldNumber, protowire.BytesType)
+	raw = protowire.AppendBytes(raw, payload)
+
+	event := &telemetry.LobbySessionEvent{}
+	event.ProtoReflect().SetUnknown(raw)
+	return event, nil
+}
+
+// newCustomEvent is NewCustomEvent for sensors whose fields are always valid structpb values
+func newCustomEvent(name string, fields map[string]any) *telemetry.LobbySessionEvent {
+	event, err := NewCustomEvent(name, fields)
+	if err != nil {
+		panic(err)
+	}
+	return event
+}
+
+// GetCustomEvent decodes the custom event carried by a LobbySessionEvent
+func GetCustomEvent(event *telemetry.LobbySessionEvent) (*CustomEvent, error) {
+	payload, ok := customEventPayload(event)
+	if !ok {
+		return nil, ErrNotCustomEvent
+	}
+
+	custom := &CustomEvent{}
+	for len(payload) > 0 {
+		num, typ, n := protowire.ConsumeTag(payload)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		payload = payload[n:]
+
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, payload)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			payload = payload[n:]
+			continue
+		}
+
+		value, n := protowire.ConsumeBytes(payload)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		payload = payload[n:]
+
+		switch num {
+		case customEventNameField:
+			custom.Name = string(value)
+		case customEventFieldsField:
+			s := &structpb.Struct{}
+			if err := proto.Unmarshal(value, s); err != nil {
+				return nil, err
+			}
+			custom.Fields = s.AsMap()
+		}
+	}
+
+	if custom.Fields == nil {
+		custom.Fields = map[string]any{}
+	}
+	return custom, nil
+}
+
+// IsCustomEvent reports whether a LobbySessionEvent carries a custom event
+func IsCustomEvent(event *telemetry.LobbySessionEvent) bool {
+	_, ok := customEventPayload(event)
+	return ok
+}
+
+// customEventName returns the name of a custom event without decoding its fields
+func customEventName(event *telemetry.LobbySessionEvent) (string, bool) {
+	custom, err := GetCustomEvent(event)
+	if err != nil {
+		return "", false
+	}
+	return custom.Name, true
+}
+
+// customEventPayload finds the custom event field among the event's unknown fields
+func customEventPayload(event *telemetry.LobbySessionEvent) ([]byte, bool) {
+	if event == nil || event.GetEvent() != nil {
+		return nil, false
+	}
+
+	raw := event.ProtoReflect().GetUnknown()
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			return nil, false
+		}
+		raw = raw[n:]
+
+		if num == CustomEventFieldNumber && typ == protowire.BytesType {
+			payload, n := protowire.ConsumeBytes(raw)
+			if n < 0 {
+				return nil, false
+			}
+			return payload, true
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, raw)
+		if n < 0 {
+			return nil, false
+		}
+		raw = raw[n:]
+	}
+	return nil, false
+}
+
+// customEventSlot returns the numeric player_slot field of a custom event
+func customEventSlot(custom *CustomEvent) (int32, bool) {
+	slot, ok := custom.Fields["player_slot"].(float64)
+	if !ok {
+		return 0, false
+	}
+	return int32(slot), true
+}
+
+// customEventTeam returns the team field of a custom event, given as a telemetry.Role name
+func customEventTeam(custom *CustomEvent) (telemetry.Role, bool) {
+	name, ok := custom.Fields["team"].(string)
+	if !ok {
+		return telemetry.Role_ROLE_UNSPECIFIED, false
+	}
+	role, ok := telemetry.Role_value[strings.ToUpper(name)]
+	if !ok {
+		return telemetry.Role_ROLE_UNSPECIFIED, false
+	}
+	return telemetry.Role(role), true
+}
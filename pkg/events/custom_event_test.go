@@ -0,0 +1,91 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCustomEvent_RoundTrip(t *testing.T) {
+	event, err := NewCustomEvent("joust", map[string]any{
+		"player_slot": 3,
+		"team":        "ROLE_ORANGE_TEAM",
+		"speed":       12.5,
+	})
+	if err != nil {
+		t.Fatalf("NewCustomEvent failed: %v", err)
+	}
+
+	// Custom events must survive marshaling, e.g. when written to a .nevrcap file
+	data, err := proto.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	decoded := &telemetry.LobbySessionEvent{}
+	if err := proto.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	custom, err := GetCustomEvent(decoded)
+	if err != nil {
+		t.Fatalf("GetCustomEvent failed: %v", err)
+	}
+	if custom.Name != "joust" {
+		t.Errorf("expected name joust, got %q", custom.Name)
+	}
+	if custom.Fields["speed"] != 12.5 {
+		t.Errorf("expected speed 12.5, got %v", custom.Fields["speed"])
+	}
+	if custom.Fields["player_slot"] != float64(3) {
+		t.Errorf("expected player_slot 3, got %v", custom.Fields["player_slot"])
+	}
+}
+
+func TestCustomEvent_NotCustom(t *testing.T) {
+	goal := &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerGoal{
+		PlayerGoal: &telemetry.PlayerGoal{PlayerSlot: 1},
+	}}
+
+	for _, event := range []*telemetry.LobbySessionEvent{nil, {}, goal} {
+		if IsCustomEvent(event) {
+			t.Errorf("expected %v not to be a custom event", event)
+		}
+		if _, err := GetCustomEvent(event); !errors.Is(err, ErrNotCustomEvent) {
+			t.Errorf("expected ErrNotCustomEvent, got %v", err)
+		}
+	}
+}
+
+func TestCustomEvent_InvalidFields(t *testing.T) {
+	if _, err := NewCustomEvent("bad", map[string]any{"ch": make(chan int)}); err == nil {
+		t.Error("expected an error for a field that cannot be encoded")
+	}
+}
+
+func TestCustomEvent_Filtering(t *testing.T) {
+	event := newCustomEvent("stun_spree", map[string]any{"player_slot": 6, "team": "ROLE_ORANGE_TEAM"})
+	noPlayer := newCustomEvent("score_phase", nil)
+
+	if got := EventType(event); got != "custom:stun_spree" {
+		t.Errorf("expected custom:stun_spree, got %q", got)
+	}
+	if slot, ok := EventPlayerSlot(event); !ok || slot != 6 {
+		t.Errorf("expected slot 6, got %d (%v)", slot, ok)
+	}
+	if team, ok := EventTeam(event); !ok || team != telemetry.Role_ROLE_ORANGE_TEAM {
+		t.Errorf("expected orange team, got %v (%v)", team, ok)
+	}
+	if _, ok := EventPlayerSlot(noPlayer); ok {
+		t.Error("expected no player slot for a custom event without player_slot")
+	}
+
+	filter := EventFilter{Types: []string{"custom:stun_spree"}, Teams: []telemetry.Role{telemetry.Role_ROLE_ORANGE_TEAM}}
+	if !filter.Match(event) {
+		t.Error("expected filter to match custom event")
+	}
+	if filter.Match(noPlayer) {
+		t.Error("expected filter not to match a different custom event")
+	}
+}
@@ -11,8 +11,37 @@ var (
 
 type detectionFunction func(i int, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent
 
-// detectPostMatchEvent checks if a post_match event should be triggered
-// Can use the frame ring buffer to analyze previous frames if needed
+// usePhaseSensors shares the match phase tracker of the detector's game
+// state sensors, if it has any, and turns off the built-in round and match
+// end detection for the events those sensors already report
+func (ed *AsyncDetector) usePhaseSensors() {
+	for _, s := range ed.sensors {
+		var phases *MatchPhaseTracker
+		switch s := s.(type) {
+		case *MatchPhaseTracker:
+			phases = s
+		case *RoundStartSensor:
+			phases = s.phases
+		case *PauseSensor:
+			phases = s.phases
+		case *RoundEndSensor:
+			phases = s.phases
+			ed.sensorRoundEnded = true
+		case *MatchEndSensor:
+			phases = s.phases
+			ed.sensorMatchEnded = true
+		}
+		if ed.phases == nil {
+			ed.phases = phases
+		}
+	}
+	if ed.phases == nil {
+		ed.phases = NewMatchPhaseTracker()
+	}
+}
+
+// detectPostMatchEvent checks if a round_over or post_match event should be
+// triggered, from the transitions of the detector's match phase tracker
 func (ed *AsyncDetector) detectPostMatchEvent(i int, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
 	// Guard against invalid index
 	if i < 0 || i >= len(ed.frameBuffer) {
@@ -24,27 +53,26 @@ func (ed *AsyncDetector) detectPostMatchEvent(i int, dst []*telemetry.LobbySessi
 		return dst
 	}
 
-	curStatus := frame.GetSession().GetGameStatus()
-
-	// Check previous game status to detect transitions
-	if ed.previousGameStatusFrame != nil && ed.previousGameStatusFrame.GetSession() != nil {
-		prevStatus := ed.previousGameStatusFrame.GetSession().GetGameStatus()
-		if prevStatus == curStatus {
-			return dst // No transition
-		}
+	// Update is idempotent per frame, so sensors sharing the tracker may have advanced it already
+	transition, ok := ed.phases.Update(frame)
+	if !ok {
+		return dst // No transition
 	}
 
-	// Update previous frame reference
-	ed.previousGameStatusFrame = frame
-
-	switch curStatus {
-	case GameStatusRoundOver:
+	switch transition.To {
+	case PhaseRoundOver:
+		if ed.sensorRoundEnded {
+			return dst
+		}
 		return append(dst, &telemetry.LobbySessionEvent{
 			Event: &telemetry.LobbySessionEvent_RoundEnded{
 				RoundEnded: &telemetry.RoundEnded{},
 			},
 		})
-	case GameStatusPostMatch:
+	case PhasePostMatch:
+		if ed.sensorMatchEnded {
+			return dst
+		}
 		return append(dst, &telemetry.LobbySessionEvent{
 			Event: &telemetry.LobbySessionEvent_MatchEnded{
 				MatchEnded: &telemetry.MatchEnded{},
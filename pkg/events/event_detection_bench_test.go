@@ -7,7 +7,7 @@ import (
 )
 
 func BenchmarkAsyncDetector_detectPostMatchEventRoundOver(b *testing.B) {
-	detector := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
+	detector := newPhaseTestDetector()
 	detector.frameBuffer[0] = newStatusOnlyFrame(GameStatusRoundOver)
 	prev := newStatusOnlyFrame("playing")
 	var buf []*telemetry.LobbySessionEvent
@@ -16,7 +16,7 @@ func BenchmarkAsyncDetector_detectPostMatchEventRoundOver(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		detector.previousGameStatusFrame = prev
+		detector.phases.Update(prev)
 		buf = buf[:0]
 		if events := detector.detectPostMatchEvent(0, buf); len(events) == 0 {
 			b.Fatalf("expected round over event, iteration %d", i)
@@ -25,7 +25,7 @@ func BenchmarkAsyncDetector_detectPostMatchEventRoundOver(b *testing.B) {
 }
 
 func BenchmarkAsyncDetector_detectPostMatchEventMatchEnded(b *testing.B) {
-	detector := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
+	detector := newPhaseTestDetector()
 	detector.frameBuffer[0] = newStatusOnlyFrame(GameStatusPostMatch)
 	prev := newStatusOnlyFrame(GameStatusRoundOver)
 	var buf []*telemetry.LobbySessionEvent
@@ -34,7 +34,7 @@ func BenchmarkAsyncDetector_detectPostMatchEventMatchEnded(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		detector.previousGameStatusFrame = prev
+		detector.phases.Update(prev)
 		buf = buf[:0]
 		if events := detector.detectPostMatchEvent(0, buf); len(events) == 0 {
 			b.Fatalf("expected match ended event, iteration %d", i)
@@ -61,6 +61,7 @@ func BenchmarkAsyncDetector_detectEventsWithSensors(b *testing.B) {
 	detector := &AsyncDetector{
 		sensors:     []Sensor{benchSensor{}, benchSensor{}},
 		frameBuffer: make([]*telemetry.LobbySessionStateFrame, DefaultFrameBufferCapacity),
+		phases:      NewMatchPhaseTracker(),
 	}
 	roundOver := newStatusOnlyFrame(GameStatusRoundOver)
 	postMatch := newStatusOnlyFrame(GameStatusPostMatch)
@@ -70,7 +71,7 @@ func BenchmarkAsyncDetector_detectEventsWithSensors(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		detector.previousGameStatusFrame = roundOver
+		detector.phases.Update(roundOver)
 		detector.addFrameToBuffer(postMatch)
 		buf = buf[:0]
 		if events := detector.detectEvents(buf); len(events) == 0 {
@@ -80,7 +81,11 @@ func BenchmarkAsyncDetector_detectEventsWithSensors(b *testing.B) {
 }
 
 func BenchmarkAsyncDetector_detectEventsNoTransition(b *testing.B) {
-	detector := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, DefaultFrameBufferCapacity)}
+	detector := &AsyncDetector{
+		frameBuffer: make([]*telemetry.LobbySessionStateFrame, DefaultFrameBufferCapacity),
+		phases:      NewMatchPhaseTracker(),
+	}
+	prev := newStatusOnlyFrame("playing")
 	playing := newStatusOnlyFrame("playing")
 	var buf []*telemetry.LobbySessionEvent
 
@@ -88,7 +93,7 @@ func BenchmarkAsyncDetector_detectEventsNoTransition(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		detector.previousGameStatusFrame = playing
+		detector.phases.Update(prev)
 		detector.addFrameToBuffer(playing)
 		buf = buf[:0]
 		if events := detector.detectEvents(buf); len(events) != 0 {
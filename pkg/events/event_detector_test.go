@@ -154,7 +154,7 @@ func TestAsyncDetector_AddFrameToBufferWraps(t *testing.T) {
 }
 
 func TestAsyncDetector_detectPostMatchEventIgnoresInvalidIndex(t *testing.T) {
-	ed := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
+	ed := newPhaseTestDetector()
 	if events := ed.detectPostMatchEvent(-1, nil); events != nil {
 		t.Fatalf("expected nil events for negative index, got %v", events)
 	}
@@ -164,7 +164,7 @@ func TestAsyncDetector_detectPostMatchEventIgnoresInvalidIndex(t *testing.T) {
 }
 
 func TestAsyncDetector_detectPostMatchEventSkipsNilFrame(t *testing.T) {
-	ed := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
+	ed := newPhaseTestDetector()
 	ed.frameBuffer[0] = nil
 	if events := ed.detectPostMatchEvent(0, nil); events != nil {
 		t.Fatalf("expected nil events for nil frame, got %v", events)
@@ -172,7 +172,7 @@ func TestAsyncDetector_detectPostMatchEventSkipsNilFrame(t *testing.T) {
 }
 
 func TestAsyncDetector_detectPostMatchEventSkipsNilSession(t *testing.T) {
-	ed := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
+	ed := newPhaseTestDetector()
 	ed.frameBuffer[0] = &telemetry.LobbySessionStateFrame{}
 	if events := ed.detectPostMatchEvent(0, nil); events != nil {
 		t.Fatalf("expected nil events for nil session, got %v", events)
@@ -180,35 +180,32 @@ func TestAsyncDetector_detectPostMatchEventSkipsNilSession(t *testing.T) {
 }
 
 func TestAsyncDetector_detectPostMatchEventSkipsRepeatedStatus(t *testing.T) {
-	ed := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
-	prev := newStatusOnlyFrame("playing")
-	ed.previousGameStatusFrame = prev
+	ed := newPhaseTestDetector()
+	ed.phases.Update(newStatusOnlyFrame("playing"))
 	ed.frameBuffer[0] = newStatusOnlyFrame("playing")
 	if events := ed.detectPostMatchEvent(0, nil); events != nil {
 		t.Fatalf("expected nil events for repeated status, got %v", events)
 	}
-	if ed.previousGameStatusFrame != prev {
-		t.Fatalf("previous frame should remain unchanged on repeated status")
+	if got := ed.phases.PreviousPhase(); got != PhaseUnknown {
+		t.Fatalf("phase should remain unchanged on repeated status, previous is %q", got)
 	}
 }
 
-func TestAsyncDetector_detectPostMatchEventUpdatesPreviousOnTransition(t *testing.T) {
-	ed := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
-	prev := newStatusOnlyFrame("playing")
-	current := newStatusOnlyFrame(GameStatusRoundOver)
-	ed.previousGameStatusFrame = prev
-	ed.frameBuffer[0] = current
+func TestAsyncDetector_detectPostMatchEventUpdatesPhaseOnTransition(t *testing.T) {
+	ed := newPhaseTestDetector()
+	ed.phases.Update(newStatusOnlyFrame("playing"))
+	ed.frameBuffer[0] = newStatusOnlyFrame(GameStatusRoundOver)
 	if events := ed.detectPostMatchEvent(0, nil); events == nil {
 		t.Fatalf("expected events for transition")
 	}
-	if ed.previousGameStatusFrame != current {
-		t.Fatalf("previous frame should update to current on transition")
+	if got := ed.phases.Phase(); got != PhaseRoundOver {
+		t.Fatalf("phase should move to round_over on transition, got %q", got)
 	}
 }
 
 func TestAsyncDetector_detectPostMatchEventEmitsRoundEnded(t *testing.T) {
-	ed := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
-	ed.previousGameStatusFrame = newStatusOnlyFrame("playing")
+	ed := newPhaseTestDetector()
+	ed.phases.Update(newStatusOnlyFrame("playing"))
 	ed.frameBuffer[0] = newStatusOnlyFrame(GameStatusRoundOver)
 	events := ed.detectPostMatchEvent(0, nil)
 	if len(events) != 1 {
@@ -220,8 +217,8 @@ func TestAsyncDetector_detectPostMatchEventEmitsRoundEnded(t *testing.T) {
 }
 
 func TestAsyncDetector_detectPostMatchEventEmitsMatchEnded(t *testing.T) {
-	ed := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
-	ed.previousGameStatusFrame = newStatusOnlyFrame(GameStatusRoundOver)
+	ed := newPhaseTestDetector()
+	ed.phases.Update(newStatusOnlyFrame(GameStatusRoundOver))
 	ed.frameBuffer[0] = newStatusOnlyFrame(GameStatusPostMatch)
 	events := ed.detectPostMatchEvent(0, nil)
 	if len(events) != 1 {
@@ -233,7 +230,7 @@ func TestAsyncDetector_detectPostMatchEventEmitsMatchEnded(t *testing.T) {
 }
 
 func TestAsyncDetector_detectPostMatchEventInitialMatchEnded(t *testing.T) {
-	ed := &AsyncDetector{frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1)}
+	ed := newPhaseTestDetector()
 	ed.frameBuffer[0] = newStatusOnlyFrame(GameStatusPostMatch)
 	events := ed.detectPostMatchEvent(0, nil)
 	if len(events) != 1 {
@@ -242,8 +239,34 @@ func TestAsyncDetector_detectPostMatchEventInitialMatchEnded(t *testing.T) {
 	if events[0].GetMatchEnded() == nil {
 		t.Fatalf("expected match ended event, got %#v", events[0])
 	}
-	if ed.previousGameStatusFrame != ed.frameBuffer[0] {
-		t.Fatalf("previous frame should update when none was set")
+	if got := ed.phases.Phase(); got != PhasePostMatch {
+		t.Fatalf("phase should update when none was set, got %q", got)
+	}
+}
+
+func TestAsyncDetector_detectPostMatchEventLeavesEndsToSensors(t *testing.T) {
+	phases := NewMatchPhaseTracker()
+	ed := &AsyncDetector{
+		frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1),
+		sensors:     []Sensor{NewRoundEndSensorWithPhases(phases), NewMatchEndSensorWithPhases(phases)},
+	}
+	ed.usePhaseSensors()
+	if ed.phases != phases {
+		t.Fatal("expected the detector to share the sensors' tracker")
+	}
+
+	for _, status := range []string{GameStatusRoundOver, GameStatusPostMatch} {
+		ed.frameBuffer[0] = newStatusOnlyFrame(status)
+		if events := ed.detectPostMatchEvent(0, nil); events != nil {
+			t.Fatalf("expected no built-in events for %s, got %v", status, events)
+		}
+	}
+}
+
+func newPhaseTestDetector() *AsyncDetector {
+	return &AsyncDetector{
+		frameBuffer: make([]*telemetry.LobbySessionStateFrame, 1),
+		phases:      NewMatchPhaseTracker(),
 	}
 }
 
@@ -5,3 +5,20 @@ import "github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 type Sensor interface {
 	AddFrame(*telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent
 }
+
+// Resetter is implemented by sensors that keep state between frames.
+// The detector calls Reset when it is reset or when the session ID changes,
+// so that state from one lobby does not leak into the next.
+type Resetter interface {
+	Reset()
+}
+
+// Snapshotter is implemented by sensors whose internal state can be
+// checkpointed and restored, so detection can resume after a restart
+// without missing or duplicating events.
+type Snapshotter interface {
+	// Snapshot returns an opaque encoding of the sensor state
+	Snapshot() ([]byte, error)
+	// Restore replaces the sensor state with a previously taken snapshot
+	Restore([]byte) error
+}
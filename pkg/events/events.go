@@ -3,6 +3,7 @@ package events
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 )
@@ -19,6 +20,11 @@ type Detector interface {
 	Stop()
 }
 
+// Emitter publishes events that did not come from a detector's sensors
+type Emitter interface {
+	Emit(...*telemetry.LobbySessionEvent)
+}
+
 const DefaultFrameBufferCapacity = 10
 
 // Option configures the AsyncDetector
@@ -27,7 +33,7 @@ type Option func(*AsyncDetector)
 // WithInputChannelSize sets the size of the input channel
 func WithInputChannelSize(size int) Option {
 	return func(ed *AsyncDetector) {
-		ed.inputChan = make(chan *telemetry.LobbySessionStateFrame, size)
+		ed.inputChan = make(chan detectorInput, size)
 	}
 }
 
@@ -52,6 +58,40 @@ func WithSensors(sensors ...Sensor) Option {
 	}
 }
 
+// WithSink delivers the events that match filter to sink
+func WithSink(sink Sink, filter EventFilter) Option {
+	return func(ed *AsyncDetector) {
+		ed.AddSink(sink, filter, 0)
+	}
+}
+
+// FrameRefs counts references to pooled frames. A detector configured with
+// WithFrameRefs owns one reference to every frame passed to ProcessFrame,
+// retains another for each additional place it keeps the frame, and
+// releases each reference once it no longer uses the frame.
+type FrameRefs interface {
+	Retain(*telemetry.LobbySessionStateFrame)
+	Release(*telemetry.LobbySessionStateFrame)
+}
+
+// WithFrameRefs makes the detector retain and release frames through refs,
+// so frames can be returned to a pool once the detector is done with them
+func WithFrameRefs(refs FrameRefs) Option {
+	return func(ed *AsyncDetector) {
+		ed.frameRefs = refs
+	}
+}
+
+// WithLossyEventsChan makes an asynchronous detector drop event batches
+// that do not fit in EventsChan instead of waiting for them to be read. Use
+// it when every consumer uses Subscribe or AddSink and EventsChan goes
+// unread; dropped batches are counted by DroppedEventBatches.
+func WithLossyEventsChan() Option {
+	return func(ed *AsyncDetector) {
+		ed.lossyEventsChan = true
+	}
+}
+
 // WithSynchronousProcessing enables synchronous processing of frames
 func WithSynchronousProcessing() Option {
 	return func(ed *AsyncDetector) {
@@ -61,7 +101,15 @@ func WithSynchronousProcessing() Option {
 
 // AsyncDetector detects post_match events
 type AsyncDetector struct {
-	previousGameStatusFrame *telemetry.LobbySessionStateFrame
+	// Match phases for the built-in round and match end detection, shared
+	// with the game state sensors if there are any
+	phases *MatchPhaseTracker
+	// Set when a sensor already reports RoundEnded or MatchEnded
+	sensorRoundEnded bool
+	sensorMatchEnded bool
+
+	// Session ID of the most recent frame, used to reset state between lobbies
+	sessionID string
 
 	// Ring buffer for frames
 	frameBuffer []*telemetry.LobbySessionStateFrame
@@ -70,30 +118,53 @@ type AsyncDetector struct {
 
 	sensors []Sensor
 
+	// Reference counting for pooled frames, if any
+	frameRefs FrameRefs
+
+	// Subscribers and sinks receiving filtered copies of detected events
+	subscribers subscribers
+
 	// Channel-based processing
-	inputChan  chan *telemetry.LobbySessionStateFrame
+	inputChan  chan detectorInput
 	eventsChan chan []*telemetry.LobbySessionEvent
 	resetChan  chan struct{}
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
-	stopOnce   sync.Once
+	// Functions that must run on the processing goroutine, e.g. snapshots
+	controlChan chan func()
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	stopOnce    sync.Once
 
 	// Reusable buffer for events to reduce allocations
 	eventBuffer []*telemetry.LobbySessionEvent
 
 	synchronous bool
+	// Set by WithLossyEventsChan
+	lossyEventsChan bool
+
+	// Event batches dropped because EventsChan was full
+	droppedBatches atomic.Uint64
 }
 
-var _ Detector = (*AsyncDetector)(nil)
+// detectorInput is a frame for detection or, if frame is nil, events given to Emit
+type detectorInput struct {
+	frame  *telemetry.LobbySessionStateFrame
+	events []*telemetry.LobbySessionEvent
+}
+
+var (
+	_ Detector = (*AsyncDetector)(nil)
+	_ Emitter  = (*AsyncDetector)(nil)
+)
 
 // New creates a new event detector with goroutine-based processing
 func New(opts ...Option) *AsyncDetector {
 	ctx, cancel := context.WithCancel(context.Background())
 	ed := &AsyncDetector{
-		inputChan:   make(chan *telemetry.LobbySessionStateFrame, 100),
+		inputChan:   make(chan detectorInput, 100),
 		eventsChan:  make(chan []*telemetry.LobbySessionEvent, 10),
 		resetChan:   make(chan struct{}),
+		controlChan: make(chan func()),
 		ctx:         ctx,
 		cancel:      cancel,
 		frameBuffer: make([]*telemetry.LobbySessionStateFrame, DefaultFrameBufferCapacity),
@@ -103,6 +174,7 @@ func New(opts ...Option) *AsyncDetector {
 	for _, opt := range opts {
 		opt(ed)
 	}
+	ed.usePhaseSensors()
 
 	ed.Start()
 	return ed
@@ -119,36 +191,129 @@ func (ed *AsyncDetector) Stop() {
 	ed.stopOnce.Do(func() {
 		ed.cancel()
 		ed.wg.Wait()
+		ed.releaseFrames()
 		close(ed.eventsChan)
+		ed.subscribers.close()
 	})
 }
 
-// Reset clears the event detector state
+// Reset clears the event detector state, including the state of any
+// sensors that implement Resetter
 func (ed *AsyncDetector) Reset() {
+	if ed.synchronous {
+		ed.reset()
+		return
+	}
+
 	select {
 	case ed.resetChan <- struct{}{}:
 	case <-ed.ctx.Done():
 	}
 }
 
+// reset clears the ring buffer, the game status tracking and the sensor state
+func (ed *AsyncDetector) reset() {
+	ed.releaseFrames()
+	ed.sessionID = ""
+	ed.phases.Reset()
+	ed.resetSensors()
+}
+
+// releaseFrames empties the ring buffer, releasing the detector's
+// references to its frames
+func (ed *AsyncDetector) releaseFrames() {
+	for i, frame := range ed.frameBuffer {
+		if frame != nil {
+			ed.releaseFrame(frame)
+			ed.frameBuffer[i] = nil
+		}
+	}
+	ed.writeIndex = 0
+	ed.frameCount = 0
+}
+
+// releaseFrame drops a reference to a pooled frame
+func (ed *AsyncDetector) releaseFrame(frame *telemetry.LobbySessionStateFrame) {
+	if ed.frameRefs != nil && frame != nil {
+		ed.frameRefs.Release(frame)
+	}
+}
+
+// resetSensors resets every sensor that implements Resetter
+func (ed *AsyncDetector) resetSensors() {
+	for _, s := range ed.sensors {
+		if r, ok := s.(Resetter); ok {
+			r.Reset()
+		}
+	}
+}
+
+// checkSessionChange resets the detector when the frame belongs to a different
+// session than the previous one. Frames without a session ID never trigger a reset.
+func (ed *AsyncDetector) checkSessionChange(frame *telemetry.LobbySessionStateFrame) {
+	sessionID := frame.GetSession().GetSessionId()
+	if sessionID == "" {
+		return
+	}
+
+	if ed.sessionID != "" && ed.sessionID != sessionID {
+		ed.reset()
+	}
+	ed.sessionID = sessionID
+}
+
 // ProcessFrame writes a frame to the processing channel (non-blocking)
 func (ed *AsyncDetector) ProcessFrame(frame *telemetry.LobbySessionStateFrame) {
+	ed.processFrame(frame)
+}
+
+// processFrame is ProcessFrame, returning false if the frame was dropped
+func (ed *AsyncDetector) processFrame(frame *telemetry.LobbySessionStateFrame) bool {
 	if ed.synchronous {
 		ed.processFrameSync(frame)
-		return
+		return true
 	}
 
 	select {
-	case ed.inputChan <- frame:
+	case ed.inputChan <- detectorInput{frame: frame}:
 		// Frame sent successfully
+		return true
 	case <-ed.ctx.Done():
 		// Detector is stopping, ignore frame
+		ed.releaseFrame(frame)
 	default:
 		// Channel full, drop frame (could also block or log)
+		ed.releaseFrame(frame)
+	}
+	return false
+}
+
+// Emit publishes events that no sensor detected, such as a capture gap
+// found before the frames reach the detector, as a batch of their own to
+// subscribers, sinks and EventsChan. An asynchronous detector queues the
+// batch behind the frames already given to ProcessFrame, so it keeps its
+// place in the stream; unlike a frame, it waits for room in the queue
+// rather than being dropped.
+func (ed *AsyncDetector) Emit(events ...*telemetry.LobbySessionEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if ed.synchronous {
+		ed.eventBuffer = append(ed.eventBuffer[:0], events...)
+		ed.emitEvents()
+		return
+	}
+
+	select {
+	case ed.inputChan <- detectorInput{events: events}:
+	case <-ed.ctx.Done():
 	}
 }
 
 func (ed *AsyncDetector) processFrameSync(frame *telemetry.LobbySessionStateFrame) {
+	// Start from a clean state if this frame belongs to a new session
+	ed.checkSessionChange(frame)
+
 	// Add frame to buffer
 	ed.addFrameToBuffer(frame)
 
@@ -156,30 +321,55 @@ func (ed *AsyncDetector) processFrameSync(frame *telemetry.LobbySessionStateFram
 	ed.eventBuffer = ed.eventBuffer[:0]
 	ed.eventBuffer = ed.detectEvents(ed.eventBuffer)
 
-	// Send events if any were detected
-	if len(ed.eventBuffer) > 0 {
-		// Copy events to avoid race conditions with the reused buffer
-		eventsToSend := make([]*telemetry.LobbySessionEvent, len(ed.eventBuffer))
-		copy(eventsToSend, ed.eventBuffer)
+	ed.emitEvents()
+}
+
+// emitEvents publishes the detected events to subscribers and sends a copy
+// on EventsChan. In asynchronous mode the send waits for EventsChan to be
+// read, unless WithLossyEventsChan is set; synchronous mode never waits, so
+// ProcessFrame returns straight away. Batches that are not sent are counted.
+// It returns false if the detector stopped while waiting.
+func (ed *AsyncDetector) emitEvents() bool {
+	if len(ed.eventBuffer) == 0 {
+		return true
+	}
+	ed.subscribers.publish(ed.eventBuffer)
+
+	// Copy events to avoid race conditions with the reused buffer
+	eventsToSend := make([]*telemetry.LobbySessionEvent, len(ed.eventBuffer))
+	copy(eventsToSend, ed.eventBuffer)
 
-		// In synchronous mode, use non-blocking send to avoid blocking ProcessFrame.
-		// This ensures ProcessFrame completes immediately in the caller's goroutine.
-		// Events are dropped if the channel is full, which is acceptable since
-		// synchronous mode prioritizes immediate processing over guaranteed delivery.
+	// EventsChan is closed once the detector has stopped
+	if ed.ctx.Err() != nil {
+		return false
+	}
+	if ed.synchronous || ed.lossyEventsChan {
 		select {
 		case ed.eventsChan <- eventsToSend:
-			// Events sent successfully
-		case <-ed.ctx.Done():
-			// Detector is stopping
-			return
 		default:
-			// Channel is full, drop events rather than blocking.
-			// This maintains the synchronous processing guarantee.
+			ed.droppedBatches.Add(1)
 		}
+		return true
+	}
+
+	select {
+	case ed.eventsChan <- eventsToSend:
+		return true
+	case <-ed.ctx.Done():
+		return false
 	}
 }
 
-// EventsChan returns the channel for receiving detected events
+// DroppedEventBatches returns the number of event batches left off
+// EventsChan because its buffer was full, in synchronous mode or with
+// WithLossyEventsChan
+func (ed *AsyncDetector) DroppedEventBatches() uint64 {
+	return ed.droppedBatches.Load()
+}
+
+// EventsChan returns the channel for receiving detected events. An
+// asynchronous detector waits for it to be read, so it must be drained
+// unless the detector was created with WithLossyEventsChan.
 func (ed *AsyncDetector) EventsChan() <-chan []*telemetry.LobbySessionEvent {
 	return ed.eventsChan
 }
@@ -191,35 +381,31 @@ func (ed *AsyncDetector) processLoop() {
 	for {
 		select {
 		case <-ed.resetChan:
-			ed.writeIndex = 0
-			ed.frameCount = 0
-			ed.previousGameStatusFrame = nil
-			for i := range ed.frameBuffer {
-				ed.frameBuffer[i] = nil
-			}
+			ed.reset()
 
-		case frame := <-ed.inputChan:
-			// Add frame to buffer
-			ed.addFrameToBuffer(frame)
+		case fn := <-ed.controlChan:
+			fn()
 
-			// Detect events using the detection algorithm
+		case in := <-ed.inputChan:
 			ed.eventBuffer = ed.eventBuffer[:0]
-			ed.eventBuffer = ed.detectEvents(ed.eventBuffer)
-
-			// Send events if any were detected
-			if len(ed.eventBuffer) > 0 {
-				// Copy events to avoid race conditions with the reused buffer
-				eventsToSend := make([]*telemetry.LobbySessionEvent, len(ed.eventBuffer))
-				copy(eventsToSend, ed.eventBuffer)
-
-				select {
-				case ed.eventsChan <- eventsToSend:
-					// Events sent successfully
-				case <-ed.ctx.Done():
-					// Context cancelled, drain inputChan and exit
-					ed.drainInputChan()
-					return
-				}
+			if in.frame == nil {
+				// Events given to Emit
+				ed.eventBuffer = append(ed.eventBuffer, in.events...)
+			} else {
+				// Start from a clean state if this frame belongs to a new session
+				ed.checkSessionChange(in.frame)
+
+				// Add frame to buffer
+				ed.addFrameToBuffer(in.frame)
+
+				// Detect events using the detection algorithm
+				ed.eventBuffer = ed.detectEvents(ed.eventBuffer)
+			}
+
+			if !ed.emitEvents() {
+				// Context cancelled, drain inputChan and exit
+				ed.drainInputChan()
+				return
 			}
 
 		case <-ed.ctx.Done():
@@ -234,8 +420,9 @@ func (ed *AsyncDetector) processLoop() {
 func (ed *AsyncDetector) drainInputChan() {
 	for {
 		select {
-		case <-ed.inputChan:
+		case in := <-ed.inputChan:
 			// Discard frame
+			ed.releaseFrame(in.frame)
 		default:
 			// Channel is empty
 			return
@@ -245,7 +432,8 @@ func (ed *AsyncDetector) drainInputChan() {
 
 // addFrameToBuffer adds a frame to the buffer
 func (ed *AsyncDetector) addFrameToBuffer(frame *telemetry.LobbySessionStateFrame) {
-	// Write to current position
+	// Release the frame being evicted, then write to current position
+	ed.releaseFrame(ed.frameBuffer[ed.writeIndex])
 	ed.frameBuffer[ed.writeIndex] = frame
 
 	// Advance write index (wrap around)
@@ -289,9 +477,10 @@ func (ed *AsyncDetector) detectEvents(dst []*telemetry.LobbySessionEvent) []*tel
 
 	for _, s := range ed.sensors {
 		event := s.AddFrame(ed.lastFrame())
-		if event != nil {
-			dst = append(dst, event)
+		if event == nil {
+			continue
 		}
+		dst = append(dst, event)
 	}
 
 	for _, fn := range [...]detectionFunction{
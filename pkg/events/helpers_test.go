@@ -0,0 +1,24 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// collectCustomEvents feeds frames to a sensor and collects every custom
+// event it emits, draining its queue after each frame
+func collectCustomEvents(t *testing.T, sensor Sensor, frames ...*telemetry.LobbySessionStateFrame) []*CustomEvent {
+	t.Helper()
+	var events []*CustomEvent
+	for _, frame := range frames {
+		for event := sensor.AddFrame(frame); event != nil; event = sensor.AddFrame(nil) {
+			custom, err := GetCustomEvent(event)
+			if err != nil {
+				t.Fatalf("expected custom event, got %v", event)
+			}
+			events = append(events, custom)
+		}
+	}
+	return events
+}
@@ -0,0 +1,317 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// DefaultSessionTTL is how long a session may go without frames before it is evicted
+const DefaultSessionTTL = 2 * time.Minute
+
+// SessionEvents is a batch of detected events tagged with the session that produced them
+type SessionEvents struct {
+	SessionID string
+	Events    []*telemetry.LobbySessionEvent
+}
+
+// SessionStats holds counters for a single session tracked by a Manager
+type SessionStats struct {
+	SessionID string
+	// FramesProcessed is the number of frames the session's detector
+	// accepted, leaving out frames dropped because its queue was full
+	FramesProcessed uint64
+	// EventsEmitted is the number of events forwarded from the session's detector
+	EventsEmitted uint64
+	CreatedAt     time.Time
+	LastFrameAt   time.Time
+}
+
+// ManagerOption configures the Manager
+type ManagerOption func(*Manager)
+
+// WithSensorFactory sets the function used to create the sensors of each new session.
+// The factory is called once per session so sensor state is never shared.
+func WithSensorFactory(factory func() []Sensor) ManagerOption {
+	return func(m *Manager) {
+		m.newSensors = factory
+	}
+}
+
+// WithDetectorOptions sets options applied to every per-session detector
+func WithDetectorOptions(opts ...Option) ManagerOption {
+	return func(m *Manager) {
+		m.detectorOpts = append(m.detectorOpts, opts...)
+	}
+}
+
+// WithSessionTTL sets how long a session may be idle before it is evicted
+func WithSessionTTL(ttl time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.ttl = ttl
+	}
+}
+
+// WithManagerEventsChannelSize sets the size of the tagged events channel
+func WithManagerEventsChannelSize(size int) ManagerOption {
+	return func(m *Manager) {
+		m.eventsChan = make(chan SessionEvents, size)
+	}
+}
+
+// managedSession is a per-session detector and its counters
+type managedSession struct {
+	id          string
+	detector    *AsyncDetector
+	createdAt   time.Time
+	lastFrameAt time.Time // guarded by Manager.mu
+	frames      atomic.Uint64
+	events      atomic.Uint64
+
+	// mu is held across every call into the detector, so frames for one
+	// session are processed one at a time and never after Stop
+	mu      sync.Mutex
+	stopped bool
+}
+
+// processFrame hands a frame to the session's detector. It returns false if
+// the session has already been stopped.
+func (s *managedSession) processFrame(frame *telemetry.LobbySessionStateFrame) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	if s.detector.processFrame(frame) {
+		s.frames.Add(1)
+	}
+	return true
+}
+
+// stop stops the session's detector once no frame is being processed
+func (s *managedSession) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	s.detector.Stop()
+}
+
+// Manager routes frames from many concurrent lobbies to per-session detectors,
+// keyed by the frame's session ID. Detectors are created on demand and evicted
+// after they have been idle for the session TTL. Events from every session are
+// fanned into a single channel, tagged with their session ID.
+type Manager struct {
+	mu       sync.Mutex
+	sessions map[string]*managedSession
+
+	newSensors   func() []Sensor
+	detectorOpts []Option
+	ttl          time.Duration
+	now          func() time.Time
+
+	eventsChan chan SessionEvents
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	stopOnce   sync.Once
+}
+
+// NewManager creates a new Manager. Sessions use DefaultSensors unless a
+// sensor factory is supplied.
+func NewManager(opts ...ManagerOption) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		sessions:   make(map[string]*managedSession),
+		newSensors: DefaultSensors,
+		ttl:        DefaultSessionTTL,
+		now:        time.Now,
+		eventsChan: make(chan SessionEvents, 100),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.ttl > 0 {
+		m.wg.Add(1)
+		go m.evictLoop()
+	}
+
+	return m
+}
+
+// ProcessFrame routes a frame to the detector for its session, creating it if needed
+func (m *Manager) ProcessFrame(frame *telemetry.LobbySessionStateFrame) {
+	if frame == nil {
+		return
+	}
+
+	sessionID := frame.GetSession().GetSessionId()
+
+	// A session evicted between the lookup and the call is stopped; look
+	// again, which starts a fresh one
+	for {
+		m.mu.Lock()
+		if m.ctx.Err() != nil {
+			m.mu.Unlock()
+			return
+		}
+		session, ok := m.sessions[sessionID]
+		if !ok {
+			session = m.startSession(sessionID)
+			m.sessions[sessionID] = session
+		}
+		session.lastFrameAt = m.now()
+		m.mu.Unlock()
+
+		if session.processFrame(frame) {
+			return
+		}
+	}
+}
+
+// EventsChan returns the channel receiving events from every session
+func (m *Manager) EventsChan() <-chan SessionEvents {
+	return m.eventsChan
+}
+
+// Stats returns the counters of every active session, ordered by session ID
+func (m *Manager) Stats() []SessionStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]SessionStats, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		stats = append(stats, session.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].SessionID < stats[j].SessionID
+	})
+	return stats
+}
+
+// SessionStats returns the counters of a single session
+func (m *Manager) SessionStats(sessionID string) (SessionStats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		return SessionStats{}, false
+	}
+	return session.stats(), true
+}
+
+// EvictIdle stops and removes every session that has been idle for longer
+// than the session TTL, returning the number of sessions evicted
+func (m *Manager) EvictIdle() int {
+	if m.ttl <= 0 {
+		return 0
+	}
+
+	cutoff := m.now().Add(-m.ttl)
+
+	m.mu.Lock()
+	var idle []*managedSession
+	for id, session := range m.sessions {
+		if session.lastFrameAt.Before(cutoff) {
+			idle = append(idle, session)
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
+
+	// Stop outside the lock; it waits for the detector goroutine to exit
+	for _, session := range idle {
+		session.stop()
+	}
+	return len(idle)
+}
+
+// Stop shuts down every session detector and closes the events channel
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() {
+		m.mu.Lock()
+		m.cancel()
+		sessions := m.sessions
+		m.sessions = make(map[string]*managedSession)
+		m.mu.Unlock()
+
+		for _, session := range sessions {
+			session.stop()
+		}
+		m.wg.Wait()
+		close(m.eventsChan)
+	})
+}
+
+// startSession creates the detector for a new session and starts forwarding its events.
+// Must be called with m.mu held.
+func (m *Manager) startSession(sessionID string) *managedSession {
+	opts := make([]Option, 0, len(m.detectorOpts)+1)
+	opts = append(opts, m.detectorOpts...)
+	opts = append(opts, WithSensors(m.newSensors()...))
+
+	session := &managedSession{
+		id:        sessionID,
+		detector:  New(opts...),
+		createdAt: m.now(),
+	}
+
+	m.wg.Add(1)
+	go m.forwardEvents(session)
+	return session
+}
+
+// forwardEvents tags events from a session detector and sends them to the shared channel.
+// It exits once the detector is stopped and its events channel is drained.
+func (m *Manager) forwardEvents(session *managedSession) {
+	defer m.wg.Done()
+
+	for events := range session.detector.EventsChan() {
+		session.events.Add(uint64(len(events)))
+		select {
+		case m.eventsChan <- SessionEvents{SessionID: session.id, Events: events}:
+		case <-m.ctx.Done():
+			// Manager is stopping; keep draining so the detector can shut down
+		}
+	}
+}
+
+// evictLoop periodically evicts idle sessions
+func (m *Manager) evictLoop() {
+	defer m.wg.Done()
+
+	interval := m.ttl / 2
+	if interval <= 0 {
+		interval = m.ttl
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.EvictIdle()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// stats returns a copy of the session counters. Must be called with Manager.mu held.
+func (s *managedSession) stats() SessionStats {
+	return SessionStats{
+		SessionID:       s.id,
+		FramesProcessed: s.frames.Load(),
+		EventsEmitted:   s.events.Load(),
+		CreatedAt:       s.createdAt,
+		LastFrameAt:     s.lastFrameAt,
+	}
+}
@@ -0,0 +1,242 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// createManagerTestFrame creates a frame for the given session and game status
+func createManagerTestFrame(sessionID, gameStatus string) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		Session: &apigame.SessionResponse{
+			SessionId:  sessionID,
+			GameStatus: gameStatus,
+		},
+	}
+}
+
+// receiveSessionEvents waits for n tagged event batches
+func receiveSessionEvents(t *testing.T, m *Manager, n int) []SessionEvents {
+	t.Helper()
+	var out []SessionEvents
+	for len(out) < n {
+		select {
+		case events := <-m.EventsChan():
+			out = append(out, events)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for events, got %d of %d", len(out), n)
+		}
+	}
+	return out
+}
+
+func TestManager_RoutesFramesBySession(t *testing.T) {
+	m := NewManager(WithSensorFactory(func() []Sensor {
+		return []Sensor{NewMatchEndSensor()}
+	}))
+	defer m.Stop()
+
+	// Interleave frames from two lobbies; only session-b reaches post_match
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	m.ProcessFrame(createManagerTestFrame("session-b", GameStatusPlaying))
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	m.ProcessFrame(createManagerTestFrame("session-b", GameStatusPostMatch))
+
+	received := receiveSessionEvents(t, m, 1)
+	if received[0].SessionID != "session-b" {
+		t.Errorf("expected events from session-b, got %q", received[0].SessionID)
+	}
+
+	var matchEnded bool
+	for _, event := range received[0].Events {
+		if event.GetMatchEnded() != nil {
+			matchEnded = true
+		}
+	}
+	if !matchEnded {
+		t.Errorf("expected MatchEnded event, got %v", received[0].Events)
+	}
+}
+
+func TestManager_SensorFactoryCalledPerSession(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	m := NewManager(WithSensorFactory(func() []Sensor {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return []Sensor{NewPlayerJoinSensor()}
+	}))
+	defer m.Stop()
+
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	m.ProcessFrame(createManagerTestFrame("session-b", GameStatusPlaying))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 {
+		t.Errorf("expected sensor factory to be called twice, got %d", calls)
+	}
+}
+
+func TestManager_Stats(t *testing.T) {
+	m := NewManager(
+		WithSensorFactory(func() []Sensor { return nil }),
+		WithDetectorOptions(WithSynchronousProcessing()),
+	)
+	defer m.Stop()
+
+	m.ProcessFrame(createManagerTestFrame("session-b", GameStatusPlaying))
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPostMatch))
+	receiveSessionEvents(t, m, 1)
+
+	stats := m.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(stats))
+	}
+	if stats[0].SessionID != "session-a" || stats[1].SessionID != "session-b" {
+		t.Errorf("expected stats ordered by session ID, got %q, %q", stats[0].SessionID, stats[1].SessionID)
+	}
+	if stats[0].FramesProcessed != 2 {
+		t.Errorf("expected 2 frames for session-a, got %d", stats[0].FramesProcessed)
+	}
+	if stats[0].EventsEmitted != 1 {
+		t.Errorf("expected 1 event for session-a, got %d", stats[0].EventsEmitted)
+	}
+
+	if _, ok := m.SessionStats("session-c"); ok {
+		t.Error("expected no stats for unknown session")
+	}
+}
+
+func TestManager_EvictIdle(t *testing.T) {
+	m := NewManager(WithSessionTTL(time.Minute))
+	defer m.Stop()
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	now = now.Add(45 * time.Second)
+	m.ProcessFrame(createManagerTestFrame("session-b", GameStatusPlaying))
+	now = now.Add(30 * time.Second)
+
+	if evicted := m.EvictIdle(); evicted != 1 {
+		t.Fatalf("expected 1 session evicted, got %d", evicted)
+	}
+	if _, ok := m.SessionStats("session-a"); ok {
+		t.Error("expected session-a to be evicted")
+	}
+	if _, ok := m.SessionStats("session-b"); !ok {
+		t.Error("expected session-b to remain")
+	}
+
+	// A new frame recreates the evicted session with fresh state
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	stats, ok := m.SessionStats("session-a")
+	if !ok || stats.FramesProcessed != 1 {
+		t.Errorf("expected recreated session-a with 1 frame, got %+v", stats)
+	}
+}
+
+func TestManager_StopClosesEventsChan(t *testing.T) {
+	m := NewManager()
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	m.Stop()
+
+	select {
+	case _, ok := <-m.EventsChan():
+		for ok {
+			_, ok = <-m.EventsChan()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("events channel was not closed")
+	}
+
+	// Frames after Stop are ignored
+	m.ProcessFrame(createManagerTestFrame("session-b", GameStatusPlaying))
+	if len(m.Stats()) != 0 {
+		t.Error("expected no sessions after Stop")
+	}
+
+	// Stop is idempotent
+	m.Stop()
+}
+
+// blockingSensor holds up its detector on the first frame until released
+type blockingSensor struct {
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingSensor) AddFrame(*telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	s.once.Do(func() {
+		close(s.entered)
+		<-s.release
+	})
+	return nil
+}
+
+func TestManager_StatsCountOnlyAcceptedFrames(t *testing.T) {
+	sensor := &blockingSensor{entered: make(chan struct{}), release: make(chan struct{})}
+	m := NewManager(
+		WithSensorFactory(func() []Sensor { return []Sensor{sensor} }),
+		WithDetectorOptions(WithInputChannelSize(1)),
+	)
+	defer m.Stop()
+
+	// The first frame holds up the detector and the second fills its queue
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	<-sensor.entered
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPlaying))
+	close(sensor.release)
+
+	stats, ok := m.SessionStats("session-a")
+	if !ok || stats.FramesProcessed != 2 {
+		t.Errorf("expected 2 accepted frames, got %+v", stats)
+	}
+}
+
+func TestManager_ConcurrentFramesAndEviction(t *testing.T) {
+	m := NewManager(
+		WithSessionTTL(time.Minute),
+		WithDetectorOptions(WithSynchronousProcessing(), WithEventsChannelSize(1)),
+	)
+	defer m.Stop()
+
+	var mu sync.Mutex
+	now := time.Now()
+	m.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	// Frames for one session race each other and the session's eviction;
+	// detectors must never run concurrently or after they are stopped
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				m.ProcessFrame(createManagerTestFrame("session-a", GameStatusPostMatch))
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		mu.Lock()
+		now = now.Add(2 * time.Minute)
+		mu.Unlock()
+		m.EvictIdle()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"testing"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// createSessionFrameWithGoals creates a frame for the given session with a single player's goal count
+func createSessionFrameWithGoals(sessionID string, goals int32) *telemetry.LobbySessionStateFrame {
+	frame := createFrameWithPlayerStats(1, &apigame.PlayerStats{Goals: goals, Points: goals * 2})
+	frame.Session.SessionId = sessionID
+	return frame
+}
+
+// drainEvents collects all events currently buffered in the channel
+func drainEvents(ch <-chan []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
+	var out []*telemetry.LobbySessionEvent
+	for {
+		select {
+		case events := <-ch:
+			out = append(out, events...)
+		default:
+			return out
+		}
+	}
+}
+
+func TestDefaultSensors_ImplementResetter(t *testing.T) {
+	for _, s := range DefaultSensors() {
+		if _, ok := s.(Resetter); !ok {
+			t.Errorf("%T does not implement Resetter", s)
+		}
+	}
+}
+
+func TestAsyncDetector_ResetClearsSensorState(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(NewStatEventSensor()))
+	defer detector.Stop()
+
+	detector.ProcessFrame(createSessionFrameWithGoals("", 0))
+	detector.Reset()
+
+	// Without resetting the sensor this would be reported as three new goals
+	detector.ProcessFrame(createSessionFrameWithGoals("", 3))
+
+	if events := drainEvents(detector.EventsChan()); len(events) != 0 {
+		t.Fatalf("expected no events after reset, got %d", len(events))
+	}
+}
+
+func TestAsyncDetector_SessionChangeResetsSensors(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(NewStatEventSensor()))
+	defer detector.Stop()
+
+	detector.ProcessFrame(createSessionFrameWithGoals("session-a", 0))
+	detector.ProcessFrame(createSessionFrameWithGoals("session-b", 3))
+
+	if events := drainEvents(detector.EventsChan()); len(events) != 0 {
+		t.Fatalf("expected no events on session change, got %d", len(events))
+	}
+
+	// Stat changes within the new session are still detected
+	detector.ProcessFrame(createSessionFrameWithGoals("session-b", 4))
+
+	events := drainEvents(detector.EventsChan())
+	if len(events) != 1 || events[0].GetPlayerGoal() == nil {
+		t.Fatalf("expected one PlayerGoal event, got %v", events)
+	}
+}
+
+func TestAsyncDetector_EmptySessionIDDoesNotReset(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(NewStatEventSensor()))
+	defer detector.Stop()
+
+	detector.ProcessFrame(createSessionFrameWithGoals("session-a", 0))
+	detector.ProcessFrame(createSessionFrameWithGoals("", 1))
+
+	events := drainEvents(detector.EventsChan())
+	if len(events) != 1 || events[0].GetPlayerGoal() == nil {
+		t.Fatalf("expected one PlayerGoal event, got %v", events)
+	}
+}
+
+func TestPlayerJoinSensor_Reset(t *testing.T) {
+	sensor := NewPlayerJoinSensor()
+
+	sensor.AddFrame(createFrameWithPlayers(createPlayer(1, "Player1", 0)))
+	sensor.Reset()
+
+	// After reset the same player is reported as joining again
+	event := sensor.AddFrame(createFrameWithPlayers(createPlayer(1, "Player1", 0)))
+	if event.GetPlayerJoined() == nil {
+		t.Fatalf("expected PlayerJoined after reset, got %v", event)
+	}
+}
+
+func TestPlayerLeaveSensor_Reset(t *testing.T) {
+	sensor := NewPlayerLeaveSensor()
+
+	sensor.AddFrame(createFrameWithPlayers(createPlayer(1, "Player1", 0)))
+	sensor.Reset()
+
+	// The player from before the reset is not reported as leaving
+	event := sensor.AddFrame(createFrameWithPlayers(createPlayer(2, "Player2", 0)))
+	if event != nil {
+		t.Fatalf("expected no event after reset, got %v", event)
+	}
+}
@@ -0,0 +1,506 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Rule expressions are one or more clauses joined by "and". Each clause names a
+// field path and a predicate over its current and previous values:
+//
+//	session.game_status changes
+//	session.game_status changes from "playing" to "score"
+//	player.stats.stuns increases
+//	player.stats.stuns increases by >= 2 within 3s
+//	session.blue_points decreases by 1
+//	player.ping > 150
+//
+// Paths start with "session" (the SessionResponse) or "player" (each
+// TeamMember in turn) and use proto or JSON field names.
+
+// ruleScope is the root message a field path is resolved against
+type ruleScope int
+
+const (
+	ruleScopeSession ruleScope = iota
+	ruleScopePlayer
+)
+
+// rulePredicate is the kind of test a clause performs
+type rulePredicate int
+
+const (
+	predicateChanges rulePredicate = iota
+	predicateIncreases
+	predicateDecreases
+	predicateCompare
+)
+
+// ruleClause is a single compiled predicate over one field path
+type ruleClause struct {
+	scope  ruleScope
+	path   string
+	fields []protoreflect.FieldDescriptor
+
+	predicate rulePredicate
+	// changes: optional from/to values
+	from, to any
+	// increases/decreases: amount comparison and optional window
+	op     string
+	amount float64
+	within time.Duration
+	// compare: literal to compare the current value against
+	value any
+}
+
+// momentary reports whether the clause is only true on the frame a change happens,
+// as opposed to a level that can stay true across frames
+func (c *ruleClause) momentary() bool {
+	switch c.predicate {
+	case predicateChanges:
+		return true
+	case predicateIncreases, predicateDecreases:
+		return c.within == 0
+	}
+	return false
+}
+
+// ruleSample is a timestamped field value
+type ruleSample struct {
+	at    time.Time
+	value float64
+}
+
+// ruleClauseState is the per-scope history of a clause
+type ruleClauseState struct {
+	prev    any
+	hasPrev bool
+	history []ruleSample
+}
+
+// eval updates the clause state with the current value and reports whether the clause holds
+func (c *ruleClause) eval(st *ruleClauseState, current any, at time.Time) bool {
+	prev, hasPrev := st.prev, st.hasPrev
+	st.prev, st.hasPrev = current, true
+
+	switch c.predicate {
+	case predicateChanges:
+		if !hasPrev || ruleValuesEqual(prev, current) {
+			return false
+		}
+		if c.from != nil && !ruleValuesEqual(prev, c.from) {
+			return false
+		}
+		return c.to == nil || ruleValuesEqual(current, c.to)
+
+	case predicateIncreases, predicateDecreases:
+		cur, ok := current.(float64)
+		if !ok {
+			return false
+		}
+		var delta float64
+		if c.within > 0 {
+			delta = c.windowDelta(st, cur, at)
+		} else {
+			p, ok := prev.(float64)
+			if !hasPrev || !ok {
+				return false
+			}
+			delta = cur - p
+			if c.predicate == predicateDecreases {
+				delta = -delta
+			}
+		}
+		if delta <= 0 {
+			return false
+		}
+		if c.op == "" {
+			return true
+		}
+		return compareRuleValues(delta, c.op, c.amount)
+
+	case predicateCompare:
+		return compareRuleValues(current, c.op, c.value)
+	}
+	return false
+}
+
+// windowDelta records the sample and returns the largest change in the clause's
+// direction between any sample in the window and the current value
+func (c *ruleClause) windowDelta(st *ruleClauseState, cur float64, at time.Time) float64 {
+	cutoff := at.Add(-c.within)
+	kept := st.history[:0]
+	for _, sample := range st.history {
+		if !sample.at.Before(cutoff) {
+			kept = append(kept, sample)
+		}
+	}
+	st.history = append(kept, ruleSample{at: at, value: cur})
+
+	var delta float64
+	for _, sample := range st.history {
+		d := cur - sample.value
+		if c.predicate == predicateDecreases {
+			d = -d
+		}
+		delta = max(delta, d)
+	}
+	return delta
+}
+
+// resolve reads the clause's field from the scope message. Unset messages along
+// the path yield the field's default value.
+func (c *ruleClause) resolve(msg protoreflect.Message) any {
+	for i, fd := range c.fields {
+		v := msg.Get(fd)
+		if i < len(c.fields)-1 {
+			msg = v.Message()
+			continue
+		}
+		switch fd.Kind() {
+		case protoreflect.BoolKind:
+			return v.Bool()
+		case protoreflect.StringKind:
+			return v.String()
+		case protoreflect.EnumKind:
+			return float64(v.Enum())
+		case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+			protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+			return float64(v.Int())
+		case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+			return float64(v.Uint())
+		case protoreflect.FloatKind, protoreflect.DoubleKind:
+			return v.Float()
+		}
+	}
+	return nil
+}
+
+// ruleValuesEqual compares two rule values of any supported type
+func ruleValuesEqual(a, b any) bool {
+	return compareRuleValues(a, "==", b)
+}
+
+// compareRuleValues applies a comparison operator. Ordering is only defined for numbers.
+func compareRuleValues(a any, op string, b any) bool {
+	if x, ok := a.(float64); ok {
+		y, ok := b.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "==":
+			return x == y
+		case "!=":
+			return x != y
+		case ">":
+			return x > y
+		case ">=":
+			return x >= y
+		case "<":
+			return x < y
+		case "<=":
+			return x <= y
+		}
+		return false
+	}
+
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
+
+// compileRuleExpression parses a rule expression into clauses
+func compileRuleExpression(expr string) ([]*ruleClause, error) {
+	tokens, err := tokenizeRuleExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{tokens: tokens}
+
+	var clauses []*ruleClause
+	for {
+		clause, err := p.parseClause()
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+
+		if p.done() {
+			return clauses, nil
+		}
+		if !p.accept("and") {
+			return nil, fmt.Errorf("expected \"and\", got %q", p.peek().text)
+		}
+	}
+}
+
+// ruleTokenKind classifies rule expression tokens
+type ruleTokenKind int
+
+const (
+	tokenWord ruleTokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type ruleToken struct {
+	kind ruleTokenKind
+	text string
+}
+
+// tokenizeRuleExpression splits an expression into words, strings, numbers and operators
+func tokenizeRuleExpression(expr string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"':
+			j := i + 1
+			var sb strings.Builder
+			for j < len(runes) && runes[j] != '"' {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string in %q", expr)
+			}
+			tokens = append(tokens, ruleToken{kind: tokenString, text: sb.String()})
+			i = j + 1
+
+		case strings.ContainsRune("<>=!", r):
+			j := i + 1
+			if j < len(runes) && runes[j] == '=' {
+				j++
+			}
+			op := string(runes[i:j])
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("invalid operator %q", op)
+			}
+			tokens = append(tokens, ruleToken{kind: tokenOperator, text: op})
+			i = j
+
+		case unicode.IsDigit(r) || r == '-' || r == '.':
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || unicode.IsLetter(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, ruleToken{kind: tokenNumber, text: string(runes[i:j])})
+			i = j
+
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, ruleToken{kind: tokenWord, text: string(runes[i:j])})
+			i = j
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q in %q", r, expr)
+		}
+	}
+	return tokens, nil
+}
+
+// ruleParser is a recursive-descent parser over rule tokens
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+func (p *ruleParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *ruleParser) peek() ruleToken {
+	if p.done() {
+		return ruleToken{text: "end of expression"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() ruleToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// accept consumes the next token if it is the given word
+func (p *ruleParser) accept(word string) bool {
+	if t := p.peek(); t.kind == tokenWord && t.text == word && !p.done() {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) parseClause() (*ruleClause, error) {
+	t := p.next()
+	if t.kind != tokenWord {
+		return nil, fmt.Errorf("expected field path, got %q", t.text)
+	}
+	clause, err := resolveRulePath(t.text)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := p.next(); {
+	case t.kind == tokenWord && t.text == "changes":
+		clause.predicate = predicateChanges
+		if p.accept("from") {
+			if clause.from, err = p.parseLiteral(); err != nil {
+				return nil, err
+			}
+		}
+		if p.accept("to") {
+			if clause.to, err = p.parseLiteral(); err != nil {
+				return nil, err
+			}
+		}
+
+	case t.kind == tokenWord && (t.text == "increases" || t.text == "decreases"):
+		clause.predicate = predicateIncreases
+		if t.text == "decreases" {
+			clause.predicate = predicateDecreases
+		}
+		if !clause.numeric() {
+			return nil, fmt.Errorf("%s %s: field is not numeric", clause.path, t.text)
+		}
+		if p.accept("by") {
+			clause.op = ">="
+			if p.peek().kind == tokenOperator {
+				clause.op = p.next().text
+			}
+			if clause.amount, err = p.parseNumber(); err != nil {
+				return nil, err
+			}
+		}
+		if p.accept("within") {
+			d := p.next()
+			if clause.within, err = time.ParseDuration(d.text); err != nil || clause.within <= 0 {
+				return nil, fmt.Errorf("invalid window %q", d.text)
+			}
+		}
+
+	case t.kind == tokenOperator:
+		clause.predicate = predicateCompare
+		clause.op = t.text
+		if clause.value, err = p.parseLiteral(); err != nil {
+			return nil, err
+		}
+		if _, ok := clause.value.(float64); !ok && t.text != "==" && t.text != "!=" {
+			return nil, fmt.Errorf("%s %s: ordering requires a number", clause.path, t.text)
+		}
+
+	default:
+		return nil, fmt.Errorf("expected predicate after %s, got %q", clause.path, t.text)
+	}
+
+	return clause, nil
+}
+
+// parseLiteral parses a string, number or boolean literal
+func (p *ruleParser) parseLiteral() (any, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenString:
+		return t.text, nil
+	case t.kind == tokenNumber:
+		return parseRuleNumber(t.text)
+	case t.kind == tokenWord && (t.text == "true" || t.text == "false"):
+		return t.text == "true", nil
+	}
+	return nil, fmt.Errorf("expected value, got %q", t.text)
+}
+
+func (p *ruleParser) parseNumber() (float64, error) {
+	t := p.next()
+	if t.kind != tokenNumber {
+		return 0, fmt.Errorf("expected number, got %q", t.text)
+	}
+	return parseRuleNumber(t.text)
+}
+
+func parseRuleNumber(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+// numeric reports whether the clause's field holds a number
+func (c *ruleClause) numeric() bool {
+	switch c.fields[len(c.fields)-1].Kind() {
+	case protoreflect.BoolKind, protoreflect.StringKind, protoreflect.BytesKind:
+		return false
+	}
+	return true
+}
+
+// resolveRulePath resolves a dotted field path against the session or player message
+func resolveRulePath(path string) (*ruleClause, error) {
+	parts := strings.Split(path, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("field path %q must start with \"session.\" or \"player.\"", path)
+	}
+
+	clause := &ruleClause{path: path}
+	var md protoreflect.MessageDescriptor
+	switch parts[0] {
+	case "session":
+		clause.scope = ruleScopeSession
+		md = (&apigame.SessionResponse{}).ProtoReflect().Descriptor()
+	case "player":
+		clause.scope = ruleScopePlayer
+		md = (&apigame.TeamMember{}).ProtoReflect().Descriptor()
+	default:
+		return nil, fmt.Errorf("field path %q must start with \"session.\" or \"player.\"", path)
+	}
+
+	for i, name := range parts[1:] {
+		fields := md.Fields()
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("unknown field %q in %q", name, path)
+		}
+		if fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("field %q in %q is repeated", name, path)
+		}
+		clause.fields = append(clause.fields, fd)
+
+		last := i == len(parts)-2
+		if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			if last {
+				return nil, fmt.Errorf("field path %q ends at a message", path)
+			}
+			md = fd.Message()
+		} else if !last {
+			return nil, fmt.Errorf("field %q in %q is not a message", name, path)
+		}
+	}
+
+	return clause, nil
+}
@@ -3,6 +3,7 @@ package events
 import (
 	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 // DiscPossessionSensor detects disc possession changes
@@ -18,6 +19,34 @@ func NewDiscPossessionSensor() *DiscPossessionSensor {
 	}
 }
 
+// Reset clears the previous possessor
+func (s *DiscPossessionSensor) Reset() {
+	s.prevPossessorSlot = -1
+	s.initialized = false
+}
+
+// discPossessionState is the snapshot form of the possession-tracking disc sensors
+type discPossessionState struct {
+	PrevPossessorSlot int32
+	Initialized       bool
+}
+
+// Snapshot returns the previous possessor
+func (s *DiscPossessionSensor) Snapshot() ([]byte, error) {
+	return encodeSnapshot(discPossessionState{PrevPossessorSlot: s.prevPossessorSlot, Initialized: s.initialized})
+}
+
+// Restore replaces the previous possessor with a snapshot
+func (s *DiscPossessionSensor) Restore(data []byte) error {
+	var state discPossessionState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	s.prevPossessorSlot = state.PrevPossessorSlot
+	s.initialized = state.Initialized
+	return nil
+}
+
 // AddFrame processes a frame and returns a DiscPossessionChanged event if detected
 func (s *DiscPossessionSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -61,6 +90,42 @@ func NewDiscThrownSensor() *DiscThrownSensor {
 	}
 }
 
+// Reset clears the previous throw and possessor
+func (s *DiscThrownSensor) Reset() {
+	s.prevLastThrow = nil
+	s.prevPossessor = -1
+}
+
+// discThrownState is the snapshot form of DiscThrownSensor
+type discThrownState struct {
+	PrevLastThrow snapshotMessage
+	PrevPossessor int32
+}
+
+// Snapshot returns the previous throw and possessor
+func (s *DiscThrownSensor) Snapshot() ([]byte, error) {
+	lastThrow, err := newSnapshotMessage(s.prevLastThrow)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSnapshot(discThrownState{PrevLastThrow: lastThrow, PrevPossessor: s.prevPossessor})
+}
+
+// Restore replaces the previous throw and possessor with a snapshot
+func (s *DiscThrownSensor) Restore(data []byte) error {
+	var state discThrownState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	lastThrow, err := restoreSnapshotMessage(state.PrevLastThrow, func() *apigame.LastThrowInfo { return &apigame.LastThrowInfo{} })
+	if err != nil {
+		return err
+	}
+	s.prevLastThrow = lastThrow
+	s.prevPossessor = state.PrevPossessor
+	return nil
+}
+
 // AddFrame processes a frame and returns a DiscThrown event if detected
 func (s *DiscThrownSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -89,6 +154,8 @@ func (s *DiscThrownSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *te
 			throwerSlot = currentPossessor
 		}
 
+		// Pooled frames reuse their messages, so keep and send a copy
+		lastThrow = proto.CloneOf(lastThrow)
 		s.prevLastThrow = lastThrow
 		s.prevPossessor = currentPossessor
 
@@ -119,6 +186,28 @@ func NewDiscCaughtSensor() *DiscCaughtSensor {
 	}
 }
 
+// Reset clears the previous possessor
+func (s *DiscCaughtSensor) Reset() {
+	s.prevPossessorSlot = -1
+	s.initialized = false
+}
+
+// Snapshot returns the previous possessor
+func (s *DiscCaughtSensor) Snapshot() ([]byte, error) {
+	return encodeSnapshot(discPossessionState{PrevPossessorSlot: s.prevPossessorSlot, Initialized: s.initialized})
+}
+
+// Restore replaces the previous possessor with a snapshot
+func (s *DiscCaughtSensor) Restore(data []byte) error {
+	var state discPossessionState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	s.prevPossessorSlot = state.PrevPossessorSlot
+	s.initialized = state.Initialized
+	return nil
+}
+
 // AddFrame processes a frame and returns a DiscCaught event if detected
 func (s *DiscCaughtSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
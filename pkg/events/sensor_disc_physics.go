@@ -0,0 +1,382 @@
+package events
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom event names emitted by DiscPhysicsSensor
+const (
+	DiscBounceEventName = "disc_bounce"
+	NearMissEventName   = "near_miss"
+	LongThrowEventName  = "long_throw"
+)
+
+// DiscPhysicsSensor thresholds
+const (
+	// MinReflectionSpeed is the speed, in meters per second, a velocity
+	// component must have on both sides of a sign change to count as a reflection
+	MinReflectionSpeed = 1.0
+	// NearMissDistance is how close, in meters, a shot must pass to the goal to be a near miss
+	NearMissDistance = 3.0
+	// nearMissRecedeDistance is how far past its closest approach the disc must
+	// travel before a miss is reported, so a shot still closing in is not a miss
+	nearMissRecedeDistance = 2.0
+	// LongThrowDistance is the distance, in meters, from release to catch of a long throw
+	LongThrowDistance = 20.0
+	// lobHeight is how far above its release height a throw must rise to be a lob
+	lobHeight = 3.0
+)
+
+// ArenaGoalZ is the distance of each goal from the center of the arena along
+// the z axis. Blue defends the goal at negative z, orange the goal at positive z.
+const ArenaGoalZ = 36.0
+
+// Surfaces reported in the "surface" field of disc_bounce events
+const (
+	SurfaceSideWall = "side_wall"
+	SurfaceEndWall  = "end_wall"
+	SurfaceCeiling  = "ceiling"
+	SurfaceFloor    = "floor"
+	SurfaceUnknown  = "unknown"
+)
+
+// Throw types reported in the "throw_type" field of long_throw events
+const (
+	ThrowTypeDirect = "direct"
+	ThrowTypeBank   = "bank"
+	ThrowTypeLob    = "lob"
+)
+
+// discFlight is a free flight of the disc from a release to the next possession
+type discFlight struct {
+	ThrowerSlot     int32
+	ThrowerTeam     telemetry.Role
+	ReleasedAt      time.Time
+	ReleasePosition []float64
+	ReleaseSpeed    float64
+	PeakHeight      float64
+	Bounces         int32
+	// ClosestGoalDistance is the closest approach to the goal the thrower attacks
+	ClosestGoalDistance float64
+	NearMissReported    bool
+}
+
+// discPhysicsState is the snapshot form of DiscPhysicsSensor
+type discPhysicsState struct {
+	Initialized   bool
+	PrevAt        time.Time
+	PrevPosition  []float64
+	PrevVelocity  []float64
+	PrevBounces   int32
+	PrevPossessor int32
+	PrevTeam      telemetry.Role
+	PrevPlaying   bool
+	Flight        *discFlight
+}
+
+// DiscPhysicsSensor follows the disc's position and velocity to emit
+// disc_bounce events when its velocity is reflected by a wall, the ceiling or
+// the floor, near_miss events when a shot passes close to the goal it attacks
+// without scoring, and long_throw events for catches far from the release
+// point. Long throws are classified as direct, bank or lob from the observed
+// path, with the release speed taken from LastThrowInfo.
+//
+// The current disc state and projected trajectory are safe to query from any goroutine.
+type DiscPhysicsSensor struct {
+	mu    sync.Mutex
+	state discPhysicsState
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewDiscPhysicsSensor creates a new DiscPhysicsSensor
+func NewDiscPhysicsSensor() *DiscPhysicsSensor {
+	return &DiscPhysicsSensor{state: discPhysicsState{PrevPossessor: -1}}
+}
+
+// Reset clears the disc state, the current flight and any pending events
+func (s *DiscPhysicsSensor) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = discPhysicsState{PrevPossessor: -1}
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// DiscState returns the disc position and velocity from the latest frame, or false before the first frame
+func (s *DiscPhysicsSensor) DiscState() (position, velocity []float64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.state.Initialized {
+		return nil, nil, false
+	}
+	return slices.Clone(s.state.PrevPosition), slices.Clone(s.state.PrevVelocity), true
+}
+
+// ProjectedTrajectory returns the disc's projected positions at each step
+// over the horizon, starting one step after the latest frame. The arena has
+// no gravity, so the disc is projected along its current velocity; bounces
+// are not predicted.
+func (s *DiscPhysicsSensor) ProjectedTrajectory(horizon, step time.Duration) [][]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	position, velocity := s.state.PrevPosition, s.state.PrevVelocity
+	if len(position) < 3 || len(velocity) < 3 || step <= 0 {
+		return nil
+	}
+
+	var points [][]float64
+	for t := step; t <= horizon; t += step {
+		secs := t.Seconds()
+		points = append(points, []float64{
+			position[0] + velocity[0]*secs,
+			position[1] + velocity[1]*secs,
+			position[2] + velocity[2]*secs,
+		})
+	}
+	return points
+}
+
+// Snapshot returns the disc state and the current flight
+func (s *DiscPhysicsSensor) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return encodeSnapshot(s.state)
+}
+
+// Restore replaces the disc state and the current flight with a snapshot
+func (s *DiscPhysicsSensor) Restore(data []byte) error {
+	var state discPhysicsState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	s.pendingEvents = s.pendingEvents[:0]
+	return nil
+}
+
+// AddFrame processes a frame and returns bounce, near miss and long throw events
+func (s *DiscPhysicsSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if frame != nil && frame.GetSession() != nil {
+		s.processFrame(frame)
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// processFrame compares the disc with the previous frame and advances the current flight
+func (s *DiscPhysicsSensor) processFrame(frame *telemetry.LobbySessionStateFrame) {
+	at := frame.GetTimestamp().AsTime()
+	session := frame.GetSession()
+	disc := session.GetDisc()
+	position := slices.Clone(disc.GetPosition())
+	velocity := slices.Clone(disc.GetVelocity())
+	bounces := disc.GetBounceCount()
+	possessor := findPossessorSlot(session)
+	playing := session.GetGameStatus() == GameStatusPlaying
+
+	var possessorTeam telemetry.Role
+	if possessor != -1 {
+		possessorTeam = determinePlayerRole(extractPlayersMap(session)[possessor])
+	}
+
+	st := &s.state
+	if len(velocity) < 3 && st.Initialized && len(position) >= 3 && len(st.PrevPosition) >= 3 {
+		if dt := at.Sub(st.PrevAt).Seconds(); dt > 0 {
+			velocity = []float64{
+				(position[0] - st.PrevPosition[0]) / dt,
+				(position[1] - st.PrevPosition[1]) / dt,
+				(position[2] - st.PrevPosition[2]) / dt,
+			}
+		}
+	}
+
+	if st.Initialized {
+		switch {
+		case !playing:
+			// A goal or the end of the round ends the flight without a miss
+			st.Flight = nil
+
+		case st.Flight != nil && possessor != -1:
+			s.endFlight(session, possessor, possessorTeam, position, at)
+
+		case st.Flight == nil && possessor == -1 && st.PrevPossessor != -1 && st.PrevPlaying:
+			st.Flight = &discFlight{
+				ThrowerSlot:         st.PrevPossessor,
+				ThrowerTeam:         st.PrevTeam,
+				ReleasedAt:          at,
+				ReleasePosition:     slices.Clone(st.PrevPosition),
+				ReleaseSpeed:        session.GetLastThrow().GetTotalSpeed(),
+				ClosestGoalDistance: math.Inf(1),
+			}
+			if len(st.PrevPosition) >= 3 {
+				st.Flight.PeakHeight = st.PrevPosition[1]
+			}
+		}
+
+		if possessor == -1 && st.PrevPossessor == -1 && playing {
+			s.checkBounce(position, velocity, bounces)
+		}
+		if st.Flight != nil && possessor == -1 {
+			s.trackFlight(position)
+		}
+	}
+
+	st.Initialized = true
+	st.PrevAt = at
+	st.PrevPosition = position
+	st.PrevVelocity = velocity
+	st.PrevBounces = bounces
+	st.PrevPossessor = possessor
+	st.PrevTeam = possessorTeam
+	st.PrevPlaying = playing
+}
+
+// checkBounce emits a disc_bounce event when the disc's bounce counter goes
+// up or its velocity is reflected between two free frames
+func (s *DiscPhysicsSensor) checkBounce(position, velocity []float64, bounces int32) {
+	st := &s.state
+	surface, reflected := reflectionSurface(st.PrevVelocity, velocity)
+	if !reflected && bounces <= st.PrevBounces {
+		return
+	}
+
+	speedBefore, _ := vecLength(st.PrevVelocity)
+	speedAfter, _ := vecLength(velocity)
+	fields := map[string]any{
+		"surface":      surface,
+		"speed_before": speedBefore,
+		"speed_after":  speedAfter,
+	}
+	if len(position) >= 3 {
+		fields["position"] = []any{position[0], position[1], position[2]}
+	}
+	if st.Flight != nil {
+		st.Flight.Bounces++
+		fields["player_slot"] = st.Flight.ThrowerSlot
+		fields["team"] = st.Flight.ThrowerTeam.String()
+	}
+	s.pendingEvents = append(s.pendingEvents, newCustomEvent(DiscBounceEventName, fields))
+}
+
+// trackFlight updates the flight's peak height and closest approach to the
+// goal, and emits a near_miss event once the disc recedes from a close pass
+func (s *DiscPhysicsSensor) trackFlight(position []float64) {
+	flight := s.state.Flight
+	if len(position) < 3 {
+		return
+	}
+	flight.PeakHeight = max(flight.PeakHeight, position[1])
+
+	goal, ok := attackedGoal(flight.ThrowerTeam)
+	if !ok || flight.NearMissReported {
+		return
+	}
+	d, _ := vecDistance(position, goal)
+	if d < flight.ClosestGoalDistance {
+		flight.ClosestGoalDistance = d
+		return
+	}
+	if flight.ClosestGoalDistance > NearMissDistance || d < flight.ClosestGoalDistance+nearMissRecedeDistance {
+		return
+	}
+
+	flight.NearMissReported = true
+	s.pendingEvents = append(s.pendingEvents, newCustomEvent(NearMissEventName, map[string]any{
+		"player_slot":      flight.ThrowerSlot,
+		"team":             flight.ThrowerTeam.String(),
+		"closest_distance": flight.ClosestGoalDistance,
+		"release_speed":    flight.ReleaseSpeed,
+	}))
+}
+
+// endFlight closes the current flight on a catch, emitting a long_throw event if it went far enough
+func (s *DiscPhysicsSensor) endFlight(session *apigame.SessionResponse, catcher int32, catcherTeam telemetry.Role, position []float64, at time.Time) {
+	flight := s.state.Flight
+	s.state.Flight = nil
+
+	distance, ok := vecDistance(flight.ReleasePosition, position)
+	if !ok || distance < LongThrowDistance {
+		return
+	}
+
+	throwType := ThrowTypeDirect
+	switch {
+	case flight.Bounces > 0:
+		throwType = ThrowTypeBank
+	case len(flight.ReleasePosition) >= 3 && flight.PeakHeight-flight.ReleasePosition[1] >= lobHeight:
+		throwType = ThrowTypeLob
+	}
+
+	s.pendingEvents = append(s.pendingEvents, newCustomEvent(LongThrowEventName, map[string]any{
+		"player_slot":   flight.ThrowerSlot,
+		"team":          flight.ThrowerTeam.String(),
+		"catcher_slot":  catcher,
+		"completed":     catcherTeam == flight.ThrowerTeam,
+		"distance":      distance,
+		"flight_time":   at.Sub(flight.ReleasedAt).Seconds(),
+		"release_speed": flight.ReleaseSpeed,
+		"bounces":       flight.Bounces,
+		"throw_type":    throwType,
+	}))
+}
+
+// reflectionSurface returns the surface that reflected the disc between two
+// velocities: the axis with the largest reversal, where both sides are at
+// least MinReflectionSpeed. It returns SurfaceUnknown and false if no
+// component was reflected.
+func reflectionSurface(prev, cur []float64) (string, bool) {
+	if len(prev) < 3 || len(cur) < 3 {
+		return SurfaceUnknown, false
+	}
+
+	axis, best := -1, 0.0
+	for i := 0; i < 3; i++ {
+		if prev[i]*cur[i] >= 0 || math.Abs(prev[i]) < MinReflectionSpeed || math.Abs(cur[i]) < MinReflectionSpeed {
+			continue
+		}
+		if change := math.Abs(prev[i] - cur[i]); change > best {
+			axis, best = i, change
+		}
+	}
+
+	switch axis {
+	case 0:
+		return SurfaceSideWall, true
+	case 1:
+		if prev[1] > 0 {
+			return SurfaceCeiling, true
+		}
+		return SurfaceFloor, true
+	case 2:
+		return SurfaceEndWall, true
+	}
+	return SurfaceUnknown, false
+}
+
+// attackedGoal returns the center of the goal the team scores on
+func attackedGoal(team telemetry.Role) ([]float64, bool) {
+	switch team {
+	case telemetry.Role_ROLE_BLUE_TEAM:
+		return []float64{0, 0, ArenaGoalZ}, true
+	case telemetry.Role_ROLE_ORANGE_TEAM:
+		return []float64{0, 0, -ArenaGoalZ}, true
+	}
+	return nil, false
+}
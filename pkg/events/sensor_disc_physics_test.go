@@ -0,0 +1,211 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// discPhysicsFrame describes a test frame for DiscPhysicsSensor
+type discPhysicsFrame struct {
+	at        time.Duration
+	status    string
+	position  []float64
+	velocity  []float64
+	bounces   int32
+	possessor int32
+}
+
+// Helper to create a frame with a blue player in slot 0 and an orange player in slot 4
+func createDiscPhysicsFrame(f discPhysicsFrame) *telemetry.LobbySessionStateFrame {
+	if f.status == "" {
+		f.status = GameStatusPlaying
+	}
+	player := func(slot int32) *apigame.TeamMember {
+		return &apigame.TeamMember{SlotNumber: slot, HasPossession: slot == f.possessor}
+	}
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(f.at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: f.status,
+			Disc:       &apigame.Disc{Position: f.position, Velocity: f.velocity, BounceCount: f.bounces},
+			LastThrow:  &apigame.LastThrowInfo{TotalSpeed: 12},
+			Teams: []*apigame.Team{
+				{Players: []*apigame.TeamMember{player(0)}},
+				{Players: []*apigame.TeamMember{player(4)}},
+			},
+		},
+	}
+}
+
+// Helper to create a frame for each discPhysicsFrame
+func createDiscPhysicsFrames(fs ...discPhysicsFrame) []*telemetry.LobbySessionStateFrame {
+	frames := make([]*telemetry.LobbySessionStateFrame, len(fs))
+	for i, f := range fs {
+		frames[i] = createDiscPhysicsFrame(f)
+	}
+	return frames
+}
+
+func TestDiscPhysicsSensor_WallBounceAndBankThrow(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+
+	events := collectCustomEvents(t, sensor, createDiscPhysicsFrames(
+		discPhysicsFrame{at: 0, position: []float64{0, 0, 0}, velocity: []float64{0, 0, 0}, possessor: 0},
+		discPhysicsFrame{at: 1 * time.Second, position: []float64{5, 0, 10}, velocity: []float64{5, 0, 10}, possessor: -1},
+		discPhysicsFrame{at: 2 * time.Second, position: []float64{10, 0, 20}, velocity: []float64{5, 0, 10}, possessor: -1},
+		discPhysicsFrame{at: 3 * time.Second, position: []float64{6, 0, 30}, velocity: []float64{-4, 0, 10}, possessor: -1, bounces: 1},
+		discPhysicsFrame{at: 4 * time.Second, position: []float64{2, 0, 25}, velocity: []float64{0, 0, 0}, possessor: 0},
+	)...)
+
+	if len(events) != 2 {
+		t.Fatalf("expected a bounce and a long throw, got %v", events)
+	}
+	bounce := events[0]
+	if bounce.Name != DiscBounceEventName || bounce.Fields["surface"] != SurfaceSideWall || bounce.Fields["player_slot"] != float64(0) {
+		t.Errorf("expected a side wall bounce of slot 0's throw, got %v", bounce)
+	}
+
+	throw := events[1]
+	if throw.Name != LongThrowEventName || throw.Fields["throw_type"] != ThrowTypeBank {
+		t.Fatalf("expected a bank long_throw, got %v", throw)
+	}
+	if throw.Fields["catcher_slot"] != float64(0) || throw.Fields["completed"] != true || throw.Fields["release_speed"] != 12.0 {
+		t.Errorf("unexpected long_throw fields: %v", throw.Fields)
+	}
+	if throw.Fields["flight_time"] != 3.0 {
+		t.Errorf("expected a 3s flight, got %v", throw.Fields["flight_time"])
+	}
+}
+
+func TestDiscPhysicsSensor_CeilingBounce(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+
+	events := collectCustomEvents(t, sensor, createDiscPhysicsFrames(
+		discPhysicsFrame{at: 0, position: []float64{0, 5, 0}, velocity: []float64{0, 4, 1}, possessor: -1},
+		discPhysicsFrame{at: 1 * time.Second, position: []float64{0, 8, 1}, velocity: []float64{0, -3, 1}, possessor: -1},
+	)...)
+	if len(events) != 1 || events[0].Fields["surface"] != SurfaceCeiling {
+		t.Fatalf("expected a ceiling bounce, got %v", events)
+	}
+	if _, ok := events[0].Fields["player_slot"]; ok {
+		t.Errorf("expected no thrower for a loose disc, got %v", events[0].Fields)
+	}
+}
+
+func TestDiscPhysicsSensor_NearMiss(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+
+	// Blue shoots at the orange goal and passes 2m wide
+	events := collectCustomEvents(t, sensor, createDiscPhysicsFrames(
+		discPhysicsFrame{at: 0, position: []float64{2, 0, 20}, velocity: []float64{0, 0, 0}, possessor: 0},
+		discPhysicsFrame{at: 1 * time.Second, position: []float64{2, 0, 28}, velocity: []float64{0, 0, 8}, possessor: -1},
+		discPhysicsFrame{at: 2 * time.Second, position: []float64{2, 0, 36}, velocity: []float64{0, 0, 8}, possessor: -1},
+		discPhysicsFrame{at: 2500 * time.Millisecond, position: []float64{2, 0, 38}, velocity: []float64{0, 0, 4}, possessor: -1},
+		discPhysicsFrame{at: 3 * time.Second, position: []float64{2, 0, 40}, velocity: []float64{0, 0, 4}, possessor: -1},
+		discPhysicsFrame{at: 4 * time.Second, position: []float64{2, 0, 41}, velocity: []float64{0, 0, 1}, possessor: -1},
+	)...)
+	if len(events) != 1 || events[0].Name != NearMissEventName {
+		t.Fatalf("expected one near_miss event, got %v", events)
+	}
+	if events[0].Fields["closest_distance"] != 2.0 || events[0].Fields["team"] != "ROLE_BLUE_TEAM" {
+		t.Errorf("unexpected near_miss fields: %v", events[0].Fields)
+	}
+}
+
+func TestDiscPhysicsSensor_GoalIsNotNearMiss(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+
+	events := collectCustomEvents(t, sensor, createDiscPhysicsFrames(
+		discPhysicsFrame{at: 0, position: []float64{0, 0, 20}, possessor: 0},
+		discPhysicsFrame{at: 1 * time.Second, position: []float64{0, 0, 30}, velocity: []float64{0, 0, 10}, possessor: -1},
+		discPhysicsFrame{at: 2 * time.Second, position: []float64{0, 0, 36}, velocity: []float64{0, 0, 10}, possessor: -1, status: GameStatusScore},
+		discPhysicsFrame{at: 3 * time.Second, position: []float64{0, 0, 42}, velocity: []float64{0, 0, 10}, possessor: -1, status: GameStatusScore},
+	)...)
+	if len(events) != 0 {
+		t.Errorf("expected no events for a goal, got %v", events)
+	}
+}
+
+func TestDiscPhysicsSensor_ShortPassIsNotLongThrow(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+
+	events := collectCustomEvents(t, sensor, createDiscPhysicsFrames(
+		discPhysicsFrame{at: 0, position: []float64{0, 0, 0}, possessor: 0},
+		discPhysicsFrame{at: 1 * time.Second, position: []float64{0, 0, 5}, velocity: []float64{0, 0, 5}, possessor: -1},
+		discPhysicsFrame{at: 2 * time.Second, position: []float64{0, 0, 10}, possessor: 4},
+	)...)
+	if len(events) != 0 {
+		t.Errorf("expected no events for a short pass, got %v", events)
+	}
+}
+
+func TestDiscPhysicsSensor_ProjectedTrajectory(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+	if points := sensor.ProjectedTrajectory(time.Second, 500*time.Millisecond); points != nil {
+		t.Errorf("expected no trajectory before the first frame, got %v", points)
+	}
+
+	collectCustomEvents(t, sensor, createDiscPhysicsFrames(discPhysicsFrame{position: []float64{1, 2, 3}, velocity: []float64{2, 0, -4}, possessor: -1})...)
+
+	points := sensor.ProjectedTrajectory(time.Second, 500*time.Millisecond)
+	want := [][]float64{{2, 2, 1}, {3, 2, -1}}
+	if len(points) != len(want) {
+		t.Fatalf("expected %d points, got %v", len(want), points)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if points[i][j] != want[i][j] {
+				t.Errorf("point %d: expected %v, got %v", i, want[i], points[i])
+			}
+		}
+	}
+}
+
+func TestDiscPhysicsSensor_DerivesVelocityFromPositions(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+	collectCustomEvents(t, sensor, createDiscPhysicsFrames(
+		discPhysicsFrame{at: 0, position: []float64{0, 0, 0}, possessor: -1},
+		discPhysicsFrame{at: 2 * time.Second, position: []float64{4, 0, 2}, possessor: -1},
+	)...)
+
+	_, velocity, ok := sensor.DiscState()
+	if !ok || len(velocity) != 3 || velocity[0] != 2 || velocity[2] != 1 {
+		t.Errorf("expected a derived velocity of (2, 0, 1), got %v", velocity)
+	}
+}
+
+func TestDiscPhysicsSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+	collectCustomEvents(t, sensor, createDiscPhysicsFrames(
+		discPhysicsFrame{at: 0, position: []float64{0, 0, 0}, possessor: 0},
+		discPhysicsFrame{at: 1 * time.Second, position: []float64{0, 0, 10}, velocity: []float64{0, 0, 10}, possessor: -1},
+	)...)
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewDiscPhysicsSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	events := collectCustomEvents(t, restored, createDiscPhysicsFrames(discPhysicsFrame{at: 3 * time.Second, position: []float64{0, 0, 25}, possessor: 0})...)
+	if len(events) != 1 || events[0].Name != LongThrowEventName || events[0].Fields["flight_time"] != 2.0 {
+		t.Errorf("expected the restored flight to end in a long throw, got %v", events)
+	}
+}
+
+func TestDiscPhysicsSensor_Reset(t *testing.T) {
+	sensor := NewDiscPhysicsSensor()
+	collectCustomEvents(t, sensor, createDiscPhysicsFrames(discPhysicsFrame{position: []float64{1, 2, 3}, velocity: []float64{1, 1, 1}, possessor: -1})...)
+
+	sensor.Reset()
+	if _, _, ok := sensor.DiscState(); ok {
+		t.Error("expected Reset to clear the disc state")
+	}
+}
@@ -15,13 +15,52 @@ const (
 
 // RoundStartSensor detects when a round starts
 type RoundStartSensor struct {
-	prevGameStatus string
-	roundNumber    int32
+	phases      *MatchPhaseTracker
+	roundNumber int32
 }
 
-// NewRoundStartSensor creates a new RoundStartSensor
+// NewRoundStartSensor creates a new RoundStartSensor with its own MatchPhaseTracker
 func NewRoundStartSensor() *RoundStartSensor {
-	return &RoundStartSensor{}
+	return NewRoundStartSensorWithPhases(NewMatchPhaseTracker())
+}
+
+// NewRoundStartSensorWithPhases creates a new RoundStartSensor that shares the given tracker
+func NewRoundStartSensorWithPhases(phases *MatchPhaseTracker) *RoundStartSensor {
+	return &RoundStartSensor{phases: phases}
+}
+
+// Reset clears the phase tracker and round number
+func (s *RoundStartSensor) Reset() {
+	s.phases.Reset()
+	s.roundNumber = 0
+}
+
+// roundStartState is the snapshot form of RoundStartSensor
+type roundStartState struct {
+	Phases      []byte
+	RoundNumber int32
+}
+
+// Snapshot returns the phase tracker state and round number
+func (s *RoundStartSensor) Snapshot() ([]byte, error) {
+	phases, err := s.phases.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return encodeSnapshot(roundStartState{Phases: phases, RoundNumber: s.roundNumber})
+}
+
+// Restore replaces the phase tracker state and round number with a snapshot
+func (s *RoundStartSensor) Restore(data []byte) error {
+	var state roundStartState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	if err := s.phases.Restore(state.Phases); err != nil {
+		return err
+	}
+	s.roundNumber = state.RoundNumber
+	return nil
 }
 
 // AddFrame processes a frame and returns a RoundStarted event if detected
@@ -30,39 +69,58 @@ func (s *RoundStartSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *te
 		return nil
 	}
 
-	currentStatus := frame.GetSession().GetGameStatus()
+	transition, ok := s.phases.Update(frame)
+	if !ok || (transition.To != PhaseRoundStart && transition.To != PhasePlaying) {
+		return nil
+	}
 
-	// Detect transition to round_start or playing from a non-playing state
-	if (currentStatus == GameStatusRoundStart || currentStatus == GameStatusPlaying) &&
-		s.prevGameStatus != GameStatusPlaying && s.prevGameStatus != GameStatusRoundStart &&
-		s.prevGameStatus != "" {
+	// Entering play from round_start, or resuming after a pause, is not a new round
+	switch transition.From {
+	case PhaseUnknown, PhaseRoundStart, PhasePlaying, PhasePaused, PhaseUnpausing:
+		return nil
+	}
 
-		// Calculate round number from round scores
-		session := frame.GetSession()
-		s.roundNumber = session.GetBlueRoundScore() + session.GetOrangeRoundScore() + 1
+	// Calculate round number from round scores
+	session := frame.GetSession()
+	s.roundNumber = session.GetBlueRoundScore() + session.GetOrangeRoundScore() + 1
 
-		s.prevGameStatus = currentStatus
-		return &telemetry.LobbySessionEvent{
-			Event: &telemetry.LobbySessionEvent_RoundStarted{
-				RoundStarted: &telemetry.RoundStarted{
-					RoundNumber: s.roundNumber,
-				},
+	return &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_RoundStarted{
+			RoundStarted: &telemetry.RoundStarted{
+				RoundNumber: s.roundNumber,
 			},
-		}
+		},
 	}
-
-	s.prevGameStatus = currentStatus
-	return nil
 }
 
 // PauseSensor detects pause/unpause events
 type PauseSensor struct {
-	prevPauseState string
+	phases *MatchPhaseTracker
 }
 
-// NewPauseSensor creates a new PauseSensor
+// NewPauseSensor creates a new PauseSensor with its own MatchPhaseTracker
 func NewPauseSensor() *PauseSensor {
-	return &PauseSensor{}
+	return NewPauseSensorWithPhases(NewMatchPhaseTracker())
+}
+
+// NewPauseSensorWithPhases creates a new PauseSensor that shares the given tracker
+func NewPauseSensorWithPhases(phases *MatchPhaseTracker) *PauseSensor {
+	return &PauseSensor{phases: phases}
+}
+
+// Reset clears the phase tracker
+func (s *PauseSensor) Reset() {
+	s.phases.Reset()
+}
+
+// Snapshot returns the phase tracker state
+func (s *PauseSensor) Snapshot() ([]byte, error) {
+	return s.phases.Snapshot()
+}
+
+// Restore replaces the phase tracker state with a snapshot
+func (s *PauseSensor) Restore(data []byte) error {
+	return s.phases.Restore(data)
 }
 
 // AddFrame processes a frame and returns pause-related events
@@ -71,42 +129,34 @@ func (s *PauseSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemet
 		return nil
 	}
 
-	pause := frame.GetSession().GetPause()
-	if pause == nil {
-		s.prevPauseState = ""
+	transition, ok := s.phases.Update(frame)
+	if !ok {
 		return nil
 	}
+	pause := frame.GetSession().GetPause()
 
-	currentState := pause.GetPausedState()
-
-	// Detect transitions
-	if currentState != s.prevPauseState {
-		defer func() { s.prevPauseState = currentState }()
-
-		// Transition to paused state
-		if isPausedState(currentState) && !isPausedState(s.prevPauseState) {
-			return &telemetry.LobbySessionEvent{
-				Event: &telemetry.LobbySessionEvent_RoundPaused{
-					RoundPaused: &telemetry.RoundPaused{
-						PauseState: pause,
-					},
+	switch {
+	// Transition to paused state
+	case transition.To == PhasePaused:
+		return &telemetry.LobbySessionEvent{
+			Event: &telemetry.LobbySessionEvent_RoundPaused{
+				RoundPaused: &telemetry.RoundPaused{
+					PauseState: pause,
 				},
-			}
+			},
 		}
 
-		// Transition from paused to unpaused
-		if !isPausedState(currentState) && isPausedState(s.prevPauseState) {
-			return &telemetry.LobbySessionEvent{
-				Event: &telemetry.LobbySessionEvent_RoundUnpaused{
-					RoundUnpaused: &telemetry.RoundUnpaused{
-						PauseState: pause,
-					},
+	// Transition from paused to unpausing or back into the match
+	case transition.From == PhasePaused:
+		return &telemetry.LobbySessionEvent{
+			Event: &telemetry.LobbySessionEvent_RoundUnpaused{
+				RoundUnpaused: &telemetry.RoundUnpaused{
+					PauseState: pause,
 				},
-			}
+			},
 		}
 	}
 
-	s.prevPauseState = currentState
 	return nil
 }
 
@@ -117,15 +167,65 @@ func isPausedState(state string) bool {
 
 // RoundEndSensor detects when a round ends (separate from match end)
 type RoundEndSensor struct {
-	prevGameStatus       string
+	phases               *MatchPhaseTracker
 	prevBlueRoundScore   int32
 	prevOrangeRoundScore int32
 	initialized          bool
 }
 
-// NewRoundEndSensor creates a new RoundEndSensor
+// NewRoundEndSensor creates a new RoundEndSensor with its own MatchPhaseTracker
 func NewRoundEndSensor() *RoundEndSensor {
-	return &RoundEndSensor{}
+	return NewRoundEndSensorWithPhases(NewMatchPhaseTracker())
+}
+
+// NewRoundEndSensorWithPhases creates a new RoundEndSensor that shares the given tracker
+func NewRoundEndSensorWithPhases(phases *MatchPhaseTracker) *RoundEndSensor {
+	return &RoundEndSensor{phases: phases}
+}
+
+// Reset clears the phase tracker and round scores
+func (s *RoundEndSensor) Reset() {
+	s.phases.Reset()
+	s.prevBlueRoundScore = 0
+	s.prevOrangeRoundScore = 0
+	s.initialized = false
+}
+
+// roundEndState is the snapshot form of RoundEndSensor
+type roundEndState struct {
+	Phases               []byte
+	PrevBlueRoundScore   int32
+	PrevOrangeRoundScore int32
+	Initialized          bool
+}
+
+// Snapshot returns the phase tracker state and round scores
+func (s *RoundEndSensor) Snapshot() ([]byte, error) {
+	phases, err := s.phases.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return encodeSnapshot(roundEndState{
+		Phases:               phases,
+		PrevBlueRoundScore:   s.prevBlueRoundScore,
+		PrevOrangeRoundScore: s.prevOrangeRoundScore,
+		Initialized:          s.initialized,
+	})
+}
+
+// Restore replaces the phase tracker state and round scores with a snapshot
+func (s *RoundEndSensor) Restore(data []byte) error {
+	var state roundEndState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	if err := s.phases.Restore(state.Phases); err != nil {
+		return err
+	}
+	s.prevBlueRoundScore = state.PrevBlueRoundScore
+	s.prevOrangeRoundScore = state.PrevOrangeRoundScore
+	s.initialized = state.Initialized
+	return nil
 }
 
 // AddFrame processes a frame and returns a RoundEnded event if detected
@@ -135,24 +235,29 @@ func (s *RoundEndSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *tele
 	}
 
 	session := frame.GetSession()
-	currentStatus := session.GetGameStatus()
 	blueRound := session.GetBlueRoundScore()
 	orangeRound := session.GetOrangeRoundScore()
 
+	prevPhase := s.phases.Phase()
+	transition, changed := s.phases.Update(frame)
+	if changed {
+		prevPhase = transition.From
+	}
+
 	if !s.initialized {
-		s.prevGameStatus = currentStatus
 		s.prevBlueRoundScore = blueRound
 		s.prevOrangeRoundScore = orangeRound
 		s.initialized = true
 		return nil
 	}
 
-	// Detect round end by transition to "round_over" or "score" status,
-	// OR by a change in round scores
+	// Detect round end by transition to round_over,
+	// OR by a change in round scores while the round was in play
 	roundScoreChanged := blueRound != s.prevBlueRoundScore || orangeRound != s.prevOrangeRoundScore
-	statusTransitionToRoundOver := currentStatus == GameStatusRoundOver && s.prevGameStatus != GameStatusRoundOver
+	enteredRoundOver := changed && transition.To == PhaseRoundOver
+	inPlay := prevPhase == PhasePlaying || prevPhase == PhaseOvertime
 
-	if statusTransitionToRoundOver || (roundScoreChanged && s.prevGameStatus == GameStatusPlaying) {
+	if enteredRoundOver || (roundScoreChanged && inPlay) {
 		roundNumber := s.prevBlueRoundScore + s.prevOrangeRoundScore + 1
 		var winningTeam telemetry.Role
 
@@ -162,7 +267,6 @@ func (s *RoundEndSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *tele
 			winningTeam = telemetry.Role_ROLE_ORANGE_TEAM
 		}
 
-		s.prevGameStatus = currentStatus
 		s.prevBlueRoundScore = blueRound
 		s.prevOrangeRoundScore = orangeRound
 
@@ -176,7 +280,6 @@ func (s *RoundEndSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *tele
 		}
 	}
 
-	s.prevGameStatus = currentStatus
 	s.prevBlueRoundScore = blueRound
 	s.prevOrangeRoundScore = orangeRound
 	return nil
@@ -184,12 +287,32 @@ func (s *RoundEndSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *tele
 
 // MatchEndSensor detects when a match ends
 type MatchEndSensor struct {
-	prevGameStatus string
+	phases *MatchPhaseTracker
 }
 
-// NewMatchEndSensor creates a new MatchEndSensor
+// NewMatchEndSensor creates a new MatchEndSensor with its own MatchPhaseTracker
 func NewMatchEndSensor() *MatchEndSensor {
-	return &MatchEndSensor{}
+	return NewMatchEndSensorWithPhases(NewMatchPhaseTracker())
+}
+
+// NewMatchEndSensorWithPhases creates a new MatchEndSensor that shares the given tracker
+func NewMatchEndSensorWithPhases(phases *MatchPhaseTracker) *MatchEndSensor {
+	return &MatchEndSensor{phases: phases}
+}
+
+// Reset clears the phase tracker
+func (s *MatchEndSensor) Reset() {
+	s.phases.Reset()
+}
+
+// Snapshot returns the phase tracker state
+func (s *MatchEndSensor) Snapshot() ([]byte, error) {
+	return s.phases.Snapshot()
+}
+
+// Restore replaces the phase tracker state with a snapshot
+func (s *MatchEndSensor) Restore(data []byte) error {
+	return s.phases.Restore(data)
 }
 
 // AddFrame processes a frame and returns a MatchEnded event if detected
@@ -198,29 +321,26 @@ func (s *MatchEndSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *tele
 		return nil
 	}
 
-	session := frame.GetSession()
-	currentStatus := session.GetGameStatus()
-
 	// Detect transition to post_match
-	if currentStatus == GameStatusPostMatch && s.prevGameStatus != GameStatusPostMatch {
-		var winningTeam telemetry.Role
-		if session.GetBluePoints() > session.GetOrangePoints() {
-			winningTeam = telemetry.Role_ROLE_BLUE_TEAM
-		} else if session.GetOrangePoints() > session.GetBluePoints() {
-			winningTeam = telemetry.Role_ROLE_ORANGE_TEAM
-		}
-		// If tied, leave as ROLE_UNSPECIFIED
+	transition, ok := s.phases.Update(frame)
+	if !ok || transition.To != PhasePostMatch {
+		return nil
+	}
 
-		s.prevGameStatus = currentStatus
-		return &telemetry.LobbySessionEvent{
-			Event: &telemetry.LobbySessionEvent_MatchEnded{
-				MatchEnded: &telemetry.MatchEnded{
-					WinningTeam: winningTeam,
-				},
-			},
-		}
+	session := frame.GetSession()
+	var winningTeam telemetry.Role
+	if session.GetBluePoints() > session.GetOrangePoints() {
+		winningTeam = telemetry.Role_ROLE_BLUE_TEAM
+	} else if session.GetOrangePoints() > session.GetBluePoints() {
+		winningTeam = telemetry.Role_ROLE_ORANGE_TEAM
 	}
+	// If tied, leave as ROLE_UNSPECIFIED
 
-	s.prevGameStatus = currentStatus
-	return nil
+	return &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_MatchEnded{
+			MatchEnded: &telemetry.MatchEnded{
+				WinningTeam: winningTeam,
+			},
+		},
+	}
 }
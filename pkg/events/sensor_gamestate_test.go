@@ -83,6 +83,18 @@ func TestRoundStartSensor_NoEventIfAlreadyPlaying(t *testing.T) {
 	}
 }
 
+func TestRoundStartSensor_NoEventOnResume(t *testing.T) {
+	sensor := NewRoundStartSensor()
+
+	sensor.AddFrame(createGameStateFrame(GameStatusPlaying, 0, 0))
+	sensor.AddFrame(createGameStateFrame(GameStatusPaused, 0, 0))
+	event := sensor.AddFrame(createGameStateFrame(GameStatusPlaying, 0, 0))
+
+	if event != nil {
+		t.Fatalf("expected no event when resuming from a pause, got %v", event)
+	}
+}
+
 // PauseSensor Tests
 
 func TestPauseSensor_DetectsPause(t *testing.T) {
@@ -0,0 +1,297 @@
+package events
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// GoalAttributionEventName is the custom event name emitted by GoalAttributionSensor
+const GoalAttributionEventName = "goal_attribution"
+
+// MaxGoalAssists is the most assisters credited on a single goal
+const MaxGoalAssists = 2
+
+// maxPossessionHistory bounds the number of possessions GoalAttributionSensor keeps
+const maxPossessionHistory = 128
+
+// GoalAttribution is a goal linked to the play that led to it
+type GoalAttribution struct {
+	ScorerSlot int32
+	ScorerName string
+	Team       telemetry.Role
+	// AssistSlots are the assisters, most recent first
+	AssistSlots []int32
+	// PassChain is every player who held the disc since the scoring team gained possession, in order
+	PassChain []int32
+	// PossessionTime is the time from the team gaining possession to the shot
+	PossessionTime time.Duration
+	ShotDistance   float64
+	DiscSpeed      float64
+	GoalType       string
+	Points         int32
+}
+
+// possessionSpan is a continuous possession of the disc by one player
+type possessionSpan struct {
+	Slot  int32
+	Team  telemetry.Role
+	Start time.Time
+	End   time.Time
+}
+
+// GoalAttributionSensor links each goal to the possessions that led to it.
+// It keeps a rolling history of possessions and, when LastScore changes,
+// emits one event with the scorer, assisters, pass chain, possession time
+// and the shot details from LastScore.
+type GoalAttributionSensor struct {
+	history       []possessionSpan
+	prevPossessor int32
+	prevLastScore *apigame.LastScore
+	initialized   bool
+	lastGoal      *GoalAttribution
+}
+
+// NewGoalAttributionSensor creates a new GoalAttributionSensor
+func NewGoalAttributionSensor() *GoalAttributionSensor {
+	return &GoalAttributionSensor{prevPossessor: -1}
+}
+
+// Reset clears the possession history and the last goal
+func (s *GoalAttributionSensor) Reset() {
+	*s = GoalAttributionSensor{prevPossessor: -1}
+}
+
+// LastGoal returns the most recently attributed goal, or nil if none has been scored
+func (s *GoalAttributionSensor) LastGoal() *GoalAttribution {
+	return s.lastGoal
+}
+
+// goalAttributionState is the snapshot form of GoalAttributionSensor
+type goalAttributionState struct {
+	History       []possessionSpan
+	PrevPossessor int32
+	PrevLastScore snapshotMessage
+	Initialized   bool
+	LastGoal      *GoalAttribution
+}
+
+// Snapshot returns the possession history and the last goal
+func (s *GoalAttributionSensor) Snapshot() ([]byte, error) {
+	m, err := newSnapshotMessage(s.prevLastScore)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSnapshot(goalAttributionState{
+		History:       s.history,
+		PrevPossessor: s.prevPossessor,
+		PrevLastScore: m,
+		Initialized:   s.initialized,
+		LastGoal:      s.lastGoal,
+	})
+}
+
+// Restore replaces the possession history and the last goal with a snapshot
+func (s *GoalAttributionSensor) Restore(data []byte) error {
+	var state goalAttributionState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	lastScore, err := restoreSnapshotMessage(state.PrevLastScore, func() *apigame.LastScore { return &apigame.LastScore{} })
+	if err != nil {
+		return err
+	}
+	*s = GoalAttributionSensor{
+		history:       state.History,
+		prevPossessor: state.PrevPossessor,
+		prevLastScore: lastScore,
+		initialized:   state.Initialized,
+		lastGoal:      state.LastGoal,
+	}
+	return nil
+}
+
+// AddFrame processes a frame and returns a goal attribution custom event when a goal is scored
+func (s *GoalAttributionSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return nil
+	}
+
+	session := frame.GetSession()
+	at := frame.GetTimestamp().AsTime()
+
+	// Each round starts with a fresh joust
+	if session.GetGameStatus() == GameStatusRoundStart {
+		s.history = s.history[:0]
+	}
+	s.trackPossession(session, at)
+
+	lastScore := session.GetLastScore()
+	if !s.initialized {
+		// A score from before we started watching cannot be attributed
+		s.prevLastScore = proto.CloneOf(lastScore)
+		s.initialized = true
+		return nil
+	}
+	if lastScore == nil {
+		s.prevLastScore = nil
+		return nil
+	}
+	if lastScoreEqual(s.prevLastScore, lastScore) {
+		return nil
+	}
+	// Pooled frames reuse their messages, so keep a copy
+	s.prevLastScore = proto.CloneOf(lastScore)
+
+	goal := s.attribute(session, lastScore)
+	s.lastGoal = goal
+	s.history = s.history[:0]
+	return goal.event()
+}
+
+// trackPossession extends the current possession or starts a new one
+func (s *GoalAttributionSensor) trackPossession(session *apigame.SessionResponse, at time.Time) {
+	slot := findPossessorSlot(session)
+	prev := s.prevPossessor
+	s.prevPossessor = slot
+	if slot == -1 {
+		return
+	}
+
+	if slot == prev && len(s.history) > 0 {
+		s.history[len(s.history)-1].End = at
+		return
+	}
+
+	if len(s.history) == maxPossessionHistory {
+		s.history = append(s.history[:0], s.history[1:]...)
+	}
+	s.history = append(s.history, possessionSpan{
+		Slot:  slot,
+		Team:  determinePlayerRole(extractPlayersMap(session)[slot]),
+		Start: at,
+		End:   at,
+	})
+}
+
+// attribute builds the attribution for a goal from the possession history
+func (s *GoalAttributionSensor) attribute(session *apigame.SessionResponse, lastScore *apigame.LastScore) *GoalAttribution {
+	players := extractPlayersMap(session)
+	goal := &GoalAttribution{
+		ScorerSlot:   -1,
+		ScorerName:   lastScore.GetPersonScored(),
+		Team:         roleFromTeamName(lastScore.GetTeam()),
+		ShotDistance: lastScore.GetDistanceThrown(),
+		DiscSpeed:    lastScore.GetDiscSpeed(),
+		GoalType:     lastScore.GetGoalType(),
+		Points:       lastScore.GetPointAmount(),
+	}
+
+	scorer, scorerFound := findSlotByName(players, goal.ScorerName)
+	if scorerFound {
+		goal.ScorerSlot = scorer
+		if goal.Team == telemetry.Role_ROLE_UNSPECIFIED {
+			goal.Team = determinePlayerRole(players[scorer])
+		}
+	}
+
+	if goal.Team == telemetry.Role_ROLE_UNSPECIFIED && len(s.history) > 0 {
+		goal.Team = s.history[len(s.history)-1].Team
+	}
+
+	// Walk back through the scoring team's possessions to when it gained the disc
+	first := len(s.history)
+	for first > 0 && s.history[first-1].Team == goal.Team {
+		first--
+	}
+	chain := s.history[first:]
+	if len(chain) == 0 {
+		return goal
+	}
+
+	for _, span := range chain {
+		if n := len(goal.PassChain); n == 0 || goal.PassChain[n-1] != span.Slot {
+			goal.PassChain = append(goal.PassChain, span.Slot)
+		}
+	}
+	if !scorerFound {
+		goal.ScorerSlot = chain[len(chain)-1].Slot
+	}
+
+	// The shot is the end of the scorer's last possession
+	shotAt := chain[len(chain)-1].End
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Slot == goal.ScorerSlot {
+			shotAt = chain[i].End
+			break
+		}
+	}
+	goal.PossessionTime = shotAt.Sub(chain[0].Start)
+
+	// LastScore names the primary assister; earlier passers fill the rest
+	if assister, ok := findSlotByName(players, lastScore.GetAssistScored()); ok && assister != goal.ScorerSlot {
+		goal.AssistSlots = append(goal.AssistSlots, assister)
+	}
+	for i := len(goal.PassChain) - 1; i >= 0 && len(goal.AssistSlots) < MaxGoalAssists; i-- {
+		slot := goal.PassChain[i]
+		if slot == goal.ScorerSlot || slices.Contains(goal.AssistSlots, slot) {
+			continue
+		}
+		goal.AssistSlots = append(goal.AssistSlots, slot)
+	}
+
+	return goal
+}
+
+// event converts the attribution to a goal attribution custom event
+func (g *GoalAttribution) event() *telemetry.LobbySessionEvent {
+	return newCustomEvent(GoalAttributionEventName, map[string]any{
+		"player_slot":     g.ScorerSlot,
+		"display_name":    g.ScorerName,
+		"team":            g.Team.String(),
+		"assist_slots":    slotsToValues(g.AssistSlots),
+		"pass_chain":      slotsToValues(g.PassChain),
+		"possession_time": g.PossessionTime.Seconds(),
+		"shot_distance":   g.ShotDistance,
+		"disc_speed":      g.DiscSpeed,
+		"goal_type":       g.GoalType,
+		"points":          g.Points,
+	})
+}
+
+// roleFromTeamName converts a team name such as "blue" or "orange" to a role
+func roleFromTeamName(name string) telemetry.Role {
+	switch strings.ToLower(name) {
+	case "blue":
+		return telemetry.Role_ROLE_BLUE_TEAM
+	case "orange":
+		return telemetry.Role_ROLE_ORANGE_TEAM
+	}
+	return telemetry.Role_ROLE_UNSPECIFIED
+}
+
+// findSlotByName returns the slot of the player with the given display name
+func findSlotByName(players map[int32]*apigame.TeamMember, name string) (int32, bool) {
+	if name == "" {
+		return -1, false
+	}
+	for slot, player := range players {
+		if player.GetDisplayName() == name {
+			return slot, true
+		}
+	}
+	return -1, false
+}
+
+// slotsToValues converts slots to a custom event list value
+func slotsToValues(slots []int32) []any {
+	values := make([]any, len(slots))
+	for i, slot := range slots {
+		values[i] = slot
+	}
+	return values
+}
@@ -0,0 +1,181 @@
+package events
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Helper to create a frame with blue players in slots 0-2 and orange players
+// in slots 4-5, where possessor holds the disc (-1 for a loose disc)
+func createGoalFrame(at time.Duration, possessor int32, lastScore *apigame.LastScore) *telemetry.LobbySessionStateFrame {
+	var blue, orange []*apigame.TeamMember
+	for _, slot := range []int32{0, 1, 2} {
+		blue = append(blue, &apigame.TeamMember{SlotNumber: slot, DisplayName: goalTestName(slot), HasPossession: slot == possessor})
+	}
+	for _, slot := range []int32{4, 5} {
+		orange = append(orange, &apigame.TeamMember{SlotNumber: slot, DisplayName: goalTestName(slot), HasPossession: slot == possessor})
+	}
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: GameStatusPlaying,
+			LastScore:  lastScore,
+			Teams:      []*apigame.Team{{Players: blue}, {Players: orange}},
+		},
+	}
+}
+
+func goalTestName(slot int32) string {
+	return []string{"Ada", "Bo", "Cy", "", "Dee", "Eli"}[slot]
+}
+
+func TestGoalAttributionSensor_PassChain(t *testing.T) {
+	sensor := NewGoalAttributionSensor()
+	score := &apigame.LastScore{
+		Team:           "blue",
+		PersonScored:   "Cy",
+		AssistScored:   "Bo",
+		DistanceThrown: 12.5,
+		DiscSpeed:      18,
+		GoalType:       "INSIDE SHOT",
+		PointAmount:    2,
+	}
+
+	frames := []*telemetry.LobbySessionStateFrame{
+		createGoalFrame(0, 0, nil),                 // blue gains possession
+		createGoalFrame(1*time.Second, 4, nil),     // orange steals
+		createGoalFrame(2*time.Second, -1, nil),    // loose disc
+		createGoalFrame(3*time.Second, 0, nil),     // blue recovers
+		createGoalFrame(4*time.Second, -1, nil),    // pass
+		createGoalFrame(5*time.Second, 1, nil),     // caught
+		createGoalFrame(6*time.Second, 1, nil),     // held
+		createGoalFrame(7*time.Second, 2, nil),     // hand-off
+		createGoalFrame(8*time.Second, 2, nil),     // shot
+		createGoalFrame(9*time.Second, -1, nil),    // disc in flight
+		createGoalFrame(10*time.Second, -1, score), // goal
+		createGoalFrame(11*time.Second, -1, score), // LastScore unchanged
+	}
+
+	var events []*telemetry.LobbySessionEvent
+	for _, frame := range frames {
+		if event := sensor.AddFrame(frame); event != nil {
+			events = append(events, event)
+		}
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 goal event, got %d", len(events))
+	}
+	if got := EventType(events[0]); got != "custom:goal_attribution" {
+		t.Errorf("expected custom:goal_attribution, got %q", got)
+	}
+
+	goal := sensor.LastGoal()
+	if goal.ScorerSlot != 2 || goal.Team != telemetry.Role_ROLE_BLUE_TEAM {
+		t.Errorf("expected blue slot 2 to score, got slot %d on %v", goal.ScorerSlot, goal.Team)
+	}
+	if !slices.Equal(goal.PassChain, []int32{0, 1, 2}) {
+		t.Errorf("expected pass chain [0 1 2], got %v", goal.PassChain)
+	}
+	if !slices.Equal(goal.AssistSlots, []int32{1, 0}) {
+		t.Errorf("expected assists [1 0], got %v", goal.AssistSlots)
+	}
+	if goal.PossessionTime != 5*time.Second {
+		t.Errorf("expected 5s of possession before the shot, got %v", goal.PossessionTime)
+	}
+	if goal.ShotDistance != 12.5 || goal.DiscSpeed != 18 || goal.Points != 2 || goal.GoalType != "INSIDE SHOT" {
+		t.Errorf("expected shot details from LastScore, got %+v", goal)
+	}
+
+	custom, err := GetCustomEvent(events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.Fields["player_slot"] != float64(2) || custom.Fields["possession_time"] != 5.0 {
+		t.Errorf("unexpected event fields: %v", custom.Fields)
+	}
+	chain, ok := custom.Fields["pass_chain"].([]any)
+	if !ok || len(chain) != 3 {
+		t.Errorf("expected pass_chain with 3 slots, got %v", custom.Fields["pass_chain"])
+	}
+}
+
+func TestGoalAttributionSensor_IgnoresScoreBeforeWatching(t *testing.T) {
+	sensor := NewGoalAttributionSensor()
+	score := &apigame.LastScore{Team: "orange", PersonScored: "Dee", PointAmount: 2}
+
+	if event := sensor.AddFrame(createGoalFrame(0, -1, score)); event != nil {
+		t.Errorf("expected no event for an existing LastScore, got %v", event)
+	}
+	if event := sensor.AddFrame(createGoalFrame(time.Second, -1, score)); event != nil {
+		t.Errorf("expected no event for an unchanged LastScore, got %v", event)
+	}
+}
+
+func TestGoalAttributionSensor_UnknownScorerUsesLastPossessor(t *testing.T) {
+	sensor := NewGoalAttributionSensor()
+	sensor.AddFrame(createGoalFrame(0, 5, nil))
+	sensor.AddFrame(createGoalFrame(time.Second, -1, nil))
+
+	event := sensor.AddFrame(createGoalFrame(2*time.Second, -1, &apigame.LastScore{Team: "orange", PersonScored: "Gone"}))
+	if event == nil {
+		t.Fatal("expected a goal event")
+	}
+	goal := sensor.LastGoal()
+	if goal.ScorerSlot != 5 || goal.Team != telemetry.Role_ROLE_ORANGE_TEAM {
+		t.Errorf("expected orange slot 5, got slot %d on %v", goal.ScorerSlot, goal.Team)
+	}
+	if len(goal.AssistSlots) != 0 {
+		t.Errorf("expected no assists for a solo goal, got %v", goal.AssistSlots)
+	}
+}
+
+func TestGoalAttributionSensor_HistoryClearedAfterGoal(t *testing.T) {
+	sensor := NewGoalAttributionSensor()
+	sensor.AddFrame(createGoalFrame(0, 0, nil))
+	sensor.AddFrame(createGoalFrame(time.Second, -1, &apigame.LastScore{Team: "blue", PersonScored: "Ada"}))
+
+	// The next goal's chain starts from the next possession
+	sensor.AddFrame(createGoalFrame(2*time.Second, 1, nil))
+	sensor.AddFrame(createGoalFrame(3*time.Second, -1, &apigame.LastScore{Team: "blue", PersonScored: "Bo", PointAmount: 3}))
+
+	if goal := sensor.LastGoal(); !slices.Equal(goal.PassChain, []int32{1}) {
+		t.Errorf("expected pass chain [1], got %v", goal.PassChain)
+	}
+}
+
+func TestGoalAttributionSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewGoalAttributionSensor()
+	sensor.AddFrame(createGoalFrame(0, 0, nil))
+	sensor.AddFrame(createGoalFrame(time.Second, 1, nil))
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewGoalAttributionSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored.AddFrame(createGoalFrame(2*time.Second, -1, &apigame.LastScore{Team: "blue", PersonScored: "Bo"}))
+	goal := restored.LastGoal()
+	if goal == nil || !slices.Equal(goal.PassChain, []int32{0, 1}) {
+		t.Errorf("expected restored pass chain [0 1], got %+v", goal)
+	}
+}
+
+func TestGoalAttributionSensor_Reset(t *testing.T) {
+	sensor := NewGoalAttributionSensor()
+	sensor.AddFrame(createGoalFrame(0, 0, nil))
+	sensor.AddFrame(createGoalFrame(time.Second, -1, &apigame.LastScore{Team: "blue", PersonScored: "Ada"}))
+
+	sensor.Reset()
+	if sensor.LastGoal() != nil {
+		t.Error("expected Reset to clear the last goal")
+	}
+}
@@ -0,0 +1,297 @@
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom event names emitted by IdleSensor
+const (
+	PlayerIdleEventName   = "player_idle"
+	PlayerActiveEventName = "player_active"
+)
+
+// Default IdleSensor settings
+const (
+	DefaultIdleDuration = 30 * time.Second
+	// DefaultIdleMovement is how far, in meters, the head or a hand must move to count as activity
+	DefaultIdleMovement = 0.15
+	// DefaultIdleRotation is how far, in degrees, the head must turn to count as activity
+	DefaultIdleRotation = 10.0
+)
+
+// IdleOption configures an IdleSensor
+type IdleOption func(*IdleSensor)
+
+// WithIdleDuration sets how long a player must stay still during play to be idle
+func WithIdleDuration(d time.Duration) IdleOption {
+	return func(s *IdleSensor) {
+		s.idleDuration = d
+	}
+}
+
+// WithIdleThresholds sets how far, in meters, the head or a hand must move,
+// or how far, in degrees, the head must turn, for a player to count as active
+func WithIdleThresholds(movement, rotationDegrees float64) IdleOption {
+	return func(s *IdleSensor) {
+		s.movement = movement
+		s.rotation = rotationDegrees
+	}
+}
+
+// idlePose is the pose a player was in when they last moved
+type idlePose struct {
+	Head        []float64
+	HeadForward []float64
+	LeftHand    []float64
+	RightHand   []float64
+}
+
+// idlePlayerState is the per-player state tracked by IdleSensor
+type idlePlayerState struct {
+	Anchor idlePose
+	// StillFor is the playing time, excluding stuns, since the player last moved
+	StillFor time.Duration
+	Idle     bool
+	IdleAt   time.Time
+}
+
+// idleState is the per-player and timing state of IdleSensor
+type idleState struct {
+	Players     map[int32]*idlePlayerState
+	PrevAt      time.Time
+	PrevPlaying bool
+}
+
+// idleSnapshot is the snapshot form of IdleSensor
+type idleSnapshot struct {
+	Phases []byte
+	State  idleState
+}
+
+// IdleSensor flags players who stop moving during play. A player is idle once
+// their head position and orientation and both hand positions stay within the
+// thresholds for the idle duration; it emits player_idle then, and
+// player_active with the idle duration once they move again.
+//
+// Only time while the match phase is playing counts towards the idle
+// duration, so pauses and breaks between rounds never make a player idle,
+// and neither does time spent stunned. Spectators are ignored. Idle state is safe to
+// query from any goroutine.
+type IdleSensor struct {
+	mu     sync.Mutex
+	state  idleState
+	phases *MatchPhaseTracker
+
+	idleDuration time.Duration
+	movement     float64
+	rotation     float64
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewIdleSensor creates a new IdleSensor
+func NewIdleSensor(opts ...IdleOption) *IdleSensor {
+	return NewIdleSensorWithPhases(NewMatchPhaseTracker(), opts...)
+}
+
+// NewIdleSensorWithPhases creates a new IdleSensor that shares the given tracker
+func NewIdleSensorWithPhases(phases *MatchPhaseTracker, opts ...IdleOption) *IdleSensor {
+	s := &IdleSensor{
+		state:        idleState{Players: make(map[int32]*idlePlayerState)},
+		phases:       phases,
+		idleDuration: DefaultIdleDuration,
+		movement:     DefaultIdleMovement,
+		rotation:     DefaultIdleRotation,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reset clears the phase tracker, player state and any pending events
+func (s *IdleSensor) Reset() {
+	s.phases.Reset()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = idleState{Players: make(map[int32]*idlePlayerState)}
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// IsIdle reports whether the player in the slot is currently idle
+func (s *IdleSensor) IsIdle(slot int32) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.state.Players[slot]
+	return ok && p.Idle
+}
+
+// IdlePlayers returns the slots of all currently idle players in ascending order
+func (s *IdleSensor) IdlePlayers() []int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var slots []int32
+	for slot, p := range s.state.Players {
+		if p.Idle {
+			slots = append(slots, slot)
+		}
+	}
+	slices.Sort(slots)
+	return slots
+}
+
+// Snapshot returns the phase tracker state and player idle state
+func (s *IdleSensor) Snapshot() ([]byte, error) {
+	phases, err := s.phases.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return encodeSnapshot(idleSnapshot{Phases: phases, State: s.state})
+}
+
+// Restore replaces the phase tracker state and player idle state with a snapshot
+func (s *IdleSensor) Restore(data []byte) error {
+	var snap idleSnapshot
+	if err := decodeSnapshot(data, &snap); err != nil {
+		return err
+	}
+	if snap.State.Players == nil {
+		snap.State.Players = make(map[int32]*idlePlayerState)
+	}
+	if err := s.phases.Restore(snap.Phases); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = snap.State
+	s.pendingEvents = s.pendingEvents[:0]
+	return nil
+}
+
+// AddFrame processes a frame and returns idle and active events
+func (s *IdleSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if frame != nil && frame.GetSession() != nil {
+		s.processFrame(frame)
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// processFrame compares each player's pose with the pose they last moved from
+func (s *IdleSensor) processFrame(frame *telemetry.LobbySessionStateFrame) {
+	at := frame.GetTimestamp().AsTime()
+	s.phases.Update(frame)
+	playing := s.phases.Phase() == PhasePlaying
+
+	// Time between two frames only counts if the match was playing throughout it
+	var dt time.Duration
+	if s.state.PrevPlaying && playing && at.After(s.state.PrevAt) {
+		dt = at.Sub(s.state.PrevAt)
+	}
+
+	players := extractPlayersMap(frame.GetSession())
+	slots := make([]int32, 0, len(players))
+	for slot, player := range players {
+		if determinePlayerRole(player) != telemetry.Role_ROLE_SPECTATOR {
+			slots = append(slots, slot)
+		}
+	}
+	slices.Sort(slots)
+
+	for _, slot := range slots {
+		s.updatePlayer(players[slot], at, dt)
+	}
+	for slot := range s.state.Players {
+		if player, ok := players[slot]; !ok || determinePlayerRole(player) == telemetry.Role_ROLE_SPECTATOR {
+			delete(s.state.Players, slot)
+		}
+	}
+
+	s.state.PrevAt = at
+	s.state.PrevPlaying = playing
+}
+
+// updatePlayer advances one player's idle state
+func (s *IdleSensor) updatePlayer(player *apigame.TeamMember, at time.Time, dt time.Duration) {
+	slot := player.GetSlotNumber()
+	pose := poseOf(player)
+
+	p, ok := s.state.Players[slot]
+	if !ok {
+		s.state.Players[slot] = &idlePlayerState{Anchor: pose}
+		return
+	}
+
+	if s.moved(p.Anchor, pose) {
+		if p.Idle {
+			s.pendingEvents = append(s.pendingEvents, newCustomEvent(PlayerActiveEventName, map[string]any{
+				"player_slot":   slot,
+				"display_name":  player.GetDisplayName(),
+				"team":          determinePlayerRole(player).String(),
+				"idle_duration": at.Sub(p.IdleAt).Seconds(),
+			}))
+		}
+		*p = idlePlayerState{Anchor: pose}
+		return
+	}
+
+	// A stunned player cannot move, so the stun does not count as idle time
+	if player.GetIsStunned() {
+		return
+	}
+	p.StillFor += dt
+	if !p.Idle && p.StillFor >= s.idleDuration {
+		p.Idle = true
+		p.IdleAt = at
+		s.pendingEvents = append(s.pendingEvents, newCustomEvent(PlayerIdleEventName, map[string]any{
+			"player_slot":  slot,
+			"display_name": player.GetDisplayName(),
+			"team":         determinePlayerRole(player).String(),
+			"still_for":    p.StillFor.Seconds(),
+		}))
+	}
+}
+
+// moved reports whether the pose is outside the thresholds of the anchor pose.
+// Parts missing from either pose are ignored.
+func (s *IdleSensor) moved(anchor, pose idlePose) bool {
+	for _, pair := range [][2][]float64{
+		{anchor.Head, pose.Head},
+		{anchor.LeftHand, pose.LeftHand},
+		{anchor.RightHand, pose.RightHand},
+	} {
+		if d, ok := vecDistance(pair[0], pair[1]); ok && d > s.movement {
+			return true
+		}
+	}
+	if angle, ok := vecAngle(anchor.HeadForward, pose.HeadForward); ok && angle > s.rotation {
+		return true
+	}
+	return false
+}
+
+// poseOf copies the parts of a player's pose that IdleSensor watches
+func poseOf(player *apigame.TeamMember) idlePose {
+	return idlePose{
+		Head:        slices.Clone(player.GetHead().GetPosition()),
+		HeadForward: slices.Clone(player.GetHead().GetForward()),
+		LeftHand:    slices.Clone(player.GetLeftHand().GetPos()),
+		RightHand:   slices.Clone(player.GetRightHand().GetPos()),
+	}
+}
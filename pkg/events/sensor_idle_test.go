@@ -0,0 +1,159 @@
+package events
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// idleTestPlayer describes a player in an IdleSensor test frame
+type idleTestPlayer struct {
+	slot    int32
+	x       float64
+	forward []float64
+	stunned bool
+	jersey  int32
+}
+
+// Helper to create a frame from test players whose head and hands sit at x
+func createIdleFrame(at time.Duration, status string, players ...idleTestPlayer) *telemetry.LobbySessionStateFrame {
+	var members []*apigame.TeamMember
+	for _, p := range players {
+		forward := p.forward
+		if forward == nil {
+			forward = []float64{0, 0, 1}
+		}
+		members = append(members, &apigame.TeamMember{
+			SlotNumber:   p.slot,
+			JerseyNumber: p.jersey,
+			Head:         &apigame.BodyPart{Position: []float64{p.x, 1.5, 0}, Forward: forward},
+			LeftHand:     &apigame.HandPart{Pos: []float64{p.x - 0.3, 1, 0}},
+			RightHand:    &apigame.HandPart{Pos: []float64{p.x + 0.3, 1, 0}},
+			IsStunned:    p.stunned,
+		})
+	}
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: status,
+			Teams:      []*apigame.Team{{Players: members}},
+		},
+	}
+}
+
+func TestIdleSensor_IdleAndActive(t *testing.T) {
+	sensor := NewIdleSensor(WithIdleDuration(10 * time.Second))
+
+	var names []string
+	for i := 0; i <= 12; i++ {
+		// Slot 0 stands still; slot 1 keeps moving
+		frame := createIdleFrame(time.Duration(i)*time.Second, GameStatusPlaying,
+			idleTestPlayer{slot: 0},
+			idleTestPlayer{slot: 1, x: float64(i)},
+		)
+		for _, event := range collectCustomEvents(t, sensor, frame) {
+			names = append(names, event.Name)
+			if event.Fields["player_slot"] != float64(0) {
+				t.Errorf("expected only slot 0 to go idle, got %v", event.Fields)
+			}
+		}
+	}
+	if len(names) != 1 || names[0] != PlayerIdleEventName {
+		t.Fatalf("expected one player_idle event, got %v", names)
+	}
+	if !sensor.IsIdle(0) || sensor.IsIdle(1) || !slices.Equal(sensor.IdlePlayers(), []int32{0}) {
+		t.Errorf("expected only slot 0 idle, got %v", sensor.IdlePlayers())
+	}
+
+	// Turning the head is activity
+	events := collectCustomEvents(t, sensor, createIdleFrame(15*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0, forward: []float64{1, 0, 0}}, idleTestPlayer{slot: 1, x: 15}))
+	if len(events) != 1 || events[0].Name != PlayerActiveEventName || events[0].Fields["idle_duration"] != 5.0 {
+		t.Fatalf("expected player_active after 5s idle, got %v", events)
+	}
+	if sensor.IsIdle(0) {
+		t.Error("expected slot 0 to be active")
+	}
+}
+
+func TestIdleSensor_SmallMovementsStayIdle(t *testing.T) {
+	sensor := NewIdleSensor(WithIdleDuration(5*time.Second), WithIdleThresholds(0.15, 10))
+
+	for i := 0; i <= 6; i++ {
+		// Jitter below the movement threshold
+		x := 0.05 * float64(i%2)
+		collectCustomEvents(t, sensor, createIdleFrame(time.Duration(i)*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0, x: x}))
+	}
+	if !sensor.IsIdle(0) {
+		t.Error("expected jitter below the threshold not to count as activity")
+	}
+}
+
+func TestIdleSensor_PausesAndStunsDoNotCount(t *testing.T) {
+	sensor := NewIdleSensor(WithIdleDuration(10 * time.Second))
+
+	frames := []*telemetry.LobbySessionStateFrame{
+		createIdleFrame(0, GameStatusPlaying, idleTestPlayer{slot: 0}),
+		createIdleFrame(5*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0}),
+		createIdleFrame(6*time.Second, GameStatusPaused, idleTestPlayer{slot: 0}),
+		createIdleFrame(60*time.Second, GameStatusPaused, idleTestPlayer{slot: 0}),
+		createIdleFrame(61*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0, stunned: true}),
+		createIdleFrame(70*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0, stunned: true}),
+		createIdleFrame(72*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0}),
+	}
+	for _, frame := range frames {
+		if events := collectCustomEvents(t, sensor, frame); len(events) != 0 {
+			t.Fatalf("expected no idle events, got %v", events)
+		}
+	}
+
+	// 5s before the pause and 2s after the stun
+	events := collectCustomEvents(t, sensor, createIdleFrame(75*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0}))
+	if len(events) != 1 || events[0].Fields["still_for"] != 10.0 {
+		t.Errorf("expected player_idle after 10s of counted stillness, got %v", events)
+	}
+}
+
+func TestIdleSensor_IgnoresSpectators(t *testing.T) {
+	sensor := NewIdleSensor(WithIdleDuration(time.Second))
+
+	for i := 0; i <= 3; i++ {
+		if events := collectCustomEvents(t, sensor, createIdleFrame(time.Duration(i)*time.Second, GameStatusPlaying, idleTestPlayer{slot: 9, jersey: -1})); len(events) != 0 {
+			t.Fatalf("expected no events for a spectator, got %v", events)
+		}
+	}
+}
+
+func TestIdleSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewIdleSensor(WithIdleDuration(10 * time.Second))
+	collectCustomEvents(t, sensor, createIdleFrame(0, GameStatusPlaying, idleTestPlayer{slot: 0}))
+	collectCustomEvents(t, sensor, createIdleFrame(8*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0}))
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewIdleSensor(WithIdleDuration(10 * time.Second))
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	events := collectCustomEvents(t, restored, createIdleFrame(10*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0}))
+	if len(events) != 1 || events[0].Name != PlayerIdleEventName {
+		t.Errorf("expected the restored sensor to continue counting, got %v", events)
+	}
+}
+
+func TestIdleSensor_Reset(t *testing.T) {
+	sensor := NewIdleSensor(WithIdleDuration(time.Second))
+	collectCustomEvents(t, sensor, createIdleFrame(0, GameStatusPlaying, idleTestPlayer{slot: 0}))
+	collectCustomEvents(t, sensor, createIdleFrame(2*time.Second, GameStatusPlaying, idleTestPlayer{slot: 0}))
+
+	sensor.Reset()
+	if sensor.IsIdle(0) {
+		t.Error("expected Reset to clear idle state")
+	}
+}
@@ -0,0 +1,215 @@
+package events
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// JoustEventName is the custom event name emitted by JoustSensor
+const JoustEventName = "joust"
+
+// JoustReachDistance is how close, in meters, a player's hand or head must
+// come to the disc for the player to count as having reached it
+const JoustReachDistance = 1.0
+
+// JoustResult is the outcome of a round's opening joust
+type JoustResult struct {
+	RoundNumber int32
+	// Duration is the time from the start of play to the first possession
+	Duration      time.Duration
+	WinningSlot   int32
+	WinningPlayer string
+	WinningTeam   telemetry.Role
+	// PlayerTimes is each player's time to reach the disc, keyed by slot.
+	// Players who had not reached the disc when it was first possessed are absent.
+	PlayerTimes map[int32]time.Duration
+}
+
+// JoustSensor times the joust, the race to the center disc at the start of
+// each round. Timing starts on the round_start → playing phase transition and
+// ends at the first possession, which decides the winning player and team.
+// A pause during the joust suspends timing, so the reach times and duration
+// count only time in play; any other end of play abandons the joust.
+type JoustSensor struct {
+	phases      *MatchPhaseTracker
+	active      bool
+	roundNumber int32
+	startedAt   time.Time
+	pausedAt    time.Time
+	reached     map[int32]time.Duration
+	lastJoust   *JoustResult
+}
+
+// NewJoustSensor creates a new JoustSensor
+func NewJoustSensor() *JoustSensor {
+	return NewJoustSensorWithPhases(NewMatchPhaseTracker())
+}
+
+// NewJoustSensorWithPhases creates a new JoustSensor that shares the given tracker
+func NewJoustSensorWithPhases(phases *MatchPhaseTracker) *JoustSensor {
+	return &JoustSensor{phases: phases}
+}
+
+// Reset clears any joust in progress and the last result
+func (s *JoustSensor) Reset() {
+	s.phases.Reset()
+	*s = JoustSensor{phases: s.phases}
+}
+
+// LastJoust returns the most recently completed joust, or nil if none has completed
+func (s *JoustSensor) LastJoust() *JoustResult {
+	return s.lastJoust
+}
+
+// joustState is the snapshot form of JoustSensor
+type joustState struct {
+	Phases      []byte
+	Active      bool
+	RoundNumber int32
+	StartedAt   time.Time
+	PausedAt    time.Time
+	Reached     map[int32]time.Duration
+	LastJoust   *JoustResult
+}
+
+// Snapshot returns the phase tracker state, the joust in progress and the last result
+func (s *JoustSensor) Snapshot() ([]byte, error) {
+	phases, err := s.phases.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return encodeSnapshot(joustState{
+		Phases:      phases,
+		Active:      s.active,
+		RoundNumber: s.roundNumber,
+		StartedAt:   s.startedAt,
+		PausedAt:    s.pausedAt,
+		Reached:     s.reached,
+		LastJoust:   s.lastJoust,
+	})
+}
+
+// Restore replaces the phase tracker state, the joust in progress and the
+// last result with a snapshot
+func (s *JoustSensor) Restore(data []byte) error {
+	var state joustState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	if err := s.phases.Restore(state.Phases); err != nil {
+		return err
+	}
+	*s = JoustSensor{
+		phases:      s.phases,
+		active:      state.Active,
+		roundNumber: state.RoundNumber,
+		startedAt:   state.StartedAt,
+		pausedAt:    state.PausedAt,
+		reached:     state.Reached,
+		lastJoust:   state.LastJoust,
+	}
+	if s.active && s.reached == nil {
+		s.reached = make(map[int32]time.Duration)
+	}
+	return nil
+}
+
+// AddFrame processes a frame and returns a joust custom event when the joust is won
+func (s *JoustSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return nil
+	}
+
+	session := frame.GetSession()
+	transition, changed := s.phases.Update(frame)
+	at := frame.GetTimestamp().AsTime()
+
+	if changed && transition.From == PhaseRoundStart && transition.To == PhasePlaying {
+		s.active = true
+		s.roundNumber = session.GetBlueRoundScore() + session.GetOrangeRoundScore() + 1
+		s.startedAt = at
+		s.pausedAt = time.Time{}
+		s.reached = make(map[int32]time.Duration)
+	}
+
+	if !s.active {
+		return nil
+	}
+
+	switch s.phases.Phase() {
+	case PhasePlaying:
+		if !s.pausedAt.IsZero() {
+			// Resuming: shift the start so elapsed time excludes the pause
+			s.startedAt = s.startedAt.Add(at.Sub(s.pausedAt))
+			s.pausedAt = time.Time{}
+		}
+	case PhasePaused, PhaseUnpausing:
+		if s.pausedAt.IsZero() {
+			s.pausedAt = at
+		}
+		return nil
+	default:
+		// Play ended before anyone took the disc, e.g. a restart
+		s.active = false
+		s.pausedAt = time.Time{}
+		s.reached = nil
+		return nil
+	}
+
+	elapsed := at.Sub(s.startedAt)
+	discPos := session.GetDisc().GetPosition()
+	for _, team := range session.GetTeams() {
+		for _, player := range team.GetPlayers() {
+			slot := player.GetSlotNumber()
+			if _, ok := s.reached[slot]; ok || determinePlayerRole(player) == telemetry.Role_ROLE_SPECTATOR {
+				continue
+			}
+			if d, ok := playerDistanceTo(player, discPos); ok && d <= JoustReachDistance {
+				s.reached[slot] = elapsed
+			}
+		}
+	}
+
+	possessorSlot := findPossessorSlot(session)
+	if possessorSlot == -1 {
+		return nil
+	}
+
+	// The first possession closes the joust
+	if _, ok := s.reached[possessorSlot]; !ok {
+		s.reached[possessorSlot] = elapsed
+	}
+	winner := extractPlayersMap(session)[possessorSlot]
+	result := &JoustResult{
+		RoundNumber:   s.roundNumber,
+		Duration:      elapsed,
+		WinningSlot:   possessorSlot,
+		WinningPlayer: winner.GetDisplayName(),
+		WinningTeam:   determinePlayerRole(winner),
+		PlayerTimes:   s.reached,
+	}
+	s.lastJoust = result
+	s.active = false
+	s.reached = nil
+
+	return result.event()
+}
+
+// event converts the result to a joust custom event. The winner is the
+// event's player_slot and team, so filters select jousts by winner.
+func (r *JoustResult) event() *telemetry.LobbySessionEvent {
+	times := make(map[string]any, len(r.PlayerTimes))
+	for slot, d := range r.PlayerTimes {
+		times[strconv.Itoa(int(slot))] = d.Seconds()
+	}
+	return newCustomEvent(JoustEventName, map[string]any{
+		"round_number": r.RoundNumber,
+		"duration":     r.Duration.Seconds(),
+		"player_slot":  r.WinningSlot,
+		"display_name": r.WinningPlayer,
+		"team":         r.WinningTeam.String(),
+		"player_times": times,
+	})
+}
@@ -0,0 +1,221 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Helper to create a joust frame with a blue player (slot 0) and an orange player (slot 4)
+// at the given distances along the x axis from the disc at the origin
+func createJoustFrame(at time.Duration, status string, blueX, orangeX float64, possessor int32) *telemetry.LobbySessionStateFrame {
+	player := func(slot int32, name string, x float64) *apigame.TeamMember {
+		return &apigame.TeamMember{
+			SlotNumber:    slot,
+			DisplayName:   name,
+			Head:          &apigame.BodyPart{Position: []float64{x, 0, 0}},
+			HasPossession: slot == possessor,
+		}
+	}
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: status,
+			Disc:       &apigame.Disc{Position: []float64{0, 0, 0}},
+			Teams: []*apigame.Team{
+				{Players: []*apigame.TeamMember{player(0, "Blue", blueX)}},
+				{Players: []*apigame.TeamMember{player(4, "Orange", orangeX)}},
+			},
+		},
+	}
+}
+
+func TestJoustSensor_TimesJoust(t *testing.T) {
+	sensor := NewJoustSensor()
+
+	frames := []*telemetry.LobbySessionStateFrame{
+		createJoustFrame(0, GameStatusRoundStart, -30, 30, -1),
+		createJoustFrame(1*time.Second, GameStatusPlaying, -25, 25, -1),
+		createJoustFrame(2500*time.Millisecond, GameStatusPlaying, -5, 0.5, -1),
+		createJoustFrame(3*time.Second, GameStatusPlaying, -0.8, 0.2, 4),
+	}
+
+	var event *telemetry.LobbySessionEvent
+	for i, frame := range frames {
+		event = sensor.AddFrame(frame)
+		if i < len(frames)-1 && event != nil {
+			t.Fatalf("frame %d: expected no event, got %v", i, event)
+		}
+	}
+	if event == nil {
+		t.Fatal("expected a joust event on first possession")
+	}
+
+	if got := EventType(event); got != "custom:joust" {
+		t.Errorf("expected custom:joust, got %q", got)
+	}
+	if team, _ := EventTeam(event); team != telemetry.Role_ROLE_ORANGE_TEAM {
+		t.Errorf("expected orange to win, got %v", team)
+	}
+
+	result := sensor.LastJoust()
+	if result == nil {
+		t.Fatal("expected LastJoust to return the result")
+	}
+	if result.WinningSlot != 4 || result.WinningPlayer != "Orange" {
+		t.Errorf("expected slot 4 (Orange) to win, got %d (%s)", result.WinningSlot, result.WinningPlayer)
+	}
+	if result.Duration != 2*time.Second {
+		t.Errorf("expected joust duration 2s, got %v", result.Duration)
+	}
+	if result.RoundNumber != 1 {
+		t.Errorf("expected round 1, got %d", result.RoundNumber)
+	}
+	if result.PlayerTimes[4] != 1500*time.Millisecond {
+		t.Errorf("expected orange to reach the disc at 1.5s, got %v", result.PlayerTimes[4])
+	}
+	if result.PlayerTimes[0] != 2*time.Second {
+		t.Errorf("expected blue to reach the disc at 2s, got %v", result.PlayerTimes[0])
+	}
+
+	custom, err := GetCustomEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.Fields["duration"] != 2.0 {
+		t.Errorf("expected duration field 2, got %v", custom.Fields["duration"])
+	}
+	times, ok := custom.Fields["player_times"].(map[string]any)
+	if !ok || times["4"] != 1.5 {
+		t.Errorf("expected player_times with slot 4 at 1.5s, got %v", custom.Fields["player_times"])
+	}
+}
+
+func TestJoustSensor_OnlyOncePerRound(t *testing.T) {
+	sensor := NewJoustSensor()
+
+	sensor.AddFrame(createJoustFrame(0, GameStatusRoundStart, -30, 30, -1))
+	if event := sensor.AddFrame(createJoustFrame(time.Second, GameStatusPlaying, 0, 30, 0)); event == nil {
+		t.Fatal("expected a joust event")
+	}
+
+	// Later possession changes in the same round are not jousts
+	if event := sensor.AddFrame(createJoustFrame(2*time.Second, GameStatusPlaying, 0, 0, 4)); event != nil {
+		t.Errorf("expected no event after the joust, got %v", event)
+	}
+}
+
+func TestJoustSensor_RequiresRoundStart(t *testing.T) {
+	sensor := NewJoustSensor()
+
+	// Joining mid-round, or resuming from a pause, does not start a joust
+	sensor.AddFrame(createJoustFrame(0, GameStatusPaused, -30, 30, -1))
+	if event := sensor.AddFrame(createJoustFrame(time.Second, GameStatusPlaying, 0, 30, 0)); event != nil {
+		t.Errorf("expected no event without round_start, got %v", event)
+	}
+}
+
+func TestJoustSensor_AbandonedWhenPlayStops(t *testing.T) {
+	sensor := NewJoustSensor()
+
+	sensor.AddFrame(createJoustFrame(0, GameStatusRoundStart, -30, 30, -1))
+	sensor.AddFrame(createJoustFrame(time.Second, GameStatusPlaying, -20, 20, -1))
+	sensor.AddFrame(createJoustFrame(2*time.Second, GameStatusScore, -20, 20, -1))
+	if event := sensor.AddFrame(createJoustFrame(3*time.Second, GameStatusPlaying, 0, 20, 0)); event != nil {
+		t.Errorf("expected no event for an interrupted joust, got %v", event)
+	}
+}
+
+func TestJoustSensor_SuspendedWhilePaused(t *testing.T) {
+	sensor := NewJoustSensor()
+
+	// game_status stays playing through a pause; the pause is in session.pause
+	paused := func(at time.Duration, pauseState string, blueX, orangeX float64) *telemetry.LobbySessionStateFrame {
+		frame := createJoustFrame(at, GameStatusPlaying, blueX, orangeX, -1)
+		frame.Session.Pause = &apigame.PauseState{PausedState: pauseState}
+		return frame
+	}
+
+	frames := []*telemetry.LobbySessionStateFrame{
+		createJoustFrame(0, GameStatusRoundStart, -30, 30, -1),
+		createJoustFrame(1*time.Second, GameStatusPlaying, -20, 20, -1),
+		paused(2*time.Second, "paused", -20, 20),
+		// Players near the disc while paused have not reached it in play
+		paused(10*time.Second, "paused", -0.5, 20),
+		paused(20*time.Second, GameStatusUnpausing, -20, 20),
+		createJoustFrame(22*time.Second, GameStatusPlaying, -20, 0.5, -1),
+		createJoustFrame(23*time.Second, GameStatusPlaying, -20, 0.2, 4),
+	}
+	var event *telemetry.LobbySessionEvent
+	for _, frame := range frames {
+		event = sensor.AddFrame(frame)
+	}
+	if event == nil {
+		t.Fatal("expected the joust to finish after the pause")
+	}
+
+	// 1s of play before the pause and 1s after it
+	result := sensor.LastJoust()
+	if result.Duration != 2*time.Second {
+		t.Errorf("expected joust duration 2s excluding the pause, got %v", result.Duration)
+	}
+	if got := result.PlayerTimes[4]; got != time.Second {
+		t.Errorf("expected orange to reach the disc at 1s of play, got %v", got)
+	}
+	if _, ok := result.PlayerTimes[0]; ok {
+		t.Errorf("expected blue to have no reach time, got %v", result.PlayerTimes[0])
+	}
+}
+
+func TestJoustSensor_SharedPhases(t *testing.T) {
+	phases := NewMatchPhaseTracker()
+	roundStart := NewRoundStartSensorWithPhases(phases)
+	sensor := NewJoustSensorWithPhases(phases)
+
+	for _, frame := range []*telemetry.LobbySessionStateFrame{
+		createJoustFrame(0, GameStatusRoundStart, -30, 30, -1),
+		createJoustFrame(time.Second, GameStatusPlaying, 0, 30, 0),
+	} {
+		roundStart.AddFrame(frame)
+		if event := sensor.AddFrame(frame); event != nil {
+			return
+		}
+	}
+	t.Error("expected a joust event from a sensor sharing the tracker")
+}
+
+func TestJoustSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewJoustSensor()
+	sensor.AddFrame(createJoustFrame(0, GameStatusRoundStart, -30, 30, -1))
+	sensor.AddFrame(createJoustFrame(time.Second, GameStatusPlaying, -0.5, 30, -1))
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewJoustSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if event := restored.AddFrame(createJoustFrame(2*time.Second, GameStatusPlaying, 0, 30, 0)); event == nil {
+		t.Fatal("expected the restored sensor to finish the joust")
+	}
+	if got := restored.LastJoust().PlayerTimes[0]; got != 0 {
+		t.Errorf("expected blue's restored reach time of 0s, got %v", got)
+	}
+}
+
+func TestJoustSensor_Reset(t *testing.T) {
+	sensor := NewJoustSensor()
+	sensor.AddFrame(createJoustFrame(0, GameStatusRoundStart, -30, 30, -1))
+	sensor.AddFrame(createJoustFrame(time.Second, GameStatusPlaying, 0, 30, 0))
+
+	sensor.Reset()
+	if sensor.LastJoust() != nil {
+		t.Error("expected Reset to clear the last joust")
+	}
+}
@@ -0,0 +1,267 @@
+package events
+
+import (
+	"strconv"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom event names emitted by the lobby audit sensors
+const (
+	RulesChangedEventName          = "rules_changed"
+	RestartRequestedEventName      = "restart_requested"
+	RestartRequestClearedEventName = "restart_request_cleared"
+	LobbySettingsChangedEventName  = "lobby_settings_changed"
+)
+
+// auditFields returns the match context recorded with every audit event, so
+// each event places the action in the match on its own
+func auditFields(session *apigame.SessionResponse) map[string]any {
+	return map[string]any{
+		"game_status":        session.GetGameStatus(),
+		"game_clock":         session.GetGameClockDisplay(),
+		"blue_points":        session.GetBluePoints(),
+		"orange_points":      session.GetOrangePoints(),
+		"blue_round_score":   session.GetBlueRoundScore(),
+		"orange_round_score": session.GetOrangeRoundScore(),
+	}
+}
+
+// rulesState is the snapshot form of RulesChangedSensor
+type rulesState struct {
+	ChangedBy   string
+	ChangedAt   uint64
+	Initialized bool
+}
+
+// RulesChangedSensor emits a rules_changed custom event when the private
+// match rules are changed, with who changed them and the game's
+// rules_changed_at value. The rules in force when the sensor starts are its
+// baseline and are not reported.
+type RulesChangedSensor struct {
+	state rulesState
+}
+
+// NewRulesChangedSensor creates a new RulesChangedSensor
+func NewRulesChangedSensor() *RulesChangedSensor {
+	return &RulesChangedSensor{}
+}
+
+// Reset clears the last seen rules change
+func (s *RulesChangedSensor) Reset() {
+	s.state = rulesState{}
+}
+
+// Snapshot returns the last seen rules change
+func (s *RulesChangedSensor) Snapshot() ([]byte, error) {
+	return encodeSnapshot(s.state)
+}
+
+// Restore replaces the last seen rules change with a snapshot
+func (s *RulesChangedSensor) Restore(data []byte) error {
+	var state rulesState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	s.state = state
+	return nil
+}
+
+// AddFrame processes a frame and returns a rules_changed event if the rules changed
+func (s *RulesChangedSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return nil
+	}
+
+	session := frame.GetSession()
+	changedBy, changedAt := session.GetRulesChangedBy(), session.GetRulesChangedAt()
+	prev := s.state
+	s.state = rulesState{ChangedBy: changedBy, ChangedAt: changedAt, Initialized: true}
+
+	if !prev.Initialized || changedAt == 0 || (changedAt == prev.ChangedAt && changedBy == prev.ChangedBy) {
+		return nil
+	}
+
+	fields := auditFields(session)
+	fields["changed_by"] = changedBy
+	// Formatted as a string because the value does not fit in a float64
+	fields["rules_changed_at"] = strconv.FormatUint(changedAt, 10)
+	return newCustomEvent(RulesChangedEventName, fields)
+}
+
+// restartRequestState is the snapshot form of RestartRequestSensor
+type restartRequestState struct {
+	Blue        int32
+	Orange      int32
+	Initialized bool
+}
+
+// RestartRequestSensor emits restart_requested when a team requests a
+// restart and restart_request_cleared when the request goes away, whether
+// it was granted or withdrawn.
+type RestartRequestSensor struct {
+	state restartRequestState
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewRestartRequestSensor creates a new RestartRequestSensor
+func NewRestartRequestSensor() *RestartRequestSensor {
+	return &RestartRequestSensor{}
+}
+
+// Reset clears the restart request state and any pending events
+func (s *RestartRequestSensor) Reset() {
+	s.state = restartRequestState{}
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// Snapshot returns the restart request state of both teams
+func (s *RestartRequestSensor) Snapshot() ([]byte, error) {
+	return encodeSnapshot(s.state)
+}
+
+// Restore replaces the restart request state of both teams with a snapshot
+func (s *RestartRequestSensor) Restore(data []byte) error {
+	var state restartRequestState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	s.state = state
+	s.pendingEvents = s.pendingEvents[:0]
+	return nil
+}
+
+// AddFrame processes a frame and returns restart request events
+func (s *RestartRequestSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	if frame != nil && frame.GetSession() != nil {
+		session := frame.GetSession()
+		blue, orange := session.GetBlueTeamRestartRequest(), session.GetOrangeTeamRestartRequest()
+		if s.state.Initialized {
+			s.checkRequest(session, telemetry.Role_ROLE_BLUE_TEAM, s.state.Blue, blue)
+			s.checkRequest(session, telemetry.Role_ROLE_ORANGE_TEAM, s.state.Orange, orange)
+		}
+		s.state = restartRequestState{Blue: blue, Orange: orange, Initialized: true}
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// checkRequest queues an event if the team's restart request was raised or cleared
+func (s *RestartRequestSensor) checkRequest(session *apigame.SessionResponse, team telemetry.Role, prev, cur int32) {
+	var name string
+	switch {
+	case cur > 0 && prev <= 0:
+		name = RestartRequestedEventName
+	case cur <= 0 && prev > 0:
+		name = RestartRequestClearedEventName
+	default:
+		return
+	}
+
+	fields := auditFields(session)
+	fields["team"] = team.String()
+	s.pendingEvents = append(s.pendingEvents, newCustomEvent(name, fields))
+}
+
+// lobbySettings are the lobby settings watched by LobbySettingsSensor
+type lobbySettings struct {
+	MapName         string
+	MatchType       string
+	PrivateMatch    bool
+	TournamentMatch bool
+	TotalRoundCount int32
+}
+
+// lobbySettingsState is the snapshot form of LobbySettingsSensor
+type lobbySettingsState struct {
+	Settings    lobbySettings
+	Initialized bool
+}
+
+// LobbySettingsSensor emits a lobby_settings_changed custom event for each
+// lobby setting that changes: map_name, match_type, private_match,
+// tournament_match or total_round_count. The event carries the setting name
+// with its previous and new values.
+type LobbySettingsSensor struct {
+	state lobbySettingsState
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewLobbySettingsSensor creates a new LobbySettingsSensor
+func NewLobbySettingsSensor() *LobbySettingsSensor {
+	return &LobbySettingsSensor{}
+}
+
+// Reset clears the lobby settings and any pending events
+func (s *LobbySettingsSensor) Reset() {
+	s.state = lobbySettingsState{}
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// Snapshot returns the lobby settings
+func (s *LobbySettingsSensor) Snapshot() ([]byte, error) {
+	return encodeSnapshot(s.state)
+}
+
+// Restore replaces the lobby settings with a snapshot
+func (s *LobbySettingsSensor) Restore(data []byte) error {
+	var state lobbySettingsState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	s.state = state
+	s.pendingEvents = s.pendingEvents[:0]
+	return nil
+}
+
+// AddFrame processes a frame and returns lobby settings change events
+func (s *LobbySettingsSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	if frame != nil && frame.GetSession() != nil {
+		session := frame.GetSession()
+		cur := lobbySettings{
+			MapName:         session.GetMapName(),
+			MatchType:       session.GetMatchType(),
+			PrivateMatch:    session.GetPrivateMatch(),
+			TournamentMatch: session.GetTournamentMatch(),
+			TotalRoundCount: session.GetTotalRoundCount(),
+		}
+		if s.state.Initialized {
+			prev := s.state.Settings
+			s.checkSetting(session, "map_name", prev.MapName, cur.MapName)
+			s.checkSetting(session, "match_type", prev.MatchType, cur.MatchType)
+			s.checkSetting(session, "private_match", prev.PrivateMatch, cur.PrivateMatch)
+			s.checkSetting(session, "tournament_match", prev.TournamentMatch, cur.TournamentMatch)
+			s.checkSetting(session, "total_round_count", prev.TotalRoundCount, cur.TotalRoundCount)
+		}
+		s.state = lobbySettingsState{Settings: cur, Initialized: true}
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// checkSetting queues a lobby_settings_changed event if the setting changed
+func (s *LobbySettingsSensor) checkSetting(session *apigame.SessionResponse, setting string, prev, cur any) {
+	if prev == cur {
+		return
+	}
+	fields := auditFields(session)
+	fields["setting"] = setting
+	fields["previous"] = prev
+	fields["value"] = cur
+	s.pendingEvents = append(s.pendingEvents, newCustomEvent(LobbySettingsChangedEventName, fields))
+}
@@ -0,0 +1,205 @@
+package events
+
+import (
+	"testing"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Helper to wrap each session in a frame
+func createLobbyFrames(sessions ...*apigame.SessionResponse) []*telemetry.LobbySessionStateFrame {
+	frames := make([]*telemetry.LobbySessionStateFrame, len(sessions))
+	for i, session := range sessions {
+		frames[i] = &telemetry.LobbySessionStateFrame{Session: session}
+	}
+	return frames
+}
+
+func TestRulesChangedSensor_DetectsChange(t *testing.T) {
+	sensor := NewRulesChangedSensor()
+
+	events := collectCustomEvents(t, sensor, createLobbyFrames(
+		&apigame.SessionResponse{RulesChangedBy: "Referee", RulesChangedAt: 100},
+		&apigame.SessionResponse{RulesChangedBy: "Referee", RulesChangedAt: 100, GameClockDisplay: "04:12.00"},
+		&apigame.SessionResponse{
+			RulesChangedBy:   "Referee",
+			RulesChangedAt:   1702857600000000001,
+			GameStatus:       GameStatusPlaying,
+			GameClockDisplay: "03:40.50",
+			BluePoints:       4,
+		},
+	)...)
+
+	if len(events) != 1 || events[0].Name != RulesChangedEventName {
+		t.Fatalf("expected one rules_changed event, got %v", events)
+	}
+	fields := events[0].Fields
+	if fields["changed_by"] != "Referee" || fields["rules_changed_at"] != "1702857600000000001" {
+		t.Errorf("unexpected rules_changed fields: %v", fields)
+	}
+	if fields["game_clock"] != "03:40.50" || fields["game_status"] != GameStatusPlaying || fields["blue_points"] != float64(4) {
+		t.Errorf("expected match context in the event, got %v", fields)
+	}
+}
+
+func TestRulesChangedSensor_BaselineNotReported(t *testing.T) {
+	sensor := NewRulesChangedSensor()
+
+	if events := collectCustomEvents(t, sensor, createLobbyFrames(&apigame.SessionResponse{RulesChangedBy: "Referee", RulesChangedAt: 100})...); len(events) != 0 {
+		t.Errorf("expected no event for the rules in force at start, got %v", events)
+	}
+}
+
+func TestRulesChangedSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewRulesChangedSensor()
+	collectCustomEvents(t, sensor, createLobbyFrames(&apigame.SessionResponse{RulesChangedBy: "Referee", RulesChangedAt: 100})...)
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewRulesChangedSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if events := collectCustomEvents(t, restored, createLobbyFrames(&apigame.SessionResponse{RulesChangedBy: "Referee", RulesChangedAt: 100})...); len(events) != 0 {
+		t.Errorf("expected no event for unchanged rules after restore, got %v", events)
+	}
+	if events := collectCustomEvents(t, restored, createLobbyFrames(&apigame.SessionResponse{RulesChangedBy: "Host", RulesChangedAt: 200})...); len(events) != 1 {
+		t.Errorf("expected a rules_changed event after restore, got %v", events)
+	}
+}
+
+func TestRestartRequestSensor_RequestAndClear(t *testing.T) {
+	sensor := NewRestartRequestSensor()
+
+	events := collectCustomEvents(t, sensor, createLobbyFrames(
+		&apigame.SessionResponse{},
+		&apigame.SessionResponse{OrangeTeamRestartRequest: 1},
+		&apigame.SessionResponse{OrangeTeamRestartRequest: 1, BlueTeamRestartRequest: 1},
+		&apigame.SessionResponse{},
+	)...)
+
+	want := []struct {
+		name string
+		team string
+	}{
+		{RestartRequestedEventName, "ROLE_ORANGE_TEAM"},
+		{RestartRequestedEventName, "ROLE_BLUE_TEAM"},
+		{RestartRequestClearedEventName, "ROLE_BLUE_TEAM"},
+		{RestartRequestClearedEventName, "ROLE_ORANGE_TEAM"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %v", len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Name != w.name || events[i].Fields["team"] != w.team {
+			t.Errorf("event %d: expected %s for %s, got %s %v", i, w.name, w.team, events[i].Name, events[i].Fields)
+		}
+	}
+}
+
+func TestRestartRequestSensor_Reset(t *testing.T) {
+	sensor := NewRestartRequestSensor()
+	collectCustomEvents(t, sensor, createLobbyFrames(&apigame.SessionResponse{BlueTeamRestartRequest: 1})...)
+
+	sensor.Reset()
+	if events := collectCustomEvents(t, sensor, createLobbyFrames(&apigame.SessionResponse{})...); len(events) != 0 {
+		t.Errorf("expected the first frame after Reset to be a baseline, got %v", events)
+	}
+}
+
+func TestLobbySettingsSensor_DetectsChanges(t *testing.T) {
+	sensor := NewLobbySettingsSensor()
+
+	events := collectCustomEvents(t, sensor, createLobbyFrames(
+		&apigame.SessionResponse{MapName: "mpl_arena_a", MatchType: "Echo_Arena_Private", PrivateMatch: true, TotalRoundCount: 3},
+		&apigame.SessionResponse{MapName: "mpl_arena_a", MatchType: "Echo_Arena_Private", PrivateMatch: true, TotalRoundCount: 3},
+		&apigame.SessionResponse{MapName: "mpl_arena_a", MatchType: "Echo_Arena_Private", PrivateMatch: true, TournamentMatch: true, TotalRoundCount: 5},
+	)...)
+
+	if len(events) != 2 {
+		t.Fatalf("expected two lobby_settings_changed events, got %v", events)
+	}
+	if events[0].Fields["setting"] != "tournament_match" || events[0].Fields["previous"] != false || events[0].Fields["value"] != true {
+		t.Errorf("unexpected tournament_match change: %v", events[0].Fields)
+	}
+	if events[1].Fields["setting"] != "total_round_count" || events[1].Fields["previous"] != float64(3) || events[1].Fields["value"] != float64(5) {
+		t.Errorf("unexpected total_round_count change: %v", events[1].Fields)
+	}
+}
+
+func TestLobbySettingsSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewLobbySettingsSensor()
+	collectCustomEvents(t, sensor, createLobbyFrames(&apigame.SessionResponse{MapName: "mpl_arena_a"})...)
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewLobbySettingsSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	events := collectCustomEvents(t, restored, createLobbyFrames(&apigame.SessionResponse{MapName: "mpl_combat_dyson"})...)
+	if len(events) != 1 || events[0].Fields["previous"] != "mpl_arena_a" || events[0].Fields["value"] != "mpl_combat_dyson" {
+		t.Errorf("expected a map change after restore, got %v", events)
+	}
+}
+
+func TestLobbySensors_RestoreReplacesStaleState(t *testing.T) {
+	type snapshotSensor interface {
+		Sensor
+		Snapshotter
+	}
+	tests := []struct {
+		name      string
+		newSensor func() snapshotSensor
+		// The source's session has zero values where the target's does not
+		source, target *apigame.SessionResponse
+	}{
+		{
+			name:      "rules changed",
+			newSensor: func() snapshotSensor { return NewRulesChangedSensor() },
+			source:    &apigame.SessionResponse{RulesChangedAt: 100},
+			target:    &apigame.SessionResponse{RulesChangedBy: "Referee", RulesChangedAt: 100},
+		},
+		{
+			name:      "restart request",
+			newSensor: func() snapshotSensor { return NewRestartRequestSensor() },
+			source:    &apigame.SessionResponse{},
+			target:    &apigame.SessionResponse{BlueTeamRestartRequest: 1},
+		},
+		{
+			name:      "lobby settings",
+			newSensor: func() snapshotSensor { return NewLobbySettingsSensor() },
+			source:    &apigame.SessionResponse{MapName: "mpl_arena_a"},
+			target:    &apigame.SessionResponse{MapName: "mpl_arena_a", PrivateMatch: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.newSensor()
+			collectCustomEvents(t, source, createLobbyFrames(tt.source)...)
+			data, err := source.Snapshot()
+			if err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+
+			target := tt.newSensor()
+			collectCustomEvents(t, target, createLobbyFrames(tt.target)...)
+			if err := target.Restore(data); err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+
+			// Nothing changed since the snapshot, so nothing is reported
+			if events := collectCustomEvents(t, target, createLobbyFrames(tt.source)...); len(events) != 0 {
+				t.Errorf("expected no events after restore, got %v", events)
+			}
+		})
+	}
+}
@@ -0,0 +1,481 @@
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom event names emitted by MovementSensor
+const (
+	MovementSummaryEventName   = "movement_summary"
+	SpeedBurstEventName        = "speed_burst"
+	GoalCreaseEnteredEventName = "goal_crease_entered"
+	GoalCreaseExitedEventName  = "goal_crease_exited"
+)
+
+// Default MovementSensor settings
+const (
+	DefaultMovementSummaryInterval = 30 * time.Second
+	// DefaultBurstAcceleration is the acceleration, in meters per second
+	// squared, between two frames that counts as a boost
+	DefaultBurstAcceleration = 8.0
+	// DefaultBurstMinSpeed is the speed, in meters per second, a boost must reach
+	DefaultBurstMinSpeed = 4.0
+	// burstCooldown is the minimum time between two boosts of the same player
+	burstCooldown = time.Second
+	// GoalCreaseRadius is the distance, in meters, from a goal center that is its crease
+	GoalCreaseRadius = 4.0
+	// maxPlausibleSpeed bounds the speed derived from two positions; faster
+	// movement is a respawn or teleport and is not counted as distance
+	maxPlausibleSpeed = 40.0
+)
+
+// FieldZone is a third of the arena relative to the goal a team defends
+type FieldZone string
+
+const (
+	ZoneDefensive FieldZone = "defensive"
+	ZoneMidfield  FieldZone = "midfield"
+	ZoneOffensive FieldZone = "offensive"
+)
+
+// MovementStats is the movement of a player accumulated while the game was playing
+type MovementStats struct {
+	Slot        int32
+	DisplayName string
+	Team        telemetry.Role
+	// Distance is the distance travelled in meters
+	Distance float64
+	// TopSpeed is the highest speed seen, in meters per second
+	TopSpeed    float64
+	PlayingTime time.Duration
+	ZoneTime    map[FieldZone]time.Duration
+	Bursts      int
+}
+
+// AverageSpeed returns the distance travelled per second of play
+func (m MovementStats) AverageSpeed() float64 {
+	if m.PlayingTime <= 0 {
+		return 0
+	}
+	return m.Distance / m.PlayingTime.Seconds()
+}
+
+// movementPlayerState is the per-player state tracked by MovementSensor
+type movementPlayerState struct {
+	Stats MovementStats
+	// AccountNumber identifies the occupant of the slot the stats belong to
+	AccountNumber uint64
+	Position      []float64
+	Speed         float64
+	LastBurst     time.Time
+	// Crease is the team whose goal crease the player is in, if any
+	Crease        telemetry.Role
+	CreaseEntered time.Time
+}
+
+// movementState is the per-player and timing state of MovementSensor
+type movementState struct {
+	Players     map[int32]*movementPlayerState
+	PrevAt      time.Time
+	PrevPlaying bool
+	LastSummary time.Time
+}
+
+// movementSnapshot is the snapshot form of MovementSensor
+type movementSnapshot struct {
+	Phases []byte
+	State  movementState
+}
+
+// MovementOption configures a MovementSensor
+type MovementOption func(*MovementSensor)
+
+// WithMovementSummaryInterval sets how often movement_summary events are
+// emitted; zero disables them, including the final summaries
+func WithMovementSummaryInterval(interval time.Duration) MovementOption {
+	return func(s *MovementSensor) {
+		s.summaryInterval = interval
+	}
+}
+
+// WithSpeedBurst sets the acceleration and resulting speed that count as a boost
+func WithSpeedBurst(acceleration, minSpeed float64) MovementOption {
+	return func(s *MovementSensor) {
+		s.burstAcceleration = acceleration
+		s.burstMinSpeed = minSpeed
+	}
+}
+
+// MovementSensor tracks each player's movement from their body position and
+// velocity: distance travelled, top speed, and time spent in the defensive,
+// midfield and offensive thirds of the arena, relative to the goal their team
+// defends. Only time and distance while the match phase is playing are
+// counted, so pauses are left out.
+//
+// It emits a movement_summary event per player at a fixed interval, and a
+// final one when the player leaves, when another account takes their slot
+// and when the match ends. It also emits speed_burst events when a player accelerates sharply, and
+// goal_crease_entered and goal_crease_exited events as players move in and
+// out of the area around either goal. Movement stats are safe to query from
+// any goroutine.
+type MovementSensor struct {
+	mu     sync.Mutex
+	state  movementState
+	phases *MatchPhaseTracker
+
+	summaryInterval   time.Duration
+	burstAcceleration float64
+	burstMinSpeed     float64
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewMovementSensor creates a new MovementSensor
+func NewMovementSensor(opts ...MovementOption) *MovementSensor {
+	return NewMovementSensorWithPhases(NewMatchPhaseTracker(), opts...)
+}
+
+// NewMovementSensorWithPhases creates a new MovementSensor that shares the given tracker
+func NewMovementSensorWithPhases(phases *MatchPhaseTracker, opts ...MovementOption) *MovementSensor {
+	s := &MovementSensor{
+		state:             movementState{Players: make(map[int32]*movementPlayerState)},
+		phases:            phases,
+		summaryInterval:   DefaultMovementSummaryInterval,
+		burstAcceleration: DefaultBurstAcceleration,
+		burstMinSpeed:     DefaultBurstMinSpeed,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reset clears the phase tracker, all movement stats and any pending events
+func (s *MovementSensor) Reset() {
+	s.phases.Reset()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = movementState{Players: make(map[int32]*movementPlayerState)}
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// PlayerMovement returns the movement stats of the player in the slot
+func (s *MovementSensor) PlayerMovement(slot int32) (MovementStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.state.Players[slot]
+	if !ok {
+		return MovementStats{}, false
+	}
+	return p.Stats.clone(), true
+}
+
+// AllMovement returns the movement stats of every tracked player in slot order
+func (s *MovementSensor) AllMovement() []MovementStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]MovementStats, 0, len(s.state.Players))
+	for _, slot := range s.sortedSlots() {
+		stats = append(stats, s.state.Players[slot].Stats.clone())
+	}
+	return stats
+}
+
+// Snapshot returns the phase tracker state, movement stats and per-player state
+func (s *MovementSensor) Snapshot() ([]byte, error) {
+	phases, err := s.phases.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return encodeSnapshot(movementSnapshot{Phases: phases, State: s.state})
+}
+
+// Restore replaces the phase tracker state, movement stats and per-player
+// state with a snapshot
+func (s *MovementSensor) Restore(data []byte) error {
+	var snap movementSnapshot
+	if err := decodeSnapshot(data, &snap); err != nil {
+		return err
+	}
+	if snap.State.Players == nil {
+		snap.State.Players = make(map[int32]*movementPlayerState)
+	}
+	if err := s.phases.Restore(snap.Phases); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = snap.State
+	s.pendingEvents = s.pendingEvents[:0]
+	return nil
+}
+
+// AddFrame processes a frame and returns movement summary, speed burst and goal crease events
+func (s *MovementSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if frame != nil && frame.GetSession() != nil {
+		s.processFrame(frame)
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// processFrame advances each player's movement and checks whether a summary is due
+func (s *MovementSensor) processFrame(frame *telemetry.LobbySessionStateFrame) {
+	at := frame.GetTimestamp().AsTime()
+	transition, changed := s.phases.Update(frame)
+	playing := s.phases.Phase() == PhasePlaying
+	dt := at.Sub(s.state.PrevAt)
+	if s.state.PrevAt.IsZero() || dt < 0 {
+		dt = 0
+	}
+	if s.state.LastSummary.IsZero() {
+		s.state.LastSummary = at
+	}
+
+	seen := make(map[int32]bool)
+	for _, team := range frame.GetSession().GetTeams() {
+		for _, player := range team.GetPlayers() {
+			role := determinePlayerRole(player)
+			if role == telemetry.Role_ROLE_SPECTATOR {
+				continue
+			}
+			seen[player.GetSlotNumber()] = true
+			s.updatePlayer(player, role, at, dt, playing)
+		}
+	}
+	for _, slot := range s.sortedSlots() {
+		if !seen[slot] {
+			s.queueSummary(s.state.Players[slot].Stats, true)
+			delete(s.state.Players, slot)
+		}
+	}
+
+	// Time between two frames only counts if the game was playing throughout it
+	s.state.PrevPlaying = playing
+	s.state.PrevAt = at
+
+	// The final summaries at the end of the match are the last until the next one starts
+	final := changed && transition.To == PhasePostMatch
+	due := s.summaryInterval > 0 && at.Sub(s.state.LastSummary) >= s.summaryInterval && s.phases.Phase() != PhasePostMatch
+	if final || due {
+		s.state.LastSummary = at
+		for _, slot := range s.sortedSlots() {
+			s.queueSummary(s.state.Players[slot].Stats, final)
+		}
+	}
+}
+
+// queueSummary queues a movement_summary event for the stats unless summaries are disabled
+func (s *MovementSensor) queueSummary(stats MovementStats, final bool) {
+	if s.summaryInterval <= 0 {
+		return
+	}
+	s.pendingEvents = append(s.pendingEvents, stats.summaryEvent(final))
+}
+
+// updatePlayer accumulates one player's movement since the previous frame
+func (s *MovementSensor) updatePlayer(player *apigame.TeamMember, role telemetry.Role, at time.Time, dt time.Duration, playing bool) {
+	slot := player.GetSlotNumber()
+	position := playerPosition(player)
+
+	p, existed := s.state.Players[slot]
+	if existed && p.AccountNumber != player.GetAccountNumber() {
+		// Another player took the slot; their movement starts from zero
+		s.queueSummary(p.Stats, true)
+		existed = false
+	}
+	if !existed {
+		p = &movementPlayerState{
+			Stats:         MovementStats{Slot: slot, ZoneTime: make(map[FieldZone]time.Duration)},
+			AccountNumber: player.GetAccountNumber(),
+		}
+		s.state.Players[slot] = p
+	}
+	p.Stats.DisplayName = player.GetDisplayName()
+	p.Stats.Team = role
+
+	// Prefer the reported velocity; fall back to the change in position
+	speed, ok := vecLength(player.GetVelocity())
+	step, moved := vecDistance(p.Position, position)
+	if !ok && moved && dt > 0 {
+		speed = step / dt.Seconds()
+	}
+	teleported := moved && dt > 0 && step/dt.Seconds() > maxPlausibleSpeed
+
+	if existed && s.state.PrevPlaying && playing && dt > 0 {
+		p.Stats.PlayingTime += dt
+		if moved && !teleported {
+			p.Stats.Distance += step
+		}
+		if zone, ok := fieldZone(p.Position, role); ok {
+			p.Stats.ZoneTime[zone] += dt
+		}
+		if speed > p.Stats.TopSpeed && !teleported {
+			p.Stats.TopSpeed = speed
+		}
+
+		acceleration := (speed - p.Speed) / dt.Seconds()
+		if acceleration >= s.burstAcceleration && speed >= s.burstMinSpeed && at.Sub(p.LastBurst) >= burstCooldown && !teleported {
+			p.LastBurst = at
+			p.Stats.Bursts++
+			s.pendingEvents = append(s.pendingEvents, newCustomEvent(SpeedBurstEventName, map[string]any{
+				"player_slot":  slot,
+				"display_name": player.GetDisplayName(),
+				"team":         role.String(),
+				"speed_before": p.Speed,
+				"speed_after":  speed,
+				"acceleration": acceleration,
+			}))
+		}
+	}
+
+	if existed && playing {
+		s.checkCrease(p, player, role, position, at)
+	}
+
+	p.Position = slices.Clone(position)
+	p.Speed = speed
+}
+
+// checkCrease emits events as the player enters or leaves the area around a goal
+func (s *MovementSensor) checkCrease(p *movementPlayerState, player *apigame.TeamMember, role telemetry.Role, position []float64, at time.Time) {
+	crease := creaseAt(position)
+	if crease == p.Crease {
+		return
+	}
+
+	fields := map[string]any{
+		"player_slot":  player.GetSlotNumber(),
+		"display_name": player.GetDisplayName(),
+		"team":         role.String(),
+	}
+	if p.Crease != telemetry.Role_ROLE_UNSPECIFIED {
+		exited := map[string]any{
+			"goal_team": p.Crease.String(),
+			"defending": p.Crease == role,
+			"duration":  at.Sub(p.CreaseEntered).Seconds(),
+		}
+		for k, v := range fields {
+			exited[k] = v
+		}
+		s.pendingEvents = append(s.pendingEvents, newCustomEvent(GoalCreaseExitedEventName, exited))
+	}
+	if crease != telemetry.Role_ROLE_UNSPECIFIED {
+		fields["goal_team"] = crease.String()
+		fields["defending"] = crease == role
+		s.pendingEvents = append(s.pendingEvents, newCustomEvent(GoalCreaseEnteredEventName, fields))
+	}
+	p.Crease = crease
+	p.CreaseEntered = at
+}
+
+// sortedSlots returns the tracked slots in ascending order
+func (s *MovementSensor) sortedSlots() []int32 {
+	slots := make([]int32, 0, len(s.state.Players))
+	for slot := range s.state.Players {
+		slots = append(slots, slot)
+	}
+	slices.Sort(slots)
+	return slots
+}
+
+// clone returns a copy of the stats that does not share the zone map
+func (m MovementStats) clone() MovementStats {
+	zones := make(map[FieldZone]time.Duration, len(m.ZoneTime))
+	for zone, d := range m.ZoneTime {
+		zones[zone] = d
+	}
+	m.ZoneTime = zones
+	return m
+}
+
+// summaryEvent converts the stats to a movement_summary custom event; final
+// marks the last summary for the player
+func (m MovementStats) summaryEvent(final bool) *telemetry.LobbySessionEvent {
+	return newCustomEvent(MovementSummaryEventName, map[string]any{
+		"player_slot":    m.Slot,
+		"display_name":   m.DisplayName,
+		"team":           m.Team.String(),
+		"distance":       m.Distance,
+		"top_speed":      m.TopSpeed,
+		"average_speed":  m.AverageSpeed(),
+		"playing_time":   m.PlayingTime.Seconds(),
+		"defensive_time": m.ZoneTime[ZoneDefensive].Seconds(),
+		"midfield_time":  m.ZoneTime[ZoneMidfield].Seconds(),
+		"offensive_time": m.ZoneTime[ZoneOffensive].Seconds(),
+		"bursts":         m.Bursts,
+		"final":          final,
+	})
+}
+
+// playerPosition returns the player's body position, falling back to the head
+func playerPosition(player *apigame.TeamMember) []float64 {
+	if pos := player.GetBody().GetPosition(); len(pos) >= 3 {
+		return pos
+	}
+	return player.GetHead().GetPosition()
+}
+
+// fieldZone returns the third of the arena a position is in, relative to the goal the team defends
+func fieldZone(position []float64, team telemetry.Role) (FieldZone, bool) {
+	if len(position) < 3 {
+		return "", false
+	}
+	// Distance towards the goal the team attacks
+	z := position[2]
+	switch team {
+	case telemetry.Role_ROLE_BLUE_TEAM:
+	case telemetry.Role_ROLE_ORANGE_TEAM:
+		z = -z
+	default:
+		return "", false
+	}
+
+	switch third := ArenaGoalZ * 2 / 3; {
+	case z < -third/2:
+		return ZoneDefensive, true
+	case z > third/2:
+		return ZoneOffensive, true
+	default:
+		return ZoneMidfield, true
+	}
+}
+
+// creaseAt returns the team whose goal crease contains the position, or ROLE_UNSPECIFIED
+func creaseAt(position []float64) telemetry.Role {
+	for _, team := range []telemetry.Role{telemetry.Role_ROLE_BLUE_TEAM, telemetry.Role_ROLE_ORANGE_TEAM} {
+		// A team's crease is around the goal the other team attacks
+		goal, _ := attackedGoal(opposingTeam(team))
+		if d, ok := vecDistance(position, goal); ok && d <= GoalCreaseRadius {
+			return team
+		}
+	}
+	return telemetry.Role_ROLE_UNSPECIFIED
+}
+
+// opposingTeam returns the other team, or ROLE_UNSPECIFIED for non-team roles
+func opposingTeam(team telemetry.Role) telemetry.Role {
+	switch team {
+	case telemetry.Role_ROLE_BLUE_TEAM:
+		return telemetry.Role_ROLE_ORANGE_TEAM
+	case telemetry.Role_ROLE_ORANGE_TEAM:
+		return telemetry.Role_ROLE_BLUE_TEAM
+	}
+	return telemetry.Role_ROLE_UNSPECIFIED
+}
@@ -0,0 +1,274 @@
+package events
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// movementTestPlayer describes a player in a MovementSensor test frame
+type movementTestPlayer struct {
+	slot     int32
+	position []float64
+	velocity []float64
+	account  uint64
+}
+
+// Helper to create a timestamped frame from test players
+func createMovementFrame(at time.Duration, status string, players ...movementTestPlayer) *telemetry.LobbySessionStateFrame {
+	var members []*apigame.TeamMember
+	for _, p := range players {
+		members = append(members, &apigame.TeamMember{
+			SlotNumber:    p.slot,
+			AccountNumber: p.account,
+			DisplayName:   "Player",
+			Body:          &apigame.BodyPart{Position: p.position},
+			Velocity:      p.velocity,
+		})
+	}
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: status,
+			Teams:      []*apigame.Team{{Players: members}},
+		},
+	}
+}
+
+func TestMovementSensor_DistanceSpeedAndZones(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(0))
+
+	// Blue player runs from its own third to the offensive third, orange stays in its own defensive third
+	frames := []*telemetry.LobbySessionStateFrame{
+		createMovementFrame(0, GameStatusPlaying,
+			movementTestPlayer{slot: 0, position: []float64{0, 0, -20}, velocity: []float64{0, 0, 3}},
+			movementTestPlayer{slot: 4, position: []float64{0, 0, 30}}),
+		createMovementFrame(2*time.Second, GameStatusPlaying,
+			movementTestPlayer{slot: 0, position: []float64{0, 0, -14}, velocity: []float64{0, 0, 3}},
+			movementTestPlayer{slot: 4, position: []float64{0, 0, 30}}),
+		createMovementFrame(4*time.Second, GameStatusPlaying,
+			movementTestPlayer{slot: 0, position: []float64{0, 0, 6}, velocity: []float64{0, 0, 10}},
+			movementTestPlayer{slot: 4, position: []float64{0, 0, 30}}),
+		createMovementFrame(6*time.Second, GameStatusPlaying,
+			movementTestPlayer{slot: 0, position: []float64{0, 0, 16}, velocity: []float64{0, 0, 5}},
+			movementTestPlayer{slot: 4, position: []float64{0, 0, 30}}),
+	}
+	for _, frame := range frames {
+		collectCustomEvents(t, sensor, frame)
+	}
+
+	blue, ok := sensor.PlayerMovement(0)
+	if !ok {
+		t.Fatal("expected movement for slot 0")
+	}
+	if blue.Distance != 36 || blue.TopSpeed != 10 || blue.PlayingTime != 6*time.Second {
+		t.Errorf("expected 36m over 6s with a 10m/s top speed, got %+v", blue)
+	}
+	if blue.AverageSpeed() != 6 {
+		t.Errorf("expected an average speed of 6m/s, got %v", blue.AverageSpeed())
+	}
+	// Zone time is credited to where the player was at the start of each interval
+	if blue.ZoneTime[ZoneDefensive] != 4*time.Second || blue.ZoneTime[ZoneMidfield] != 2*time.Second {
+		t.Errorf("unexpected blue zone times: %v", blue.ZoneTime)
+	}
+
+	orange, _ := sensor.PlayerMovement(4)
+	if orange.ZoneTime[ZoneDefensive] != 6*time.Second || orange.Distance != 0 {
+		t.Errorf("expected orange to stay in its defensive third, got %+v", orange)
+	}
+}
+
+func TestMovementSensor_OnlyCountsPlayingTime(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(0))
+
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 0}}))
+	collectCustomEvents(t, sensor, createMovementFrame(time.Second, GameStatusScore, movementTestPlayer{slot: 0, position: []float64{0, 0, 5}}))
+	collectCustomEvents(t, sensor, createMovementFrame(2*time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 10}}))
+
+	if got, _ := sensor.PlayerMovement(0); got.Distance != 0 || got.PlayingTime != 0 {
+		t.Errorf("expected no movement outside play, got %+v", got)
+	}
+}
+
+func TestMovementSensor_PauseDoesNotCount(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(0))
+
+	// game_status stays playing through a pause; the pause is in session.pause
+	paused := createMovementFrame(time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 1}})
+	paused.Session.Pause = &apigame.PauseState{PausedState: "paused"}
+
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 0}}))
+	collectCustomEvents(t, sensor, paused)
+	collectCustomEvents(t, sensor, createMovementFrame(5*time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 1}}))
+	collectCustomEvents(t, sensor, createMovementFrame(6*time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 2}}))
+
+	if got, _ := sensor.PlayerMovement(0); got.PlayingTime != time.Second || got.Distance != 1 {
+		t.Errorf("expected 1s and 1m of play after the pause, got %v and %vm", got.PlayingTime, got.Distance)
+	}
+}
+
+func TestMovementSensor_IgnoresTeleports(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(0))
+
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 30}}))
+	collectCustomEvents(t, sensor, createMovementFrame(100*time.Millisecond, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, -30}}))
+
+	if got, _ := sensor.PlayerMovement(0); got.Distance != 0 || got.TopSpeed != 0 {
+		t.Errorf("expected a respawn not to count as movement, got %+v", got)
+	}
+}
+
+func TestMovementSensor_SpeedBurst(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(0))
+
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 0}, velocity: []float64{0, 0, 1}}))
+	events := collectCustomEvents(t, sensor, createMovementFrame(500*time.Millisecond, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 1}, velocity: []float64{0, 0, 7}}))
+
+	if len(events) != 1 || events[0].Name != SpeedBurstEventName {
+		t.Fatalf("expected a speed_burst event, got %v", events)
+	}
+	if events[0].Fields["speed_before"] != 1.0 || events[0].Fields["speed_after"] != 7.0 || events[0].Fields["acceleration"] != 12.0 {
+		t.Errorf("unexpected speed_burst fields: %v", events[0].Fields)
+	}
+
+	// A second burst within the cooldown is not reported
+	collectCustomEvents(t, sensor, createMovementFrame(600*time.Millisecond, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 2}, velocity: []float64{0, 0, 1}}))
+	events = collectCustomEvents(t, sensor, createMovementFrame(900*time.Millisecond, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 3}, velocity: []float64{0, 0, 8}}))
+	if len(events) != 0 {
+		t.Errorf("expected no burst within the cooldown, got %v", events)
+	}
+	if got, _ := sensor.PlayerMovement(0); got.Bursts != 1 {
+		t.Errorf("expected 1 burst, got %d", got.Bursts)
+	}
+}
+
+func TestMovementSensor_GoalCrease(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(0))
+
+	// Blue defends the goal at negative z
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, -25}}))
+	events := collectCustomEvents(t, sensor, createMovementFrame(3*time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, -34}}))
+	if len(events) != 1 || events[0].Name != GoalCreaseEnteredEventName {
+		t.Fatalf("expected a goal_crease_entered event, got %v", events)
+	}
+	if events[0].Fields["goal_team"] != "ROLE_BLUE_TEAM" || events[0].Fields["defending"] != true {
+		t.Errorf("expected blue to enter its own crease, got %v", events[0].Fields)
+	}
+
+	events = collectCustomEvents(t, sensor, createMovementFrame(6*time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, -25}}))
+	if len(events) != 1 || events[0].Name != GoalCreaseExitedEventName || events[0].Fields["duration"] != 3.0 {
+		t.Errorf("expected a goal_crease_exited event after 3s, got %v", events)
+	}
+}
+
+func TestMovementSensor_PeriodicSummary(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(10 * time.Second))
+
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying,
+		movementTestPlayer{slot: 4, position: []float64{0, 0, 0}},
+		movementTestPlayer{slot: 0, position: []float64{0, 0, 0}}))
+	if events := collectCustomEvents(t, sensor, createMovementFrame(5*time.Second, GameStatusPlaying,
+		movementTestPlayer{slot: 4, position: []float64{0, 0, 0}},
+		movementTestPlayer{slot: 0, position: []float64{3, 0, 4}})); len(events) != 0 {
+		t.Fatalf("expected no summary before the interval, got %v", events)
+	}
+
+	events := collectCustomEvents(t, sensor, createMovementFrame(10*time.Second, GameStatusPlaying,
+		movementTestPlayer{slot: 4, position: []float64{0, 0, 0}},
+		movementTestPlayer{slot: 0, position: []float64{3, 0, 4}}))
+	if len(events) != 2 || events[0].Name != MovementSummaryEventName {
+		t.Fatalf("expected a summary per player, got %v", events)
+	}
+	if events[0].Fields["player_slot"] != float64(0) || events[1].Fields["player_slot"] != float64(4) {
+		t.Errorf("expected summaries in slot order, got %v", events)
+	}
+	if events[0].Fields["distance"] != 5.0 || math.Abs(events[0].Fields["average_speed"].(float64)-0.5) > 1e-9 {
+		t.Errorf("unexpected summary fields: %v", events[0].Fields)
+	}
+}
+
+func TestMovementSensor_FinalSummaryOnDeparture(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(time.Minute))
+
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying,
+		movementTestPlayer{slot: 0, position: []float64{0, 0, 0}},
+		movementTestPlayer{slot: 1, position: []float64{0, 0, 0}}))
+	collectCustomEvents(t, sensor, createMovementFrame(5*time.Second, GameStatusPlaying,
+		movementTestPlayer{slot: 0, position: []float64{0, 0, 0}},
+		movementTestPlayer{slot: 1, position: []float64{0, 0, 3}}))
+
+	events := collectCustomEvents(t, sensor, createMovementFrame(6*time.Second, GameStatusPlaying,
+		movementTestPlayer{slot: 0, position: []float64{0, 0, 0}}))
+	if len(events) != 1 || events[0].Name != MovementSummaryEventName {
+		t.Fatalf("expected a summary for the departed player, got %v", events)
+	}
+	if events[0].Fields["player_slot"] != float64(1) || events[0].Fields["distance"] != 3.0 || events[0].Fields["final"] != true {
+		t.Errorf("unexpected departure summary: %v", events[0].Fields)
+	}
+}
+
+func TestMovementSensor_NewOccupantStartsFromZero(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(time.Minute))
+
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, account: 1, position: []float64{0, 0, 0}}))
+	collectCustomEvents(t, sensor, createMovementFrame(time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, account: 1, position: []float64{0, 0, 4}}))
+
+	events := collectCustomEvents(t, sensor, createMovementFrame(2*time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, account: 2, position: []float64{0, 0, 0}}))
+	if len(events) != 1 || events[0].Fields["distance"] != 4.0 || events[0].Fields["final"] != true {
+		t.Fatalf("expected a final summary for the previous occupant, got %v", events)
+	}
+	if got, _ := sensor.PlayerMovement(0); got.Distance != 0 || got.PlayingTime != 0 {
+		t.Errorf("expected the new occupant to start from zero, got %+v", got)
+	}
+}
+
+func TestMovementSensor_FinalSummaryAtMatchEnd(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(10 * time.Second))
+
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 0}}))
+	collectCustomEvents(t, sensor, createMovementFrame(4*time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 2}}))
+
+	events := collectCustomEvents(t, sensor, createMovementFrame(5*time.Second, GameStatusPostMatch, movementTestPlayer{slot: 0, position: []float64{0, 0, 2}}))
+	if len(events) != 1 || events[0].Fields["distance"] != 2.0 || events[0].Fields["final"] != true {
+		t.Fatalf("expected a final summary at post_match, got %v", events)
+	}
+
+	// No more periodic summaries once the match is over
+	if events := collectCustomEvents(t, sensor, createMovementFrame(30*time.Second, GameStatusPostMatch, movementTestPlayer{slot: 0, position: []float64{0, 0, 2}})); len(events) != 0 {
+		t.Errorf("expected no summaries after the match, got %v", events)
+	}
+}
+
+func TestMovementSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewMovementSensor(WithMovementSummaryInterval(0))
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 0}}))
+	collectCustomEvents(t, sensor, createMovementFrame(time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 2}}))
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewMovementSensor(WithMovementSummaryInterval(0))
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	collectCustomEvents(t, restored, createMovementFrame(2*time.Second, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 5}}))
+	if got, _ := restored.PlayerMovement(0); got.Distance != 5 || got.PlayingTime != 2*time.Second {
+		t.Errorf("expected 5m over 2s after restore, got %+v", got)
+	}
+}
+
+func TestMovementSensor_Reset(t *testing.T) {
+	sensor := NewMovementSensor()
+	collectCustomEvents(t, sensor, createMovementFrame(0, GameStatusPlaying, movementTestPlayer{slot: 0, position: []float64{0, 0, 0}}))
+
+	sensor.Reset()
+	if got := sensor.AllMovement(); len(got) != 0 {
+		t.Errorf("expected Reset to clear movement stats, got %v", got)
+	}
+}
@@ -0,0 +1,421 @@
+package events
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Custom event names emitted by NetworkQualitySensor
+const (
+	PingThresholdEventName      = "ping_threshold"
+	PingSpikeEventName          = "ping_spike"
+	PacketLossEventName         = "packet_loss"
+	PacketLossEndedEventName    = "packet_loss_ended"
+	PlayerReconnectedEventName  = "player_reconnected"
+	PlayerDisconnectedEventName = "player_disconnected"
+)
+
+// Default NetworkQualitySensor settings
+const (
+	DefaultPingSpikeFactor      = 2.0
+	DefaultPingSpikeMinDelta    = 50
+	DefaultPingMedianSamples    = 120
+	DefaultPacketLossThreshold  = 0.05
+	DefaultPacketLossSustain    = 3 * time.Second
+	DefaultReconnectWindow      = 30 * time.Second
+	minPingSamplesForSpikeCheck = 10
+)
+
+// DefaultPingThresholds are the ping levels, in milliseconds, that NetworkQualitySensor reports crossing
+var DefaultPingThresholds = []int32{100, 150, 250}
+
+// NetworkQualityOption configures a NetworkQualitySensor
+type NetworkQualityOption func(*NetworkQualitySensor)
+
+// WithPingThresholds sets the ping levels, in milliseconds, whose crossing is reported
+func WithPingThresholds(thresholds ...int32) NetworkQualityOption {
+	return func(s *NetworkQualitySensor) {
+		s.pingThresholds = slices.Clone(thresholds)
+		slices.Sort(s.pingThresholds)
+	}
+}
+
+// WithPingSpike sets when a ping counts as a spike: at least factor times the
+// player's rolling median over the last samples frames, and at least minDelta
+// milliseconds above it
+func WithPingSpike(factor float64, minDelta int32, samples int) NetworkQualityOption {
+	return func(s *NetworkQualitySensor) {
+		s.spikeFactor = factor
+		s.spikeMinDelta = minDelta
+		if samples > 0 {
+			s.medianSamples = samples
+		}
+	}
+}
+
+// WithPacketLoss sets the packet loss ratio that must be exceeded for sustain to be reported
+func WithPacketLoss(threshold float64, sustain time.Duration) NetworkQualityOption {
+	return func(s *NetworkQualitySensor) {
+		s.lossThreshold = threshold
+		s.lossSustain = sustain
+	}
+}
+
+// WithReconnectWindow sets how long after leaving a player can rejoin and count as reconnecting
+func WithReconnectWindow(window time.Duration) NetworkQualityOption {
+	return func(s *NetworkQualitySensor) {
+		s.reconnectWindow = window
+	}
+}
+
+// WithJoinLeaveEvents makes the sensor report PlayerJoined and PlayerLeft,
+// for use in place of PlayerJoinSensor and PlayerLeaveSensor. A reconnect is
+// then reported only as player_reconnected, not also as a leave and a join,
+// and PlayerLeft is held back until the reconnect window passes. See
+// DefaultSensorsWithNetwork.
+func WithJoinLeaveEvents() NetworkQualityOption {
+	return func(s *NetworkQualitySensor) {
+		s.reportJoins = true
+		s.reportLeaves = true
+	}
+}
+
+// networkPlayerState is the per-player state tracked by NetworkQualitySensor
+type networkPlayerState struct {
+	AccountNumber uint64
+	DisplayName   string
+	// Pings is a rolling window of recent pings, oldest first
+	Pings []int32
+	// SortedPings holds the same pings in ascending order, for the median
+	SortedPings []int32
+	// Level is the number of thresholds the ping is at or above
+	Level    int
+	Spiking  bool
+	LossFrom time.Time
+	Lossy    bool
+	// LossReported is set once sustained packet loss has been reported
+	LossReported bool
+}
+
+// networkDeparture is a player who left and may reconnect
+type networkDeparture struct {
+	Slot          int32
+	AccountNumber uint64
+	DisplayName   string
+	LeftAt        time.Time
+}
+
+// NetworkQualitySensor watches each player's ping and packet loss. It emits
+// ping_threshold when a ping crosses one of the configured thresholds,
+// ping_spike when a ping jumps well above the player's rolling median, and
+// packet_loss / packet_loss_ended around sustained packet loss.
+//
+// It also reports departures with reconnects in mind: a player who leaves
+// and rejoins with the same account within the reconnect window produces a
+// single player_reconnected event, and player_disconnected is only emitted
+// once the window passes without a rejoin. A player without an account
+// cannot be matched, so their departure is reported straight away.
+//
+// With WithJoinLeaveEvents, it also reports PlayerJoined and PlayerLeft in
+// place of PlayerJoinSensor and PlayerLeaveSensor.
+type NetworkQualitySensor struct {
+	pingThresholds  []int32
+	spikeFactor     float64
+	spikeMinDelta   int32
+	medianSamples   int
+	lossThreshold   float64
+	lossSustain     time.Duration
+	reconnectWindow time.Duration
+	// Set by WithJoinLeaveEvents
+	reportJoins  bool
+	reportLeaves bool
+
+	players    map[int32]*networkPlayerState
+	departures []networkDeparture
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewNetworkQualitySensor creates a new NetworkQualitySensor
+func NewNetworkQualitySensor(opts ...NetworkQualityOption) *NetworkQualitySensor {
+	s := &NetworkQualitySensor{
+		pingThresholds:  slices.Clone(DefaultPingThresholds),
+		spikeFactor:     DefaultPingSpikeFactor,
+		spikeMinDelta:   DefaultPingSpikeMinDelta,
+		medianSamples:   DefaultPingMedianSamples,
+		lossThreshold:   DefaultPacketLossThreshold,
+		lossSustain:     DefaultPacketLossSustain,
+		reconnectWindow: DefaultReconnectWindow,
+		players:         make(map[int32]*networkPlayerState),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reset clears player state, pending departures and any pending events
+func (s *NetworkQualitySensor) Reset() {
+	s.players = make(map[int32]*networkPlayerState)
+	s.departures = nil
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// networkQualityState is the snapshot form of NetworkQualitySensor
+type networkQualityState struct {
+	Players    map[int32]*networkPlayerState
+	Departures []networkDeparture
+}
+
+// Snapshot returns player state and pending departures
+func (s *NetworkQualitySensor) Snapshot() ([]byte, error) {
+	return encodeSnapshot(networkQualityState{Players: s.players, Departures: s.departures})
+}
+
+// Restore replaces player state and pending departures with a snapshot
+func (s *NetworkQualitySensor) Restore(data []byte) error {
+	var state networkQualityState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	if state.Players != nil {
+		s.players = state.Players
+	}
+	for _, player := range s.players {
+		player.SortedPings = slices.Sorted(slices.Values(player.Pings))
+	}
+	s.departures = state.Departures
+	return nil
+}
+
+// AddFrame processes a frame and returns network quality and reconnect events
+func (s *NetworkQualitySensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	if frame != nil && frame.GetSession() != nil {
+		s.processFrame(frame)
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// processFrame updates every player's network state from a frame
+func (s *NetworkQualitySensor) processFrame(frame *telemetry.LobbySessionStateFrame) {
+	at := frame.GetTimestamp().AsTime()
+	current := extractPlayersMap(frame.GetSession())
+
+	// Departures first, so a player who changed slots is matched on this frame
+	var left []int32
+	for slot := range s.players {
+		if _, ok := current[slot]; !ok {
+			left = append(left, slot)
+		}
+	}
+	slices.Sort(left)
+	for _, slot := range left {
+		state := s.players[slot]
+		d := networkDeparture{
+			Slot:          slot,
+			AccountNumber: state.AccountNumber,
+			DisplayName:   state.DisplayName,
+			LeftAt:        at,
+		}
+		delete(s.players, slot)
+		if d.AccountNumber == 0 {
+			s.disconnect(d)
+			continue
+		}
+		s.departures = append(s.departures, d)
+	}
+
+	for _, team := range frame.GetSession().GetTeams() {
+		for _, player := range team.GetPlayers() {
+			slot := player.GetSlotNumber()
+			state, ok := s.players[slot]
+			if !ok {
+				state = &networkPlayerState{AccountNumber: player.GetAccountNumber(), DisplayName: player.GetDisplayName()}
+				s.players[slot] = state
+				if !s.checkReconnect(player, at) && s.reportJoins {
+					s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+						Event: &telemetry.LobbySessionEvent_PlayerJoined{
+							PlayerJoined: &telemetry.PlayerJoined{
+								// Pooled frames reuse their messages, so send a copy
+								Player: proto.CloneOf(player),
+								Role:   determinePlayerRole(player),
+							},
+						},
+					})
+				}
+			}
+			s.checkPing(player, state)
+			s.checkPacketLoss(player, state, at)
+		}
+	}
+
+	// Departures that were not followed by a rejoin in time are disconnects
+	s.departures = slices.DeleteFunc(s.departures, func(d networkDeparture) bool {
+		if at.Sub(d.LeftAt) < s.reconnectWindow {
+			return false
+		}
+		s.disconnect(d)
+		return true
+	})
+}
+
+// disconnect reports a departure that will not be followed by a reconnect
+func (s *NetworkQualitySensor) disconnect(d networkDeparture) {
+	s.leave(d)
+	s.pendingEvents = append(s.pendingEvents, newCustomEvent(PlayerDisconnectedEventName, map[string]any{
+		"player_slot":    d.Slot,
+		"display_name":   d.DisplayName,
+		"account_number": strconv.FormatUint(d.AccountNumber, 10),
+	}))
+}
+
+// leave reports the raw PlayerLeft for a departure, if this sensor reports leaves
+func (s *NetworkQualitySensor) leave(d networkDeparture) {
+	if !s.reportLeaves {
+		return
+	}
+	s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_PlayerLeft{
+			PlayerLeft: &telemetry.PlayerLeft{
+				PlayerSlot:  d.Slot,
+				DisplayName: d.DisplayName,
+			},
+		},
+	})
+}
+
+// checkReconnect matches a newly seen player against recent departures by
+// account. It returns true if the player is reconnecting.
+func (s *NetworkQualitySensor) checkReconnect(player *apigame.TeamMember, at time.Time) bool {
+	account := player.GetAccountNumber()
+	if account == 0 {
+		return false
+	}
+	i := slices.IndexFunc(s.departures, func(d networkDeparture) bool {
+		return d.AccountNumber == account
+	})
+	if i < 0 {
+		return false
+	}
+
+	d := s.departures[i]
+	s.departures = slices.Delete(s.departures, i, i+1)
+	if !at.After(d.LeftAt) {
+		// Moved slots on the same frame, e.g. a team switch, without
+		// disconnecting; the old slot is left and the new one joined
+		s.leave(d)
+		return false
+	}
+	s.pendingEvents = append(s.pendingEvents, newCustomEvent(PlayerReconnectedEventName, map[string]any{
+		"player_slot":         player.GetSlotNumber(),
+		"previous_slot":       d.Slot,
+		"display_name":        player.GetDisplayName(),
+		"team":                determinePlayerRole(player).String(),
+		"account_number":      strconv.FormatUint(account, 10),
+		"disconnect_duration": at.Sub(d.LeftAt).Seconds(),
+	}))
+	return true
+}
+
+// checkPing reports threshold crossings and spikes relative to the rolling median
+func (s *NetworkQualitySensor) checkPing(player *apigame.TeamMember, state *networkPlayerState) {
+	ping := player.GetPing()
+
+	level := 0
+	for level < len(s.pingThresholds) && ping >= s.pingThresholds[level] {
+		level++
+	}
+	if len(state.Pings) > 0 && level != state.Level {
+		// Report the highest threshold crossed, in either direction
+		direction, threshold := "above", s.pingThresholds[max(level, state.Level)-1]
+		if level < state.Level {
+			direction = "below"
+		}
+		s.pendingEvents = append(s.pendingEvents, s.networkEvent(PingThresholdEventName, player, map[string]any{
+			"ping":      ping,
+			"threshold": threshold,
+			"direction": direction,
+		}))
+	}
+	state.Level = level
+
+	if len(state.Pings) >= minPingSamplesForSpikeCheck {
+		median := medianPing(state.SortedPings)
+		spiking := float64(ping) >= float64(median)*s.spikeFactor && ping-median >= s.spikeMinDelta
+		if spiking && !state.Spiking {
+			s.pendingEvents = append(s.pendingEvents, s.networkEvent(PingSpikeEventName, player, map[string]any{
+				"ping":   ping,
+				"median": median,
+			}))
+		}
+		state.Spiking = spiking
+	}
+
+	// Keep the window sorted as it slides, rather than sorting it every frame
+	for len(state.Pings) >= s.medianSamples {
+		oldest := state.Pings[0]
+		state.Pings = append(state.Pings[:0], state.Pings[1:]...)
+		i, _ := slices.BinarySearch(state.SortedPings, oldest)
+		state.SortedPings = slices.Delete(state.SortedPings, i, i+1)
+	}
+	state.Pings = append(state.Pings, ping)
+	i, _ := slices.BinarySearch(state.SortedPings, ping)
+	state.SortedPings = slices.Insert(state.SortedPings, i, ping)
+}
+
+// checkPacketLoss reports packet loss above the threshold once it has lasted long enough
+func (s *NetworkQualitySensor) checkPacketLoss(player *apigame.TeamMember, state *networkPlayerState, at time.Time) {
+	loss := player.GetPacketLossRatio()
+	if loss <= s.lossThreshold {
+		if state.LossReported {
+			s.pendingEvents = append(s.pendingEvents, s.networkEvent(PacketLossEndedEventName, player, map[string]any{
+				"duration": at.Sub(state.LossFrom).Seconds(),
+			}))
+		}
+		state.Lossy = false
+		state.LossReported = false
+		return
+	}
+
+	if !state.Lossy {
+		state.Lossy = true
+		state.LossFrom = at
+	}
+	if !state.LossReported && at.Sub(state.LossFrom) >= s.lossSustain {
+		state.LossReported = true
+		s.pendingEvents = append(s.pendingEvents, s.networkEvent(PacketLossEventName, player, map[string]any{
+			"packet_loss_ratio": loss,
+			"duration":          at.Sub(state.LossFrom).Seconds(),
+		}))
+	}
+}
+
+// networkEvent builds a network custom event for a player
+func (s *NetworkQualitySensor) networkEvent(name string, player *apigame.TeamMember, fields map[string]any) *telemetry.LobbySessionEvent {
+	fields["player_slot"] = player.GetSlotNumber()
+	fields["display_name"] = player.GetDisplayName()
+	fields["team"] = determinePlayerRole(player).String()
+	return newCustomEvent(name, fields)
+}
+
+// medianPing returns the median of samples sorted in ascending order
+func medianPing(sorted []int32) int32 {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,308 @@
+package events
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Helper to create a timestamped frame from players
+func createNetworkFrame(at time.Duration, players ...*apigame.TeamMember) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(at)),
+		Session: &apigame.SessionResponse{
+			Teams: []*apigame.Team{{Players: players}},
+		},
+	}
+}
+
+func createNetworkPlayer(slot int32, account uint64, ping int32, loss float64) *apigame.TeamMember {
+	return &apigame.TeamMember{SlotNumber: slot, AccountNumber: account, DisplayName: "Player", Ping: ping, PacketLossRatio: loss}
+}
+
+func TestNetworkQualitySensor_PingThresholds(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithPingThresholds(150, 100))
+
+	steps := []struct {
+		ping      int32
+		threshold float64
+		direction string
+	}{
+		{ping: 50},
+		{ping: 90},
+		{ping: 120, threshold: 100, direction: "above"},
+		{ping: 130},
+		{ping: 200, threshold: 150, direction: "above"},
+		{ping: 60, threshold: 150, direction: "below"},
+	}
+
+	for i, step := range steps {
+		frame := createNetworkFrame(time.Duration(i)*time.Second, createNetworkPlayer(1, 42, step.ping, 0))
+		events := collectCustomEvents(t, sensor, frame)
+		if step.direction == "" {
+			if len(events) != 0 {
+				t.Errorf("step %d: expected no events, got %v", i, events)
+			}
+			continue
+		}
+		if len(events) != 1 || events[0].Name != PingThresholdEventName {
+			t.Fatalf("step %d: expected a ping_threshold event, got %v", i, events)
+		}
+		if events[0].Fields["threshold"] != step.threshold || events[0].Fields["direction"] != step.direction {
+			t.Errorf("step %d: expected %s %v, got %v", i, step.direction, step.threshold, events[0].Fields)
+		}
+	}
+}
+
+func TestNetworkQualitySensor_PingSpike(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithPingThresholds(), WithPingSpike(2, 50, 20))
+
+	for i := 0; i < 15; i++ {
+		frame := createNetworkFrame(time.Duration(i)*time.Second, createNetworkPlayer(1, 42, 40, 0))
+		if events := collectCustomEvents(t, sensor, frame); len(events) != 0 {
+			t.Fatalf("expected no events at a steady ping, got %v", events)
+		}
+	}
+
+	events := collectCustomEvents(t, sensor, createNetworkFrame(16*time.Second, createNetworkPlayer(1, 42, 120, 0)))
+	if len(events) != 1 || events[0].Name != PingSpikeEventName || events[0].Fields["median"] != float64(40) {
+		t.Fatalf("expected a ping_spike over a median of 40, got %v", events)
+	}
+
+	// The spike is only reported once while it lasts
+	if events := collectCustomEvents(t, sensor, createNetworkFrame(17*time.Second, createNetworkPlayer(1, 42, 125, 0))); len(events) != 0 {
+		t.Errorf("expected no repeat spike event, got %v", events)
+	}
+}
+
+func TestNetworkQualitySensor_SustainedPacketLoss(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithPacketLoss(0.05, 2*time.Second))
+
+	losses := []float64{0, 0.1, 0.2, 0.1, 0.15, 0}
+	var names []string
+	for i, loss := range losses {
+		frame := createNetworkFrame(time.Duration(i)*time.Second, createNetworkPlayer(1, 42, 40, loss))
+		for _, event := range collectCustomEvents(t, sensor, frame) {
+			names = append(names, event.Name)
+			if event.Name == PacketLossEndedEventName && event.Fields["duration"] != 4.0 {
+				t.Errorf("expected 4s of packet loss, got %v", event.Fields["duration"])
+			}
+		}
+	}
+
+	if len(names) != 2 || names[0] != PacketLossEventName || names[1] != PacketLossEndedEventName {
+		t.Errorf("expected packet_loss then packet_loss_ended, got %v", names)
+	}
+}
+
+func TestNetworkQualitySensor_BriefPacketLossIgnored(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithPacketLoss(0.05, 2*time.Second))
+
+	for i, loss := range []float64{0, 0.3, 0, 0.3, 0} {
+		frame := createNetworkFrame(time.Duration(i)*time.Second, createNetworkPlayer(1, 42, 40, loss))
+		if events := collectCustomEvents(t, sensor, frame); len(events) != 0 {
+			t.Errorf("expected no events for brief packet loss, got %v", events)
+		}
+	}
+}
+
+func TestNetworkQualitySensor_Reconnect(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithReconnectWindow(10 * time.Second))
+
+	collectCustomEvents(t, sensor, createNetworkFrame(0, createNetworkPlayer(1, 42, 40, 0), createNetworkPlayer(2, 43, 40, 0)))
+	collectCustomEvents(t, sensor, createNetworkFrame(1*time.Second, createNetworkPlayer(2, 43, 40, 0)))
+
+	// The same account returns in a different slot within the window
+	events := collectCustomEvents(t, sensor, createNetworkFrame(5*time.Second, createNetworkPlayer(2, 43, 40, 0), createNetworkPlayer(3, 42, 40, 0)))
+	if len(events) != 1 || events[0].Name != PlayerReconnectedEventName {
+		t.Fatalf("expected a player_reconnected event, got %v", events)
+	}
+	fields := events[0].Fields
+	if fields["player_slot"] != float64(3) || fields["previous_slot"] != float64(1) || fields["disconnect_duration"] != 4.0 {
+		t.Errorf("unexpected reconnect fields: %v", fields)
+	}
+	if fields["account_number"] != "42" {
+		t.Errorf("expected account 42, got %v", fields["account_number"])
+	}
+
+	// No disconnect is reported once the window passes
+	if events := collectCustomEvents(t, sensor, createNetworkFrame(20*time.Second, createNetworkPlayer(2, 43, 40, 0), createNetworkPlayer(3, 42, 40, 0))); len(events) != 0 {
+		t.Errorf("expected no events after a reconnect, got %v", events)
+	}
+}
+
+func TestNetworkQualitySensor_Disconnect(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithReconnectWindow(10 * time.Second))
+
+	collectCustomEvents(t, sensor, createNetworkFrame(0, createNetworkPlayer(1, 42, 40, 0)))
+	if events := collectCustomEvents(t, sensor, createNetworkFrame(1*time.Second)); len(events) != 0 {
+		t.Fatalf("expected no events while the player may reconnect, got %v", events)
+	}
+
+	events := collectCustomEvents(t, sensor, createNetworkFrame(11*time.Second))
+	if len(events) != 1 || events[0].Name != PlayerDisconnectedEventName || events[0].Fields["player_slot"] != float64(1) {
+		t.Fatalf("expected a player_disconnected event, got %v", events)
+	}
+
+	// Rejoining after the window is a fresh join
+	if events := collectCustomEvents(t, sensor, createNetworkFrame(12*time.Second, createNetworkPlayer(1, 42, 40, 0))); len(events) != 0 {
+		t.Errorf("expected no reconnect after the window, got %v", events)
+	}
+}
+
+func TestNetworkQualitySensor_SlotMoveIsNotReconnect(t *testing.T) {
+	sensor := NewNetworkQualitySensor()
+
+	collectCustomEvents(t, sensor, createNetworkFrame(0, createNetworkPlayer(1, 42, 40, 0)))
+	if events := collectCustomEvents(t, sensor, createNetworkFrame(time.Second, createNetworkPlayer(5, 42, 40, 0))); len(events) != 0 {
+		t.Errorf("expected no events for a slot move, got %v", events)
+	}
+}
+
+func TestNetworkQualitySensor_SnapshotRestore(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithReconnectWindow(10 * time.Second))
+	collectCustomEvents(t, sensor, createNetworkFrame(0, createNetworkPlayer(1, 42, 40, 0)))
+	collectCustomEvents(t, sensor, createNetworkFrame(time.Second))
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewNetworkQualitySensor(WithReconnectWindow(10 * time.Second))
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	events := collectCustomEvents(t, restored, createNetworkFrame(3*time.Second, createNetworkPlayer(1, 42, 40, 0)))
+	if len(events) != 1 || events[0].Name != PlayerReconnectedEventName {
+		t.Errorf("expected the restored sensor to recognize the reconnect, got %v", events)
+	}
+}
+
+func TestNetworkQualitySensor_Reset(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithReconnectWindow(10 * time.Second))
+	collectCustomEvents(t, sensor, createNetworkFrame(0, createNetworkPlayer(1, 42, 40, 0)))
+	collectCustomEvents(t, sensor, createNetworkFrame(time.Second))
+
+	sensor.Reset()
+	if events := collectCustomEvents(t, sensor, createNetworkFrame(20*time.Second)); len(events) != 0 {
+		t.Errorf("expected Reset to drop pending departures, got %v", events)
+	}
+}
+
+func TestNetworkQualitySensor_MedianWindowSlides(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithPingThresholds(), WithPingSpike(2, 50, 5))
+
+	pings := []int32{90, 10, 70, 30, 50, 20, 80, 40, 60}
+	for i, ping := range pings {
+		collectCustomEvents(t, sensor, createNetworkFrame(time.Duration(i)*time.Second, createNetworkPlayer(1, 42, ping, 0)))
+	}
+
+	state := sensor.players[1]
+	if !slices.Equal(state.Pings, []int32{50, 20, 80, 40, 60}) {
+		t.Fatalf("expected the last 5 pings, got %v", state.Pings)
+	}
+	if !slices.Equal(state.SortedPings, []int32{20, 40, 50, 60, 80}) {
+		t.Errorf("expected the window in ascending order, got %v", state.SortedPings)
+	}
+}
+
+func TestNetworkQualitySensor_ReportsJoinsAndLeaves(t *testing.T) {
+	detector := New(
+		WithSynchronousProcessing(),
+		WithSensors(DefaultSensorsWithNetwork(NewMatchPhaseTracker(), WithReconnectWindow(10*time.Second))...),
+	)
+	defer detector.Stop()
+
+	// Account 42 drops out of slot 1 and comes back in slot 3; account 43 leaves for good
+	var names []string
+	for i := 0; i < 20; i++ {
+		at := time.Duration(i) * time.Second
+		players := []*apigame.TeamMember{}
+		switch {
+		case i < 2:
+			players = append(players, createNetworkPlayer(1, 42, 40, 0))
+		case i >= 5:
+			players = append(players, createNetworkPlayer(3, 42, 40, 0))
+		}
+		if i < 6 {
+			players = append(players, createNetworkPlayer(2, 43, 40, 0))
+		}
+		detector.ProcessFrame(createNetworkFrame(at, players...))
+
+		select {
+		case events := <-detector.EventsChan():
+			for _, event := range events {
+				names = append(names, EventType(event))
+			}
+		default:
+		}
+	}
+
+	want := []string{"player_joined", "player_joined", "custom:" + PlayerReconnectedEventName, "player_left", "custom:" + PlayerDisconnectedEventName}
+	if !slices.Equal(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+}
+
+func TestNetworkQualitySensor_SlotMoveReportsLeaveAndJoin(t *testing.T) {
+	sensor := NewNetworkQualitySensor(WithJoinLeaveEvents())
+
+	sensor.AddFrame(createNetworkFrame(0, createNetworkPlayer(1, 42, 40, 0)))
+	var got []string
+	for event := sensor.AddFrame(createNetworkFrame(time.Second, createNetworkPlayer(5, 42, 40, 0))); event != nil; event = sensor.AddFrame(nil) {
+		got = append(got, EventType(event))
+	}
+	if want := []string{"player_left", "player_joined"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestNetworkQualitySensor_LeavesJoinSensorsAlone(t *testing.T) {
+	// Without WithJoinLeaveEvents, the join and leave sensors report as usual
+	detector := New(
+		WithSynchronousProcessing(),
+		WithSensors(NewPlayerJoinSensor(), NewPlayerLeaveSensor(), NewNetworkQualitySensor()),
+	)
+	defer detector.Stop()
+
+	var names []string
+	for i, players := range [][]*apigame.TeamMember{
+		{createNetworkPlayer(1, 42, 40, 0)},
+		{createNetworkPlayer(1, 42, 40, 0), createNetworkPlayer(2, 43, 40, 0)},
+		{createNetworkPlayer(1, 42, 40, 0)},
+	} {
+		detector.ProcessFrame(createNetworkFrame(time.Duration(i)*time.Second, players...))
+		select {
+		case events := <-detector.EventsChan():
+			for _, event := range events {
+				names = append(names, EventType(event))
+			}
+		default:
+		}
+	}
+
+	want := []string{"player_joined", "player_joined", "player_left"}
+	if !slices.Equal(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+}
+
+func TestDefaultSensorsWithNetwork(t *testing.T) {
+	var network *NetworkQualitySensor
+	for _, s := range DefaultSensorsWithNetwork(NewMatchPhaseTracker()) {
+		switch s := s.(type) {
+		case *PlayerJoinSensor, *PlayerLeaveSensor:
+			t.Errorf("expected no %T", s)
+		case *NetworkQualitySensor:
+			network = s
+		}
+	}
+	if network == nil || !network.reportJoins || !network.reportLeaves {
+		t.Errorf("expected a NetworkQualitySensor reporting joins and leaves, got %+v", network)
+	}
+}
@@ -0,0 +1,331 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Game status values that have no dedicated sensor
+const (
+	GameStatusPreMatch        = "pre_match"
+	GameStatusPreSuddenDeath  = "pre_sudden_death"
+	GameStatusSuddenDeath     = "sudden_death"
+	GameStatusPostSuddenDeath = "post_sudden_death"
+)
+
+// MatchPhaseEventName is the custom event emitted by MatchPhaseTracker on every phase change
+const MatchPhaseEventName = "match_phase"
+
+// MatchPhase is a phase of a match as modelled by MatchPhaseTracker
+type MatchPhase string
+
+const (
+	// PhaseUnknown is the phase before the first frame, or of frames without a recognizable status
+	PhaseUnknown    MatchPhase = ""
+	PhasePreMatch   MatchPhase = "pre_match"
+	PhaseRoundStart MatchPhase = "round_start"
+	PhasePlaying    MatchPhase = "playing"
+	PhaseScore      MatchPhase = "score"
+	PhaseRoundOver  MatchPhase = "round_over"
+	PhaseOvertime   MatchPhase = "overtime"
+	PhasePaused     MatchPhase = "paused"
+	PhaseUnpausing  MatchPhase = "unpausing"
+	PhasePostMatch  MatchPhase = "post_match"
+)
+
+// phaseTransitions lists the phases each phase may move to. Any phase may be
+// paused; paused and unpausing may only return to the phase that was paused.
+var phaseTransitions = map[MatchPhase][]MatchPhase{
+	PhasePreMatch:   {PhaseRoundStart, PhasePlaying, PhasePostMatch},
+	PhaseRoundStart: {PhasePlaying, PhasePreMatch, PhasePostMatch},
+	PhasePlaying:    {PhaseScore, PhaseRoundOver, PhaseOvertime, PhaseRoundStart, PhasePostMatch},
+	PhaseScore:      {PhaseRoundStart, PhasePlaying, PhaseRoundOver, PhaseOvertime, PhasePostMatch},
+	PhaseRoundOver:  {PhaseRoundStart, PhasePlaying, PhasePreMatch, PhaseOvertime, PhasePostMatch},
+	PhaseOvertime:   {PhaseScore, PhaseRoundOver, PhasePostMatch},
+	PhasePostMatch:  {PhasePreMatch, PhaseRoundStart},
+}
+
+// PhaseTransition describes a change of match phase
+type PhaseTransition struct {
+	From MatchPhase
+	To   MatchPhase
+	// At is the timestamp of the frame that entered To
+	At time.Time
+	// Duration is how long the match spent in From
+	Duration time.Duration
+}
+
+// MatchPhaseOption configures a MatchPhaseTracker
+type MatchPhaseOption func(*MatchPhaseTracker)
+
+// WithInvalidTransitionHandler sets a function called for every transition
+// that the phase model does not allow, such as playing to pre_match. The
+// tracker still follows the game into the new phase.
+func WithInvalidTransitionHandler(fn func(PhaseTransition)) MatchPhaseOption {
+	return func(t *MatchPhaseTracker) {
+		t.onInvalid = fn
+	}
+}
+
+// matchPhaseState is the snapshot form of MatchPhaseTracker
+type matchPhaseState struct {
+	Phase       MatchPhase
+	Previous    MatchPhase
+	ResumePhase MatchPhase
+	EnteredAt   time.Time
+	LastFrameAt time.Time
+	Durations   map[MatchPhase]time.Duration
+}
+
+// MatchPhaseTracker is a state machine over the match phases, driven by the
+// session's game status and pause state. The game state sensors and the
+// detector's built-in detection share one tracker so they agree on what
+// counts as a round start, round end, pause or match end; it can also be
+// added to a detector as a sensor to emit a match_phase custom event on
+// every transition.
+//
+// Update is idempotent for a given frame, so every sensor sharing the
+// tracker may call it regardless of order. Queries are safe from any goroutine.
+type MatchPhaseTracker struct {
+	mu        sync.Mutex
+	state     matchPhaseState
+	onInvalid func(PhaseTransition)
+
+	// Most recent frame and the transition it caused, for idempotent updates.
+	// The index and timestamp are compared too because pooled frames are reused.
+	lastFrame      *telemetry.LobbySessionStateFrame
+	lastFrameIndex uint32
+	lastFrameAt    time.Time
+	lastTransition PhaseTransition
+	lastChanged    bool
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewMatchPhaseTracker creates a new MatchPhaseTracker
+func NewMatchPhaseTracker(opts ...MatchPhaseOption) *MatchPhaseTracker {
+	t := &MatchPhaseTracker{state: matchPhaseState{Durations: make(map[MatchPhase]time.Duration)}}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Reset returns the tracker to PhaseUnknown and clears phase durations
+func (t *MatchPhaseTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state = matchPhaseState{Durations: make(map[MatchPhase]time.Duration)}
+	t.lastFrame = nil
+	t.lastTransition = PhaseTransition{}
+	t.lastChanged = false
+	t.pendingEvents = t.pendingEvents[:0]
+}
+
+// Phase returns the current phase
+func (t *MatchPhaseTracker) Phase() MatchPhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.Phase
+}
+
+// PreviousPhase returns the phase before the current one
+func (t *MatchPhaseTracker) PreviousPhase() MatchPhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.Previous
+}
+
+// TimeInPhase returns how long the match has been in the current phase, as of the latest frame
+func (t *MatchPhaseTracker) TimeInPhase() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.LastFrameAt.Sub(t.state.EnteredAt)
+}
+
+// PhaseDuration returns the total time spent in the phase, including the current visit
+func (t *MatchPhaseTracker) PhaseDuration(phase MatchPhase) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := t.state.Durations[phase]
+	if phase == t.state.Phase {
+		d += t.state.LastFrameAt.Sub(t.state.EnteredAt)
+	}
+	return d
+}
+
+// Snapshot returns the current phase and phase durations
+func (t *MatchPhaseTracker) Snapshot() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return encodeSnapshot(t.state)
+}
+
+// Restore replaces the current phase and phase durations with a snapshot
+func (t *MatchPhaseTracker) Restore(data []byte) error {
+	state, err := decodeMatchPhaseState(data)
+	if err != nil {
+		return err
+	}
+	t.setState(state)
+	return nil
+}
+
+// decodeMatchPhaseState decodes a snapshot taken by Snapshot
+func decodeMatchPhaseState(data []byte) (matchPhaseState, error) {
+	var state matchPhaseState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return state, err
+	}
+	if state.Durations == nil {
+		state.Durations = make(map[MatchPhase]time.Duration)
+	}
+	return state, nil
+}
+
+// setState replaces the tracker state with a decoded snapshot
+func (t *MatchPhaseTracker) setState(state matchPhaseState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state = state
+	t.lastFrame = nil
+	t.lastChanged = false
+	t.pendingEvents = t.pendingEvents[:0]
+}
+
+// AddFrame processes a frame and returns a match_phase custom event on each transition
+func (t *MatchPhaseTracker) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	if frame != nil && frame.GetSession() != nil {
+		if transition, ok := t.Update(frame); ok {
+			event := newCustomEvent(MatchPhaseEventName, map[string]any{
+				"from":     string(transition.From),
+				"to":       string(transition.To),
+				"duration": transition.Duration.Seconds(),
+			})
+			t.mu.Lock()
+			t.pendingEvents = append(t.pendingEvents, event)
+			t.mu.Unlock()
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pendingEvents) > 0 {
+		event := t.pendingEvents[0]
+		t.pendingEvents = t.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// Update advances the state machine with a frame and returns the transition
+// it caused, if any. Calling Update again with the same frame returns the
+// same result without changing state.
+func (t *MatchPhaseTracker) Update(frame *telemetry.LobbySessionStateFrame) (PhaseTransition, bool) {
+	at := frame.GetTimestamp().AsTime()
+
+	t.mu.Lock()
+	if frame == t.lastFrame && frame.GetFrameIndex() == t.lastFrameIndex && at.Equal(t.lastFrameAt) {
+		defer t.mu.Unlock()
+		return t.lastTransition, t.lastChanged
+	}
+
+	s := &t.state
+	s.LastFrameAt = at
+	t.lastFrame = frame
+	t.lastFrameIndex = frame.GetFrameIndex()
+	t.lastFrameAt = at
+	t.lastTransition = PhaseTransition{}
+	t.lastChanged = false
+
+	next := phaseFromFrame(frame)
+	if next == s.Phase {
+		t.mu.Unlock()
+		return PhaseTransition{}, false
+	}
+
+	transition := PhaseTransition{From: s.Phase, To: next, At: at, Duration: at.Sub(s.EnteredAt)}
+	if s.Phase == PhaseUnknown {
+		transition.Duration = 0
+	}
+	valid := s.allows(next)
+
+	if next == PhasePaused && s.Phase != PhaseUnpausing {
+		s.ResumePhase = s.Phase
+	}
+	s.Durations[s.Phase] += transition.Duration
+	s.Previous = s.Phase
+	s.Phase = next
+	s.EnteredAt = at
+
+	t.lastTransition = transition
+	t.lastChanged = true
+	onInvalid := t.onInvalid
+	t.mu.Unlock()
+
+	// Called without the lock so the handler can query the tracker
+	if !valid && onInvalid != nil {
+		onInvalid(transition)
+	}
+	return transition, true
+}
+
+// allows reports whether the phase model permits moving to the next phase.
+// Transitions to or from PhaseUnknown carry no information and are always allowed.
+func (s *matchPhaseState) allows(next MatchPhase) bool {
+	if s.Phase == PhaseUnknown || next == PhaseUnknown || next == PhasePaused {
+		return true
+	}
+	switch s.Phase {
+	case PhasePaused, PhaseUnpausing:
+		return next == PhaseUnpausing || next == s.ResumePhase
+	}
+	for _, allowed := range phaseTransitions[s.Phase] {
+		if next == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// phaseFromFrame maps a frame's pause state and game status to a phase.
+// The pause state takes precedence, since the game status keeps its
+// pre-pause value while a match is paused.
+func phaseFromFrame(frame *telemetry.LobbySessionStateFrame) MatchPhase {
+	session := frame.GetSession()
+
+	pauseState := session.GetPause().GetPausedState()
+	switch {
+	case isPausedState(pauseState):
+		return PhasePaused
+	case pauseState == GameStatusUnpausing:
+		return PhaseUnpausing
+	}
+
+	switch status := session.GetGameStatus(); status {
+	case GameStatusPreMatch:
+		return PhasePreMatch
+	case GameStatusRoundStart:
+		return PhaseRoundStart
+	case GameStatusPlaying:
+		return PhasePlaying
+	case GameStatusScore:
+		return PhaseScore
+	case GameStatusRoundOver, GameStatusPostSuddenDeath:
+		return PhaseRoundOver
+	case GameStatusPreSuddenDeath, GameStatusSuddenDeath:
+		return PhaseOvertime
+	case GameStatusPostMatch:
+		return PhasePostMatch
+	case GameStatusUnpausing:
+		return PhaseUnpausing
+	default:
+		if isPausedState(status) {
+			return PhasePaused
+		}
+		return PhaseUnknown
+	}
+}
@@ -0,0 +1,200 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Helper to create a timestamped frame with a game status and pause state
+func createPhaseFrame(at time.Duration, status, pauseState string) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: status,
+			Pause:      &apigame.PauseState{PausedState: pauseState},
+		},
+	}
+}
+
+func TestMatchPhaseTracker_TransitionsAndDurations(t *testing.T) {
+	var invalid []PhaseTransition
+	tracker := NewMatchPhaseTracker(WithInvalidTransitionHandler(func(tr PhaseTransition) {
+		invalid = append(invalid, tr)
+	}))
+
+	steps := []struct {
+		at     time.Duration
+		status string
+		pause  string
+		want   MatchPhase
+	}{
+		{0, GameStatusPreMatch, "none", PhasePreMatch},
+		{10 * time.Second, GameStatusRoundStart, "none", PhaseRoundStart},
+		{15 * time.Second, GameStatusPlaying, "none", PhasePlaying},
+		{45 * time.Second, GameStatusPlaying, "paused", PhasePaused},
+		{75 * time.Second, GameStatusPlaying, GameStatusUnpausing, PhaseUnpausing},
+		{80 * time.Second, GameStatusPlaying, "none", PhasePlaying},
+		{100 * time.Second, GameStatusScore, "none", PhaseScore},
+		{105 * time.Second, GameStatusRoundStart, "none", PhaseRoundStart},
+		{110 * time.Second, GameStatusPlaying, "none", PhasePlaying},
+		{130 * time.Second, GameStatusSuddenDeath, "none", PhaseOvertime},
+		{150 * time.Second, GameStatusPostMatch, "none", PhasePostMatch},
+	}
+	for i, step := range steps {
+		tracker.Update(createPhaseFrame(step.at, step.status, step.pause))
+		if got := tracker.Phase(); got != step.want {
+			t.Fatalf("step %d: expected phase %q, got %q", i, step.want, got)
+		}
+	}
+
+	if len(invalid) != 0 {
+		t.Errorf("expected no invalid transitions, got %v", invalid)
+	}
+	if got := tracker.PreviousPhase(); got != PhaseOvertime {
+		t.Errorf("expected previous phase overtime, got %q", got)
+	}
+	if got := tracker.PhaseDuration(PhasePlaying); got != 70*time.Second {
+		t.Errorf("expected 70s of play, got %v", got)
+	}
+	if got := tracker.PhaseDuration(PhasePaused); got != 30*time.Second {
+		t.Errorf("expected 30s paused, got %v", got)
+	}
+	if got := tracker.PhaseDuration(PhaseOvertime); got != 20*time.Second {
+		t.Errorf("expected 20s of overtime, got %v", got)
+	}
+
+	tracker.Update(createPhaseFrame(160*time.Second, GameStatusPostMatch, "none"))
+	if got := tracker.TimeInPhase(); got != 10*time.Second {
+		t.Errorf("expected 10s in post_match, got %v", got)
+	}
+}
+
+func TestMatchPhaseTracker_InvalidTransition(t *testing.T) {
+	var invalid []PhaseTransition
+	tracker := NewMatchPhaseTracker(WithInvalidTransitionHandler(func(tr PhaseTransition) {
+		invalid = append(invalid, tr)
+	}))
+
+	tracker.Update(createPhaseFrame(0, GameStatusPlaying, "none"))
+	tracker.Update(createPhaseFrame(time.Second, GameStatusPreMatch, "none"))
+
+	if len(invalid) != 1 || invalid[0].From != PhasePlaying || invalid[0].To != PhasePreMatch {
+		t.Fatalf("expected playing -> pre_match to be invalid, got %v", invalid)
+	}
+	// The tracker still follows the game
+	if got := tracker.Phase(); got != PhasePreMatch {
+		t.Errorf("expected phase pre_match, got %q", got)
+	}
+}
+
+func TestMatchPhaseTracker_PauseMustResumeSamePhase(t *testing.T) {
+	var invalid []PhaseTransition
+	tracker := NewMatchPhaseTracker(WithInvalidTransitionHandler(func(tr PhaseTransition) {
+		invalid = append(invalid, tr)
+	}))
+
+	tracker.Update(createPhaseFrame(0, GameStatusPlaying, "none"))
+	tracker.Update(createPhaseFrame(time.Second, GameStatusPlaying, "paused"))
+	tracker.Update(createPhaseFrame(2*time.Second, GameStatusRoundStart, "none"))
+
+	if len(invalid) != 1 || invalid[0].From != PhasePaused || invalid[0].To != PhaseRoundStart {
+		t.Errorf("expected paused -> round_start to be invalid, got %v", invalid)
+	}
+}
+
+func TestMatchPhaseTracker_UpdateIsIdempotent(t *testing.T) {
+	tracker := NewMatchPhaseTracker()
+	tracker.Update(createPhaseFrame(0, GameStatusPlaying, "none"))
+
+	frame := createPhaseFrame(5*time.Second, GameStatusScore, "none")
+	first, ok := tracker.Update(frame)
+	if !ok || first.From != PhasePlaying || first.To != PhaseScore || first.Duration != 5*time.Second {
+		t.Fatalf("expected playing -> score after 5s, got %v", first)
+	}
+	second, ok := tracker.Update(frame)
+	if !ok || second != first {
+		t.Errorf("expected the same transition for the same frame, got %v", second)
+	}
+	if got := tracker.PhaseDuration(PhasePlaying); got != 5*time.Second {
+		t.Errorf("expected play time to be counted once, got %v", got)
+	}
+}
+
+func TestMatchPhaseTracker_EmitsPhaseEvents(t *testing.T) {
+	tracker := NewMatchPhaseTracker()
+	tracker.AddFrame(createPhaseFrame(0, GameStatusRoundStart, "none"))
+
+	event := tracker.AddFrame(createPhaseFrame(3*time.Second, GameStatusPlaying, "none"))
+	custom, err := GetCustomEvent(event)
+	if err != nil {
+		t.Fatalf("expected a custom event: %v", err)
+	}
+	if custom.Name != MatchPhaseEventName || custom.Fields["from"] != "round_start" || custom.Fields["to"] != "playing" || custom.Fields["duration"] != 3.0 {
+		t.Errorf("unexpected match_phase event: %v", custom)
+	}
+	if event := tracker.AddFrame(createPhaseFrame(4*time.Second, GameStatusPlaying, "none")); event != nil {
+		t.Errorf("expected no event without a transition, got %v", event)
+	}
+}
+
+func TestMatchPhaseTracker_SnapshotRestore(t *testing.T) {
+	tracker := NewMatchPhaseTracker()
+	tracker.Update(createPhaseFrame(0, GameStatusPlaying, "none"))
+	tracker.Update(createPhaseFrame(10*time.Second, GameStatusPlaying, "paused"))
+
+	data, err := tracker.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewMatchPhaseTracker()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if got := restored.Phase(); got != PhasePaused {
+		t.Errorf("expected restored phase paused, got %q", got)
+	}
+	transition, ok := restored.Update(createPhaseFrame(15*time.Second, GameStatusPlaying, "none"))
+	if !ok || transition.Duration != 5*time.Second {
+		t.Errorf("expected a 5s pause after restore, got %v", transition)
+	}
+	if got := restored.PhaseDuration(PhasePlaying); got != 10*time.Second {
+		t.Errorf("expected 10s of play after restore, got %v", got)
+	}
+}
+
+func TestMatchPhaseTracker_Reset(t *testing.T) {
+	tracker := NewMatchPhaseTracker()
+	tracker.Update(createPhaseFrame(0, GameStatusPlaying, "none"))
+	tracker.Update(createPhaseFrame(10*time.Second, GameStatusScore, "none"))
+
+	tracker.Reset()
+	if got := tracker.Phase(); got != PhaseUnknown {
+		t.Errorf("expected unknown phase after reset, got %q", got)
+	}
+	if got := tracker.PhaseDuration(PhasePlaying); got != 0 {
+		t.Errorf("expected Reset to clear durations, got %v", got)
+	}
+}
+
+func TestDefaultSensors_SingleRoundEnded(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(DefaultSensors()...))
+	defer detector.Stop()
+
+	detector.ProcessFrame(createPostMatchTestFrame(GameStatusPlaying, 1, 0))
+	detector.ProcessFrame(createPostMatchTestFrame(GameStatusRoundOver, 2, 1))
+
+	var roundEnded int
+	for _, event := range drainEvents(detector.EventsChan()) {
+		if event.GetRoundEnded() != nil {
+			roundEnded++
+		}
+	}
+	if roundEnded != 1 {
+		t.Errorf("expected exactly one RoundEnded event, got %d", roundEnded)
+	}
+}
@@ -3,6 +3,7 @@ package events
 import (
 	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 // PlayerJoinSensor detects when players join the session
@@ -17,6 +18,26 @@ func NewPlayerJoinSensor() *PlayerJoinSensor {
 	}
 }
 
+// Reset clears the tracked player list
+func (s *PlayerJoinSensor) Reset() {
+	s.previousPlayers = make(map[int32]*apigame.TeamMember)
+}
+
+// Snapshot returns the tracked player list
+func (s *PlayerJoinSensor) Snapshot() ([]byte, error) {
+	return snapshotPlayers(s.previousPlayers)
+}
+
+// Restore replaces the tracked player list with a snapshot
+func (s *PlayerJoinSensor) Restore(data []byte) error {
+	players, err := restorePlayers(data)
+	if err != nil {
+		return err
+	}
+	s.previousPlayers = players
+	return nil
+}
+
 // AddFrame processes a frame and returns a PlayerJoined event if detected
 func (s *PlayerJoinSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -33,7 +54,8 @@ func (s *PlayerJoinSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *te
 			return &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerJoined{
 					PlayerJoined: &telemetry.PlayerJoined{
-						Player: player,
+						// Pooled frames reuse their messages, so send a copy
+						Player: proto.CloneOf(player),
 						Role:   determinePlayerRole(player),
 					},
 				},
@@ -57,6 +79,26 @@ func NewPlayerLeaveSensor() *PlayerLeaveSensor {
 	}
 }
 
+// Reset clears the tracked player list
+func (s *PlayerLeaveSensor) Reset() {
+	s.previousPlayers = make(map[int32]*apigame.TeamMember)
+}
+
+// Snapshot returns the tracked player list
+func (s *PlayerLeaveSensor) Snapshot() ([]byte, error) {
+	return snapshotPlayers(s.previousPlayers)
+}
+
+// Restore replaces the tracked player list with a snapshot
+func (s *PlayerLeaveSensor) Restore(data []byte) error {
+	players, err := restorePlayers(data)
+	if err != nil {
+		return err
+	}
+	s.previousPlayers = players
+	return nil
+}
+
 // AddFrame processes a frame and returns a PlayerLeft event if detected
 func (s *PlayerLeaveSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -97,6 +139,26 @@ func NewPlayerTeamSwitchSensor() *PlayerTeamSwitchSensor {
 	}
 }
 
+// Reset clears the tracked player list
+func (s *PlayerTeamSwitchSensor) Reset() {
+	s.previousPlayers = make(map[int32]*apigame.TeamMember)
+}
+
+// Snapshot returns the tracked player list
+func (s *PlayerTeamSwitchSensor) Snapshot() ([]byte, error) {
+	return snapshotPlayers(s.previousPlayers)
+}
+
+// Restore replaces the tracked player list with a snapshot
+func (s *PlayerTeamSwitchSensor) Restore(data []byte) error {
+	players, err := restorePlayers(data)
+	if err != nil {
+		return err
+	}
+	s.previousPlayers = players
+	return nil
+}
+
 // AddFrame processes a frame and returns a PlayerSwitchedTeam event if detected
 func (s *PlayerTeamSwitchSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -141,6 +203,26 @@ func NewEmoteSensor() *EmoteSensor {
 	}
 }
 
+// Reset clears the tracked emote states
+func (s *EmoteSensor) Reset() {
+	s.previousEmoteStates = make(map[int32]bool)
+}
+
+// Snapshot returns the tracked emote states
+func (s *EmoteSensor) Snapshot() ([]byte, error) {
+	return encodeSnapshot(s.previousEmoteStates)
+}
+
+// Restore replaces the tracked emote states with a snapshot
+func (s *EmoteSensor) Restore(data []byte) error {
+	states := make(map[int32]bool)
+	if err := decodeSnapshot(data, &states); err != nil {
+		return err
+	}
+	s.previousEmoteStates = states
+	return nil
+}
+
 // AddFrame processes a frame and returns an EmotePlayed event if detected
 func (s *EmoteSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -183,6 +265,40 @@ func extractPlayersMap(session *apigame.SessionResponse) map[int32]*apigame.Team
 	return players
 }
 
+// snapshotPlayers encodes a player map keyed by slot
+func snapshotPlayers(players map[int32]*apigame.TeamMember) ([]byte, error) {
+	state := make(map[int32]snapshotMessage, len(players))
+	for slot, player := range players {
+		m, err := newSnapshotMessage(player)
+		if err != nil {
+			return nil, err
+		}
+		state[slot] = m
+	}
+	return encodeSnapshot(state)
+}
+
+// restorePlayers decodes a player map encoded by snapshotPlayers
+func restorePlayers(data []byte) (map[int32]*apigame.TeamMember, error) {
+	state := make(map[int32]snapshotMessage)
+	if err := decodeSnapshot(data, &state); err != nil {
+		return nil, err
+	}
+	players := make(map[int32]*apigame.TeamMember, len(state))
+	for slot, m := range state {
+		player, err := restoreSnapshotMessage(m, newTeamMember)
+		if err != nil {
+			return nil, err
+		}
+		players[slot] = player
+	}
+	return players, nil
+}
+
+func newTeamMember() *apigame.TeamMember {
+	return &apigame.TeamMember{}
+}
+
 // determinePlayerRole determines a player's role based on their jersey number and slot
 func determinePlayerRole(player *apigame.TeamMember) telemetry.Role {
 	if player == nil {
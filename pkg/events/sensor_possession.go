@@ -0,0 +1,344 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom event names emitted by PossessionTracker
+const (
+	PassEventName     = "pass"
+	TurnoverEventName = "turnover"
+)
+
+// TurnoverKind describes how a team lost possession
+type TurnoverKind string
+
+const (
+	// TurnoverSteal is the disc taken directly from an opponent's hands
+	TurnoverSteal TurnoverKind = "steal"
+	// TurnoverInterception is an opponent's throw caught before it bounced
+	TurnoverInterception TurnoverKind = "interception"
+	// TurnoverRecovery is a loose disc picked up after it bounced
+	TurnoverRecovery TurnoverKind = "recovery"
+)
+
+// PossessionTracker turns raw disc possession into team possessions. It
+// emits a pass event when the disc moves between teammates and a turnover
+// event when the other team takes it, with the duration of the possession
+// that ended. A loose disc stays with the team that last held it.
+//
+// Cumulative team possession time only counts time while the match phase
+// is playing, so pauses are left out, and is safe to query from any
+// goroutine.
+type PossessionTracker struct {
+	mu     sync.Mutex
+	phases *MatchPhaseTracker
+
+	initialized bool
+	prevAt      time.Time
+	// prevPlaying is set when the previous frame was in play
+	prevPlaying bool
+	// holder is the player holding the disc, or -1 while it is loose
+	holder int32
+	// lastHolder is the last player to hold the disc and when they held it
+	lastHolder int32
+	holdStart  time.Time
+	holdEnd    time.Time
+	// team is the team in possession, unspecified between rounds
+	team      telemetry.Role
+	teamStart time.Time
+	// looseBounces is the most bounces seen since the disc was last held
+	looseBounces int32
+	prevStats    map[int32]turnoverStats
+	totals       map[telemetry.Role]time.Duration
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// turnoverStats are the player stats that identify how a turnover happened
+type turnoverStats struct {
+	Steals        int32
+	Interceptions int32
+}
+
+// NewPossessionTracker creates a new PossessionTracker
+func NewPossessionTracker() *PossessionTracker {
+	return NewPossessionTrackerWithPhases(NewMatchPhaseTracker())
+}
+
+// NewPossessionTrackerWithPhases creates a new PossessionTracker that shares the given tracker
+func NewPossessionTrackerWithPhases(phases *MatchPhaseTracker) *PossessionTracker {
+	s := &PossessionTracker{phases: phases}
+	s.reset()
+	return s
+}
+
+// Reset clears the phase tracker, possession state and cumulative possession time
+func (s *PossessionTracker) Reset() {
+	s.phases.Reset()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+}
+
+func (s *PossessionTracker) reset() {
+	s.initialized = false
+	s.prevAt = time.Time{}
+	s.prevPlaying = false
+	s.holder = -1
+	s.lastHolder = -1
+	s.holdStart = time.Time{}
+	s.holdEnd = time.Time{}
+	s.team = telemetry.Role_ROLE_UNSPECIFIED
+	s.teamStart = time.Time{}
+	s.looseBounces = 0
+	s.prevStats = make(map[int32]turnoverStats)
+	s.totals = make(map[telemetry.Role]time.Duration)
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// TeamPossessionTime returns the team's cumulative possession time
+func (s *PossessionTracker) TeamPossessionTime(team telemetry.Role) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.totals[team]
+}
+
+// PossessionShare returns the team's share of total possession time, from 0 to 1
+func (s *PossessionTracker) PossessionShare(team telemetry.Role) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total time.Duration
+	for _, d := range s.totals {
+		total += d
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(s.totals[team]) / float64(total)
+}
+
+// PossessionTeam returns the team currently in possession, or ROLE_UNSPECIFIED between rounds
+func (s *PossessionTracker) PossessionTeam() telemetry.Role {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.team
+}
+
+// possessionTrackerState is the snapshot form of PossessionTracker
+type possessionTrackerState struct {
+	Phases       []byte
+	Initialized  bool
+	PrevAt       time.Time
+	PrevPlaying  bool
+	Holder       int32
+	LastHolder   int32
+	HoldStart    time.Time
+	HoldEnd      time.Time
+	Team         telemetry.Role
+	TeamStart    time.Time
+	LooseBounces int32
+	PrevStats    map[int32]turnoverStats
+	Totals       map[telemetry.Role]time.Duration
+}
+
+// Snapshot returns the phase tracker state, the possession state and
+// cumulative possession time
+func (s *PossessionTracker) Snapshot() ([]byte, error) {
+	phases, err := s.phases.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return encodeSnapshot(possessionTrackerState{
+		Phases:       phases,
+		Initialized:  s.initialized,
+		PrevAt:       s.prevAt,
+		PrevPlaying:  s.prevPlaying,
+		Holder:       s.holder,
+		LastHolder:   s.lastHolder,
+		HoldStart:    s.holdStart,
+		HoldEnd:      s.holdEnd,
+		Team:         s.team,
+		TeamStart:    s.teamStart,
+		LooseBounces: s.looseBounces,
+		PrevStats:    s.prevStats,
+		Totals:       s.totals,
+	})
+}
+
+// Restore replaces the phase tracker state, the possession state and
+// cumulative possession time with a snapshot
+func (s *PossessionTracker) Restore(data []byte) error {
+	var state possessionTrackerState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	if err := s.phases.Restore(state.Phases); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+	s.initialized = state.Initialized
+	s.prevAt = state.PrevAt
+	s.prevPlaying = state.PrevPlaying
+	s.holder = state.Holder
+	s.lastHolder = state.LastHolder
+	s.holdStart = state.HoldStart
+	s.holdEnd = state.HoldEnd
+	s.team = state.Team
+	s.teamStart = state.TeamStart
+	s.looseBounces = state.LooseBounces
+	for slot, stats := range state.PrevStats {
+		s.prevStats[slot] = stats
+	}
+	for team, d := range state.Totals {
+		s.totals[team] = d
+	}
+	return nil
+}
+
+// AddFrame processes a frame and returns pass and turnover custom events
+func (s *PossessionTracker) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if frame != nil && frame.GetSession() != nil {
+		s.processFrame(frame)
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// processFrame updates possession from a frame and queues any events
+func (s *PossessionTracker) processFrame(frame *telemetry.LobbySessionStateFrame) {
+	session := frame.GetSession()
+	at := frame.GetTimestamp().AsTime()
+	s.phases.Update(frame)
+	phase := s.phases.Phase()
+	players := extractPlayersMap(session)
+	defer s.updateStats(players)
+
+	// Credit the time since the previous frame to the team that had the disc
+	if s.initialized && s.prevPlaying && s.team != telemetry.Role_ROLE_UNSPECIFIED {
+		s.totals[s.team] += at.Sub(s.prevAt)
+	}
+	s.prevAt = at
+	s.prevPlaying = phase == PhasePlaying
+
+	switch phase {
+	case PhasePlaying, PhasePaused, PhaseUnpausing:
+	default:
+		// Possession ends with the round; the next one starts with a joust
+		s.holder = -1
+		s.lastHolder = -1
+		s.team = telemetry.Role_ROLE_UNSPECIFIED
+		s.initialized = true
+		return
+	}
+
+	slot := findPossessorSlot(session)
+	if slot == -1 {
+		if s.holder != -1 {
+			s.holdEnd = at
+			s.looseBounces = 0
+		}
+		s.holder = -1
+		s.looseBounces = max(s.looseBounces, session.GetDisc().GetBounceCount())
+		s.initialized = true
+		return
+	}
+	if slot == s.holder {
+		s.initialized = true
+		return
+	}
+
+	role := determinePlayerRole(players[slot])
+	direct := s.holder != -1
+	if direct {
+		s.holdEnd = at
+	}
+
+	switch {
+	case !s.initialized || s.team == telemetry.Role_ROLE_UNSPECIFIED:
+		// First possession of the round, or of our observation
+		s.team = role
+		s.teamStart = at
+
+	case role == s.team:
+		if slot != s.lastHolder {
+			s.pendingEvents = append(s.pendingEvents, newCustomEvent(PassEventName, map[string]any{
+				"player_slot":          slot,
+				"previous_player_slot": s.lastHolder,
+				"team":                 role.String(),
+				"hold_duration":        s.holdEnd.Sub(s.holdStart).Seconds(),
+				"flight_time":          at.Sub(s.holdEnd).Seconds(),
+			}))
+		}
+
+	default:
+		kind := s.turnoverKind(slot, players[slot], direct)
+		s.pendingEvents = append(s.pendingEvents, newCustomEvent(TurnoverEventName, map[string]any{
+			"kind":                 string(kind),
+			"player_slot":          slot,
+			"team":                 role.String(),
+			"previous_player_slot": s.lastHolder,
+			"previous_team":        s.team.String(),
+			"possession_duration":  at.Sub(s.teamStart).Seconds(),
+		}))
+		s.team = role
+		s.teamStart = at
+	}
+
+	s.holder = slot
+	s.lastHolder = slot
+	s.holdStart = at
+	s.looseBounces = 0
+	s.initialized = true
+}
+
+// turnoverKind classifies a turnover, preferring the game's own steal and
+// interception stats and falling back to how the disc changed hands
+func (s *PossessionTracker) turnoverKind(slot int32, player *apigame.TeamMember, direct bool) TurnoverKind {
+	prev, current := s.prevStats[slot], statsForTurnover(player)
+	switch {
+	case current.Steals > prev.Steals:
+		return TurnoverSteal
+	case current.Interceptions > prev.Interceptions:
+		return TurnoverInterception
+	case direct:
+		return TurnoverSteal
+	case s.looseBounces == 0:
+		return TurnoverInterception
+	}
+	return TurnoverRecovery
+}
+
+// updateStats records the turnover stats of every player for the next frame
+func (s *PossessionTracker) updateStats(players map[int32]*apigame.TeamMember) {
+	clear(s.prevStats)
+	for slot, player := range players {
+		s.prevStats[slot] = statsForTurnover(player)
+	}
+}
+
+func statsForTurnover(player *apigame.TeamMember) turnoverStats {
+	return turnoverStats{
+		Steals:        player.GetStats().GetSteals(),
+		Interceptions: player.GetStats().GetInterceptions(),
+	}
+}
@@ -0,0 +1,223 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// possessionFrame describes a test frame for PossessionTracker
+type possessionFrame struct {
+	at        time.Duration
+	status    string
+	possessor int32
+	bounces   int32
+	stats     map[int32]*apigame.PlayerStats
+}
+
+// Helper to create a frame with blue players in slots 0-1 and orange players in slots 4-5
+func createPossessionFrame(f possessionFrame) *telemetry.LobbySessionStateFrame {
+	if f.status == "" {
+		f.status = GameStatusPlaying
+	}
+	player := func(slot int32) *apigame.TeamMember {
+		return &apigame.TeamMember{SlotNumber: slot, HasPossession: slot == f.possessor, Stats: f.stats[slot]}
+	}
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(f.at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: f.status,
+			Disc:       &apigame.Disc{BounceCount: f.bounces},
+			Teams: []*apigame.Team{
+				{Players: []*apigame.TeamMember{player(0), player(1)}},
+				{Players: []*apigame.TeamMember{player(4), player(5)}},
+			},
+		},
+	}
+}
+
+// Helper to create a frame for each possessionFrame
+func createPossessionFrames(fs ...possessionFrame) []*telemetry.LobbySessionStateFrame {
+	frames := make([]*telemetry.LobbySessionStateFrame, len(fs))
+	for i, f := range fs {
+		frames[i] = createPossessionFrame(f)
+	}
+	return frames
+}
+
+func TestPossessionTracker_PassesAndTurnovers(t *testing.T) {
+	tracker := NewPossessionTracker()
+
+	events := collectCustomEvents(t, tracker, createPossessionFrames(
+		possessionFrame{at: 0, possessor: -1},
+		possessionFrame{at: 1 * time.Second, possessor: 0},               // blue wins the joust
+		possessionFrame{at: 2 * time.Second, possessor: -1},              // pass in flight
+		possessionFrame{at: 3 * time.Second, possessor: 1},               // caught by a teammate
+		possessionFrame{at: 4 * time.Second, possessor: 4},               // stripped by orange
+		possessionFrame{at: 5 * time.Second, possessor: -1},              // orange throws
+		possessionFrame{at: 6 * time.Second, possessor: 0},               // blue intercepts
+		possessionFrame{at: 7 * time.Second, possessor: -1},              // blue loses the disc
+		possessionFrame{at: 8 * time.Second, possessor: -1, bounces: 2},  // it bounces
+		possessionFrame{at: 9 * time.Second, possessor: 5, bounces: 2},   // orange recovers
+		possessionFrame{at: 10 * time.Second, possessor: 5, bounces: 0},  // orange holds
+		possessionFrame{at: 11 * time.Second, possessor: -1, bounces: 0}, // orange drops it
+		possessionFrame{at: 12 * time.Second, possessor: 5, bounces: 0},  // and picks it back up
+	)...)
+
+	want := []struct {
+		name string
+		kind string
+		slot float64
+	}{
+		{PassEventName, "", 1},
+		{TurnoverEventName, string(TurnoverSteal), 4},
+		{TurnoverEventName, string(TurnoverInterception), 0},
+		{TurnoverEventName, string(TurnoverRecovery), 5},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %v", len(want), len(events), events)
+	}
+	for i, w := range want {
+		got := events[i]
+		if got.Name != w.name || got.Fields["player_slot"] != w.slot {
+			t.Errorf("event %d: expected %s for slot %v, got %s %v", i, w.name, w.slot, got.Name, got.Fields)
+		}
+		if w.kind != "" && got.Fields["kind"] != w.kind {
+			t.Errorf("event %d: expected kind %s, got %v", i, w.kind, got.Fields["kind"])
+		}
+	}
+
+	pass := events[0].Fields
+	if pass["previous_player_slot"] != float64(0) || pass["hold_duration"] != 1.0 || pass["flight_time"] != 1.0 {
+		t.Errorf("unexpected pass fields: %v", pass)
+	}
+	steal := events[1].Fields
+	if steal["team"] != "ROLE_ORANGE_TEAM" || steal["previous_team"] != "ROLE_BLUE_TEAM" || steal["possession_duration"] != 3.0 {
+		t.Errorf("unexpected turnover fields: %v", steal)
+	}
+}
+
+func TestPossessionTracker_StatsIdentifyTurnover(t *testing.T) {
+	tracker := NewPossessionTracker()
+
+	// A catch off a bounce is still an interception when the game credits one
+	events := collectCustomEvents(t, tracker, createPossessionFrames(
+		possessionFrame{at: 0, possessor: 0},
+		possessionFrame{at: 1 * time.Second, possessor: -1, bounces: 1},
+		possessionFrame{at: 2 * time.Second, possessor: 4, stats: map[int32]*apigame.PlayerStats{4: {Interceptions: 1}}},
+	)...)
+	if len(events) != 1 || events[0].Fields["kind"] != string(TurnoverInterception) {
+		t.Errorf("expected an interception, got %v", events)
+	}
+}
+
+func TestPossessionTracker_PossessionTime(t *testing.T) {
+	tracker := NewPossessionTracker()
+
+	collectCustomEvents(t, tracker, createPossessionFrames(
+		possessionFrame{at: 0, possessor: 0},
+		possessionFrame{at: 1 * time.Second, possessor: -1},
+		possessionFrame{at: 3 * time.Second, possessor: 1},
+		possessionFrame{at: 4 * time.Second, possessor: 4},
+		possessionFrame{at: 5 * time.Second, possessor: 4, status: GameStatusPaused},
+		possessionFrame{at: 9 * time.Second, possessor: 4, status: GameStatusPaused},
+		possessionFrame{at: 10 * time.Second, possessor: 4},
+		possessionFrame{at: 12 * time.Second, possessor: 4, status: GameStatusScore},
+		possessionFrame{at: 20 * time.Second, possessor: -1, status: GameStatusRoundStart},
+	)...)
+
+	if got := tracker.TeamPossessionTime(telemetry.Role_ROLE_BLUE_TEAM); got != 4*time.Second {
+		t.Errorf("expected 4s of blue possession, got %v", got)
+	}
+	// Paused time and time between rounds do not count
+	if got := tracker.TeamPossessionTime(telemetry.Role_ROLE_ORANGE_TEAM); got != 3*time.Second {
+		t.Errorf("expected 3s of orange possession, got %v", got)
+	}
+	if got := tracker.PossessionShare(telemetry.Role_ROLE_BLUE_TEAM); got < 0.57 || got > 0.58 {
+		t.Errorf("expected a 4/7 blue possession share, got %v", got)
+	}
+	if got := tracker.PossessionTeam(); got != telemetry.Role_ROLE_UNSPECIFIED {
+		t.Errorf("expected no team in possession between rounds, got %v", got)
+	}
+}
+
+func TestPossessionTracker_SharedPhasesExcludePause(t *testing.T) {
+	tracker := NewPossessionTrackerWithPhases(NewMatchPhaseTracker())
+
+	// game_status stays playing through a pause; the pause is in session.pause
+	frames := []*telemetry.LobbySessionStateFrame{
+		createPossessionFrame(possessionFrame{at: 0, possessor: 0}),
+		createPossessionFrame(possessionFrame{at: time.Second, possessor: 0}),
+		createPossessionFrame(possessionFrame{at: 5 * time.Second, possessor: 0}),
+		createPossessionFrame(possessionFrame{at: 6 * time.Second, possessor: 0}),
+	}
+	frames[1].Session.Pause = &apigame.PauseState{PausedState: "paused"}
+	for _, frame := range frames {
+		tracker.AddFrame(frame)
+	}
+
+	if got := tracker.TeamPossessionTime(telemetry.Role_ROLE_BLUE_TEAM); got != 2*time.Second {
+		t.Errorf("expected 2s of blue possession outside the pause, got %v", got)
+	}
+}
+
+func TestPossessionTracker_NoTurnoverAcrossRounds(t *testing.T) {
+	tracker := NewPossessionTracker()
+
+	events := collectCustomEvents(t, tracker, createPossessionFrames(
+		possessionFrame{at: 0, possessor: 0},
+		possessionFrame{at: 1 * time.Second, possessor: -1, status: GameStatusScore},
+		possessionFrame{at: 2 * time.Second, possessor: -1, status: GameStatusRoundStart},
+		possessionFrame{at: 3 * time.Second, possessor: 4},
+	)...)
+	if len(events) != 0 {
+		t.Errorf("expected the joust not to count as a turnover, got %v", events)
+	}
+	if got := tracker.PossessionTeam(); got != telemetry.Role_ROLE_ORANGE_TEAM {
+		t.Errorf("expected orange in possession, got %v", got)
+	}
+}
+
+func TestPossessionTracker_SnapshotRestore(t *testing.T) {
+	tracker := NewPossessionTracker()
+	collectCustomEvents(t, tracker, createPossessionFrames(
+		possessionFrame{at: 0, possessor: 0},
+		possessionFrame{at: 2 * time.Second, possessor: 0},
+	)...)
+
+	data, err := tracker.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewPossessionTracker()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	events := collectCustomEvents(t, restored, createPossessionFrames(possessionFrame{at: 3 * time.Second, possessor: 4})...)
+	if len(events) != 1 || events[0].Fields["possession_duration"] != 3.0 {
+		t.Errorf("expected a turnover after 3s of possession, got %v", events)
+	}
+	if got := restored.TeamPossessionTime(telemetry.Role_ROLE_BLUE_TEAM); got != 3*time.Second {
+		t.Errorf("expected 3s of blue possession, got %v", got)
+	}
+}
+
+func TestPossessionTracker_Reset(t *testing.T) {
+	tracker := NewPossessionTracker()
+	collectCustomEvents(t, tracker, createPossessionFrames(
+		possessionFrame{at: 0, possessor: 0},
+		possessionFrame{at: 2 * time.Second, possessor: 0},
+	)...)
+
+	tracker.Reset()
+	if got := tracker.TeamPossessionTime(telemetry.Role_ROLE_BLUE_TEAM); got != 0 {
+		t.Errorf("expected Reset to clear possession time, got %v", got)
+	}
+	if got := tracker.PossessionShare(telemetry.Role_ROLE_BLUE_TEAM); got != 0 {
+		t.Errorf("expected no possession share after reset, got %v", got)
+	}
+}
@@ -0,0 +1,349 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleConfig is the file format for RuleSensor. It is read as YAML, which
+// also accepts JSON documents.
+//
+//	rules:
+//	  - name: score_phase
+//	    when: session.game_status changes to "score"
+//	  - name: stun_spree
+//	    when: player.stats.stuns increases by >= 2 within 3s
+//	    cooldown: 10s
+//	    fields:
+//	      severity: high
+type RuleConfig struct {
+	Rules []RuleDefinition `yaml:"rules" json:"rules"`
+}
+
+// RuleDefinition describes a single custom event trigger
+type RuleDefinition struct {
+	// Name is the name of the custom event emitted when the rule matches
+	Name string `yaml:"name" json:"name"`
+	// When is the rule expression, e.g. `session.game_status changes to "score"`
+	When string `yaml:"when" json:"when"`
+	// Cooldown suppresses repeat matches for the same session or player, e.g. "5s"
+	Cooldown string `yaml:"cooldown,omitempty" json:"cooldown,omitempty"`
+	// Fields are static key/values added to every emitted event
+	Fields map[string]any `yaml:"fields,omitempty" json:"fields,omitempty"`
+}
+
+// ParseRuleConfig parses a YAML or JSON rule configuration
+func ParseRuleConfig(data []byte) (*RuleConfig, error) {
+	var cfg RuleConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse rule config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// LoadRuleSensor creates a RuleSensor from a YAML or JSON rule configuration file
+func LoadRuleSensor(path string) (*RuleSensor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseRuleConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleSensor(cfg.Rules...)
+}
+
+// compiledRule is a rule definition with its expression parsed
+type compiledRule struct {
+	def      RuleDefinition
+	clauses  []*ruleClause
+	cooldown time.Duration
+	// perPlayer is set when any clause reads a player field
+	perPlayer bool
+	// momentary rules fire on every matching frame; others only when they start matching
+	momentary bool
+}
+
+// ruleScopeKey identifies the session or the player a rule is evaluated for.
+// Players are keyed by account number so that state follows the player, not
+// the slot; players without one fall back to their slot.
+type ruleScopeKey struct {
+	Account uint64
+	Slot    int32
+}
+
+var sessionScopeKey = ruleScopeKey{Slot: -1}
+
+// playerScopeKey returns the scope key of a player
+func playerScopeKey(player *apigame.TeamMember) ruleScopeKey {
+	if account := player.GetAccountNumber(); account != 0 {
+		return ruleScopeKey{Account: account}
+	}
+	return ruleScopeKey{Slot: player.GetSlotNumber()}
+}
+
+// ruleState is the per-scope state of a compiled rule
+type ruleState struct {
+	clauses   []ruleClauseState
+	matched   bool
+	lastFired time.Time
+}
+
+// RuleSensor emits custom events from declarative rules over frame fields and
+// their previous values. Each matching rule emits a custom event named after
+// the rule, carrying the rule's static fields, the current value of every
+// field path in the expression and, for player rules, the player's slot,
+// display name and team. See rule_expression.go for the expression syntax.
+type RuleSensor struct {
+	rules  []*compiledRule
+	states []map[ruleScopeKey]*ruleState
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewRuleSensor compiles rule definitions into a RuleSensor
+func NewRuleSensor(defs ...RuleDefinition) (*RuleSensor, error) {
+	s := &RuleSensor{}
+	for _, def := range defs {
+		if def.Name == "" {
+			return nil, fmt.Errorf("rule %q has no name", def.When)
+		}
+
+		clauses, err := compileRuleExpression(def.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", def.Name, err)
+		}
+
+		rule := &compiledRule{def: def, clauses: clauses}
+		if def.Cooldown != "" {
+			if rule.cooldown, err = time.ParseDuration(def.Cooldown); err != nil {
+				return nil, fmt.Errorf("rule %q: invalid cooldown: %w", def.Name, err)
+			}
+		}
+		for _, c := range clauses {
+			rule.perPlayer = rule.perPlayer || c.scope == ruleScopePlayer
+			rule.momentary = rule.momentary || c.momentary()
+		}
+
+		// Reject fields that cannot be carried by a custom event up front
+		if _, err := NewCustomEvent(def.Name, def.Fields); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", def.Name, err)
+		}
+
+		s.rules = append(s.rules, rule)
+		s.states = append(s.states, make(map[ruleScopeKey]*ruleState))
+	}
+	return s, nil
+}
+
+// Reset clears rule history and any pending events
+func (s *RuleSensor) Reset() {
+	for i := range s.states {
+		s.states[i] = make(map[ruleScopeKey]*ruleState)
+	}
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// ruleSnapshot is the snapshot form of one rule's state, by scope
+type ruleSnapshot struct {
+	Name   string
+	States map[ruleScopeKey]ruleStateSnapshot
+}
+
+// ruleStateSnapshot is the snapshot form of ruleState
+type ruleStateSnapshot struct {
+	Clauses   []ruleClauseSnapshot
+	Matched   bool
+	LastFired time.Time
+}
+
+// ruleClauseSnapshot is the snapshot form of ruleClauseState. Prev is a
+// bool, string or float64, which gob carries in an interface as is.
+type ruleClauseSnapshot struct {
+	Prev    any
+	HasPrev bool
+	History []ruleSampleSnapshot
+}
+
+// ruleSampleSnapshot is the snapshot form of ruleSample
+type ruleSampleSnapshot struct {
+	At    time.Time
+	Value float64
+}
+
+// Snapshot returns every rule's clause history, matched state and last firing, by scope
+func (s *RuleSensor) Snapshot() ([]byte, error) {
+	snap := make([]ruleSnapshot, len(s.rules))
+	for i, rule := range s.rules {
+		states := make(map[ruleScopeKey]ruleStateSnapshot, len(s.states[i]))
+		for key, st := range s.states[i] {
+			clauses := make([]ruleClauseSnapshot, len(st.clauses))
+			for j, c := range st.clauses {
+				clauses[j] = ruleClauseSnapshot{Prev: c.prev, HasPrev: c.hasPrev}
+				for _, sample := range c.history {
+					clauses[j].History = append(clauses[j].History, ruleSampleSnapshot{At: sample.at, Value: sample.value})
+				}
+			}
+			states[key] = ruleStateSnapshot{Clauses: clauses, Matched: st.matched, LastFired: st.lastFired}
+		}
+		snap[i] = ruleSnapshot{Name: rule.def.Name, States: states}
+	}
+	return encodeSnapshot(snap)
+}
+
+// Restore replaces rule state with a snapshot taken from a sensor with the same rules
+func (s *RuleSensor) Restore(data []byte) error {
+	var snap []ruleSnapshot
+	if err := decodeSnapshot(data, &snap); err != nil {
+		return err
+	}
+	if len(snap) != len(s.rules) {
+		return fmt.Errorf("snapshot has %d rules, sensor has %d", len(snap), len(s.rules))
+	}
+
+	states := make([]map[ruleScopeKey]*ruleState, len(s.rules))
+	for i, rule := range s.rules {
+		if snap[i].Name != rule.def.Name {
+			return fmt.Errorf("snapshot rule %d is %q, sensor has %q", i, snap[i].Name, rule.def.Name)
+		}
+		states[i] = make(map[ruleScopeKey]*ruleState, len(snap[i].States))
+		for key, st := range snap[i].States {
+			if len(st.Clauses) != len(rule.clauses) {
+				return fmt.Errorf("rule %q: snapshot has %d clauses, rule has %d", rule.def.Name, len(st.Clauses), len(rule.clauses))
+			}
+			restored := &ruleState{
+				clauses:   make([]ruleClauseState, len(st.Clauses)),
+				matched:   st.Matched,
+				lastFired: st.LastFired,
+			}
+			for j, c := range st.Clauses {
+				restored.clauses[j] = ruleClauseState{prev: c.Prev, hasPrev: c.HasPrev}
+				for _, sample := range c.History {
+					restored.clauses[j].history = append(restored.clauses[j].history, ruleSample{at: sample.At, value: sample.Value})
+				}
+			}
+			states[i][key] = restored
+		}
+	}
+
+	s.states = states
+	s.pendingEvents = s.pendingEvents[:0]
+	return nil
+}
+
+// AddFrame evaluates every rule against the frame and returns a custom event for the first match
+func (s *RuleSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	if frame != nil && frame.GetSession() != nil {
+		at := frame.GetTimestamp().AsTime()
+		for i, rule := range s.rules {
+			s.evalRule(rule, s.states[i], frame, at)
+		}
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// evalRule evaluates a rule for the session or for every player and queues
+// events for matches. State of players no longer in the frame is dropped.
+func (s *RuleSensor) evalRule(rule *compiledRule, states map[ruleScopeKey]*ruleState, frame *telemetry.LobbySessionStateFrame, at time.Time) {
+	session := frame.GetSession()
+	sessionMsg := session.ProtoReflect()
+
+	if !rule.perPlayer {
+		values := make(map[string]any, len(rule.clauses))
+		if s.evalScope(rule, ruleStateFor(states, sessionScopeKey, rule), func(c *ruleClause) any {
+			return c.resolve(sessionMsg)
+		}, values, at) {
+			s.queue(rule, values, nil)
+		}
+		return
+	}
+
+	present := make(map[ruleScopeKey]bool, len(states))
+	for _, team := range session.GetTeams() {
+		for _, player := range team.GetPlayers() {
+			key := playerScopeKey(player)
+			present[key] = true
+			playerMsg := player.ProtoReflect()
+			values := make(map[string]any, len(rule.clauses))
+			if s.evalScope(rule, ruleStateFor(states, key, rule), func(c *ruleClause) any {
+				if c.scope == ruleScopePlayer {
+					return c.resolve(playerMsg)
+				}
+				return c.resolve(sessionMsg)
+			}, values, at) {
+				s.queue(rule, values, map[string]any{
+					"player_slot":  player.GetSlotNumber(),
+					"display_name": player.GetDisplayName(),
+					"team":         determinePlayerRole(player).String(),
+				})
+			}
+		}
+	}
+	for key := range states {
+		if !present[key] {
+			delete(states, key)
+		}
+	}
+}
+
+// evalScope evaluates every clause of a rule for one scope, recording current
+// values, and reports whether the rule should fire
+func (s *RuleSensor) evalScope(rule *compiledRule, st *ruleState, resolve func(*ruleClause) any, values map[string]any, at time.Time) bool {
+	// Evaluate every clause so each one's history stays current
+	matched := true
+	for i, c := range rule.clauses {
+		current := resolve(c)
+		values[c.path] = current
+		if !c.eval(&st.clauses[i], current, at) {
+			matched = false
+		}
+	}
+
+	wasMatched := st.matched
+	st.matched = matched
+	if !matched || (wasMatched && !rule.momentary) {
+		return false
+	}
+	if rule.cooldown > 0 && !st.lastFired.IsZero() && at.Sub(st.lastFired) < rule.cooldown {
+		return false
+	}
+	st.lastFired = at
+	return true
+}
+
+// queue adds a custom event for a rule match to the pending events
+func (s *RuleSensor) queue(rule *compiledRule, values map[string]any, player map[string]any) {
+	fields := make(map[string]any, len(rule.def.Fields)+len(values)+len(player))
+	for k, v := range rule.def.Fields {
+		fields[k] = v
+	}
+	for k, v := range values {
+		fields[k] = v
+	}
+	for k, v := range player {
+		fields[k] = v
+	}
+	s.pendingEvents = append(s.pendingEvents, newCustomEvent(rule.def.Name, fields))
+}
+
+// ruleStateFor returns the state of a rule for a scope, creating it on first use
+func ruleStateFor(states map[ruleScopeKey]*ruleState, key ruleScopeKey, rule *compiledRule) *ruleState {
+	st, ok := states[key]
+	if !ok {
+		st = &ruleState{clauses: make([]ruleClauseState, len(rule.clauses))}
+		states[key] = st
+	}
+	return st
+}
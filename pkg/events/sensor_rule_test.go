@@ -0,0 +1,409 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Helper to create a timestamped frame with a game status and one player's stats
+func createRuleTestFrame(at time.Duration, status string, stuns int32) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: status,
+			Teams: []*apigame.Team{
+				{
+					Players: []*apigame.TeamMember{
+						{SlotNumber: 1, DisplayName: "Player1", Stats: &apigame.PlayerStats{Stuns: stuns}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestParseRuleConfig(t *testing.T) {
+	yamlConfig := `
+rules:
+  - name: score_phase
+    when: session.game_status changes to "score"
+  - name: stun_spree
+    when: player.stats.stuns increases by >= 2 within 3s
+    cooldown: 10s
+    fields:
+      severity: high
+`
+	jsonConfig := `{"rules": [
+  {"name": "score_phase", "when": "session.game_status changes to \"score\""},
+  {"name": "stun_spree", "when": "player.stats.stuns increases by >= 2 within 3s", "cooldown": "10s", "fields": {"severity": "high"}}
+]}`
+
+	for name, data := range map[string]string{"yaml": yamlConfig, "json": jsonConfig} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := ParseRuleConfig([]byte(data))
+			if err != nil {
+				t.Fatalf("ParseRuleConfig failed: %v", err)
+			}
+			if len(cfg.Rules) != 2 {
+				t.Fatalf("expected 2 rules, got %d", len(cfg.Rules))
+			}
+			rule := cfg.Rules[1]
+			if rule.Name != "stun_spree" || rule.Cooldown != "10s" || rule.Fields["severity"] != "high" {
+				t.Errorf("unexpected rule: %+v", rule)
+			}
+			if _, err := NewRuleSensor(cfg.Rules...); err != nil {
+				t.Errorf("NewRuleSensor failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadRuleSensor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	config := "rules:\n  - name: score_phase\n    when: session.game_status changes to \"score\"\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sensor, err := LoadRuleSensor(path)
+	if err != nil {
+		t.Fatalf("LoadRuleSensor failed: %v", err)
+	}
+	events := collectCustomEvents(t, sensor,
+		createRuleTestFrame(0, GameStatusPlaying, 0),
+		createRuleTestFrame(time.Second, GameStatusScore, 0),
+	)
+	if len(events) != 1 || events[0].Name != "score_phase" {
+		t.Errorf("expected one score_phase event, got %v", events)
+	}
+
+	if _, err := LoadRuleSensor(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestNewRuleSensor_InvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		def  RuleDefinition
+	}{
+		{name: "missing name", def: RuleDefinition{When: "session.game_status changes"}},
+		{name: "unknown root", def: RuleDefinition{Name: "r", When: "match.game_status changes"}},
+		{name: "unknown field", def: RuleDefinition{Name: "r", When: "session.nope changes"}},
+		{name: "message field", def: RuleDefinition{Name: "r", When: "player.stats changes"}},
+		{name: "repeated field", def: RuleDefinition{Name: "r", When: "session.teams changes"}},
+		{name: "missing predicate", def: RuleDefinition{Name: "r", When: "session.game_status"}},
+		{name: "non-numeric increase", def: RuleDefinition{Name: "r", When: "session.game_status increases"}},
+		{name: "string ordering", def: RuleDefinition{Name: "r", When: `session.game_status > "score"`}},
+		{name: "unterminated string", def: RuleDefinition{Name: "r", When: `session.game_status changes to "score`}},
+		{name: "bad window", def: RuleDefinition{Name: "r", When: "player.stats.stuns increases within soon"}},
+		{name: "missing and", def: RuleDefinition{Name: "r", When: "player.ping > 100 player.ping < 200"}},
+		{name: "bad cooldown", def: RuleDefinition{Name: "r", When: "player.ping > 100", Cooldown: "later"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRuleSensor(tt.def); err == nil {
+				t.Errorf("expected an error for %q", tt.def.When)
+			}
+		})
+	}
+}
+
+func TestRuleSensor_ChangesFromTo(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{
+		Name:   "goal_scored",
+		When:   `session.game_status changes from "playing" to "score"`,
+		Fields: map[string]any{"source": "rules"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := collectCustomEvents(t, sensor,
+		createRuleTestFrame(0, GameStatusRoundStart, 0),
+		createRuleTestFrame(1*time.Second, GameStatusScore, 0), // not from playing
+		createRuleTestFrame(2*time.Second, GameStatusPlaying, 0),
+		createRuleTestFrame(3*time.Second, GameStatusScore, 0),
+		createRuleTestFrame(4*time.Second, GameStatusScore, 0),
+	)
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if events[0].Fields["source"] != "rules" {
+		t.Errorf("expected static field, got %v", events[0].Fields)
+	}
+	if events[0].Fields["session.game_status"] != GameStatusScore {
+		t.Errorf("expected current value in fields, got %v", events[0].Fields)
+	}
+	if _, ok := events[0].Fields["player_slot"]; ok {
+		t.Error("expected no player_slot on a session rule")
+	}
+}
+
+func TestRuleSensor_IncreasesWithinWindow(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{
+		Name: "stun_spree",
+		When: "player.stats.stuns increases by >= 2 within 3s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two stuns spread over more than 3s do not match
+	events := collectCustomEvents(t, sensor,
+		createRuleTestFrame(0, GameStatusPlaying, 0),
+		createRuleTestFrame(2*time.Second, GameStatusPlaying, 1),
+		createRuleTestFrame(6*time.Second, GameStatusPlaying, 2),
+	)
+	if len(events) != 0 {
+		t.Fatalf("expected no events, got %v", events)
+	}
+
+	// Two stuns within 3s match once, even while the window stays satisfied
+	events = collectCustomEvents(t, sensor,
+		createRuleTestFrame(7*time.Second, GameStatusPlaying, 3),
+		createRuleTestFrame(8*time.Second, GameStatusPlaying, 4),
+		createRuleTestFrame(9*time.Second, GameStatusPlaying, 4),
+	)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+	if event.Name != "stun_spree" {
+		t.Errorf("expected stun_spree, got %q", event.Name)
+	}
+	if event.Fields["player_slot"] != float64(1) || event.Fields["display_name"] != "Player1" {
+		t.Errorf("expected player fields, got %v", event.Fields)
+	}
+	if event.Fields["player.stats.stuns"] != float64(4) {
+		t.Errorf("expected current stuns 4, got %v", event.Fields["player.stats.stuns"])
+	}
+}
+
+func TestRuleSensor_IncreasesByAmount(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{Name: "double", When: "player.stats.stuns increases by 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := collectCustomEvents(t, sensor,
+		createRuleTestFrame(0, GameStatusPlaying, 0),
+		createRuleTestFrame(time.Second, GameStatusPlaying, 1),
+		createRuleTestFrame(2*time.Second, GameStatusPlaying, 3),
+		createRuleTestFrame(3*time.Second, GameStatusPlaying, 5),
+	)
+	if len(events) != 2 {
+		t.Errorf("expected 2 events, got %d", len(events))
+	}
+}
+
+func TestRuleSensor_PlayerStateFollowsAccount(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{Name: "double", When: "player.stats.stuns increases by 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	occupant := func(at time.Duration, account uint64, stuns int32) *telemetry.LobbySessionStateFrame {
+		frame := createRuleTestFrame(at, GameStatusPlaying, stuns)
+		if account == 0 {
+			frame.Session.Teams[0].Players = nil
+		} else {
+			frame.Session.Teams[0].Players[0].AccountNumber = account
+		}
+		return frame
+	}
+
+	events := collectCustomEvents(t, sensor,
+		occupant(0, 100, 0),
+		// A different player takes slot 1 with more stuns; that is not an increase
+		occupant(time.Second, 200, 5),
+		// The first player rejoins after leaving; their old state was dropped
+		occupant(2*time.Second, 0, 0),
+		occupant(3*time.Second, 100, 4),
+		occupant(4*time.Second, 100, 6),
+	)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if events[0].Fields["player.stats.stuns"] != 6.0 {
+		t.Errorf("expected the event for the rejoined player's own increase, got %v", events[0].Fields)
+	}
+}
+
+func TestRuleSensor_CompareIsEdgeTriggered(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{
+		Name: "high_ping",
+		When: "player.ping > 150 and session.game_status == \"playing\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := func(at time.Duration, ping int32) *telemetry.LobbySessionStateFrame {
+		f := createRuleTestFrame(at, GameStatusPlaying, 0)
+		f.Session.Teams[0].Players[0].Ping = ping
+		return f
+	}
+
+	events := collectCustomEvents(t, sensor,
+		frame(0, 100),
+		frame(1*time.Second, 200),
+		frame(2*time.Second, 220),
+		frame(3*time.Second, 90),
+		frame(4*time.Second, 180),
+	)
+	if len(events) != 2 {
+		t.Errorf("expected 2 events, got %d", len(events))
+	}
+}
+
+func TestRuleSensor_Cooldown(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{
+		Name:     "stun",
+		When:     "player.stats.stuns increases",
+		Cooldown: "5s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := collectCustomEvents(t, sensor,
+		createRuleTestFrame(0, GameStatusPlaying, 0),
+		createRuleTestFrame(1*time.Second, GameStatusPlaying, 1),
+		createRuleTestFrame(2*time.Second, GameStatusPlaying, 2), // within cooldown
+		createRuleTestFrame(7*time.Second, GameStatusPlaying, 3),
+	)
+	if len(events) != 2 {
+		t.Errorf("expected 2 events, got %d", len(events))
+	}
+}
+
+func TestRuleSensor_MultipleMatchesQueued(t *testing.T) {
+	sensor, err := NewRuleSensor(
+		RuleDefinition{Name: "score_phase", When: `session.game_status changes to "score"`},
+		RuleDefinition{Name: "any_change", When: "session.game_status changes"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := collectCustomEvents(t, sensor,
+		createRuleTestFrame(0, GameStatusPlaying, 0),
+		createRuleTestFrame(time.Second, GameStatusScore, 0),
+	)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Name != "score_phase" || events[1].Name != "any_change" {
+		t.Errorf("expected events in rule order, got %q and %q", events[0].Name, events[1].Name)
+	}
+}
+
+func TestRuleSensor_Reset(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{Name: "stun", When: "player.stats.stuns increases"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collectCustomEvents(t, sensor, createRuleTestFrame(0, GameStatusPlaying, 5))
+	sensor.Reset()
+
+	// After a reset the first frame only establishes the baseline
+	events := collectCustomEvents(t, sensor, createRuleTestFrame(time.Second, GameStatusPlaying, 0))
+	if len(events) != 0 {
+		t.Errorf("expected no events after reset, got %v", events)
+	}
+}
+
+func TestRuleSensor_SnapshotRestore(t *testing.T) {
+	defs := []RuleDefinition{
+		{Name: "stun", When: "player.stats.stuns increases", Cooldown: "5s"},
+		{Name: "stun_spree", When: "player.stats.stuns increases by >= 2 within 3s"},
+		{Name: "score_phase", When: `session.game_status changes to "score"`},
+	}
+	sensor, err := NewRuleSensor(defs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectCustomEvents(t, sensor,
+		createRuleTestFrame(0, GameStatusPlaying, 0),
+		createRuleTestFrame(1*time.Second, GameStatusPlaying, 1),
+	)
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored, err := NewRuleSensor(defs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// The cooldown, the stun history and the previous status all carry over
+	events := collectCustomEvents(t, restored,
+		createRuleTestFrame(2*time.Second, GameStatusScore, 2),
+	)
+	var names []string
+	for _, event := range events {
+		names = append(names, event.Name)
+	}
+	if want := []string{"stun_spree", "score_phase"}; !slices.Equal(names, want) {
+		t.Errorf("expected %v after restore, got %v", want, names)
+	}
+}
+
+func TestRuleSensor_RestoreRejectsOtherRules(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{Name: "stun", When: "player.stats.stuns increases"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectCustomEvents(t, sensor, createRuleTestFrame(0, GameStatusPlaying, 1))
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	other, err := NewRuleSensor(RuleDefinition{Name: "score_phase", When: `session.game_status changes to "score"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Restore(data); err == nil {
+		t.Error("expected Restore to reject a snapshot of different rules")
+	}
+}
+
+func TestRuleSensor_WithDetector(t *testing.T) {
+	sensor, err := NewRuleSensor(RuleDefinition{Name: "score_phase", When: `session.game_status changes to "score"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	detector := New(WithSynchronousProcessing(), WithSensors(sensor))
+	defer detector.Stop()
+
+	sub := detector.Subscribe(EventFilter{Types: []string{"custom:score_phase"}}, 8)
+	detector.ProcessFrame(createRuleTestFrame(0, GameStatusPlaying, 0))
+	detector.ProcessFrame(createRuleTestFrame(time.Second, GameStatusScore, 0))
+
+	select {
+	case event := <-sub.Events():
+		if !IsCustomEvent(event) {
+			t.Errorf("expected custom event, got %v", event)
+		}
+	default:
+		t.Error("expected a custom:score_phase event")
+	}
+}
@@ -3,6 +3,7 @@ package events
 import (
 	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 // ScoreboardSensor detects scoreboard changes
@@ -19,6 +20,45 @@ func NewScoreboardSensor() *ScoreboardSensor {
 	return &ScoreboardSensor{}
 }
 
+// Reset clears the previous scoreboard state
+func (s *ScoreboardSensor) Reset() {
+	*s = ScoreboardSensor{}
+}
+
+// scoreboardSensorState is the snapshot form of ScoreboardSensor
+type scoreboardSensorState struct {
+	PrevBluePoints       int32
+	PrevOrangePoints     int32
+	PrevBlueRoundScore   int32
+	PrevOrangeRoundScore int32
+	Initialized          bool
+}
+
+// Snapshot returns the previous scoreboard state
+func (s *ScoreboardSensor) Snapshot() ([]byte, error) {
+	return encodeSnapshot(scoreboardSensorState{
+		PrevBluePoints:       s.prevBluePoints,
+		PrevOrangePoints:     s.prevOrangePoints,
+		PrevBlueRoundScore:   s.prevBlueRoundScore,
+		PrevOrangeRoundScore: s.prevOrangeRoundScore,
+		Initialized:          s.initialized,
+	})
+}
+
+// Restore replaces the previous scoreboard state with a snapshot
+func (s *ScoreboardSensor) Restore(data []byte) error {
+	var state scoreboardSensorState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+	s.prevBluePoints = state.PrevBluePoints
+	s.prevOrangePoints = state.PrevOrangePoints
+	s.prevBlueRoundScore = state.PrevBlueRoundScore
+	s.prevOrangeRoundScore = state.PrevOrangeRoundScore
+	s.initialized = state.Initialized
+	return nil
+}
+
 // AddFrame processes a frame and returns a ScoreboardUpdated event if detected
 func (s *ScoreboardSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -78,6 +118,34 @@ func NewGoalScoredSensor() *GoalScoredSensor {
 	return &GoalScoredSensor{}
 }
 
+// Reset clears the previous LastScore
+func (s *GoalScoredSensor) Reset() {
+	s.prevLastScore = nil
+}
+
+// Snapshot returns the previous LastScore
+func (s *GoalScoredSensor) Snapshot() ([]byte, error) {
+	m, err := newSnapshotMessage(s.prevLastScore)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSnapshot(m)
+}
+
+// Restore replaces the previous LastScore with a snapshot
+func (s *GoalScoredSensor) Restore(data []byte) error {
+	var m snapshotMessage
+	if err := decodeSnapshot(data, &m); err != nil {
+		return err
+	}
+	lastScore, err := restoreSnapshotMessage(m, func() *apigame.LastScore { return &apigame.LastScore{} })
+	if err != nil {
+		return err
+	}
+	s.prevLastScore = lastScore
+	return nil
+}
+
 // AddFrame processes a frame and returns a GoalScored event if detected
 func (s *GoalScoredSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -92,6 +160,8 @@ func (s *GoalScoredSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *te
 
 	// Detect new goal by comparing with previous
 	if s.prevLastScore == nil || !lastScoreEqual(s.prevLastScore, lastScore) {
+		// Pooled frames reuse their messages, so keep and send a copy
+		lastScore = proto.CloneOf(lastScore)
 		s.prevLastScore = lastScore
 		return &telemetry.LobbySessionEvent{
 			Event: &telemetry.LobbySessionEvent_GoalScored{
@@ -3,6 +3,7 @@ package events
 import (
 	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 // playerStatSnapshot holds the stat values for a player
@@ -39,7 +40,30 @@ func snapshotFromStats(stats *apigame.PlayerStats) playerStatSnapshot {
 	}
 }
 
-// StatEventSensor detects all stat-based events for players
+// values returns the stat values in declaration order, for snapshots
+func (p playerStatSnapshot) values() []int32 {
+	return []int32{
+		p.goals, p.saves, p.stuns, p.passes, p.catches, p.steals,
+		p.blocks, p.interceptions, p.assists, p.shotsTaken, p.points,
+	}
+}
+
+// statSnapshotFromValues is the inverse of playerStatSnapshot.values
+func statSnapshotFromValues(v []int32) playerStatSnapshot {
+	var p playerStatSnapshot
+	for i, dst := range []*int32{
+		&p.goals, &p.saves, &p.stuns, &p.passes, &p.catches, &p.steals,
+		&p.blocks, &p.interceptions, &p.assists, &p.shotsTaken, &p.points,
+	} {
+		if i < len(v) {
+			*dst = v[i]
+		}
+	}
+	return p
+}
+
+// StatEventSensor detects stat-based events for players. PlayerStun is
+// emitted by StunSensor, when a player is seen to be stunned, instead.
 type StatEventSensor struct {
 	prevStats map[int32]playerStatSnapshot // keyed by slot number
 	// Queue of pending events (since we can only return one at a time)
@@ -59,6 +83,68 @@ func NewStatEventSensor() *StatEventSensor {
 	}
 }
 
+// Reset clears the previous stats and any pending events
+func (s *StatEventSensor) Reset() {
+	s.prevStats = make(map[int32]playerStatSnapshot)
+	s.pendingEvents = s.pendingEvents[:0]
+	s.prevPossessorSlot = -1
+	s.initialized = false
+}
+
+// statEventState is the snapshot form of StatEventSensor
+type statEventState struct {
+	PrevStats         map[int32][]int32
+	PendingEvents     [][]byte
+	PrevPossessorSlot int32
+	Initialized       bool
+}
+
+// Snapshot returns the previous stats and any pending events
+func (s *StatEventSensor) Snapshot() ([]byte, error) {
+	state := statEventState{
+		PrevStats:         make(map[int32][]int32, len(s.prevStats)),
+		PendingEvents:     make([][]byte, len(s.pendingEvents)),
+		PrevPossessorSlot: s.prevPossessorSlot,
+		Initialized:       s.initialized,
+	}
+	for slot, stats := range s.prevStats {
+		state.PrevStats[slot] = stats.values()
+	}
+	for i, event := range s.pendingEvents {
+		data, err := proto.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		state.PendingEvents[i] = data
+	}
+	return encodeSnapshot(state)
+}
+
+// Restore replaces the previous stats and pending events with a snapshot
+func (s *StatEventSensor) Restore(data []byte) error {
+	var state statEventState
+	if err := decodeSnapshot(data, &state); err != nil {
+		return err
+	}
+
+	pendingEvents := make([]*telemetry.LobbySessionEvent, len(state.PendingEvents))
+	for i, b := range state.PendingEvents {
+		pendingEvents[i] = &telemetry.LobbySessionEvent{}
+		if err := proto.Unmarshal(b, pendingEvents[i]); err != nil {
+			return err
+		}
+	}
+
+	s.prevStats = make(map[int32]playerStatSnapshot, len(state.PrevStats))
+	for slot, values := range state.PrevStats {
+		s.prevStats[slot] = statSnapshotFromValues(values)
+	}
+	s.pendingEvents = pendingEvents
+	s.prevPossessorSlot = state.PrevPossessorSlot
+	s.initialized = state.Initialized
+	return nil
+}
+
 // AddFrame processes a frame and returns stat events if detected
 func (s *StatEventSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	// Return any pending events first
@@ -156,19 +242,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 		}
 	}
 
-	// Stuns
-	if current.stuns > prev.stuns {
-		for i := int32(0); i < current.stuns-prev.stuns; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
-				Event: &telemetry.LobbySessionEvent_PlayerStun{
-					PlayerStun: &telemetry.PlayerStun{
-						PlayerSlot: slot,
-						TotalStuns: current.stuns,
-					},
-				},
-			})
-		}
-	}
+	// PlayerStun is emitted by StunSensor, which also finds the victim
 
 	// Passes
 	if current.passes > prev.passes {
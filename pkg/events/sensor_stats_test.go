@@ -104,25 +104,32 @@ func TestStatEventSensor_DetectsSave(t *testing.T) {
 }
 
 func TestStatEventSensor_DetectsStun(t *testing.T) {
-	sensor := NewStatEventSensor()
-
-	frame1 := createFrameWithPlayerStats(1, &apigame.PlayerStats{Stuns: 0})
-	sensor.AddFrame(frame1)
+	stats := NewStatEventSensor()
+	stuns := NewStunSensor()
 
-	frame2 := createFrameWithPlayerStats(1, &apigame.PlayerStats{Stuns: 1})
-	event := sensor.AddFrame(frame2)
+	frame1 := createStunFrame(0, stunTestPlayer{slot: 0, x: 0}, stunTestPlayer{slot: 4, x: 1})
+	stats.AddFrame(frame1)
+	stuns.AddFrame(frame1)
 
-	if event == nil {
-		t.Fatal("expected PlayerStun event")
+	// The stun counter alone is not a PlayerStun; the victim being stunned is
+	frame2 := createStunFrame(1, stunTestPlayer{slot: 0, x: 0, stunned: true}, stunTestPlayer{slot: 4, x: 1, stuns: 1})
+	if event := stats.AddFrame(frame2); event != nil {
+		t.Fatalf("expected StatEventSensor to leave stuns to StunSensor, got %v", event)
 	}
 
+	event := stuns.AddFrame(frame2)
 	stun := event.GetPlayerStun()
 	if stun == nil {
-		t.Fatalf("expected PlayerStun, got %T", event.Event)
+		t.Fatalf("expected PlayerStun, got %v", event)
+	}
+	if stun.PlayerSlot != 4 || stun.TotalStuns != 1 {
+		t.Errorf("expected a stun by slot 4 with 1 total, got slot %d with %d", stun.PlayerSlot, stun.TotalStuns)
 	}
 
-	if stun.TotalStuns != 1 {
-		t.Errorf("expected TotalStuns=1, got %d", stun.TotalStuns)
+	// The custom event names the victim
+	custom, err := GetCustomEvent(stuns.AddFrame(nil))
+	if err != nil || custom.Name != PlayerStunnedEventName || custom.Fields["player_slot"] != float64(0) {
+		t.Errorf("expected player_stunned for slot 0, got %v (%v)", custom, err)
 	}
 }
 
@@ -312,7 +319,7 @@ func TestStatEventSensor_MultipleEventsInOneFrame(t *testing.T) {
 
 	// Second frame: multiple stat increases
 	frame2 := createFrameWithPlayerStats(1, &apigame.PlayerStats{
-		Stuns:  2,
+		Saves:  2,
 		Passes: 1,
 	})
 
@@ -326,25 +333,25 @@ func TestStatEventSensor_MultipleEventsInOneFrame(t *testing.T) {
 		events = append(events, event)
 	}
 
-	// Should have 3 events: 2 stuns + 1 pass
+	// Should have 3 events: 2 saves + 1 pass
 	if len(events) != 3 {
 		t.Errorf("expected 3 events, got %d", len(events))
 	}
 
 	// Count event types
-	stunCount := 0
+	saveCount := 0
 	passCount := 0
 	for _, e := range events {
-		if e.GetPlayerStun() != nil {
-			stunCount++
+		if e.GetPlayerSave() != nil {
+			saveCount++
 		}
 		if e.GetPlayerPass() != nil {
 			passCount++
 		}
 	}
 
-	if stunCount != 2 {
-		t.Errorf("expected 2 stun events, got %d", stunCount)
+	if saveCount != 2 {
+		t.Errorf("expected 2 save events, got %d", saveCount)
 	}
 	if passCount != 1 {
 		t.Errorf("expected 1 pass event, got %d", passCount)
@@ -0,0 +1,363 @@
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom event names emitted by StunSensor
+const (
+	PlayerStunnedEventName           = "player_stunned"
+	PlayerRecoveredEventName         = "player_recovered"
+	ShieldBlockEventName             = "shield_block"
+	ShieldBlockEndedEventName        = "shield_block_ended"
+	PlayerInvulnerableEventName      = "player_invulnerable"
+	PlayerInvulnerableEndedEventName = "player_invulnerable_ended"
+)
+
+// StunAttributionDistance is how close, in meters, an opponent must be to a
+// stunned or blocking player to be credited by proximity alone
+const StunAttributionDistance = 3.0
+
+// Attribution methods reported in the "attribution" field of stun and block events
+const (
+	// attributionStat credits the opponent whose stun counter went up on the same frame
+	attributionStat = "stat"
+	// attributionProximity credits the closest opponent
+	attributionProximity = "proximity"
+)
+
+// stunPlayerState is the per-player state tracked by StunSensor
+type stunPlayerState struct {
+	Stunned     bool
+	StunnedAt   time.Time
+	StunnerSlot int32
+
+	Blocking     bool
+	BlockingAt   time.Time
+	AttackerSlot int32
+
+	Invulnerable   bool
+	InvulnerableAt time.Time
+
+	// Stuns is the player's stun counter, used to attribute stuns they land
+	Stuns int32
+}
+
+// StunSensor follows each player's stunned, blocking and invulnerable flags.
+// When a player is stunned it emits the PlayerStun telemetry event for the
+// credited stunner, with the stunner's stun total, followed by a
+// player_stunned custom event naming the victim. It also emits
+// player_recovered with the stun duration, shield_block and
+// shield_block_ended around a block, and player_invulnerable and
+// player_invulnerable_ended around invulnerability.
+//
+// The stunner is the opponent whose stun counter increased on the same
+// frame, closest first, falling back to the closest opponent. A block's
+// attacker is the closest opponent.
+//
+// Stun state is safe to query from any goroutine.
+type StunSensor struct {
+	mu      sync.Mutex
+	players map[int32]*stunPlayerState
+
+	// Queue of pending events (since we can only return one at a time)
+	pendingEvents []*telemetry.LobbySessionEvent
+}
+
+// NewStunSensor creates a new StunSensor
+func NewStunSensor() *StunSensor {
+	return &StunSensor{players: make(map[int32]*stunPlayerState)}
+}
+
+// Reset clears player state and any pending events
+func (s *StunSensor) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.players = make(map[int32]*stunPlayerState)
+	s.pendingEvents = s.pendingEvents[:0]
+}
+
+// IsStunned reports whether the player in the slot is currently stunned
+func (s *StunSensor) IsStunned(slot int32) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.players[slot]
+	return ok && p.Stunned
+}
+
+// IsBlocking reports whether the player in the slot is currently blocking
+func (s *StunSensor) IsBlocking(slot int32) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.players[slot]
+	return ok && p.Blocking
+}
+
+// IsInvulnerable reports whether the player in the slot is currently invulnerable
+func (s *StunSensor) IsInvulnerable(slot int32) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.players[slot]
+	return ok && p.Invulnerable
+}
+
+// StunnedPlayers returns the slots of all currently stunned players in ascending order
+func (s *StunSensor) StunnedPlayers() []int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var slots []int32
+	for slot, p := range s.players {
+		if p.Stunned {
+			slots = append(slots, slot)
+		}
+	}
+	slices.Sort(slots)
+	return slots
+}
+
+// Snapshot returns player stun state
+func (s *StunSensor) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return encodeSnapshot(s.players)
+}
+
+// Restore replaces player stun state with a snapshot
+func (s *StunSensor) Restore(data []byte) error {
+	players := make(map[int32]*stunPlayerState)
+	if err := decodeSnapshot(data, &players); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.players = players
+	s.pendingEvents = s.pendingEvents[:0]
+	return nil
+}
+
+// AddFrame processes a frame and returns stun, block and invulnerability events
+func (s *StunSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if frame != nil && frame.GetSession() != nil {
+		s.processFrame(frame)
+	}
+
+	if len(s.pendingEvents) > 0 {
+		event := s.pendingEvents[0]
+		s.pendingEvents = s.pendingEvents[1:]
+		return event
+	}
+	return nil
+}
+
+// processFrame compares each player's flags with the previous frame
+func (s *StunSensor) processFrame(frame *telemetry.LobbySessionStateFrame) {
+	at := frame.GetTimestamp().AsTime()
+
+	// Players in session order, so attribution is deterministic
+	var players []*apigame.TeamMember
+	for _, team := range frame.GetSession().GetTeams() {
+		players = append(players, team.GetPlayers()...)
+	}
+
+	// Stun counter increases on this frame, by stunner slot, for attribution
+	stunDeltas := make(map[int32]int32)
+	var changed []*apigame.TeamMember
+	seen := make(map[int32]bool, len(players))
+
+	for _, player := range players {
+		slot := player.GetSlotNumber()
+		seen[slot] = true
+		prev, existed := s.players[slot]
+		if !existed {
+			// Flags already set when a player is first seen have no known start
+			s.players[slot] = &stunPlayerState{
+				Stunned:        player.GetIsStunned(),
+				StunnedAt:      at,
+				StunnerSlot:    -1,
+				Blocking:       player.GetIsBlocking(),
+				BlockingAt:     at,
+				AttackerSlot:   -1,
+				Invulnerable:   player.GetIsInvulnerable(),
+				InvulnerableAt: at,
+				Stuns:          player.GetStats().GetStuns(),
+			}
+			continue
+		}
+
+		if stuns := player.GetStats().GetStuns(); stuns > prev.Stuns {
+			stunDeltas[slot] = stuns - prev.Stuns
+		}
+		if player.GetIsStunned() != prev.Stunned || player.GetIsBlocking() != prev.Blocking || player.GetIsInvulnerable() != prev.Invulnerable {
+			changed = append(changed, player)
+		}
+	}
+
+	for _, player := range changed {
+		state := s.players[player.GetSlotNumber()]
+		if player.GetIsStunned() != state.Stunned {
+			s.stunChanged(player, state, players, stunDeltas, at)
+		}
+		if player.GetIsBlocking() != state.Blocking {
+			s.blockChanged(player, state, players, at)
+		}
+		if player.GetIsInvulnerable() != state.Invulnerable {
+			s.invulnerableChanged(player, state, at)
+		}
+	}
+
+	for _, player := range players {
+		s.players[player.GetSlotNumber()].Stuns = player.GetStats().GetStuns()
+	}
+	for slot := range s.players {
+		if !seen[slot] {
+			delete(s.players, slot)
+		}
+	}
+}
+
+// stunChanged reports a player being stunned or recovering
+func (s *StunSensor) stunChanged(player *apigame.TeamMember, state *stunPlayerState, players []*apigame.TeamMember, stunDeltas map[int32]int32, at time.Time) {
+	if player.GetIsStunned() {
+		stunner, attribution := attributeStun(player, players, stunDeltas)
+		state.Stunned = true
+		state.StunnedAt = at
+		state.StunnerSlot = stunner
+		if stunner >= 0 {
+			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+				Event: &telemetry.LobbySessionEvent_PlayerStun{
+					PlayerStun: &telemetry.PlayerStun{
+						PlayerSlot: stunner,
+						TotalStuns: stunTotal(players, stunner),
+					},
+				},
+			})
+		}
+		s.pendingEvents = append(s.pendingEvents, stunEvent(PlayerStunnedEventName, player, map[string]any{
+			"stunner_slot": stunner,
+			"attribution":  attribution,
+		}))
+		return
+	}
+
+	s.pendingEvents = append(s.pendingEvents, stunEvent(PlayerRecoveredEventName, player, map[string]any{
+		"stunner_slot":  state.StunnerSlot,
+		"stun_duration": at.Sub(state.StunnedAt).Seconds(),
+		"invulnerable":  player.GetIsInvulnerable(),
+	}))
+	state.Stunned = false
+	state.StunnerSlot = -1
+}
+
+// blockChanged reports a player raising or lowering their shield
+func (s *StunSensor) blockChanged(player *apigame.TeamMember, state *stunPlayerState, players []*apigame.TeamMember, at time.Time) {
+	if player.GetIsBlocking() {
+		attacker, attribution := int32(-1), ""
+		if slot, ok := closestOpponent(player, players, nil); ok {
+			attacker, attribution = slot, attributionProximity
+		}
+		state.Blocking = true
+		state.BlockingAt = at
+		state.AttackerSlot = attacker
+		s.pendingEvents = append(s.pendingEvents, stunEvent(ShieldBlockEventName, player, map[string]any{
+			"attacker_slot": attacker,
+			"attribution":   attribution,
+		}))
+		return
+	}
+
+	s.pendingEvents = append(s.pendingEvents, stunEvent(ShieldBlockEndedEventName, player, map[string]any{
+		"attacker_slot":  state.AttackerSlot,
+		"block_duration": at.Sub(state.BlockingAt).Seconds(),
+	}))
+	state.Blocking = false
+	state.AttackerSlot = -1
+}
+
+// invulnerableChanged reports a player becoming invulnerable or vulnerable again
+func (s *StunSensor) invulnerableChanged(player *apigame.TeamMember, state *stunPlayerState, at time.Time) {
+	if player.GetIsInvulnerable() {
+		state.Invulnerable = true
+		state.InvulnerableAt = at
+		s.pendingEvents = append(s.pendingEvents, stunEvent(PlayerInvulnerableEventName, player, map[string]any{}))
+		return
+	}
+
+	s.pendingEvents = append(s.pendingEvents, stunEvent(PlayerInvulnerableEndedEventName, player, map[string]any{
+		"invulnerable_duration": at.Sub(state.InvulnerableAt).Seconds(),
+	}))
+	state.Invulnerable = false
+}
+
+// stunEvent builds a StunSensor custom event for a player
+func stunEvent(name string, player *apigame.TeamMember, fields map[string]any) *telemetry.LobbySessionEvent {
+	fields["player_slot"] = player.GetSlotNumber()
+	fields["display_name"] = player.GetDisplayName()
+	fields["team"] = determinePlayerRole(player).String()
+	return newCustomEvent(name, fields)
+}
+
+// stunTotal returns the stun counter of the player in a slot
+func stunTotal(players []*apigame.TeamMember, slot int32) int32 {
+	for _, p := range players {
+		if p.GetSlotNumber() == slot {
+			return p.GetStats().GetStuns()
+		}
+	}
+	return 0
+}
+
+// attributeStun finds who stunned the victim, consuming one of their counter increases
+func attributeStun(victim *apigame.TeamMember, players []*apigame.TeamMember, stunDeltas map[int32]int32) (int32, string) {
+	if slot, ok := closestOpponent(victim, players, func(p *apigame.TeamMember) bool {
+		return stunDeltas[p.GetSlotNumber()] > 0
+	}); ok {
+		stunDeltas[slot]--
+		return slot, attributionStat
+	}
+	if slot, ok := closestOpponent(victim, players, nil); ok {
+		return slot, attributionProximity
+	}
+	return -1, ""
+}
+
+// closestOpponent returns the closest opponent of the player that passes the
+// filter. Without a filter, only opponents within StunAttributionDistance
+// qualify; with one, distance is only used to choose between candidates.
+func closestOpponent(player *apigame.TeamMember, players []*apigame.TeamMember, filter func(*apigame.TeamMember) bool) (int32, bool) {
+	role := determinePlayerRole(player)
+	position := player.GetHead().GetPosition()
+
+	best, bestDistance, found := int32(-1), 0.0, false
+	for _, other := range players {
+		otherRole := determinePlayerRole(other)
+		if otherRole == role || otherRole == telemetry.Role_ROLE_SPECTATOR {
+			continue
+		}
+		if filter != nil && !filter(other) {
+			continue
+		}
+
+		d, ok := playerDistanceTo(other, position)
+		if !ok {
+			if filter == nil {
+				continue
+			}
+			d = StunAttributionDistance
+		}
+		if filter == nil && d > StunAttributionDistance {
+			continue
+		}
+		if !found || d < bestDistance {
+			best, bestDistance, found = other.GetSlotNumber(), d, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,263 @@
+package events
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// stunTestPlayer describes a player in a StunSensor test frame
+type stunTestPlayer struct {
+	slot         int32
+	x            float64
+	stunned      bool
+	blocking     bool
+	invulnerable bool
+	stuns        int32
+}
+
+// Helper to create a frame from test players placed along the x axis
+func createStunFrame(at time.Duration, players ...stunTestPlayer) *telemetry.LobbySessionStateFrame {
+	var members []*apigame.TeamMember
+	for _, p := range players {
+		members = append(members, &apigame.TeamMember{
+			SlotNumber:     p.slot,
+			Head:           &apigame.BodyPart{Position: []float64{p.x, 0, 0}},
+			IsStunned:      p.stunned,
+			IsBlocking:     p.blocking,
+			IsInvulnerable: p.invulnerable,
+			Stats:          &apigame.PlayerStats{Stuns: p.stuns},
+		})
+	}
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(time.Unix(1700000000, 0).Add(at)),
+		Session: &apigame.SessionResponse{
+			GameStatus: GameStatusPlaying,
+			Teams:      []*apigame.Team{{Players: members}},
+		},
+	}
+}
+
+// Helper to feed a frame to the sensor and collect every event it emits
+func collectStunEvents(sensor *StunSensor, frame *telemetry.LobbySessionStateFrame) []*telemetry.LobbySessionEvent {
+	var events []*telemetry.LobbySessionEvent
+	for event := sensor.AddFrame(frame); event != nil; event = sensor.AddFrame(nil) {
+		events = append(events, event)
+	}
+	return events
+}
+
+// Helper to decode the custom events with the given name
+func customEventsNamed(t *testing.T, events []*telemetry.LobbySessionEvent, name string) []*CustomEvent {
+	t.Helper()
+	var found []*CustomEvent
+	for _, event := range events {
+		if custom, err := GetCustomEvent(event); err == nil && custom.Name == name {
+			found = append(found, custom)
+		}
+	}
+	return found
+}
+
+func TestStunSensor_StunAndRecovery(t *testing.T) {
+	sensor := NewStunSensor()
+
+	collectStunEvents(sensor, createStunFrame(0,
+		stunTestPlayer{slot: 0, x: 0},
+		stunTestPlayer{slot: 4, x: 10},
+		stunTestPlayer{slot: 5, x: 1},
+	))
+
+	// Slot 4 is credited by its stun counter even though slot 5 is closer
+	events := collectStunEvents(sensor, createStunFrame(time.Second,
+		stunTestPlayer{slot: 0, x: 0, stunned: true},
+		stunTestPlayer{slot: 4, x: 10, stuns: 1},
+		stunTestPlayer{slot: 5, x: 1},
+	))
+
+	var typed []*telemetry.PlayerStun
+	for _, event := range events {
+		if stun := event.GetPlayerStun(); stun != nil {
+			typed = append(typed, stun)
+		}
+	}
+	if len(typed) != 1 || typed[0].PlayerSlot != 4 || typed[0].TotalStuns != 1 {
+		t.Errorf("expected one PlayerStun for slot 4, got %v", typed)
+	}
+
+	stunned := customEventsNamed(t, events, PlayerStunnedEventName)
+	if len(stunned) != 1 {
+		t.Fatalf("expected one player_stunned event, got %d", len(stunned))
+	}
+	if stunned[0].Fields["player_slot"] != float64(0) || stunned[0].Fields["stunner_slot"] != float64(4) {
+		t.Errorf("expected slot 0 stunned by slot 4, got %v", stunned[0].Fields)
+	}
+	if stunned[0].Fields["attribution"] != attributionStat {
+		t.Errorf("expected stat attribution, got %v", stunned[0].Fields["attribution"])
+	}
+	if !sensor.IsStunned(0) || !slices.Equal(sensor.StunnedPlayers(), []int32{0}) {
+		t.Errorf("expected slot 0 to be stunned, got %v", sensor.StunnedPlayers())
+	}
+
+	events = collectStunEvents(sensor, createStunFrame(3*time.Second,
+		stunTestPlayer{slot: 0, x: 0},
+		stunTestPlayer{slot: 4, x: 10, stuns: 1},
+		stunTestPlayer{slot: 5, x: 1},
+	))
+	recovered := customEventsNamed(t, events, PlayerRecoveredEventName)
+	if len(recovered) != 1 {
+		t.Fatalf("expected one player_recovered event, got %d", len(recovered))
+	}
+	if recovered[0].Fields["stun_duration"] != 2.0 || recovered[0].Fields["stunner_slot"] != float64(4) {
+		t.Errorf("expected a 2s stun by slot 4, got %v", recovered[0].Fields)
+	}
+	if sensor.IsStunned(0) {
+		t.Error("expected slot 0 to have recovered")
+	}
+}
+
+func TestStunSensor_ProximityAttribution(t *testing.T) {
+	sensor := NewStunSensor()
+
+	collectStunEvents(sensor, createStunFrame(0,
+		stunTestPlayer{slot: 0, x: 0},
+		stunTestPlayer{slot: 1, x: 0.5},
+		stunTestPlayer{slot: 4, x: 2},
+		stunTestPlayer{slot: 5, x: 20},
+	))
+
+	// No counter moved this frame, so the closest opponent is credited; teammates never are
+	events := collectStunEvents(sensor, createStunFrame(time.Second,
+		stunTestPlayer{slot: 0, x: 0, stunned: true},
+		stunTestPlayer{slot: 1, x: 0.5},
+		stunTestPlayer{slot: 4, x: 2},
+		stunTestPlayer{slot: 5, x: 20},
+	))
+	stunned := customEventsNamed(t, events, PlayerStunnedEventName)
+	if len(stunned) != 1 || stunned[0].Fields["stunner_slot"] != float64(4) || stunned[0].Fields["attribution"] != attributionProximity {
+		t.Errorf("expected slot 4 credited by proximity, got %v", stunned)
+	}
+}
+
+func TestStunSensor_NoOpponentNearby(t *testing.T) {
+	sensor := NewStunSensor()
+
+	collectStunEvents(sensor, createStunFrame(0, stunTestPlayer{slot: 0, x: 0}, stunTestPlayer{slot: 4, x: 30}))
+	events := collectStunEvents(sensor, createStunFrame(time.Second,
+		stunTestPlayer{slot: 0, x: 0, stunned: true},
+		stunTestPlayer{slot: 4, x: 30},
+	))
+	stunned := customEventsNamed(t, events, PlayerStunnedEventName)
+	if len(stunned) != 1 || stunned[0].Fields["stunner_slot"] != float64(-1) {
+		t.Errorf("expected an unattributed stun, got %v", stunned)
+	}
+}
+
+func TestStunSensor_ShieldBlock(t *testing.T) {
+	sensor := NewStunSensor()
+
+	collectStunEvents(sensor, createStunFrame(0, stunTestPlayer{slot: 0, x: 0}, stunTestPlayer{slot: 4, x: 1}))
+	events := collectStunEvents(sensor, createStunFrame(time.Second,
+		stunTestPlayer{slot: 0, x: 0, blocking: true},
+		stunTestPlayer{slot: 4, x: 1},
+	))
+
+	blocks := customEventsNamed(t, events, ShieldBlockEventName)
+	if len(blocks) != 1 {
+		t.Fatalf("expected one shield_block event, got %d", len(blocks))
+	}
+	if blocks[0].Fields["player_slot"] != float64(0) || blocks[0].Fields["attacker_slot"] != float64(4) || blocks[0].Fields["attribution"] != attributionProximity {
+		t.Errorf("unexpected shield_block fields: %v", blocks[0].Fields)
+	}
+	if !sensor.IsBlocking(0) {
+		t.Error("expected slot 0 to be blocking")
+	}
+
+	// Holding the shield up is one block
+	events = collectStunEvents(sensor, createStunFrame(2*time.Second,
+		stunTestPlayer{slot: 0, x: 0, blocking: true},
+		stunTestPlayer{slot: 4, x: 1},
+	))
+	if len(events) != 0 {
+		t.Errorf("expected no events while blocking, got %v", events)
+	}
+
+	events = collectStunEvents(sensor, createStunFrame(2500*time.Millisecond,
+		stunTestPlayer{slot: 0, x: 0},
+		stunTestPlayer{slot: 4, x: 1},
+	))
+	ended := customEventsNamed(t, events, ShieldBlockEndedEventName)
+	if len(ended) != 1 || ended[0].Fields["block_duration"] != 1.5 || ended[0].Fields["attacker_slot"] != float64(4) {
+		t.Errorf("expected a 1.5s block against slot 4 to end, got %v", ended)
+	}
+	if sensor.IsBlocking(0) {
+		t.Error("expected slot 0 to have stopped blocking")
+	}
+}
+
+func TestStunSensor_Invulnerability(t *testing.T) {
+	sensor := NewStunSensor()
+
+	collectStunEvents(sensor, createStunFrame(0, stunTestPlayer{slot: 0}))
+	events := collectStunEvents(sensor, createStunFrame(time.Second, stunTestPlayer{slot: 0, invulnerable: true}))
+	if got := customEventsNamed(t, events, PlayerInvulnerableEventName); len(got) != 1 || got[0].Fields["player_slot"] != float64(0) {
+		t.Fatalf("expected one player_invulnerable event for slot 0, got %v", got)
+	}
+	if !sensor.IsInvulnerable(0) {
+		t.Error("expected slot 0 to be invulnerable")
+	}
+
+	events = collectStunEvents(sensor, createStunFrame(4*time.Second, stunTestPlayer{slot: 0}))
+	if got := customEventsNamed(t, events, PlayerInvulnerableEndedEventName); len(got) != 1 || got[0].Fields["invulnerable_duration"] != 3.0 {
+		t.Errorf("expected 3s of invulnerability to end, got %v", got)
+	}
+}
+
+func TestStunSensor_FirstFrameIsBaseline(t *testing.T) {
+	sensor := NewStunSensor()
+
+	// Players already stunned when first seen have no known start
+	events := collectStunEvents(sensor, createStunFrame(0, stunTestPlayer{slot: 0, stunned: true, blocking: true, invulnerable: true, stuns: 3}))
+	if len(events) != 0 {
+		t.Errorf("expected no events on the first frame, got %v", events)
+	}
+}
+
+func TestStunSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewStunSensor()
+	collectStunEvents(sensor, createStunFrame(0, stunTestPlayer{slot: 0}, stunTestPlayer{slot: 4, x: 1}))
+	collectStunEvents(sensor, createStunFrame(time.Second, stunTestPlayer{slot: 0, stunned: true}, stunTestPlayer{slot: 4, x: 1, stuns: 1}))
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewStunSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if !restored.IsStunned(0) {
+		t.Error("expected restored sensor to know slot 0 is stunned")
+	}
+
+	events := collectStunEvents(restored, createStunFrame(2*time.Second, stunTestPlayer{slot: 0}, stunTestPlayer{slot: 4, x: 1, stuns: 1}))
+	recovered := customEventsNamed(t, events, PlayerRecoveredEventName)
+	if len(recovered) != 1 || recovered[0].Fields["stun_duration"] != 1.0 {
+		t.Errorf("expected a 1s stun after restore, got %v", recovered)
+	}
+}
+
+func TestStunSensor_Reset(t *testing.T) {
+	sensor := NewStunSensor()
+	collectStunEvents(sensor, createStunFrame(0, stunTestPlayer{slot: 0}))
+	collectStunEvents(sensor, createStunFrame(time.Second, stunTestPlayer{slot: 0, stunned: true}))
+
+	sensor.Reset()
+	if sensor.IsStunned(0) {
+		t.Error("expected Reset to clear stun state")
+	}
+}
@@ -1,7 +1,15 @@
 package events
 
+import "slices"
+
 // DefaultSensors returns all available event sensors
 func DefaultSensors() []Sensor {
+	return DefaultSensorsWithPhases(NewMatchPhaseTracker())
+}
+
+// DefaultSensorsWithPhases returns all available event sensors, with the
+// game state sensors sharing the given tracker so they agree on transitions
+func DefaultSensorsWithPhases(phases *MatchPhaseTracker) []Sensor {
 	return []Sensor{
 		// Player events
 		NewPlayerJoinSensor(),
@@ -20,12 +28,62 @@ func DefaultSensors() []Sensor {
 
 		// Stat events
 		NewStatEventSensor(),
+		NewStunSensor(),
 
 		// Game state events
-		NewRoundStartSensor(),
-		NewRoundEndSensor(),
-		NewMatchEndSensor(),
-		NewPauseSensor(),
+		NewRoundStartSensorWithPhases(phases),
+		NewRoundEndSensorWithPhases(phases),
+		NewMatchEndSensorWithPhases(phases),
+		NewPauseSensorWithPhases(phases),
+	}
+}
+
+// DefaultSensorsWithNetwork returns the sensors of DefaultSensorsWithPhases
+// with PlayerJoinSensor and PlayerLeaveSensor replaced by a
+// NetworkQualitySensor that reports joins and leaves, so a player who
+// reconnects is reported once rather than as a leave and a join. The
+// sensor is created with opts and WithJoinLeaveEvents, and also reports
+// network quality, so it takes the place of the NetworkQualitySensor from
+// AnalyticsSensors; leave that one out.
+func DefaultSensorsWithNetwork(phases *MatchPhaseTracker, opts ...NetworkQualityOption) []Sensor {
+	sensors := []Sensor{NewNetworkQualitySensor(append(slices.Clone(opts), WithJoinLeaveEvents())...)}
+	for _, s := range DefaultSensorsWithPhases(phases) {
+		switch s.(type) {
+		case *PlayerJoinSensor, *PlayerLeaveSensor:
+			continue
+		}
+		sensors = append(sensors, s)
+	}
+	return sensors
+}
+
+// AnalyticsSensors returns sensors that emit derived analytics as custom
+// events. They are not part of DefaultSensors because their events have no
+// dedicated telemetry message; see CustomEvent. The match_phase events come
+// from phases itself, so pass the tracker given to DefaultSensorsWithPhases
+// to report the same transitions as the game state sensors. The sensors
+// that only count time in play share phases too, so they agree with the
+// game state sensors about pauses and round ends.
+func AnalyticsSensors(phases *MatchPhaseTracker) []Sensor {
+	return []Sensor{
+		NewJoustSensorWithPhases(phases),
+		NewGoalAttributionSensor(),
+		NewPossessionTrackerWithPhases(phases),
+		NewNetworkQualitySensor(),
+		phases,
+		NewDiscPhysicsSensor(),
+		NewMovementSensorWithPhases(phases),
+		NewIdleSensorWithPhases(phases),
+	}
+}
+
+// AuditSensors returns sensors that record rule, restart and lobby settings
+// changes as custom events, for an audit trail of referee and lobby actions
+func AuditSensors() []Sensor {
+	return []Sensor{
+		NewRulesChangedSensor(),
+		NewRestartRequestSensor(),
+		NewLobbySettingsSensor(),
 	}
 }
 
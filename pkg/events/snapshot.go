@@ -0,0 +1,265 @@
+package events
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// snapshotVersion is incremented whenever the detector snapshot layout changes
+const snapshotVersion = 2
+
+var (
+	ErrDetectorStopped      = errors.New("detector is stopped")
+	ErrSnapshotVersion      = errors.New("unsupported snapshot version")
+	ErrSnapshotSensorsMatch = errors.New("snapshot sensors do not match detector sensors")
+)
+
+// detectorSnapshot is the encoded form of an AsyncDetector checkpoint
+type detectorSnapshot struct {
+	Version int
+	// Frames holds the ring buffer contents, oldest first
+	Frames    [][]byte
+	Phases    []byte
+	SessionID string
+	Sensors   []sensorSnapshot
+}
+
+// sensorSnapshot holds the state of a single sensor
+type sensorSnapshot struct {
+	Type  string
+	State []byte
+}
+
+// Snapshot checkpoints the ring buffer and the state of every sensor that
+// implements Snapshotter. In asynchronous mode, frames still waiting in the
+// input channel are not part of the snapshot.
+func (ed *AsyncDetector) Snapshot() ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if doErr := ed.do(func() { data, err = ed.snapshot() }); doErr != nil {
+		return nil, doErr
+	}
+	return data, err
+}
+
+// Restore replaces the detector state with a snapshot taken by Snapshot.
+// The detector must be configured with the same sensors, in the same order,
+// as the detector the snapshot was taken from.
+func (ed *AsyncDetector) Restore(data []byte) error {
+	var err error
+	if doErr := ed.do(func() { err = ed.restore(data) }); doErr != nil {
+		return doErr
+	}
+	return err
+}
+
+// do runs fn on the goroutine that owns the detector state
+func (ed *AsyncDetector) do(fn func()) error {
+	if ed.synchronous {
+		fn()
+		return nil
+	}
+
+	done := make(chan struct{})
+	select {
+	case ed.controlChan <- func() { fn(); close(done) }:
+	case <-ed.ctx.Done():
+		return ErrDetectorStopped
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ed.ctx.Done():
+		return ErrDetectorStopped
+	}
+}
+
+func (ed *AsyncDetector) snapshot() ([]byte, error) {
+	snap := detectorSnapshot{
+		Version:   snapshotVersion,
+		Frames:    make([][]byte, 0, ed.frameCount),
+		SessionID: ed.sessionID,
+		Sensors:   make([]sensorSnapshot, len(ed.sensors)),
+	}
+
+	for offset := ed.frameCount - 1; offset >= 0; offset-- {
+		data, err := proto.Marshal(ed.getFrame(offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal buffered frame: %w", err)
+		}
+		snap.Frames = append(snap.Frames, data)
+	}
+
+	var err error
+	if snap.Phases, err = ed.phases.Snapshot(); err != nil {
+		return nil, fmt.Errorf("failed to snapshot match phases: %w", err)
+	}
+
+	for i, s := range ed.sensors {
+		snap.Sensors[i].Type = fmt.Sprintf("%T", s)
+		if ss, ok := s.(Snapshotter); ok {
+			if snap.Sensors[i].State, err = ss.Snapshot(); err != nil {
+				return nil, fmt.Errorf("failed to snapshot %T: %w", s, err)
+			}
+		}
+	}
+
+	return encodeSnapshot(&snap)
+}
+
+func (ed *AsyncDetector) restore(data []byte) error {
+	var snap detectorSnapshot
+	if err := decodeSnapshot(data, &snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, snap.Version)
+	}
+
+	if len(snap.Sensors) != len(ed.sensors) {
+		return fmt.Errorf("%w: snapshot has %d sensors, detector has %d", ErrSnapshotSensorsMatch, len(snap.Sensors), len(ed.sensors))
+	}
+	for i, s := range ed.sensors {
+		if typ := fmt.Sprintf("%T", s); snap.Sensors[i].Type != typ {
+			return fmt.Errorf("%w: sensor %d is %s, snapshot has %s", ErrSnapshotSensorsMatch, i, typ, snap.Sensors[i].Type)
+		}
+	}
+
+	// Decode everything before touching the detector so a bad snapshot leaves it unchanged
+	frames := make([]*telemetry.LobbySessionStateFrame, len(snap.Frames))
+	for i, b := range snap.Frames {
+		frames[i] = newFrame()
+		if err := proto.Unmarshal(b, frames[i]); err != nil {
+			return fmt.Errorf("failed to unmarshal buffered frame: %w", err)
+		}
+	}
+	phases, err := decodeMatchPhaseState(snap.Phases)
+	if err != nil {
+		return fmt.Errorf("failed to restore match phases: %w", err)
+	}
+	if err := ed.restoreSensors(snap.Sensors); err != nil {
+		return err
+	}
+
+	// Every part decoded; commit the rest
+	ed.releaseFrames()
+	for _, s := range ed.sensors {
+		if _, ok := s.(Snapshotter); ok {
+			continue
+		}
+		if r, ok := s.(Resetter); ok {
+			r.Reset()
+		}
+	}
+
+	// Keep only the newest frames if the snapshot holds more than the buffer can
+	if len(frames) > len(ed.frameBuffer) {
+		frames = frames[len(frames)-len(ed.frameBuffer):]
+	}
+	for _, frame := range frames {
+		ed.addFrameToBuffer(frame)
+	}
+	ed.phases.setState(phases)
+	ed.sessionID = snap.SessionID
+
+	return nil
+}
+
+// restoreSensors restores every Snapshotter sensor, or none of them. A
+// sensor can only decode a snapshot into itself, so each one's current state
+// is kept first and put back if a later sensor's snapshot fails to decode.
+func (ed *AsyncDetector) restoreSensors(states []sensorSnapshot) error {
+	var restored []Snapshotter
+	var backups [][]byte
+	for i, s := range ed.sensors {
+		ss, ok := s.(Snapshotter)
+		if !ok {
+			continue
+		}
+		backup, err := ss.Snapshot()
+		if err != nil {
+			return fmt.Errorf("failed to snapshot %T: %w", s, err)
+		}
+		if err := restoreSensor(ss, states[i].State); err != nil {
+			// Sensors restore their own snapshots
+			for j, prev := range restored {
+				_ = restoreSensor(prev, backups[j])
+			}
+			return fmt.Errorf("failed to restore %T: %w", s, err)
+		}
+		restored = append(restored, ss)
+		backups = append(backups, backup)
+	}
+	return nil
+}
+
+// restoreSensor resets a sensor, if it can be, then restores a snapshot into it
+func restoreSensor(ss Snapshotter, data []byte) error {
+	if r, ok := ss.(Resetter); ok {
+		r.Reset()
+	}
+	return ss.Restore(data)
+}
+
+// newFrame allocates an empty frame for unmarshaling
+func newFrame() *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{}
+}
+
+// encodeSnapshot gob-encodes a snapshot state struct
+func encodeSnapshot(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeSnapshot decodes a snapshot state struct encoded by encodeSnapshot
+func decodeSnapshot(data []byte, v any) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return nil
+}
+
+// snapshotMessage is a protobuf message encoded for a snapshot.
+// Present distinguishes a nil message from an empty one, which gob cannot.
+type snapshotMessage struct {
+	Present bool
+	Data    []byte
+}
+
+// newSnapshotMessage marshals msg for inclusion in a snapshot
+func newSnapshotMessage(msg proto.Message) (snapshotMessage, error) {
+	if msg == nil || !msg.ProtoReflect().IsValid() {
+		return snapshotMessage{}, nil
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return snapshotMessage{}, err
+	}
+	return snapshotMessage{Present: true, Data: data}, nil
+}
+
+// restoreSnapshotMessage unmarshals a message encoded by newSnapshotMessage,
+// returning nil if the original message was nil
+func restoreSnapshotMessage[M proto.Message](m snapshotMessage, newMsg func() M) (M, error) {
+	var zero M
+	if !m.Present {
+		return zero, nil
+	}
+	msg := newMsg()
+	if err := proto.Unmarshal(m.Data, msg); err != nil {
+		return zero, err
+	}
+	return msg, nil
+}
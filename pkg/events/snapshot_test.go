@@ -0,0 +1,234 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// createSnapshotTestFrames creates a short sequence of frames exercising several sensors
+func createSnapshotTestFrames() []*telemetry.LobbySessionStateFrame {
+	frame := func(status string, goals int32, possession bool) *telemetry.LobbySessionStateFrame {
+		return &telemetry.LobbySessionStateFrame{
+			Session: &apigame.SessionResponse{
+				SessionId:  "session-a",
+				GameStatus: status,
+				BluePoints: goals * 2,
+				Teams: []*apigame.Team{{
+					Players: []*apigame.TeamMember{
+						{SlotNumber: 1, DisplayName: "Player1", HasPossession: possession, Stats: &apigame.PlayerStats{Goals: goals, Points: goals * 2}},
+						{SlotNumber: 5, DisplayName: "Player5", JerseyNumber: 5},
+					},
+				}},
+			},
+		}
+	}
+	return []*telemetry.LobbySessionStateFrame{
+		frame("pre_match", 0, false),
+		frame("round_start", 0, false),
+		frame("playing", 0, true),
+		frame("playing", 0, false),
+		frame("score", 1, false),
+		frame("playing", 1, true),
+	}
+}
+
+func TestDefaultSensors_ImplementSnapshotter(t *testing.T) {
+	for _, s := range DefaultSensors() {
+		if _, ok := s.(Snapshotter); !ok {
+			t.Errorf("%T does not implement Snapshotter", s)
+		}
+	}
+}
+
+func TestAsyncDetector_SnapshotRestore(t *testing.T) {
+	frames := createSnapshotTestFrames()
+	split := 3
+
+	// Reference detector processes every frame
+	reference := NewWithDefaultSensors(WithSynchronousProcessing(), WithEventsChannelSize(100))
+	defer reference.Stop()
+	for _, frame := range frames[:split] {
+		reference.ProcessFrame(frame)
+	}
+	drainEvents(reference.EventsChan())
+
+	data, err := reference.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// Restored detector resumes from the checkpoint
+	restored := NewWithDefaultSensors(WithSynchronousProcessing(), WithEventsChannelSize(100))
+	defer restored.Stop()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if restored.frameCount != reference.frameCount {
+		t.Errorf("expected %d buffered frames, got %d", reference.frameCount, restored.frameCount)
+	}
+	if !proto.Equal(restored.lastFrame(), reference.lastFrame()) {
+		t.Error("restored last frame does not match reference")
+	}
+
+	for _, frame := range frames[split:] {
+		reference.ProcessFrame(frame)
+		restored.ProcessFrame(frame)
+	}
+
+	want := drainEvents(reference.EventsChan())
+	got := drainEvents(restored.EventsChan())
+	if len(want) == 0 {
+		t.Fatal("expected reference detector to emit events")
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("event %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestAsyncDetector_SnapshotAsync(t *testing.T) {
+	detector := NewWithDefaultSensors()
+
+	if _, err := detector.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	detector.Stop()
+	if _, err := detector.Snapshot(); !errors.Is(err, ErrDetectorStopped) {
+		t.Fatalf("expected ErrDetectorStopped, got %v", err)
+	}
+}
+
+func TestAsyncDetector_RestoreSensorMismatch(t *testing.T) {
+	source := New(WithSynchronousProcessing(), WithSensors(NewPlayerJoinSensor()))
+	defer source.Stop()
+	data, err := source.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		sensors []Sensor
+	}{
+		{name: "different count", sensors: []Sensor{NewPlayerJoinSensor(), NewPlayerLeaveSensor()}},
+		{name: "different type", sensors: []Sensor{NewPlayerLeaveSensor()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := New(WithSynchronousProcessing(), WithSensors(tt.sensors...))
+			defer target.Stop()
+			if err := target.Restore(data); !errors.Is(err, ErrSnapshotSensorsMatch) {
+				t.Fatalf("expected ErrSnapshotSensorsMatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAsyncDetector_RestoreBadSensorLeavesDetectorUnchanged(t *testing.T) {
+	source := New(WithSynchronousProcessing(), WithSensors(NewStunSensor(), NewPlayerJoinSensor()))
+	defer source.Stop()
+	source.ProcessFrame(createStunFrame(0, stunTestPlayer{slot: 0}))
+	data, err := source.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// Corrupt the second sensor's state
+	var snap detectorSnapshot
+	if err := decodeSnapshot(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	snap.Sensors[1].State = []byte("not a snapshot")
+	if data, err = encodeSnapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+
+	stun := NewStunSensor()
+	target := New(WithSynchronousProcessing(), WithSensors(stun, NewPlayerJoinSensor()))
+	defer target.Stop()
+	target.ProcessFrame(createStunFrame(0, stunTestPlayer{slot: 3}))
+	target.ProcessFrame(createStunFrame(time.Second, stunTestPlayer{slot: 3, stunned: true}))
+
+	if err := target.Restore(data); err == nil {
+		t.Fatal("expected Restore to fail")
+	}
+	if !stun.IsStunned(3) {
+		t.Error("expected the first sensor's state to be put back")
+	}
+	if target.frameCount != 2 || target.lastFrame().GetSession().GetTeams()[0].GetPlayers()[0].GetSlotNumber() != 3 {
+		t.Errorf("expected the frame buffer to be left alone, got %d frames", target.frameCount)
+	}
+}
+
+func TestAsyncDetector_RestoreInvalidData(t *testing.T) {
+	detector := New(WithSynchronousProcessing())
+	defer detector.Stop()
+
+	if err := detector.Restore([]byte("not a snapshot")); err == nil {
+		t.Fatal("expected error restoring invalid data")
+	}
+}
+
+func TestGoalScoredSensor_SnapshotPreservesEmptyLastScore(t *testing.T) {
+	sensor := NewGoalScoredSensor()
+	sensor.AddFrame(&telemetry.LobbySessionStateFrame{
+		Session: &apigame.SessionResponse{LastScore: &apigame.LastScore{}},
+	})
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	restored := NewGoalScoredSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// The same empty LastScore must not be reported as a new goal
+	event := restored.AddFrame(&telemetry.LobbySessionStateFrame{
+		Session: &apigame.SessionResponse{LastScore: &apigame.LastScore{}},
+	})
+	if event != nil {
+		t.Fatalf("expected no event after restore, got %v", event)
+	}
+}
+
+func TestStatEventSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewStatEventSensor()
+	sensor.AddFrame(createFrameWithPlayerStats(1, &apigame.PlayerStats{Goals: 0, Saves: 0}))
+	// Two stat changes: one event is returned, one stays pending
+	sensor.AddFrame(createFrameWithPlayerStats(1, &apigame.PlayerStats{Goals: 1, Saves: 1, Points: 2}))
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	restored := NewStatEventSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	event := restored.AddFrame(createFrameWithPlayerStats(1, &apigame.PlayerStats{Goals: 1, Saves: 1, Points: 2}))
+	if event.GetPlayerSave() == nil {
+		t.Fatalf("expected pending PlayerSave event, got %v", event)
+	}
+
+	event = restored.AddFrame(createFrameWithPlayerStats(1, &apigame.PlayerStats{Goals: 1, Saves: 1, Points: 2}))
+	if event != nil {
+		t.Fatalf("expected no event for unchanged stats, got %v", event)
+	}
+}
@@ -0,0 +1,266 @@
+package events
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DefaultSubscriptionBufferSize is the buffer size used when a subscriber does not set one
+const DefaultSubscriptionBufferSize = 64
+
+// EventFilter selects events for a subscriber. Each non-empty field must
+// match for an event to be delivered; the zero value matches every event.
+type EventFilter struct {
+	// Types are event type names as returned by EventType, e.g. "player_goal"
+	Types []string
+	// PlayerSlots matches events attributed to one of the given player slots
+	PlayerSlots []int32
+	// Teams matches events attributed to one of the given teams
+	Teams []telemetry.Role
+}
+
+// Match reports whether the event passes the filter
+func (f EventFilter) Match(event *telemetry.LobbySessionEvent) bool {
+	if event == nil {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, EventType(event)) {
+		return false
+	}
+	if len(f.PlayerSlots) > 0 {
+		slot, ok := EventPlayerSlot(event)
+		if !ok || !slices.Contains(f.PlayerSlots, slot) {
+			return false
+		}
+	}
+	if len(f.Teams) > 0 {
+		team, ok := EventTeam(event)
+		if !ok || !slices.Contains(f.Teams, team) {
+			return false
+		}
+	}
+	return true
+}
+
+// EventType returns the name of the event's oneof field, e.g. "player_goal",
+// or CustomEventTypePrefix followed by the name for custom events, e.g.
+// "custom:joust". It returns an empty string if no event is set.
+func EventType(event *telemetry.LobbySessionEvent) string {
+	if name, ok := customEventName(event); ok {
+		return CustomEventTypePrefix + name
+	}
+
+	field := eventField(event)
+	if field == nil {
+		return ""
+	}
+	return string(field.Name())
+}
+
+// EventPlayerSlot returns the player slot an event is attributed to.
+// Custom events are attributed through their "player_slot" field.
+func EventPlayerSlot(event *telemetry.LobbySessionEvent) (int32, bool) {
+	if custom, err := GetCustomEvent(event); err == nil {
+		return customEventSlot(custom)
+	}
+
+	if joined := event.GetPlayerJoined(); joined != nil {
+		if joined.GetPlayer() == nil {
+			return 0, false
+		}
+		return joined.GetPlayer().GetSlotNumber(), true
+	}
+
+	field := eventField(event)
+	if field == nil || field.Message() == nil {
+		return 0, false
+	}
+	msg := event.ProtoReflect().Get(field).Message()
+	slotField := field.Message().Fields().ByName("player_slot")
+	if slotField == nil || slotField.Kind() != protoreflect.Int32Kind {
+		return 0, false
+	}
+	return int32(msg.Get(slotField).Int()), true
+}
+
+// EventTeam returns the team an event is attributed to. Events that carry a
+// role use it directly, as do custom events with a "team" field holding a
+// role name; other player events use the slot-based team heuristic.
+func EventTeam(event *telemetry.LobbySessionEvent) (telemetry.Role, bool) {
+	if custom, err := GetCustomEvent(event); err == nil {
+		if team, ok := customEventTeam(custom); ok {
+			return team, true
+		}
+	}
+
+	switch e := event.GetEvent().(type) {
+	case *telemetry.LobbySessionEvent_PlayerJoined:
+		return e.PlayerJoined.GetRole(), true
+	case *telemetry.LobbySessionEvent_PlayerSwitchedTeam:
+		return e.PlayerSwitchedTeam.GetNewRole(), true
+	case *telemetry.LobbySessionEvent_RoundEnded:
+		return e.RoundEnded.GetWinningTeam(), true
+	case *telemetry.LobbySessionEvent_MatchEnded:
+		return e.MatchEnded.GetWinningTeam(), true
+	}
+
+	slot, ok := EventPlayerSlot(event)
+	if !ok || slot < 0 {
+		return telemetry.Role_ROLE_UNSPECIFIED, false
+	}
+	return determinePlayerRole(&apigame.TeamMember{SlotNumber: slot}), true
+}
+
+// eventField returns the descriptor of the event's populated oneof field
+func eventField(event *telemetry.LobbySessionEvent) protoreflect.FieldDescriptor {
+	if event == nil {
+		return nil
+	}
+	m := event.ProtoReflect()
+	oneof := m.Descriptor().Oneofs().ByName("event")
+	if oneof == nil {
+		return nil
+	}
+	return m.WhichOneof(oneof)
+}
+
+// Subscription is a buffered stream of filtered events from a detector.
+// Events are dropped rather than blocking the detector when the buffer is full.
+type Subscription struct {
+	filter  EventFilter
+	ch      chan *telemetry.LobbySessionEvent
+	dropped atomic.Uint64
+	owner   *subscribers
+}
+
+// Events returns the subscription's event stream. It is closed when the
+// subscription is cancelled or the detector stops.
+func (s *Subscription) Events() <-chan *telemetry.LobbySessionEvent {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops delivery and closes the event stream
+func (s *Subscription) Unsubscribe() {
+	s.owner.remove(s)
+}
+
+// Sink consumes detected events, e.g. to write them to a database or forward them to a bot
+type Sink interface {
+	Consume(*telemetry.LobbySessionEvent)
+}
+
+// SinkFunc adapts an ordinary function to a Sink
+type SinkFunc func(*telemetry.LobbySessionEvent)
+
+// Consume calls f(event)
+func (f SinkFunc) Consume(event *telemetry.LobbySessionEvent) {
+	f(event)
+}
+
+// subscribers fans detected events out to subscriptions
+type subscribers struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+	// Tracks goroutines feeding sinks so Stop can wait for them to drain
+	sinkWg sync.WaitGroup
+}
+
+// add registers a new subscription, returning a closed one if the detector has stopped
+func (b *subscribers) add(filter EventFilter, bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultSubscriptionBufferSize
+	}
+	sub := &Subscription{
+		filter: filter,
+		ch:     make(chan *telemetry.LobbySessionEvent, bufferSize),
+		owner:  b,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.ch)
+		return sub
+	}
+	if b.subs == nil {
+		b.subs = make(map[*Subscription]struct{})
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// remove unregisters a subscription and closes its stream
+func (b *subscribers) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// publish delivers events to every matching subscription without blocking
+func (b *subscribers) publish(events []*telemetry.LobbySessionEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		for _, event := range events {
+			if !sub.filter.Match(event) {
+				continue
+			}
+			select {
+			case sub.ch <- event:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// close closes every subscription and waits for sinks to drain
+func (b *subscribers) close() {
+	b.mu.Lock()
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+	b.mu.Unlock()
+
+	b.sinkWg.Wait()
+}
+
+// Subscribe returns a new subscription receiving the events that match filter.
+// A bufferSize of zero or less uses DefaultSubscriptionBufferSize.
+func (ed *AsyncDetector) Subscribe(filter EventFilter, bufferSize int) *Subscription {
+	return ed.subscribers.add(filter, bufferSize)
+}
+
+// AddSink delivers the events that match filter to sink on its own goroutine,
+// so a slow sink only drops its own events. Unsubscribe the returned
+// subscription to detach the sink.
+func (ed *AsyncDetector) AddSink(sink Sink, filter EventFilter, bufferSize int) *Subscription {
+	sub := ed.subscribers.add(filter, bufferSize)
+
+	ed.subscribers.sinkWg.Add(1)
+	go func() {
+		defer ed.subscribers.sinkWg.Done()
+		for event := range sub.Events() {
+			sink.Consume(event)
+		}
+	}()
+
+	return sub
+}
@@ -0,0 +1,281 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestEventType(t *testing.T) {
+	tests := []struct {
+		name  string
+		event *telemetry.LobbySessionEvent
+		want  string
+	}{
+		{name: "nil event", event: nil, want: ""},
+		{name: "empty event", event: &telemetry.LobbySessionEvent{}, want: ""},
+		{
+			name: "player goal",
+			event: &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerGoal{
+				PlayerGoal: &telemetry.PlayerGoal{PlayerSlot: 1},
+			}},
+			want: "player_goal",
+		},
+		{
+			name: "match ended",
+			event: &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_MatchEnded{
+				MatchEnded: &telemetry.MatchEnded{},
+			}},
+			want: "match_ended",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EventType(tt.event); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEventFilter_Match(t *testing.T) {
+	goal := &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerGoal{
+		PlayerGoal: &telemetry.PlayerGoal{PlayerSlot: 1},
+	}}
+	orangeSave := &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerSave{
+		PlayerSave: &telemetry.PlayerSave{PlayerSlot: 5},
+	}}
+	joined := &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerJoined{
+		PlayerJoined: &telemetry.PlayerJoined{Player: createPlayer(2, "Player2", 0), Role: telemetry.Role_ROLE_BLUE_TEAM},
+	}}
+	roundStarted := &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_RoundStarted{
+		RoundStarted: &telemetry.RoundStarted{RoundNumber: 1},
+	}}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		event  *telemetry.LobbySessionEvent
+		want   bool
+	}{
+		{name: "zero filter matches all", filter: EventFilter{}, event: roundStarted, want: true},
+		{name: "type match", filter: EventFilter{Types: []string{"player_goal"}}, event: goal, want: true},
+		{name: "type mismatch", filter: EventFilter{Types: []string{"player_goal"}}, event: orangeSave, want: false},
+		{name: "slot match", filter: EventFilter{PlayerSlots: []int32{5}}, event: orangeSave, want: true},
+		{name: "slot from joined player", filter: EventFilter{PlayerSlots: []int32{2}}, event: joined, want: true},
+		{name: "slot filter skips events without slot", filter: EventFilter{PlayerSlots: []int32{1}}, event: roundStarted, want: false},
+		{name: "team from slot", filter: EventFilter{Teams: []telemetry.Role{telemetry.Role_ROLE_ORANGE_TEAM}}, event: orangeSave, want: true},
+		{name: "team mismatch", filter: EventFilter{Teams: []telemetry.Role{telemetry.Role_ROLE_ORANGE_TEAM}}, event: goal, want: false},
+		{name: "team from role", filter: EventFilter{Teams: []telemetry.Role{telemetry.Role_ROLE_BLUE_TEAM}}, event: joined, want: true},
+		{
+			name:   "all criteria must match",
+			filter: EventFilter{Types: []string{"player_goal"}, Teams: []telemetry.Role{telemetry.Role_ROLE_ORANGE_TEAM}},
+			event:  goal,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.event); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAsyncDetector_Subscribe(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(NewStatEventSensor()))
+	defer detector.Stop()
+
+	goals := detector.Subscribe(EventFilter{Types: []string{"player_goal"}}, 10)
+	all := detector.Subscribe(EventFilter{}, 10)
+
+	detector.ProcessFrame(createSessionFrameWithGoals("", 0))
+	detector.ProcessFrame(createSessionFrameWithGoals("", 1))
+
+	select {
+	case event := <-goals.Events():
+		if event.GetPlayerGoal() == nil {
+			t.Errorf("expected PlayerGoal, got %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for subscribed event")
+	}
+
+	select {
+	case <-all.Events():
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for unfiltered event")
+	}
+}
+
+func TestAsyncDetector_SlowSubscriberDoesNotBlock(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(NewStatEventSensor()))
+	defer detector.Stop()
+
+	slow := detector.Subscribe(EventFilter{}, 1)
+	fast := detector.Subscribe(EventFilter{}, 10)
+
+	detector.ProcessFrame(createSessionFrameWithGoals("", 0))
+	for goals := int32(1); goals <= 3; goals++ {
+		detector.ProcessFrame(createSessionFrameWithGoals("", goals))
+	}
+
+	if len(fast.Events()) != 3 {
+		t.Errorf("expected 3 events for fast subscriber, got %d", len(fast.Events()))
+	}
+	if slow.Dropped() != 2 {
+		t.Errorf("expected 2 dropped events for slow subscriber, got %d", slow.Dropped())
+	}
+}
+
+func TestAsyncDetector_Unsubscribe(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(NewStatEventSensor()))
+	defer detector.Stop()
+
+	sub := detector.Subscribe(EventFilter{}, 10)
+	sub.Unsubscribe()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("expected closed channel after Unsubscribe")
+	}
+
+	// Unsubscribing twice and publishing afterwards must not panic
+	sub.Unsubscribe()
+	detector.ProcessFrame(createSessionFrameWithGoals("", 0))
+	detector.ProcessFrame(createSessionFrameWithGoals("", 1))
+}
+
+func TestAsyncDetector_SinkReceivesEventsBeforeStop(t *testing.T) {
+	var mu sync.Mutex
+	var received []*telemetry.LobbySessionEvent
+	sink := SinkFunc(func(event *telemetry.LobbySessionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, event)
+	})
+
+	detector := New(
+		WithSynchronousProcessing(),
+		WithSensors(NewStatEventSensor()),
+		WithSink(sink, EventFilter{PlayerSlots: []int32{1}}),
+	)
+
+	detector.ProcessFrame(createSessionFrameWithGoals("", 0))
+	detector.ProcessFrame(createSessionFrameWithGoals("", 1))
+
+	// Stop waits for sinks to drain their buffers
+	detector.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0].GetPlayerGoal() == nil {
+		t.Fatalf("expected one PlayerGoal event, got %v", received)
+	}
+}
+
+func TestAsyncDetector_SubscribeAfterStop(t *testing.T) {
+	detector := New()
+	detector.Stop()
+
+	sub := detector.Subscribe(EventFilter{}, 1)
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("expected closed channel when subscribing to a stopped detector")
+	}
+}
+
+// everyFrameSensor reports one custom event per frame
+type everyFrameSensor struct{}
+
+func (everyFrameSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	return newCustomEvent("frame_seen", map[string]any{"frame": float64(frame.GetFrameIndex())})
+}
+
+func TestAsyncDetector_SubscribersWithoutEventsChanReader(t *testing.T) {
+	// Asynchronous, and nobody reads EventsChan
+	detector := New(WithSensors(everyFrameSensor{}), WithInputChannelSize(200), WithLossyEventsChan())
+	defer detector.Stop()
+
+	const frames = 100
+	sub := detector.Subscribe(EventFilter{}, frames)
+	for i := range frames {
+		detector.ProcessFrame(&telemetry.LobbySessionStateFrame{FrameIndex: uint32(i)})
+	}
+
+	timeout := time.After(5 * time.Second)
+	for received := 0; received < frames; received++ {
+		select {
+		case <-sub.Events():
+		case <-timeout:
+			t.Fatalf("detector stalled: subscriber received %d of %d events", received, frames)
+		}
+	}
+	if dropped := detector.DroppedEventBatches(); dropped != frames-10 {
+		t.Errorf("expected %d batches left off the full EventsChan, got %d", frames-10, dropped)
+	}
+}
+
+func TestAsyncDetector_EventsChanIsLosslessByDefault(t *testing.T) {
+	detector := New(WithSensors(everyFrameSensor{}), WithInputChannelSize(100), WithEventsChannelSize(1))
+	defer detector.Stop()
+
+	const frames = 50
+	for i := range frames {
+		detector.ProcessFrame(&telemetry.LobbySessionStateFrame{FrameIndex: uint32(i)})
+	}
+
+	// The detector waits for the slow reader rather than dropping batches
+	timeout := time.After(5 * time.Second)
+	for received := 0; received < frames; received++ {
+		select {
+		case <-detector.EventsChan():
+		case <-timeout:
+			t.Fatalf("received %d of %d batches", received, frames)
+		}
+	}
+	if dropped := detector.DroppedEventBatches(); dropped != 0 {
+		t.Errorf("expected no dropped batches, got %d", dropped)
+	}
+}
+
+func TestAsyncDetector_EmitKeepsItsPlaceInTheStream(t *testing.T) {
+	detector := New(WithSensors(NewStatEventSensor()))
+	defer detector.Stop()
+	all := detector.Subscribe(EventFilter{}, 10)
+
+	detector.ProcessFrame(createSessionFrameWithGoals("", 0))
+	detector.ProcessFrame(createSessionFrameWithGoals("", 1))
+	marker := newCustomEvent("marker", nil)
+	detector.Emit(marker)
+
+	var types []string
+	for len(types) < 2 {
+		select {
+		case event := <-all.Events():
+			types = append(types, EventType(event))
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for events, got %v", types)
+		}
+	}
+	if types[0] != "player_goal" || types[1] != "custom:marker" {
+		t.Errorf("expected the goal then the emitted event, got %v", types)
+	}
+
+	// EventsChan gets the emitted events as a batch of their own
+	var batches [][]*telemetry.LobbySessionEvent
+	for len(batches) < 2 {
+		select {
+		case batch := <-detector.EventsChan():
+			batches = append(batches, batch)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for batches, got %d", len(batches))
+		}
+	}
+	if len(batches[1]) != 1 || batches[1][0] != marker {
+		t.Errorf("expected the emitted event in its own batch, got %v", batches[1])
+	}
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"math"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+)
+
+// vecDistance returns the distance between two positions, or false if either is not a 3D vector
+func vecDistance(a, b []float64) (float64, bool) {
+	if len(a) < 3 || len(b) < 3 {
+		return 0, false
+	}
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return math.Sqrt(dx*dx + dy*dy + dz*dz), true
+}
+
+// vecLength returns the length of a vector, or false if it is not a 3D vector
+func vecLength(v []float64) (float64, bool) {
+	if len(v) < 3 {
+		return 0, false
+	}
+	return math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2]), true
+}
+
+// vecAngle returns the angle between two vectors in degrees, or false if
+// either is not a 3D vector or has no length
+func vecAngle(a, b []float64) (float64, bool) {
+	la, okA := vecLength(a)
+	lb, okB := vecLength(b)
+	if !okA || !okB || la == 0 || lb == 0 {
+		return 0, false
+	}
+	cos := (a[0]*b[0] + a[1]*b[1] + a[2]*b[2]) / (la * lb)
+	return math.Acos(max(-1, min(1, cos))) * 180 / math.Pi, true
+}
+
+// playerDistanceTo returns the distance from a point to the closest of the
+// player's hands and head, or false if the player has no tracked positions
+func playerDistanceTo(player *apigame.TeamMember, point []float64) (float64, bool) {
+	best, found := math.Inf(1), false
+	for _, pos := range [][]float64{
+		player.GetLeftHand().GetPos(),
+		player.GetRightHand().GetPos(),
+		player.GetHead().GetPosition(),
+	} {
+		if d, ok := vecDistance(pos, point); ok {
+			best, found = min(best, d), true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,108 @@
+package processing
+
+import (
+	"sync"
+
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FramePool recycles frames together with their session, player bones and
+// timestamp messages. Frames are reference counted: Get returns a frame with
+// one reference, Retain adds one and Release drops one, and the frame goes
+// back to the pool when the last reference is released. A frame must not be
+// used after its last reference is released.
+//
+// A released frame keeps its session and player bones, nested messages
+// included, for the next Get. Decoding into them with apijson reuses those
+// messages instead of allocating new ones, so anything that keeps part of a
+// frame past its last reference must copy it.
+//
+// FramePool implements events.FrameRefs, so a detector can hold pooled
+// frames in its ring buffer.
+type FramePool struct {
+	pool sync.Pool
+
+	mu   sync.Mutex
+	refs map[*telemetry.LobbySessionStateFrame]int32
+}
+
+// NewFramePool creates an empty frame pool
+func NewFramePool() *FramePool {
+	return &FramePool{
+		pool: sync.Pool{New: func() any {
+			return &telemetry.LobbySessionStateFrame{
+				Timestamp:   &timestamppb.Timestamp{},
+				Session:     &apigame.SessionResponse{},
+				PlayerBones: &apigame.PlayerBonesResponse{},
+			}
+		}},
+		refs: make(map[*telemetry.LobbySessionStateFrame]int32),
+	}
+}
+
+// Get returns a frame with one reference. Its Timestamp, Session and
+// PlayerBones are allocated; the session and bones may hold a released
+// frame's contents, to be replaced by decoding into them.
+func (fp *FramePool) Get() *telemetry.LobbySessionStateFrame {
+	frame := fp.pool.Get().(*telemetry.LobbySessionStateFrame)
+	fp.mu.Lock()
+	fp.refs[frame] = 1
+	fp.mu.Unlock()
+	return frame
+}
+
+// Retain adds a reference to a frame from the pool. Frames that did not come
+// from the pool, or were already returned to it, are ignored.
+func (fp *FramePool) Retain(frame *telemetry.LobbySessionStateFrame) {
+	fp.mu.Lock()
+	if n, ok := fp.refs[frame]; ok {
+		fp.refs[frame] = n + 1
+	}
+	fp.mu.Unlock()
+}
+
+// Release drops a reference to a frame from the pool and returns the frame
+// to the pool if it was the last one. Frames that did not come from the
+// pool are ignored.
+func (fp *FramePool) Release(frame *telemetry.LobbySessionStateFrame) {
+	fp.mu.Lock()
+	n, ok := fp.refs[frame]
+	if !ok {
+		fp.mu.Unlock()
+		return
+	}
+	if n > 1 {
+		fp.refs[frame] = n - 1
+		fp.mu.Unlock()
+		return
+	}
+	delete(fp.refs, frame)
+	fp.mu.Unlock()
+
+	// Keep the session and bones as they are, so decoding the next frame
+	// into them reuses their nested messages
+	timestamp, session, bones := frame.Timestamp, frame.Session, frame.PlayerBones
+	if timestamp == nil {
+		timestamp = &timestamppb.Timestamp{}
+	}
+	if session == nil {
+		session = &apigame.SessionResponse{}
+	}
+	if bones == nil {
+		bones = &apigame.PlayerBonesResponse{}
+	}
+	timestamp.Reset()
+	frame.Reset()
+	frame.Timestamp, frame.Session, frame.PlayerBones = timestamp, session, bones
+	fp.pool.Put(frame)
+}
+
+// InUse returns the number of frames handed out by Get that have not been
+// returned to the pool
+func (fp *FramePool) InUse() int {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return len(fp.refs)
+}
@@ -0,0 +1,253 @@
+package processing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestFramePool_RefCounting(t *testing.T) {
+	pool := NewFramePool()
+
+	frame := pool.Get()
+	frame.Session.SessionId = "test-session"
+	pool.Retain(frame)
+	if pool.InUse() != 1 {
+		t.Fatalf("expected 1 frame in use, got %d", pool.InUse())
+	}
+
+	pool.Release(frame)
+	if pool.InUse() != 1 || frame.GetSession().GetSessionId() != "test-session" {
+		t.Fatal("expected the frame to stay in use while a reference remains")
+	}
+
+	pool.Release(frame)
+	if pool.InUse() != 0 {
+		t.Fatalf("expected the frame to be returned, got %d in use", pool.InUse())
+	}
+	if frame.GetSession().GetSessionId() != "test-session" || frame.GetTimestamp() == nil || frame.GetTimestamp().GetSeconds() != 0 {
+		t.Errorf("expected a returned frame to keep its session for reuse, got %v", frame)
+	}
+
+	// Releasing again, or releasing a frame from elsewhere, is ignored
+	pool.Release(frame)
+	pool.Release(&telemetry.LobbySessionStateFrame{})
+	pool.Retain(&telemetry.LobbySessionStateFrame{})
+	if pool.InUse() != 0 {
+		t.Errorf("expected unknown frames to be ignored, got %d in use", pool.InUse())
+	}
+}
+
+func TestFramePool_DetectorReleasesEvictedFrames(t *testing.T) {
+	processor := NewWithFramePool(events.WithSynchronousProcessing(), events.WithFrameBufferSize(3))
+	defer processor.Stop()
+
+	sessionData := createTestSessionData(t)
+	for i := 0; i < 10; i++ {
+		frame, err := processor.ProcessAndDetectEvents(sessionData, nil, time.Now())
+		if err != nil {
+			t.Fatalf("ProcessAndDetectEvents failed: %v", err)
+		}
+		processor.Release(frame)
+	}
+
+	// The ring buffer holds the only references
+	if n := processor.pool.InUse(); n != 3 {
+		t.Errorf("expected only buffered frames to be in use, got %d", n)
+	}
+
+	processor.Reset()
+	if n := processor.pool.InUse(); n != 0 {
+		t.Errorf("expected Reset to release every frame, got %d in use", n)
+	}
+}
+
+func TestFramePool_ReusedFramesHoldNewData(t *testing.T) {
+	processor := NewWithFramePool(events.WithSynchronousProcessing(), events.WithFrameBufferSize(2))
+	defer processor.Stop()
+
+	bones, err := protojson.Marshal(&apigame.PlayerBonesResponse{ErrCode: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := processor.ProcessAndDetectEvents(createTestSessionData(t), bones, time.Unix(100, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor.Release(first)
+
+	for i := 0; i < 3; i++ {
+		frame, err := processor.ProcessAndDetectEvents(createModifiedSessionData(t), nil, time.Unix(200, 5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.GetPlayerBones().GetErrCode() != 0 {
+			t.Errorf("expected bones from a previous use to be cleared, got %v", frame.GetPlayerBones())
+		}
+		if frame.GetSession().GetBluePoints() != 1 || !frame.GetTimestamp().AsTime().Equal(time.Unix(200, 5)) {
+			t.Errorf("unexpected frame contents: %v", frame)
+		}
+		processor.Release(frame)
+	}
+}
+
+func TestFramePool_EventsOutliveReusedFrames(t *testing.T) {
+	processor := NewWithFramePool(events.WithSynchronousProcessing(), events.WithFrameBufferSize(2),
+		events.WithSensors(events.NewGoalScoredSensor()))
+	defer processor.Stop()
+
+	var scored []*telemetry.LobbySessionEvent
+	for i, person := range []string{"first", "second", "third", "fourth"} {
+		data, err := protojson.Marshal(&apigame.SessionResponse{
+			SessionId: "test-session",
+			LastScore: &apigame.LastScore{PersonScored: person, PointAmount: 2},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame, err := processor.ProcessAndDetectEvents(data, nil, time.Unix(int64(i), 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		processor.Release(frame)
+		for _, event := range <-processor.EventsChan() {
+			scored = append(scored, event)
+		}
+	}
+
+	// Later frames are decoded into the messages of earlier ones
+	want := []string{"first", "second", "third", "fourth"}
+	if len(scored) != len(want) {
+		t.Fatalf("expected %d goals, got %d", len(want), len(scored))
+	}
+	for i, event := range scored {
+		if got := event.GetGoalScored().GetScoreDetails().GetPersonScored(); got != want[i] {
+			t.Errorf("goal %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+}
+
+func TestFramePool_ReleaseWithoutPool(t *testing.T) {
+	processor := New()
+	defer processor.Stop()
+
+	frame, err := processor.ProcessAndDetectEvents(createTestSessionData(t), nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor.Release(frame)
+	if frame.GetSession().GetSessionId() != "test-session" {
+		t.Error("expected Release to leave unpooled frames untouched")
+	}
+}
+
+func BenchmarkFrameProcessing(b *testing.B) {
+	b.Run("Allocating", func(b *testing.B) {
+		benchmarkFrameProcessing(b, NewWithDetector(events.New(events.WithSynchronousProcessing())))
+	})
+	b.Run("Pooled", func(b *testing.B) {
+		benchmarkFrameProcessing(b, NewWithFramePool(events.WithSynchronousProcessing()))
+	})
+}
+
+func benchmarkFrameProcessing(b *testing.B, processor *Processor) {
+	defer processor.Stop()
+	sessionData, bonesData := benchmarkPayload(b)
+	now := time.Now()
+
+	b.SetBytes(int64(len(sessionData) + len(bonesData)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frame, err := processor.ProcessAndDetectEvents(sessionData, bonesData, now)
+		if err != nil {
+			b.Fatal(err)
+		}
+		processor.Release(frame)
+	}
+}
+
+// benchmarkPayload returns a session and player bones response for a full
+// 4v4 match with a spectator, with every player fully populated
+func benchmarkPayload(tb testing.TB) (session, bones []byte) {
+	tb.Helper()
+	v3 := func(x, y, z float64) []float64 { return []float64{x, y, z} }
+	part := func(x, y, z float64) *apigame.BodyPart {
+		return &apigame.BodyPart{Position: v3(x, y, z), Forward: v3(0, 0, 1), Left: v3(1, 0, 0), Up: v3(0, 1, 0)}
+	}
+	hand := func(x, y, z float64) *apigame.HandPart {
+		return &apigame.HandPart{Pos: v3(x, y, z), Forward: v3(0, 0, 1), Left: v3(1, 0, 0), Up: v3(0, 1, 0)}
+	}
+
+	s := &apigame.SessionResponse{
+		SessionId:        "7A5B3C1D-2E4F-4A6B-8C9D-0E1F2A3B4C5D",
+		GameClockDisplay: "03:41.27",
+		GameStatus:       "playing",
+		SessionIp:        "10.0.0.1",
+		MatchType:        "Echo_Arena",
+		MapName:          "mpl_arena_a",
+		GameClock:        221.27,
+		BluePoints:       4,
+		OrangePoints:     2,
+		TotalRoundCount:  3,
+		ClientName:       "player_0",
+		Possession:       []int32{0, 1},
+		Disc:             &apigame.Disc{Position: v3(1, 2, 3), Forward: v3(0, 0, 1), Left: v3(1, 0, 0), Up: v3(0, 1, 0), Velocity: v3(4, 0.5, -2), BounceCount: 1},
+		Player:           &apigame.PlayerRoot{VrLeft: v3(1, 0, 0), VrPosition: v3(0, 1.6, 0), VrForward: v3(0, 0, 1), VrUp: v3(0, 1, 0)},
+		Pause:            &apigame.PauseState{PausedState: "unpaused", UnpausedTeam: "none", PausedRequestedTeam: "none"},
+		LastThrow:        &apigame.LastThrowInfo{ArmSpeed: 5.5, TotalSpeed: 12.25, RotPerSec: 2, SpeedFromArm: 4, SpeedFromMovement: 6},
+		LastScore:        &apigame.LastScore{DiscSpeed: 18.5, Team: "blue", GoalType: "INSIDE SHOT", PointAmount: 2, DistanceThrown: 9.75, PersonScored: "player_1", AssistScored: "player_2"},
+	}
+	b := &apigame.PlayerBonesResponse{}
+	for t, name := range []string{"BLUE TEAM", "ORANGE TEAM", "SPECTATORS"} {
+		players := 4
+		if t == 2 {
+			players = 1
+		}
+		team := &apigame.Team{TeamName: name, Stats: &apigame.TeamStats{PossessionTime: 40, Points: 4, Saves: 2, Stuns: 9, Passes: 5}}
+		for p := 0; p < players; p++ {
+			slot := int32(t*5 + p)
+			x := float64(slot)
+			team.Players = append(team.Players, &apigame.TeamMember{
+				DisplayName:      fmt.Sprintf("player_%d", slot),
+				AccountNumber:    4355631244523776 + uint64(slot),
+				SlotNumber:       slot,
+				JerseyNumber:     slot + 10,
+				Level:            50,
+				Ping:             32 + slot,
+				PacketLossRatio:  0.001,
+				LeftHoldingOnto:  "none",
+				RightHoldingOnto: "none",
+				Head:             part(x, 1.7, 2),
+				Body:             part(x, 1.2, 2),
+				LeftHand:         hand(x-0.3, 1.1, 2.1),
+				RightHand:        hand(x+0.3, 1.1, 2.1),
+				Velocity:         v3(1, 0, -0.5),
+				Stats:            &apigame.PlayerStats{PossessionTime: 10, Points: 2, Saves: 1, Stuns: 3, Passes: 1, Catches: 2, ShotsTaken: 4},
+			})
+
+			// 23 bones, each with a translation and a rotation quaternion
+			bone := &apigame.UserBones{PlayerIndex: slot}
+			for i := 0; i < 23; i++ {
+				bone.BoneT = append(bone.BoneT, float32(x), float32(i)*0.1, 0.5)
+				bone.BoneO = append(bone.BoneO, 0, 0, 0.70710677, 0.70710677)
+			}
+			b.UserBones = append(b.UserBones, bone)
+		}
+		s.Teams = append(s.Teams, team)
+	}
+
+	var err error
+	if session, err = protojson.Marshal(s); err != nil {
+		tb.Fatal(err)
+	}
+	if bones, err = protojson.Marshal(b); err != nil {
+		tb.Fatal(err)
+	}
+	return session, bones
+}
@@ -3,10 +3,10 @@ package processing
 import (
 	"time"
 
+	"github.com/echotools/nevr-capture/v3/pkg/apijson"
 	"github.com/echotools/nevr-capture/v3/pkg/events"
 	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -15,7 +15,18 @@ import (
 type Processor struct {
 	frameIndex    uint32
 	eventDetector events.Detector
-	unmarshaler   *protojson.UnmarshalOptions
+	decoder       apijson.Decoder
+
+	// Frame pool, if frames are pooled
+	pool *FramePool
+
+	// Middleware chain ending in detect, and whether the last frame reached detect
+	chain    Chain
+	handler  Handler
+	detected bool
+
+	// Middleware run by Write before a frame is written
+	writeChain Chain
 }
 
 // New creates a new optimized frame processor
@@ -29,49 +40,148 @@ func NewWithDetector(det events.Detector) *Processor {
 		det = events.New()
 	}
 
-	return &Processor{
+	fp := &Processor{
 		frameIndex:    0,
 		eventDetector: det,
-		unmarshaler: &protojson.UnmarshalOptions{
-			AllowPartial: true,
-		},
 	}
+	fp.handler = fp.detect
+	return fp
+}
+
+// Use appends middleware to the processor's chain. Every frame passes
+// through the chain after decoding and before event detection, so a frame
+// dropped by middleware is neither detected on nor returned.
+func (fp *Processor) Use(mw ...Middleware) {
+	fp.chain = append(fp.chain, mw...)
+	fp.handler = fp.chain.Then(fp.detect)
+}
+
+// UseBeforeWrite appends middleware to the chain Write passes frames
+// through. It runs after event detection, so it sees each frame's events,
+// and a frame it drops is not written.
+func (fp *Processor) UseBeforeWrite(mw ...Middleware) {
+	fp.writeChain = append(fp.writeChain, mw...)
+}
+
+// Write passes a detected frame through the UseBeforeWrite middleware to
+// write, such as a codec's WriteFrame. It returns ErrFrameDropped if
+// middleware drops the frame.
+func (fp *Processor) Write(frame *telemetry.LobbySessionStateFrame, write Handler) error {
+	written := false
+	err := fp.writeChain.Then(func(frame *telemetry.LobbySessionStateFrame) error {
+		written = true
+		return write(frame)
+	})(frame)
+	if err != nil {
+		return err
+	}
+	if !written {
+		return ErrFrameDropped
+	}
+	return nil
 }
 
-// ProcessAndDetectEvents takes raw session and user bones data, unmarshals it, and sends it through the event detector
+// NewWithFramePool creates a processor that recycles frames through a
+// FramePool instead of allocating a new frame per call. The detector is
+// created with opts and holds its own references to the frames it buffers.
+// Sensors compare each frame with the previous one, which is only safe
+// while the detector still holds it, so the frame buffer must keep at
+// least two frames; the default keeps DefaultFrameBufferCapacity.
+//
+// Every frame returned by ProcessAndDetectEvents must be passed to Release
+// once the caller is done with it, and must not be used afterwards.
+func NewWithFramePool(opts ...events.Option) *Processor {
+	pool := NewFramePool()
+	opts = append(opts, events.WithFrameRefs(pool))
+
+	fp := NewWithDetector(events.New(opts...))
+	fp.pool = pool
+	return fp
+}
+
+// ProcessAndDetectEvents takes raw session and user bones data, unmarshals it, and sends it through the middleware chain and the event detector
 // This is optimized for high-frequency invocation (up to 600 Hz)
 // Note: Events are now processed asynchronously and can be received via EventDetector.EventsChan()
+// It returns ErrFrameDropped if middleware drops the frame.
 func (fp *Processor) ProcessAndDetectEvents(sessionResponseData, userBonesData []byte, timestamp time.Time) (*telemetry.LobbySessionStateFrame, error) {
-	// Reset the pre-allocated structs to avoid allocations
-	// Pre-allocated structs to avoid memory allocations
-	sessionResponse := &apigame.SessionResponse{}
-	bonesResponse := &apigame.PlayerBonesResponse{}
+	frame := fp.newFrame()
 
 	// Parse session data
-	if err := fp.unmarshaler.Unmarshal(sessionResponseData, sessionResponse); err != nil {
+	if err := fp.decoder.UnmarshalSession(sessionResponseData, frame.Session); err != nil {
+		fp.Release(frame)
 		return nil, err
 	}
 
-	// Parse user bones data (if provided)
+	// Parse user bones data (if provided); a pooled frame may hold old bones
 	if len(userBonesData) > 0 {
-		if err := fp.unmarshaler.Unmarshal(userBonesData, bonesResponse); err != nil {
+		if err := fp.decoder.UnmarshalPlayerBones(userBonesData, frame.PlayerBones); err != nil {
+			fp.Release(frame)
 			return nil, err
 		}
+	} else if fp.pool != nil {
+		frame.PlayerBones.Reset()
+	}
+
+	frame.Timestamp.Seconds = timestamp.Unix()
+	frame.Timestamp.Nanos = int32(timestamp.Nanosecond())
+
+	if err := fp.Process(frame); err != nil {
+		fp.Release(frame)
+		return nil, err
+	}
+	return frame, nil
+}
+
+// Process sends an already decoded frame, such as one read from a file,
+// through the middleware chain and the event detector. The frame is given
+// the next frame index. It returns ErrFrameDropped if middleware drops the
+// frame; dropped frames do not use up an index.
+func (fp *Processor) Process(frame *telemetry.LobbySessionStateFrame) error {
+	frame.FrameIndex = fp.frameIndex
+	fp.detected = false
+	if err := fp.handler(frame); err != nil {
+		return err
 	}
+	if !fp.detected {
+		return ErrFrameDropped
+	}
+	fp.frameIndex++
+	return nil
+}
+
+// detect ends the middleware chain by sending the frame to the event detector
+func (fp *Processor) detect(frame *telemetry.LobbySessionStateFrame) error {
+	fp.detected = true
 
-	// Create the frame
-	frame := &telemetry.LobbySessionStateFrame{
-		FrameIndex:  fp.frameIndex,
-		Timestamp:   timestamppb.New(timestamp),
-		Session:     sessionResponse,
-		PlayerBones: bonesResponse,
+	// The detector takes its own reference to a pooled frame
+	if fp.pool != nil {
+		fp.pool.Retain(frame)
 	}
 
 	// Send frame to event detector for async processing
 	fp.eventDetector.ProcessFrame(frame)
-	fp.frameIndex++
+	return nil
+}
 
-	return frame, nil
+// newFrame returns an empty frame from the pool, or a newly allocated one if
+// frames are not pooled
+func (fp *Processor) newFrame() *telemetry.LobbySessionStateFrame {
+	if fp.pool != nil {
+		return fp.pool.Get()
+	}
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp:   &timestamppb.Timestamp{},
+		Session:     &apigame.SessionResponse{},
+		PlayerBones: &apigame.PlayerBonesResponse{},
+	}
+}
+
+// Release returns a frame from ProcessAndDetectEvents to the pool once the
+// detector is also done with it. It does nothing if frames are not pooled.
+func (fp *Processor) Release(frame *telemetry.LobbySessionStateFrame) {
+	if fp.pool != nil {
+		fp.pool.Release(frame)
+	}
 }
 
 // DetectEvents queues a frame for event detection
@@ -79,6 +189,16 @@ func (p *Processor) DetectEvents(f *telemetry.LobbySessionStateFrame) {
 	p.eventDetector.ProcessFrame(f)
 }
 
+// Emit publishes events alongside the detector's own, if the detector is an
+// events.Emitter such as an AsyncDetector; otherwise it does nothing.
+// Processor is itself an events.Emitter, so it can be given to
+// WithGapEmitter.
+func (fp *Processor) Emit(evts ...*telemetry.LobbySessionEvent) {
+	if e, ok := fp.eventDetector.(events.Emitter); ok {
+		e.Emit(evts...)
+	}
+}
+
 // EventsChan returns the channel for receiving detected events
 func (fp *Processor) EventsChan() <-chan []*telemetry.LobbySessionEvent {
 	return fp.eventDetector.EventsChan()
@@ -0,0 +1,293 @@
+package processing
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Custom events added by a GapDetector
+const (
+	CaptureGapEventName     = "capture_gap"
+	SyntheticFrameEventName = "synthetic_frame"
+)
+
+// Default GapDetector settings
+const (
+	DefaultExpectedRate = 60
+	DefaultGapThreshold = 250 * time.Millisecond
+)
+
+// Header metadata keys written by GapDetector.Metadata
+const (
+	MetadataCaptureGapCount   = "capture_gap_count"
+	MetadataCaptureGapSeconds = "capture_gap_seconds"
+	MetadataCaptureGaps       = "capture_gaps"
+)
+
+// Gap is a stretch of a capture with no frames
+type Gap struct {
+	// Start and End are the timestamps of the frames either side of the gap
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Missing is the number of frames the expected rate would have produced
+	Missing int `json:"missing_frames"`
+	// Interpolated is the number of synthetic frames filling the gap
+	Interpolated int `json:"interpolated_frames"`
+}
+
+// Duration returns the length of the gap
+func (g Gap) Duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// GapOption configures a GapDetector
+type GapOption func(*GapDetector)
+
+// WithExpectedRate sets the capture rate, in frames per second, used to
+// count missing frames and space interpolated ones
+func WithExpectedRate(hz int) GapOption {
+	return func(g *GapDetector) {
+		if hz > 0 {
+			g.interval = time.Second / time.Duration(hz)
+		}
+	}
+}
+
+// WithGapThreshold sets the time between frames above which a gap is reported
+func WithGapThreshold(d time.Duration) GapOption {
+	return func(g *GapDetector) {
+		g.threshold = d
+	}
+}
+
+// WithInterpolation fills gaps no longer than maxGap with synthetic frames at
+// the expected rate. Zero, the default, disables interpolation.
+func WithInterpolation(maxGap time.Duration) GapOption {
+	return func(g *GapDetector) {
+		g.maxInterpolated = maxGap
+	}
+}
+
+// WithGapEmitter also publishes each capture_gap event through e, such as
+// the Processor or AsyncDetector the frames go on to, so that subscribers,
+// sinks and EventsChan see it as well as the frame after the gap. The event
+// is emitted just before that frame is passed on.
+func WithGapEmitter(e events.Emitter) GapOption {
+	return func(g *GapDetector) {
+		g.emitter = e
+	}
+}
+
+// GapDetector finds stalls in a capture, where the time between frames is
+// well above the expected rate. The frame after each gap gets a capture_gap
+// custom event, so sensors and consumers can tell a stall from real play,
+// and every gap is kept for the capture's header. The event is added to
+// the frame, which the detector does not publish; use WithGapEmitter for
+// it to reach the detector's subscribers too.
+//
+// Short gaps can be filled with synthetic frames: continuous values such as
+// positions, velocities and the game clock are interpolated between the
+// frames either side, and everything else is held from the frame before.
+// Synthetic frames carry a synthetic_frame custom event; see IsSynthetic.
+// Gaps across a session change are never filled.
+//
+// Like ReorderBuffer, a GapDetector sits in front of a Handler such as
+// Processor.Process and expects frames in order. It keeps the last frame it
+// was given, so it must not be fed pooled frames. Push must not be called
+// concurrently; Gaps and Metadata may be.
+type GapDetector struct {
+	next            Handler
+	interval        time.Duration
+	threshold       time.Duration
+	maxInterpolated time.Duration
+	emitter         events.Emitter
+
+	prev *telemetry.LobbySessionStateFrame
+
+	mu   sync.Mutex
+	gaps []Gap
+}
+
+// NewGapDetector creates a GapDetector that passes frames to next
+func NewGapDetector(next Handler, opts ...GapOption) *GapDetector {
+	g := &GapDetector{
+		next:      next,
+		interval:  time.Second / DefaultExpectedRate,
+		threshold: DefaultGapThreshold,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Push passes a frame on, preceded by any synthetic frames filling the gap
+// before it. Push has the Handler signature, so it can follow a
+// ReorderBuffer.
+func (g *GapDetector) Push(frame *telemetry.LobbySessionStateFrame) error {
+	prev := g.prev
+	g.prev = frame
+	if prev == nil {
+		return g.next(frame)
+	}
+
+	start, end := prev.GetTimestamp().AsTime(), frame.GetTimestamp().AsTime()
+	delta := end.Sub(start)
+	if delta <= g.threshold {
+		return g.next(frame)
+	}
+
+	gap := Gap{Start: start, End: end, Missing: int((delta - g.interval/2) / g.interval)}
+	fill := g.maxInterpolated > 0 && delta <= g.maxInterpolated &&
+		prev.GetSession().GetSessionId() == frame.GetSession().GetSessionId()
+	if fill {
+		for t := start.Add(g.interval); end.Sub(t) >= g.interval/2; t = t.Add(g.interval) {
+			synthetic := interpolateFrame(prev, frame, float64(t.Sub(start))/float64(delta), t)
+			marker, err := events.NewCustomEvent(SyntheticFrameEventName, map[string]any{
+				"gap_start": start.Format(time.RFC3339Nano),
+			})
+			if err != nil {
+				return err
+			}
+			synthetic.Events = append(synthetic.Events, marker)
+			gap.Interpolated++
+			if err := g.next(synthetic); err != nil && !errors.Is(err, ErrFrameDropped) {
+				return err
+			}
+		}
+	}
+
+	event, err := events.NewCustomEvent(CaptureGapEventName, map[string]any{
+		"gap_start":           start.Format(time.RFC3339Nano),
+		"gap_end":             end.Format(time.RFC3339Nano),
+		"duration_seconds":    delta.Seconds(),
+		"missing_frames":      float64(gap.Missing),
+		"interpolated_frames": float64(gap.Interpolated),
+	})
+	if err != nil {
+		return err
+	}
+	frame.Events = append(frame.Events, event)
+	if g.emitter != nil {
+		g.emitter.Emit(event)
+	}
+	g.mu.Lock()
+	g.gaps = append(g.gaps, gap)
+	g.mu.Unlock()
+	return g.next(frame)
+}
+
+// Gaps returns the gaps found so far
+func (g *GapDetector) Gaps() []Gap {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Gap(nil), g.gaps...)
+}
+
+// Metadata describes the gaps found so far as header metadata: their count,
+// their total length in seconds and a JSON list of them. A .nevrcap header
+// is written before the first frame, so only offline conversions, which
+// find every gap before writing, can put it there; a live capture.Recorder
+// has no gap metadata, and its gaps are recorded only as the capture_gap
+// event on the frame after each one.
+func (g *GapDetector) Metadata() map[string]string {
+	gaps := g.Gaps()
+	var total time.Duration
+	for _, gap := range gaps {
+		total += gap.Duration()
+	}
+	list, _ := json.Marshal(gaps)
+	if gaps == nil {
+		list = []byte("[]")
+	}
+	return map[string]string{
+		MetadataCaptureGapCount:   strconv.Itoa(len(gaps)),
+		MetadataCaptureGapSeconds: strconv.FormatFloat(total.Seconds(), 'f', 3, 64),
+		MetadataCaptureGaps:       string(list),
+	}
+}
+
+// IsSynthetic reports whether a frame was made up by a GapDetector
+func IsSynthetic(frame *telemetry.LobbySessionStateFrame) bool {
+	for _, event := range frame.GetEvents() {
+		if custom, err := events.GetCustomEvent(event); err == nil && custom.Name == SyntheticFrameEventName {
+			return true
+		}
+	}
+	return false
+}
+
+// interpolateFrame returns a copy of a with continuous values moved fraction
+// of the way towards b, timestamped t
+func interpolateFrame(a, b *telemetry.LobbySessionStateFrame, fraction float64, t time.Time) *telemetry.LobbySessionStateFrame {
+	frame := proto.Clone(a).(*telemetry.LobbySessionStateFrame)
+	frame.FrameIndex = 0
+	frame.Events = nil
+	frame.Timestamp = timestamppb.New(t)
+
+	s, bs := frame.GetSession(), b.GetSession()
+	if s == nil || bs == nil {
+		return frame
+	}
+	s.GameClock = lerp(s.GameClock, bs.GameClock, fraction)
+	if disc := s.GetDisc(); disc != nil {
+		disc.Position = lerpVec(disc.Position, bs.GetDisc().GetPosition(), fraction)
+		disc.Velocity = lerpVec(disc.Velocity, bs.GetDisc().GetVelocity(), fraction)
+	}
+	if player := s.GetPlayer(); player != nil {
+		player.VrPosition = lerpVec(player.VrPosition, bs.GetPlayer().GetVrPosition(), fraction)
+	}
+
+	targets := make(map[int32]*apigame.TeamMember)
+	for _, team := range bs.GetTeams() {
+		for _, player := range team.GetPlayers() {
+			targets[player.GetSlotNumber()] = player
+		}
+	}
+	for _, team := range s.GetTeams() {
+		for _, player := range team.GetPlayers() {
+			target, ok := targets[player.GetSlotNumber()]
+			if !ok {
+				continue
+			}
+			player.Velocity = lerpVec(player.Velocity, target.GetVelocity(), fraction)
+			if head := player.GetHead(); head != nil {
+				head.Position = lerpVec(head.Position, target.GetHead().GetPosition(), fraction)
+			}
+			if body := player.GetBody(); body != nil {
+				body.Position = lerpVec(body.Position, target.GetBody().GetPosition(), fraction)
+			}
+			if hand := player.GetLeftHand(); hand != nil {
+				hand.Pos = lerpVec(hand.Pos, target.GetLeftHand().GetPos(), fraction)
+			}
+			if hand := player.GetRightHand(); hand != nil {
+				hand.Pos = lerpVec(hand.Pos, target.GetRightHand().GetPos(), fraction)
+			}
+		}
+	}
+	return frame
+}
+
+func lerp(a, b, fraction float64) float64 {
+	return a + (b-a)*fraction
+}
+
+// lerpVec interpolates a towards b in place, holding a if the lengths differ
+func lerpVec(a, b []float64, fraction float64) []float64 {
+	if len(a) != len(b) {
+		return a
+	}
+	for i := range a {
+		a[i] = lerp(a[i], b[i], fraction)
+	}
+	return a
+}
@@ -0,0 +1,248 @@
+package processing
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// gapFrame returns a frame at ms milliseconds with the disc and one player
+// moving along x at 1 m/s
+func gapFrame(ms int) *telemetry.LobbySessionStateFrame {
+	frame := reorderFrame(ms)
+	x := float64(ms) / 1000
+	frame.Session.SessionId = "session-a"
+	frame.Session.Disc = &apigame.Disc{Position: []float64{x, 1, 0}, Velocity: []float64{1, 0, 0}}
+	frame.Session.Teams = []*apigame.Team{{
+		Players: []*apigame.TeamMember{{
+			SlotNumber:    3,
+			HasPossession: ms < 500,
+			Head:          &apigame.BodyPart{Position: []float64{x, 1.7, 0}, Forward: []float64{1, 0, 0}},
+			Velocity:      []float64{1, 0, 0},
+		}},
+	}}
+	return frame
+}
+
+func customEvents(frame *telemetry.LobbySessionStateFrame, name string) []*events.CustomEvent {
+	var found []*events.CustomEvent
+	for _, event := range frame.GetEvents() {
+		if custom, err := events.GetCustomEvent(event); err == nil && custom.Name == name {
+			found = append(found, custom)
+		}
+	}
+	return found
+}
+
+func TestGapDetector_ReportsGaps(t *testing.T) {
+	var frames []*telemetry.LobbySessionStateFrame
+	detector := NewGapDetector(func(frame *telemetry.LobbySessionStateFrame) error {
+		frames = append(frames, frame)
+		return nil
+	})
+
+	for _, ms := range []int{0, 16, 33, 1033, 1050, 1200} {
+		if err := detector.Push(gapFrame(ms)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(frames) != 6 {
+		t.Fatalf("expected 6 frames without interpolation, got %d", len(frames))
+	}
+	for i, frame := range frames {
+		gaps := customEvents(frame, CaptureGapEventName)
+		if i != 3 {
+			if len(gaps) != 0 {
+				t.Errorf("unexpected capture_gap event on frame %d", i)
+			}
+			continue
+		}
+		if len(gaps) != 1 {
+			t.Fatalf("expected a capture_gap event after the stall, got %d", len(gaps))
+		}
+		if d := gaps[0].Fields["duration_seconds"]; d != 1.0 {
+			t.Errorf("expected a 1s gap, got %v", d)
+		}
+		if n := gaps[0].Fields["missing_frames"]; n != 59.0 {
+			t.Errorf("expected 59 missing frames, got %v", n)
+		}
+	}
+
+	gaps := detector.Gaps()
+	if len(gaps) != 1 || gaps[0].Duration() != time.Second || gaps[0].Interpolated != 0 {
+		t.Fatalf("unexpected gaps %+v", gaps)
+	}
+
+	metadata := detector.Metadata()
+	if metadata[MetadataCaptureGapCount] != "1" || metadata[MetadataCaptureGapSeconds] != "1.000" {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+	var decoded []Gap
+	if err := json.Unmarshal([]byte(metadata[MetadataCaptureGaps]), &decoded); err != nil || len(decoded) != 1 || decoded[0].Missing != 59 {
+		t.Errorf("unexpected gap list %q: %v", metadata[MetadataCaptureGaps], err)
+	}
+}
+
+func TestGapDetector_NoGaps(t *testing.T) {
+	detector := NewGapDetector(func(*telemetry.LobbySessionStateFrame) error { return nil })
+	for ms := 0; ms < 1000; ms += 16 {
+		detector.Push(gapFrame(ms))
+	}
+	metadata := detector.Metadata()
+	if metadata[MetadataCaptureGapCount] != "0" || metadata[MetadataCaptureGaps] != "[]" {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+}
+
+func TestGapDetector_Interpolates(t *testing.T) {
+	var frames []*telemetry.LobbySessionStateFrame
+	detector := NewGapDetector(func(frame *telemetry.LobbySessionStateFrame) error {
+		frames = append(frames, frame)
+		return nil
+	}, WithExpectedRate(10), WithGapThreshold(150*time.Millisecond), WithInterpolation(time.Second))
+
+	before, after := gapFrame(0), gapFrame(1000)
+	detector.Push(before)
+	detector.Push(after)
+
+	// 100 ms apart from 100 to 900 ms
+	if len(frames) != 11 {
+		t.Fatalf("expected 9 synthetic frames between the real ones, got %d frames", len(frames))
+	}
+	if frames[0] != before || frames[10] != after {
+		t.Fatal("expected the real frames to be passed on unchanged either side")
+	}
+	for i, frame := range frames[1:10] {
+		want := float64(i+1) / 10
+		if !IsSynthetic(frame) {
+			t.Errorf("frame %d: expected a synthetic_frame event", i+1)
+		}
+		if got := frame.GetTimestamp().AsTime().Sub(reorderStart); got != time.Duration(i+1)*100*time.Millisecond {
+			t.Errorf("frame %d: unexpected timestamp offset %v", i+1, got)
+		}
+		if got := frame.Session.Disc.Position[0]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("frame %d: expected disc x %v, got %v", i+1, want, got)
+		}
+		player := frame.Session.Teams[0].Players[0]
+		if got := player.Head.Position[0]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("frame %d: expected head x %v, got %v", i+1, want, got)
+		}
+		if !player.HasPossession {
+			t.Errorf("frame %d: expected possession to be held from the frame before", i+1)
+		}
+		if math.Abs(frame.Session.GameClock-(300-want)) > 1e-9 {
+			t.Errorf("frame %d: expected game clock %v, got %v", i+1, 300-want, frame.Session.GameClock)
+		}
+	}
+	if IsSynthetic(before) || IsSynthetic(after) {
+		t.Error("expected real frames not to be marked synthetic")
+	}
+	if before.Session.Disc.Position[0] != 0 {
+		t.Error("expected interpolation not to modify the frame before the gap")
+	}
+	if gaps := detector.Gaps(); len(gaps) != 1 || gaps[0].Interpolated != 9 || gaps[0].Missing != 9 {
+		t.Errorf("unexpected gaps %+v", gaps)
+	}
+}
+
+func TestGapDetector_InterpolationLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		after func() *telemetry.LobbySessionStateFrame
+	}{
+		{"too long", func() *telemetry.LobbySessionStateFrame { return gapFrame(3000) }},
+		{"new session", func() *telemetry.LobbySessionStateFrame {
+			frame := gapFrame(500)
+			frame.Session.SessionId = "session-b"
+			return frame
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n int
+			detector := NewGapDetector(func(*telemetry.LobbySessionStateFrame) error {
+				n++
+				return nil
+			}, WithInterpolation(time.Second))
+			detector.Push(gapFrame(0))
+			detector.Push(tt.after())
+			if n != 2 {
+				t.Errorf("expected no synthetic frames, got %d frames", n)
+			}
+			if gaps := detector.Gaps(); len(gaps) != 1 {
+				t.Errorf("expected the gap to be reported, got %+v", gaps)
+			}
+		})
+	}
+}
+
+func TestGapDetector_HandlerErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	detector := NewGapDetector(func(frame *telemetry.LobbySessionStateFrame) error {
+		if IsSynthetic(frame) {
+			return ErrFrameDropped
+		}
+		if frame.GetTimestamp().AsTime().Sub(reorderStart) > time.Second {
+			return errBoom
+		}
+		return nil
+	}, WithInterpolation(time.Second))
+
+	detector.Push(gapFrame(0))
+	if err := detector.Push(gapFrame(500)); err != nil {
+		t.Errorf("expected dropped synthetic frames not to be errors, got %v", err)
+	}
+	if err := detector.Push(gapFrame(2000)); !errors.Is(err, errBoom) {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+}
+
+func TestGapDetector_FeedsProcessor(t *testing.T) {
+	processor := NewWithDetector(events.New(events.WithSynchronousProcessing()))
+	defer processor.Stop()
+
+	var indexes []uint32
+	processor.Use(Transform(func(frame *telemetry.LobbySessionStateFrame) {
+		indexes = append(indexes, frame.FrameIndex)
+	}))
+	detector := NewGapDetector(processor.Process, WithExpectedRate(10), WithGapThreshold(150*time.Millisecond), WithInterpolation(time.Second))
+	detector.Push(gapFrame(0))
+	detector.Push(gapFrame(400))
+
+	if want := []int{0, 1, 2, 3, 4}; len(indexes) != len(want) {
+		t.Fatalf("expected synthetic frames to be indexed in order, got %v", indexes)
+	}
+	for i, index := range indexes {
+		if index != uint32(i) {
+			t.Errorf("expected frame index %d, got %d", i, index)
+		}
+	}
+}
+
+func TestGapDetector_EmitsThroughDetector(t *testing.T) {
+	detector := events.New(events.WithSynchronousProcessing())
+	processor := NewWithDetector(detector)
+	defer processor.Stop()
+	sub := detector.Subscribe(events.EventFilter{Types: []string{"custom:" + CaptureGapEventName}}, 10)
+
+	gaps := NewGapDetector(processor.Process, WithGapEmitter(processor))
+	gaps.Push(gapFrame(0))
+	gaps.Push(gapFrame(1000))
+
+	select {
+	case event := <-sub.Events():
+		custom, err := events.GetCustomEvent(event)
+		if err != nil || custom.Fields["duration_seconds"] != 1.0 {
+			t.Errorf("expected a one second capture_gap, got %v (%v)", custom, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected subscribers to receive the capture_gap event")
+	}
+}
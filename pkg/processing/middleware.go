@@ -0,0 +1,75 @@
+package processing
+
+import (
+	"errors"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// ErrFrameDropped is returned when middleware drops a frame instead of
+// passing it on
+var ErrFrameDropped = errors.New("frame dropped by middleware")
+
+// Handler handles a decoded frame
+type Handler func(frame *telemetry.LobbySessionStateFrame) error
+
+// Middleware wraps a Handler. It may modify the frame before or after
+// calling next, return an error, or drop the frame by returning without
+// calling next.
+type Middleware func(next Handler) Handler
+
+// Chain is a list of middleware applied in order, so the first middleware
+// sees each frame first. The same chain can be installed on a Processor with
+// Use and applied to frames read from files, so live and offline paths
+// behave identically.
+type Chain []Middleware
+
+// Then returns a Handler that passes frames through the chain and then to h
+func (c Chain) Then(h Handler) Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+	return h
+}
+
+// Transform returns middleware that calls fn on every frame, such as to
+// normalize coordinates or compute derived fields
+func Transform(fn func(frame *telemetry.LobbySessionStateFrame)) Middleware {
+	return func(next Handler) Handler {
+		return func(frame *telemetry.LobbySessionStateFrame) error {
+			fn(frame)
+			return next(frame)
+		}
+	}
+}
+
+// Filter returns middleware that drops frames for which keep returns false
+func Filter(keep func(frame *telemetry.LobbySessionStateFrame) bool) Middleware {
+	return func(next Handler) Handler {
+		return func(frame *telemetry.LobbySessionStateFrame) error {
+			if !keep(frame) {
+				return nil
+			}
+			return next(frame)
+		}
+	}
+}
+
+// RateLimit returns middleware that drops frames less than interval after
+// the last frame passed on, by frame timestamp, so recordings downsample the
+// same way they do live. A frame older than the last, as when a new
+// recording starts, is passed on.
+func RateLimit(interval time.Duration) Middleware {
+	return func(next Handler) Handler {
+		var last time.Time
+		return func(frame *telemetry.LobbySessionStateFrame) error {
+			t := frame.GetTimestamp().AsTime()
+			if !last.IsZero() && t.Sub(last) < interval && !t.Before(last) {
+				return nil
+			}
+			last = t
+			return next(frame)
+		}
+	}
+}
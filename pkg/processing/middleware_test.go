@@ -0,0 +1,183 @@
+package processing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestChain_Order(t *testing.T) {
+	var calls []string
+	step := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(frame *telemetry.LobbySessionStateFrame) error {
+				calls = append(calls, name+" before")
+				err := next(frame)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+
+	h := Chain{step("a"), step("b")}.Then(func(*telemetry.LobbySessionStateFrame) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	if err := h(&telemetry.LobbySessionStateFrame{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"a before", "b before", "handler", "b after", "a after"}
+	if len(calls) != len(want) {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("expected calls %v, got %v", want, calls)
+		}
+	}
+}
+
+func TestProcessor_Use(t *testing.T) {
+	mock := &mockDetector{eventsChan: make(chan []*telemetry.LobbySessionEvent)}
+	processor := NewWithDetector(mock)
+
+	var seenIndex []uint32
+	processor.Use(
+		Filter(func(frame *telemetry.LobbySessionStateFrame) bool {
+			return frame.GetSession().GetGameStatus() != "pre_match"
+		}),
+		Transform(func(frame *telemetry.LobbySessionStateFrame) {
+			seenIndex = append(seenIndex, frame.FrameIndex)
+			frame.Session.MapName = "normalized"
+		}),
+	)
+
+	playing := []byte(`{"game_status":"playing","map_name":"mpl_arena_a"}`)
+	preMatch := []byte(`{"game_status":"pre_match","map_name":"mpl_arena_a"}`)
+
+	frame, err := processor.ProcessAndDetectEvents(playing, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.GetSession().GetMapName() != "normalized" {
+		t.Errorf("expected the transform to run before the frame is returned, got %q", frame.GetSession().GetMapName())
+	}
+
+	if frame, err := processor.ProcessAndDetectEvents(preMatch, nil, time.Now()); !errors.Is(err, ErrFrameDropped) || frame != nil {
+		t.Fatalf("expected ErrFrameDropped, got %v, %v", frame, err)
+	}
+
+	frame, err = processor.ProcessAndDetectEvents(playing, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.FrameIndex != 1 {
+		t.Errorf("expected dropped frames not to use up an index, got %d", frame.FrameIndex)
+	}
+
+	if len(mock.processedFrames) != 2 {
+		t.Errorf("expected 2 frames detected on, got %d", len(mock.processedFrames))
+	}
+	if len(seenIndex) != 2 || seenIndex[0] != 0 || seenIndex[1] != 1 {
+		t.Errorf("expected middleware to see frame indexes [0 1], got %v", seenIndex)
+	}
+}
+
+func TestProcessor_MiddlewareError(t *testing.T) {
+	processor := NewWithFramePool()
+	defer processor.Stop()
+
+	errBoom := errors.New("boom")
+	processor.Use(func(next Handler) Handler {
+		return func(*telemetry.LobbySessionStateFrame) error {
+			return errBoom
+		}
+	})
+
+	if _, err := processor.ProcessAndDetectEvents(createTestSessionData(t), nil, time.Now()); !errors.Is(err, errBoom) {
+		t.Fatalf("expected the middleware error, got %v", err)
+	}
+	if n := processor.pool.InUse(); n != 0 {
+		t.Errorf("expected the failed frame to be released, got %d in use", n)
+	}
+}
+
+func TestProcessor_Process(t *testing.T) {
+	mock := &mockDetector{eventsChan: make(chan []*telemetry.LobbySessionEvent)}
+	processor := NewWithDetector(mock)
+	processor.Use(RateLimit(100 * time.Millisecond))
+
+	start := time.Now()
+	var kept int
+	for i := range 10 {
+		frame := createTestFrame(t)
+		frame.Timestamp = timestamppb.New(start.Add(time.Duration(i) * 40 * time.Millisecond))
+		err := processor.Process(frame)
+		if errors.Is(err, ErrFrameDropped) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.FrameIndex != uint32(kept) {
+			t.Errorf("expected frame index %d, got %d", kept, frame.FrameIndex)
+		}
+		kept++
+	}
+
+	// Frames at 0, 120, 240 and 360 ms pass
+	if kept != 4 || len(mock.processedFrames) != 4 {
+		t.Errorf("expected 4 frames kept, got %d (%d detected)", kept, len(mock.processedFrames))
+	}
+}
+
+func TestRateLimit_PassesOlderFrames(t *testing.T) {
+	var passed int
+	h := Chain{RateLimit(time.Second)}.Then(func(*telemetry.LobbySessionStateFrame) error {
+		passed++
+		return nil
+	})
+
+	start := time.Now()
+	for _, offset := range []time.Duration{0, 500 * time.Millisecond, -time.Hour, -time.Hour + 100*time.Millisecond} {
+		h(&telemetry.LobbySessionStateFrame{Timestamp: timestamppb.New(start.Add(offset))})
+	}
+	if passed != 2 {
+		t.Errorf("expected 2 frames to pass, got %d", passed)
+	}
+}
+
+func TestProcessor_Write(t *testing.T) {
+	processor := NewWithDetector(&mockDetector{eventsChan: make(chan []*telemetry.LobbySessionEvent)})
+	processor.UseBeforeWrite(
+		Filter(func(frame *telemetry.LobbySessionStateFrame) bool {
+			return frame.GetSession().GetGameStatus() != "pre_match"
+		}),
+		Transform(func(frame *telemetry.LobbySessionStateFrame) {
+			frame.Session.MapName = "tagged"
+		}),
+	)
+
+	var written []*telemetry.LobbySessionStateFrame
+	write := func(frame *telemetry.LobbySessionStateFrame) error {
+		written = append(written, frame)
+		return nil
+	}
+
+	frame := createTestFrame(t)
+	frame.Session.GameStatus = "pre_match"
+	if err := processor.Write(frame, write); !errors.Is(err, ErrFrameDropped) {
+		t.Errorf("expected ErrFrameDropped, got %v", err)
+	}
+	frame = createTestFrame(t)
+	if err := processor.Write(frame, write); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 1 || written[0].GetSession().GetMapName() != "tagged" {
+		t.Errorf("expected one tagged frame written, got %v", written)
+	}
+}
@@ -0,0 +1,319 @@
+package processing
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Default ReorderBuffer settings
+const (
+	DefaultReorderResetThreshold = 5 * time.Second
+	DefaultReorderCapacity       = 1024
+)
+
+// ReorderKey returns a frame's position in the stream. A ReorderBuffer
+// passes frames on in increasing key order.
+type ReorderKey func(frame *telemetry.LobbySessionStateFrame) time.Duration
+
+// ByTimestamp orders frames by capture timestamp
+func ByTimestamp(frame *telemetry.LobbySessionStateFrame) time.Duration {
+	ts := frame.GetTimestamp()
+	return time.Duration(ts.GetSeconds())*time.Second + time.Duration(ts.GetNanos())
+}
+
+// ByGameClock orders frames by the game clock, which counts down during a
+// round. Frames are held while the clock is stopped, and the jump back up
+// at the start of a round resets the buffer, so it suits offline sources
+// whose timestamps cannot be trusted.
+func ByGameClock(frame *telemetry.LobbySessionStateFrame) time.Duration {
+	return -time.Duration(frame.GetSession().GetGameClock() * float64(time.Second))
+}
+
+// ReorderOption configures a ReorderBuffer
+type ReorderOption func(*ReorderBuffer)
+
+// WithReorderKey sets how frames are ordered; the default is ByTimestamp
+func WithReorderKey(key ReorderKey) ReorderOption {
+	return func(b *ReorderBuffer) {
+		b.key = key
+	}
+}
+
+// WithDedup sets whether a frame is dropped when a frame with the same key
+// and the same session and bones content was already seen, as when a relay
+// sends a frame twice. It is disabled by default. Hashing the content costs
+// a marshal of every frame, and frames with equal content but different keys,
+// such as those of a paused game, are never duplicates.
+func WithDedup(enabled bool) ReorderOption {
+	return func(b *ReorderBuffer) {
+		b.dedup = enabled
+	}
+}
+
+// WithReorderFrameRefs releases the frames the buffer drops, as late or
+// duplicate, to refs, such as the FramePool they were taken from. Frames it
+// passes on are the next handler's to release.
+func WithReorderFrameRefs(refs events.FrameRefs) ReorderOption {
+	return func(b *ReorderBuffer) {
+		b.refs = refs
+	}
+}
+
+// WithReorderResetThreshold sets how far behind the last frame passed on a
+// frame may be before it is taken as the start of a new stream, such as a
+// new recording, rather than a late frame
+func WithReorderResetThreshold(d time.Duration) ReorderOption {
+	return func(b *ReorderBuffer) {
+		b.resetAfter = d
+	}
+}
+
+// WithReorderCapacity bounds the frames held; beyond it the earliest frame
+// is passed on early
+func WithReorderCapacity(n int) ReorderOption {
+	return func(b *ReorderBuffer) {
+		if n > 0 {
+			b.capacity = n
+		}
+	}
+}
+
+// ReorderStats are running totals kept by a ReorderBuffer
+type ReorderStats struct {
+	// Released is the number of frames passed on
+	Released uint64
+	// Reordered is the number of frames that arrived after a later frame and were put back in order
+	Reordered uint64
+	// Duplicates is the number of frames dropped as duplicates
+	Duplicates uint64
+	// Late is the number of frames dropped because a later frame had already been passed on
+	Late uint64
+	// Resets is the number of times a large jump back started a new stream
+	Resets uint64
+}
+
+// ReorderBuffer puts frames from unreliable sources, such as relays or
+// merged captures, back in order before they reach the event detector,
+// whose ring buffer assumes strict order. Frames are held until a frame at
+// least the latency window later has arrived, dropping frames that arrive
+// after later ones have been passed on and, with WithDedup, duplicates.
+//
+// A ReorderBuffer sits in front of a Handler such as Processor.Process:
+//
+//	buf := processing.NewReorderBuffer(200*time.Millisecond, processor.Process)
+//	for frame := range frames {
+//		buf.Push(frame)
+//	}
+//	buf.Flush()
+//
+// The buffer holds on to the frames it is given until they are passed on.
+// Push and Flush must not be called concurrently; Stats may be.
+type ReorderBuffer struct {
+	window     time.Duration
+	next       Handler
+	key        ReorderKey
+	dedup      bool
+	refs       events.FrameRefs
+	resetAfter time.Duration
+	capacity   int
+
+	// pending is sorted by key, then arrival
+	pending  []reorderEntry
+	seq      uint64
+	maxKey   time.Duration
+	lastKey  time.Duration
+	started  bool
+	released bool
+	// seen holds the key and content hash of recent frames, for dedup;
+	// seenOrder holds the same entries in arrival order, for pruning
+	seen      map[seenFrame]struct{}
+	seenOrder []seenFrame
+	scratch   []byte
+
+	mu    sync.Mutex
+	stats ReorderStats
+}
+
+type seenFrame struct {
+	key  time.Duration
+	hash uint64
+}
+
+type reorderEntry struct {
+	frame *telemetry.LobbySessionStateFrame
+	key   time.Duration
+	seq   uint64
+}
+
+// NewReorderBuffer creates a ReorderBuffer that passes frames to next in order
+// once they are window old
+func NewReorderBuffer(window time.Duration, next Handler, opts ...ReorderOption) *ReorderBuffer {
+	b := &ReorderBuffer{
+		window:     window,
+		next:       next,
+		key:        ByTimestamp,
+		resetAfter: DefaultReorderResetThreshold,
+		capacity:   DefaultReorderCapacity,
+		seen:       make(map[seenFrame]struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Push adds a frame and passes on every frame that has become ready. It
+// returns the errors from the next handler; frames it drops with
+// ErrFrameDropped are not errors. Push has the Handler signature, so a
+// ReorderBuffer can feed another buffer or a Chain.
+func (b *ReorderBuffer) Push(frame *telemetry.LobbySessionStateFrame) error {
+	key := b.key(frame)
+
+	var seen seenFrame
+	if b.dedup {
+		seen = seenFrame{key: key, hash: b.hash(frame)}
+		if _, ok := b.seen[seen]; ok {
+			b.count(func(s *ReorderStats) { s.Duplicates++ })
+			b.drop(frame)
+			return nil
+		}
+	}
+
+	var errs []error
+	if b.released && key < b.lastKey {
+		if b.lastKey-key <= b.resetAfter {
+			b.count(func(s *ReorderStats) { s.Late++ })
+			b.drop(frame)
+			return nil
+		}
+		// Too far back to be late; start over as a new stream
+		errs = append(errs, b.Flush())
+		b.started, b.released = false, false
+		clear(b.seen)
+		b.seenOrder = b.seenOrder[:0]
+		b.count(func(s *ReorderStats) { s.Resets++ })
+	}
+
+	if b.started && key < b.maxKey {
+		b.count(func(s *ReorderStats) { s.Reordered++ })
+	}
+	if !b.started || key > b.maxKey {
+		b.maxKey = key
+	}
+	b.started = true
+
+	entry := reorderEntry{frame: frame, key: key, seq: b.seq}
+	b.seq++
+	i, _ := slices.BinarySearchFunc(b.pending, entry, compareReorderEntries)
+	b.pending = slices.Insert(b.pending, i, entry)
+	if b.dedup {
+		b.seen[seen] = struct{}{}
+		b.seenOrder = append(b.seenOrder, seen)
+	}
+
+	errs = append(errs, b.releaseWhile(func(e reorderEntry, held int) bool {
+		return b.maxKey-e.key >= b.window || held > b.capacity
+	}))
+	if b.dedup {
+		b.pruneSeen()
+	}
+	return errors.Join(errs...)
+}
+
+// Flush passes on every frame still held, in order
+func (b *ReorderBuffer) Flush() error {
+	return b.releaseWhile(func(reorderEntry, int) bool { return true })
+}
+
+// Len returns the number of frames held
+func (b *ReorderBuffer) Len() int {
+	return len(b.pending)
+}
+
+// Stats returns the buffer's running totals
+func (b *ReorderBuffer) Stats() ReorderStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// releaseWhile passes on frames from the front of the buffer while ready,
+// given the front frame and the number of frames held, reports true
+func (b *ReorderBuffer) releaseWhile(ready func(e reorderEntry, held int) bool) error {
+	var errs []error
+	n := 0
+	for n < len(b.pending) && ready(b.pending[n], len(b.pending)-n) {
+		e := b.pending[n]
+		b.pending[n].frame = nil
+		n++
+		b.lastKey, b.released = e.key, true
+		b.count(func(s *ReorderStats) { s.Released++ })
+		if err := b.next(e.frame); err != nil && !errors.Is(err, ErrFrameDropped) {
+			errs = append(errs, err)
+		}
+	}
+	b.pending = append(b.pending[:0], b.pending[n:]...)
+	return errors.Join(errs...)
+}
+
+// hash returns a hash of the frame's session and bones, ignoring its index,
+// timestamp and events
+func (b *ReorderBuffer) hash(frame *telemetry.LobbySessionStateFrame) uint64 {
+	opts := proto.MarshalOptions{Deterministic: true}
+	b.scratch, _ = opts.MarshalAppend(b.scratch[:0], frame.GetSession())
+	b.scratch = append(b.scratch, 0xff)
+	b.scratch, _ = opts.MarshalAppend(b.scratch, frame.GetPlayerBones())
+
+	// FNV-1a
+	h := uint64(14695981039346656037)
+	for _, c := range b.scratch {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return h
+}
+
+// pruneSeen forgets, oldest first, frames before the last one passed on,
+// whose copies would be dropped as late anyway. Frames arrive in close to
+// key order, so one that arrived late is forgotten soon after.
+func (b *ReorderBuffer) pruneSeen() {
+	n := 0
+	for n < len(b.seenOrder) && b.released && b.seenOrder[n].key < b.lastKey {
+		delete(b.seen, b.seenOrder[n])
+		n++
+	}
+	b.seenOrder = b.seenOrder[n:]
+}
+
+// drop releases a frame the buffer does not pass on
+func (b *ReorderBuffer) drop(frame *telemetry.LobbySessionStateFrame) {
+	if b.refs != nil {
+		b.refs.Release(frame)
+	}
+}
+
+func (b *ReorderBuffer) count(fn func(*ReorderStats)) {
+	b.mu.Lock()
+	fn(&b.stats)
+	b.mu.Unlock()
+}
+
+func compareReorderEntries(a, b reorderEntry) int {
+	switch {
+	case a.key < b.key:
+		return -1
+	case a.key > b.key:
+		return 1
+	case a.seq < b.seq:
+		return -1
+	case a.seq > b.seq:
+		return 1
+	}
+	return 0
+}
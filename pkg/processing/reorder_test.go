@@ -0,0 +1,263 @@
+package processing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var reorderStart = time.Date(2026, 1, 19, 22, 50, 54, 0, time.UTC)
+
+// reorderFrame returns a frame at ms milliseconds whose content identifies it
+func reorderFrame(ms int) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(reorderStart.Add(time.Duration(ms) * time.Millisecond)),
+		Session:   &apigame.SessionResponse{GameClock: 300 - float64(ms)/1000, GameStatus: "playing"},
+	}
+}
+
+// collect returns a handler recording the millisecond offset of each frame it gets
+func collect(got *[]int) Handler {
+	return func(frame *telemetry.LobbySessionStateFrame) error {
+		*got = append(*got, int(frame.GetTimestamp().AsTime().Sub(reorderStart)/time.Millisecond))
+		return nil
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReorderBuffer_Reorders(t *testing.T) {
+	var got []int
+	buf := NewReorderBuffer(50*time.Millisecond, collect(&got))
+
+	for _, ms := range []int{0, 32, 16, 48, 80, 64, 96, 112, 160} {
+		if err := buf.Push(reorderFrame(ms)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Frames at least 50 ms older than the newest (160) have been passed on
+	if want := []int{0, 16, 32, 48, 64, 80, 96}; !equalInts(got, want) {
+		t.Fatalf("expected %v before flushing, got %v", want, got)
+	}
+	if buf.Len() != 2 {
+		t.Errorf("expected 2 frames held, got %d", buf.Len())
+	}
+
+	if err := buf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 16, 32, 48, 64, 80, 96, 112, 160}; !equalInts(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	stats := buf.Stats()
+	if stats.Released != 9 || stats.Reordered != 2 || stats.Duplicates != 0 || stats.Late != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestReorderBuffer_DropsDuplicatesAndLateFrames(t *testing.T) {
+	var got []int
+	buf := NewReorderBuffer(30*time.Millisecond, collect(&got), WithDedup(true))
+
+	push := func(frame *telemetry.LobbySessionStateFrame) {
+		t.Helper()
+		if err := buf.Push(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	push(reorderFrame(0))
+	push(reorderFrame(16))
+	push(reorderFrame(16)) // exact duplicate
+
+	// Same content relayed with a different timestamp is a different frame
+	relayed := reorderFrame(16)
+	relayed.Timestamp = timestamppb.New(reorderStart.Add(20 * time.Millisecond))
+	push(relayed)
+
+	push(reorderFrame(64)) // passes on 0, 16 and 20
+	push(reorderFrame(8))  // arrives after 16 was passed on
+	buf.Flush()
+
+	if want := []int{0, 16, 20, 64}; !equalInts(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	stats := buf.Stats()
+	if stats.Duplicates != 1 || stats.Late != 1 || stats.Released != 4 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestReorderBuffer_IdenticalContentAtOtherKeys(t *testing.T) {
+	var got []int
+	buf := NewReorderBuffer(30*time.Millisecond, collect(&got), WithDedup(true))
+
+	// A paused game repeats the same content; every frame is kept
+	for _, ms := range []int{0, 10, 20, 100} {
+		frame := reorderFrame(0)
+		frame.Timestamp = timestamppb.New(reorderStart.Add(time.Duration(ms) * time.Millisecond))
+		buf.Push(frame)
+	}
+	buf.Flush()
+	if want := []int{0, 10, 20, 100}; !equalInts(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestReorderBuffer_DedupIsOptIn(t *testing.T) {
+	var got []int
+	buf := NewReorderBuffer(30*time.Millisecond, collect(&got))
+	buf.Push(reorderFrame(0))
+	buf.Push(reorderFrame(0))
+	buf.Flush()
+	if len(got) != 2 {
+		t.Errorf("expected both copies, got %v", got)
+	}
+}
+
+func TestReorderBuffer_PrunesSeenFrames(t *testing.T) {
+	buf := NewReorderBuffer(30*time.Millisecond, func(*telemetry.LobbySessionStateFrame) error { return nil }, WithDedup(true))
+	for ms := 0; ms < 10_000; ms += 10 {
+		buf.Push(reorderFrame(ms))
+	}
+	// Only frames at or after the last one passed on are remembered
+	if len(buf.seen) > 5 || len(buf.seen) != len(buf.seenOrder) {
+		t.Errorf("expected a handful of seen frames, got %d (%d in order)", len(buf.seen), len(buf.seenOrder))
+	}
+}
+
+func TestReorderBuffer_ReleasesDroppedFrames(t *testing.T) {
+	pool := NewFramePool()
+	frame := func(ms int) *telemetry.LobbySessionStateFrame {
+		f := pool.Get()
+		f.Timestamp = timestamppb.New(reorderStart.Add(time.Duration(ms) * time.Millisecond))
+		f.Session = reorderFrame(ms).Session
+		return f
+	}
+	// The next handler releases the frames passed on to it
+	buf := NewReorderBuffer(30*time.Millisecond, func(f *telemetry.LobbySessionStateFrame) error {
+		pool.Release(f)
+		return nil
+	}, WithDedup(true), WithReorderFrameRefs(pool))
+
+	buf.Push(frame(0))
+	buf.Push(frame(16))
+	buf.Push(frame(16)) // duplicate
+	buf.Push(frame(64))
+	buf.Push(frame(8)) // late
+	buf.Flush()
+
+	if n := pool.InUse(); n != 0 {
+		t.Errorf("expected every frame back in the pool, %d still in use", n)
+	}
+}
+
+func TestReorderBuffer_ResetOnJumpBack(t *testing.T) {
+	var got []int
+	buf := NewReorderBuffer(30*time.Millisecond, collect(&got), WithReorderResetThreshold(time.Second))
+
+	buf.Push(reorderFrame(60_000))
+	buf.Push(reorderFrame(60_016))
+	buf.Push(reorderFrame(60_100))
+	// A new recording starts a minute earlier
+	buf.Push(reorderFrame(0))
+	buf.Push(reorderFrame(16))
+	buf.Flush()
+
+	if want := []int{60_000, 60_016, 60_100, 0, 16}; !equalInts(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if stats := buf.Stats(); stats.Resets != 1 || stats.Late != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestReorderBuffer_ByGameClock(t *testing.T) {
+	var got []int
+	buf := NewReorderBuffer(30*time.Millisecond, collect(&got), WithReorderKey(ByGameClock))
+
+	// Timestamps are scrambled but the game clock counts down in order
+	for i, ms := range []int{48, 32, 16, 0} {
+		frame := reorderFrame(ms)
+		frame.Session.GameClock = 300 - float64(i)/100
+		buf.Push(frame)
+	}
+	buf.Flush()
+	if want := []int{48, 32, 16, 0}; !equalInts(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestReorderBuffer_Capacity(t *testing.T) {
+	var got []int
+	buf := NewReorderBuffer(time.Hour, collect(&got), WithReorderCapacity(2))
+	for _, ms := range []int{0, 16, 32, 48} {
+		buf.Push(reorderFrame(ms))
+	}
+	if want := []int{0, 16}; !equalInts(got, want) || buf.Len() != 2 {
+		t.Errorf("expected %v passed on with 2 held, got %v with %d held", want, got, buf.Len())
+	}
+}
+
+func TestReorderBuffer_HandlerErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	var calls int
+	buf := NewReorderBuffer(0, func(*telemetry.LobbySessionStateFrame) error {
+		calls++
+		if calls == 1 {
+			return ErrFrameDropped
+		}
+		return errBoom
+	})
+
+	if err := buf.Push(reorderFrame(0)); err != nil {
+		t.Errorf("expected ErrFrameDropped to be ignored, got %v", err)
+	}
+	if err := buf.Push(reorderFrame(16)); !errors.Is(err, errBoom) {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+}
+
+func TestReorderBuffer_FeedsProcessor(t *testing.T) {
+	processor := NewWithDetector(events.New(events.WithSynchronousProcessing()))
+	defer processor.Stop()
+
+	var statuses []string
+	processor.Use(Transform(func(frame *telemetry.LobbySessionStateFrame) {
+		statuses = append(statuses, frame.GetSession().GetGameStatus())
+	}))
+	buf := NewReorderBuffer(50*time.Millisecond, processor.Process)
+
+	frames := []*telemetry.LobbySessionStateFrame{reorderFrame(0), reorderFrame(32), reorderFrame(16)}
+	frames[1].Session.GameStatus = "round_over"
+	for _, frame := range frames {
+		buf.Push(frame)
+	}
+	buf.Flush()
+
+	if len(statuses) != 3 || statuses[2] != "round_over" {
+		t.Errorf("expected round_over to reach the processor last, got %v", statuses)
+	}
+	for i, frame := range []*telemetry.LobbySessionStateFrame{frames[0], frames[2], frames[1]} {
+		if frame.FrameIndex != uint32(i) {
+			t.Errorf("expected frame index %d in timestamp order, got %d", i, frame.FrameIndex)
+		}
+	}
+}
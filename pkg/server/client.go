@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Client subscribes to a Server over a gRPC connection
+//
+//	conn, err := grpc.NewClient("capture-pc:50051",
+//		grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	client := server.NewClient(conn)
+//	frames, err := client.StreamFrames(ctx, server.FrameFilter{MaxRate: 10})
+//	for {
+//		frame, err := frames.Recv()
+//		...
+//	}
+type Client struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewClient creates a Client using conn
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn}
+}
+
+// FrameStream receives frames from a Server
+type FrameStream struct {
+	stream grpc.ClientStream
+}
+
+// Recv returns the next frame. It returns io.EOF once the server closes the
+// stream, and the context's error if it is cancelled.
+func (s *FrameStream) Recv() (*telemetry.LobbySessionStateFrame, error) {
+	frame := &telemetry.LobbySessionStateFrame{}
+	if err := s.stream.RecvMsg(frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// EventStream receives events from a Server
+type EventStream struct {
+	stream grpc.ClientStream
+}
+
+// Recv returns the next event. It returns io.EOF once the server closes the
+// stream, and the context's error if it is cancelled.
+func (s *EventStream) Recv() (*telemetry.LobbySessionEvent, error) {
+	event := &telemetry.LobbySessionEvent{}
+	if err := s.stream.RecvMsg(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// StreamFrames subscribes to the frames that pass filter until ctx is
+// cancelled. It returns once the server has accepted the subscription.
+func (c *Client) StreamFrames(ctx context.Context, filter FrameFilter, opts ...grpc.CallOption) (*FrameStream, error) {
+	stream, err := c.open(ctx, StreamFramesFullMethod, filter.request(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &FrameStream{stream: stream}, nil
+}
+
+// StreamEvents subscribes to the detected events that pass filter until ctx
+// is cancelled. It returns once the server has accepted the subscription.
+func (c *Client) StreamEvents(ctx context.Context, filter events.EventFilter, opts ...grpc.CallOption) (*EventStream, error) {
+	stream, err := c.open(ctx, StreamEventsFullMethod, eventFilterRequest(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{stream: stream}, nil
+}
+
+// open starts a server-streaming call and waits for the response headers,
+// which the server sends once the subscription is in place
+func (c *Client) open(ctx context.Context, method string, req proto.Message, opts []grpc.CallOption) (grpc.ClientStream, error) {
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	stream, err := c.conn.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	header, err := stream.Header()
+	if err != nil {
+		return nil, err
+	}
+	if len(header.Get(metadataSubscribed)) == 0 {
+		// The server refused the subscription; its status ends the stream
+		if err := stream.RecvMsg(&emptypb.Empty{}); err != nil {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, "server did not confirm the subscription")
+	}
+	return stream, nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// captureFilePath is the registered path of capture.proto
+const captureFilePath = "nevrcapture/v1/capture.proto"
+
+// Descriptors of capture.proto, registered with protoregistry.GlobalFiles so
+// gRPC reflection can serve them
+var (
+	captureFile         = registerCaptureFile()
+	streamFramesRequest = captureFile.Messages().ByName("StreamFramesRequest")
+	streamEventsRequest = captureFile.Messages().ByName("StreamEventsRequest")
+)
+
+// registerCaptureFile builds the descriptor of capture.proto by hand, as the
+// module has no protoc step, and registers it unless generated code for the
+// same file already has
+func registerCaptureFile() protoreflect.FileDescriptor {
+	if file, err := protoregistry.GlobalFiles.FindFileByPath(captureFilePath); err == nil {
+		return file
+	}
+
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	field := func(name string, number int32, label *descriptorpb.FieldDescriptorProto_Label, kind descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  label,
+			Type:   kind.Enum(),
+		}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+	method := func(name string, input string, output protoreflect.FullName) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".nevrcapture.v1." + input),
+			OutputType:      proto.String("." + string(output)),
+			ServerStreaming: proto.Bool(true),
+		}
+	}
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String(captureFilePath),
+		Package:    proto.String("nevrcapture.v1"),
+		Dependency: []string{frameDescriptor.ParentFile().Path()},
+		Syntax:     proto.String("proto3"),
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("github.com/echotools/nevr-capture/v3/pkg/server")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("StreamFramesRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("fields", 1, repeated, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("max_rate", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, ""),
+				},
+			},
+			{
+				Name: proto.String("StreamEventsRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("types", 1, repeated, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("player_slots", 2, repeated, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+					field("teams", 3, repeated, descriptorpb.FieldDescriptorProto_TYPE_ENUM, "."+string(telemetry.Role(0).Descriptor().FullName())),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("CaptureStream"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("StreamFrames", "StreamFramesRequest", frameDescriptor.FullName()),
+				method("StreamEvents", "StreamEventsRequest", (&telemetry.LobbySessionEvent{}).ProtoReflect().Descriptor().FullName()),
+			},
+		}},
+	}
+
+	file, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(fmt.Sprintf("server: invalid %s descriptor: %v", captureFilePath, err))
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(fmt.Sprintf("server: registering %s: %v", captureFilePath, err))
+	}
+	return file
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/codecs"
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/metadata"
+)
+
+// Default HTTP handler settings
+const (
+	// DefaultSnapshotRate is the highest rate, in frames per second of
+	// capture time, at which frame snapshots are sent to a connection
+	DefaultSnapshotRate = 10
+	DefaultHeartbeat    = 15 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// HTTPOption configures the handler returned by Server.HTTPHandler
+type HTTPOption func(*httpHandler)
+
+// WithSnapshotRate sets the highest frame snapshot rate, in frames per
+// second of capture time. Connections may ask for a lower rate.
+func WithSnapshotRate(hz float64) HTTPOption {
+	return func(h *httpHandler) {
+		if hz > 0 {
+			h.snapshotRate = hz
+		}
+	}
+}
+
+// WithHeartbeat sets how often a heartbeat message is sent, so clients and
+// proxies can tell a quiet stream from a dead one
+func WithHeartbeat(d time.Duration) HTTPOption {
+	return func(h *httpHandler) {
+		if d > 0 {
+			h.heartbeat = d
+		}
+	}
+}
+
+// WithWriteTimeout sets how long a write to a connection may take before
+// the connection is closed
+func WithWriteTimeout(d time.Duration) HTTPOption {
+	return func(h *httpHandler) {
+		if d > 0 {
+			h.writeTimeout = d
+		}
+	}
+}
+
+// HTTPHandler returns an http.Handler that streams the server's events and
+// throttled frame snapshots as JSON, for browser overlays that cannot use
+// gRPC. Requests with a WebSocket upgrade get one text message per item;
+// other requests get Server-Sent Events. Connections from any origin are
+// accepted.
+//
+// Every message is an object with a "type" of "event", "frame" or
+// "heartbeat". Events carry an "id", "event_type" as returned by
+// events.EventType and the "event" itself; custom events are sent as their
+// name and fields. Frames carry "frame_index", "timestamp", "session" and
+// "player_bones". Sessions, bones and events use the same JSON as
+// .echoreplay files.
+//
+// Query parameters filter each connection, with lists comma separated:
+//
+//	types     event types, e.g. player_goal,custom:joust
+//	slots     player slots events are attributed to
+//	teams     teams events are attributed to, e.g. ROLE_BLUE_TEAM
+//	fields    frame fields to send, session and/or player_bones
+//	rate      frame snapshots per second, up to the handler's snapshot
+//	          rate; 0 sends no frames
+//	backfill  number of recent events sent on connect; by default every
+//	          kept event (see WithBacklog)
+//
+// Server-Sent Events clients that reconnect with a Last-Event-ID header are
+// backfilled with the kept events they missed.
+func (s *Server) HTTPHandler(opts ...HTTPOption) http.Handler {
+	h := &httpHandler{
+		server:       s,
+		snapshotRate: DefaultSnapshotRate,
+		heartbeat:    DefaultHeartbeat,
+		writeTimeout: DefaultWriteTimeout,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type httpHandler struct {
+	server       *Server
+	snapshotRate float64
+	heartbeat    time.Duration
+	writeTimeout time.Duration
+}
+
+// connection describes what one HTTP client asked for
+type connection struct {
+	events   events.EventFilter
+	frames   FrameFilter
+	noFrames bool
+	backfill int
+	after    uint64
+}
+
+// queryMetadata maps query parameters to the request metadata used by gRPC
+// subscribers, so both transports parse filters the same way
+var queryMetadata = map[string]string{
+	"types":  metadataEventTypes,
+	"slots":  metadataEventPlayerSlots,
+	"teams":  metadataEventTeams,
+	"fields": metadataFields,
+	"rate":   metadataMaxRate,
+}
+
+// parseConnection reads a connection's filters from its request
+func (h *httpHandler) parseConnection(r *http.Request) (connection, error) {
+	query := r.URL.Query()
+	md := metadata.MD{}
+	for param, key := range queryMetadata {
+		for _, v := range query[param] {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					md.Append(key, item)
+				}
+			}
+		}
+	}
+
+	var c connection
+	var err error
+	if c.events, err = eventFilterFromMetadata(md); err != nil {
+		return c, err
+	}
+	if c.frames, err = frameFilterFromMetadata(md); err != nil {
+		return c, err
+	}
+	if query.Has("rate") && c.frames.MaxRate == 0 {
+		c.noFrames = true
+	}
+	if c.frames.MaxRate == 0 || c.frames.MaxRate > h.snapshotRate {
+		c.frames.MaxRate = h.snapshotRate
+	}
+
+	c.backfill = h.server.backlogSize
+	if v := query.Get("backfill"); v != "" {
+		if c.backfill, err = strconv.Atoi(v); err != nil || c.backfill < 0 {
+			return c, fmt.Errorf("invalid backfill %q", v)
+		}
+	}
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if c.after, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return c, fmt.Errorf("invalid Last-Event-ID %q", v)
+		}
+	}
+	return c, nil
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := h.parseConnection(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		// Without a Handshake function, any Origin is accepted
+		websocket.Server{Handler: func(ws *websocket.Conn) {
+			h.serveWebSocket(ws, c)
+		}}.ServeHTTP(w, r)
+		return
+	}
+	h.serveSSE(w, r, c)
+}
+
+// serveSSE streams to a Server-Sent Events client
+func (h *httpHandler) serveSSE(w http.ResponseWriter, r *http.Request, c connection) {
+	rc := http.NewResponseController(w)
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Access-Control-Allow-Origin", "*")
+
+	var buf []byte
+	h.stream(r.Context(), c, func() error {
+		// Headers go out once subscribed, so clients know they will miss nothing
+		w.WriteHeader(http.StatusOK)
+		return rc.Flush()
+	}, func(kind string, id uint64, payload []byte) error {
+		buf = buf[:0]
+		if id > 0 {
+			buf = append(buf, "id: "...)
+			buf = strconv.AppendUint(buf, id, 10)
+			buf = append(buf, '\n')
+		}
+		buf = append(buf, "event: "...)
+		buf = append(buf, kind...)
+		buf = append(buf, "\ndata: "...)
+		buf = append(buf, payload...)
+		buf = append(buf, "\n\n"...)
+
+		if err := rc.SetWriteDeadline(time.Now().Add(h.writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+}
+
+// serveWebSocket streams to a WebSocket client
+func (h *httpHandler) serveWebSocket(ws *websocket.Conn, c connection) {
+	defer ws.Close()
+	ws.PayloadType = websocket.TextFrame
+
+	// Clients only send to close the connection; stop once they do
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	h.stream(ctx, c, func() error { return nil }, func(_ string, _ uint64, payload []byte) error {
+		if err := ws.SetWriteDeadline(time.Now().Add(h.writeTimeout)); err != nil {
+			return err
+		}
+		_, err := ws.Write(payload)
+		return err
+	})
+}
+
+// stream subscribes for a connection, calls ready once subscribed, then
+// sends the backfill followed by new events, frames and heartbeats until
+// ctx is done, the server is closed or a send fails
+func (h *httpHandler) stream(ctx context.Context, c connection, ready func() error, send func(kind string, id uint64, payload []byte) error) {
+	s := h.server
+	eventSub, backfill := s.subscribeEvents(c.events, c.after, c.backfill)
+	if eventSub == nil {
+		return
+	}
+	defer s.unsubscribeEvents(eventSub)
+
+	// A nil channel never receives, so a connection without frames just waits on events
+	var frames <-chan *telemetry.LobbySessionStateFrame
+	if !c.noFrames {
+		frameSub := s.subscribeFrames(c.frames)
+		if frameSub == nil {
+			return
+		}
+		defer s.unsubscribeFrames(frameSub)
+		frames = frameSub.ch
+	}
+
+	if err := ready(); err != nil {
+		return
+	}
+
+	var buf []byte
+	var err error
+	for _, e := range backfill {
+		if buf, err = appendEventJSON(buf[:0], e); err != nil {
+			continue
+		}
+		if err := send("event", e.seq, buf); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-eventSub.ch:
+			if !ok {
+				return
+			}
+			if buf, err = appendEventJSON(buf[:0], e); err != nil {
+				continue
+			}
+			if err := send("event", e.seq, buf); err != nil {
+				return
+			}
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			if buf, err = appendFrameJSON(buf[:0], trimFrame(frame, c.frames.Fields)); err != nil {
+				continue
+			}
+			if err := send("frame", 0, buf); err != nil {
+				return
+			}
+		case now := <-heartbeat.C:
+			buf = appendHeartbeatJSON(buf[:0], now)
+			if err := send("heartbeat", 0, buf); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// appendEventJSON appends an event message. Custom events are carried in
+// unknown fields, which protojson leaves out, so they are written as their
+// name and fields instead.
+func appendEventJSON(dst []byte, e sequencedEvent) ([]byte, error) {
+	eventType, err := json.Marshal(events.EventType(e.event))
+	if err != nil {
+		return dst, err
+	}
+	start := len(dst)
+	dst = append(dst, `{"type":"event","id":`...)
+	dst = strconv.AppendUint(dst, e.seq, 10)
+	dst = append(dst, `,"event_type":`...)
+	dst = append(dst, eventType...)
+	dst = append(dst, `,"event":`...)
+
+	if custom, err := events.GetCustomEvent(e.event); err == nil {
+		data, err := json.Marshal(struct {
+			Name   string         `json:"name"`
+			Fields map[string]any `json:"fields"`
+		}{custom.Name, custom.Fields})
+		if err != nil {
+			return dst[:start], err
+		}
+		dst = append(dst, data...)
+	} else if dst, err = codecs.AppendEchoReplayJSON(dst, e.event); err != nil {
+		return dst[:start], err
+	}
+	return append(dst, '}'), nil
+}
+
+// appendFrameJSON appends a frame message with whichever of the session and
+// bones the frame has
+func appendFrameJSON(dst []byte, frame *telemetry.LobbySessionStateFrame) ([]byte, error) {
+	start := len(dst)
+	var err error
+	dst = append(dst, `{"type":"frame","frame_index":`...)
+	dst = strconv.AppendUint(dst, uint64(frame.GetFrameIndex()), 10)
+	dst = append(dst, `,"timestamp":"`...)
+	dst = frame.GetTimestamp().AsTime().AppendFormat(dst, time.RFC3339Nano)
+	dst = append(dst, '"')
+	if frame.Session != nil {
+		dst = append(dst, `,"session":`...)
+		if dst, err = codecs.AppendEchoReplayJSON(dst, frame.Session); err != nil {
+			return dst[:start], err
+		}
+	}
+	if frame.PlayerBones != nil {
+		dst = append(dst, `,"player_bones":`...)
+		if dst, err = codecs.AppendEchoReplayJSON(dst, frame.PlayerBones); err != nil {
+			return dst[:start], err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+func appendHeartbeatJSON(dst []byte, now time.Time) []byte {
+	dst = append(dst, `{"type":"heartbeat","time":"`...)
+	dst = now.UTC().AppendFormat(dst, time.RFC3339Nano)
+	return append(dst, `"}`...)
+}
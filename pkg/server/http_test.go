@@ -0,0 +1,271 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"golang.org/x/net/websocket"
+)
+
+// sseMessage is one Server-Sent Event
+type sseMessage struct {
+	id, event, data string
+}
+
+// sseStream connects to an SSE endpoint and returns a function reading the next event
+func sseStream(t *testing.T, url string, header http.Header) func() sseMessage {
+	t.Helper()
+	req, err := http.NewRequestWithContext(testContext(t), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s %q", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	return func() sseMessage {
+		t.Helper()
+		var msg sseMessage
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading event stream: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return msg
+			case strings.HasPrefix(line, "id: "):
+				msg.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				msg.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				msg.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+}
+
+func customEvent(t *testing.T, name string, fields map[string]any) *telemetry.LobbySessionEvent {
+	t.Helper()
+	event, err := events.NewCustomEvent(name, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// decode unmarshals a JSON message into a generic map
+func decode(t *testing.T, data string) map[string]any {
+	t.Helper()
+	var msg map[string]any
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		t.Fatalf("invalid JSON %q: %v", data, err)
+	}
+	return msg
+}
+
+func TestHTTPHandler_SSE(t *testing.T) {
+	srv := New()
+	ts := httptest.NewServer(srv.HTTPHandler())
+	defer ts.Close()
+	defer srv.Close() // ends the open streams, which ts.Close waits for
+
+	next := sseStream(t, ts.URL+"?types=custom:joust,player_goal&fields=session&rate=5", nil)
+
+	srv.Consume(customEvent(t, "ping_spike", map[string]any{"player_slot": 1.0}))
+	srv.Consume(customEvent(t, "joust", map[string]any{"winner": "blue"}))
+	srv.Consume(&telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerGoal{
+		PlayerGoal: &telemetry.PlayerGoal{PlayerSlot: 2, Points: 3},
+	}})
+
+	msg := next()
+	if msg.event != "event" || msg.id != "2" {
+		t.Fatalf("expected the joust event with id 2, got %+v", msg)
+	}
+	joust := decode(t, msg.data)
+	if joust["event_type"] != "custom:joust" || joust["event"].(map[string]any)["fields"].(map[string]any)["winner"] != "blue" {
+		t.Errorf("unexpected custom event %s", msg.data)
+	}
+
+	msg = next()
+	goal := decode(t, msg.data)
+	if msg.id != "3" || goal["event_type"] != "player_goal" {
+		t.Fatalf("expected the goal with id 3, got %+v", msg)
+	}
+	if points := goal["event"].(map[string]any)["playerGoal"].(map[string]any)["points"]; points != 3.0 {
+		t.Errorf("expected the goal's points, got %s", msg.data)
+	}
+
+	// 5 Hz keeps the frames at 0 and 200 ms
+	for ms := 0; ms <= 200; ms += 50 {
+		srv.PublishFrame(testFrame(ms))
+	}
+	for _, want := range []float64{0, 200} {
+		msg := next()
+		frame := decode(t, msg.data)
+		if msg.event != "frame" || frame["frame_index"] != want {
+			t.Fatalf("expected frame %v, got %+v", want, msg)
+		}
+		if frame["session"].(map[string]any)["sessionid"] != "session-a" {
+			t.Errorf("expected the session in echoreplay JSON, got %s", msg.data)
+		}
+		if _, ok := frame["player_bones"]; ok {
+			t.Errorf("expected player bones to be left out, got %s", msg.data)
+		}
+	}
+}
+
+func TestHTTPHandler_Backfill(t *testing.T) {
+	srv := New(WithBacklog(3))
+	ts := httptest.NewServer(srv.HTTPHandler())
+	defer ts.Close()
+	defer srv.Close()
+
+	for i := range 5 {
+		srv.Consume(customEvent(t, "seen", map[string]any{"n": float64(i)}))
+	}
+
+	// Only the last 3 events are kept; ask for 2 of them
+	next := sseStream(t, ts.URL+"?rate=0&backfill=2", nil)
+	for _, want := range []string{"4", "5"} {
+		if msg := next(); msg.id != want {
+			t.Errorf("expected backfilled event %s, got %+v", want, msg)
+		}
+	}
+
+	// A reconnecting client gets what it missed
+	next = sseStream(t, ts.URL+"?rate=0", http.Header{"Last-Event-Id": {"4"}})
+	if msg := next(); msg.id != "5" {
+		t.Errorf("expected event 5 after Last-Event-ID 4, got %+v", msg)
+	}
+	srv.Consume(customEvent(t, "seen", map[string]any{"n": 5.0}))
+	if msg := next(); msg.id != "6" {
+		t.Errorf("expected the new event 6, got %+v", msg)
+	}
+}
+
+func TestHTTPHandler_Heartbeat(t *testing.T) {
+	srv := New()
+	ts := httptest.NewServer(srv.HTTPHandler(WithHeartbeat(10 * time.Millisecond)))
+	defer ts.Close()
+	defer srv.Close()
+
+	next := sseStream(t, ts.URL, nil)
+	msg := next()
+	if msg.event != "heartbeat" || decode(t, msg.data)["type"] != "heartbeat" {
+		t.Errorf("expected a heartbeat, got %+v", msg)
+	}
+}
+
+func TestHTTPHandler_InvalidFilter(t *testing.T) {
+	srv := New()
+	ts := httptest.NewServer(srv.HTTPHandler())
+	defer ts.Close()
+	defer srv.Close()
+
+	for _, query := range []string{"fields=scoreboard", "teams=ROLE_GREEN", "slots=one", "rate=-1", "backfill=-1"} {
+		resp, err := http.Get(ts.URL + "?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %s", query, resp.Status)
+		}
+	}
+}
+
+func TestHTTPHandler_WebSocket(t *testing.T) {
+	srv := New()
+	ts := httptest.NewServer(srv.HTTPHandler())
+	defer ts.Close()
+	defer srv.Close()
+
+	srv.Consume(customEvent(t, "joust", map[string]any{"winner": "orange"}))
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"?fields=player_bones", "", "http://overlay.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var data string
+	if err := websocket.Message.Receive(ws, &data); err != nil {
+		t.Fatal(err)
+	}
+	if msg := decode(t, data); msg["type"] != "event" || msg["event_type"] != "custom:joust" {
+		t.Errorf("expected the backfilled joust, got %s", data)
+	}
+
+	// The backfill is sent once subscribed, so the frame reaches the connection
+	srv.PublishFrame(testFrame(16))
+	if err := websocket.Message.Receive(ws, &data); err != nil {
+		t.Fatal(err)
+	}
+	frame := decode(t, data)
+	if frame["type"] != "frame" || frame["frame_index"] != 16.0 {
+		t.Fatalf("expected frame 16, got %s", data)
+	}
+	if _, ok := frame["session"]; ok {
+		t.Errorf("expected the session to be left out, got %s", data)
+	}
+	if bones, ok := frame["player_bones"].(map[string]any); !ok || bones["err_code"] != 1.0 {
+		t.Errorf("expected the player bones, got %s", data)
+	}
+
+	// Closing the socket removes the subscribers
+	ws.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if frames, events := srv.Subscribers(); frames == 0 && events == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the subscribers to be removed after the client closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHTTPHandler_ServerClose(t *testing.T) {
+	srv := New()
+	ts := httptest.NewServer(srv.HTTPHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	srv.Close()
+
+	// The stream ends rather than waiting for the client
+	done := make(chan struct{})
+	go func() {
+		bufio.NewReader(resp.Body).WriteTo(new(strings.Builder))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end once the server closed")
+	}
+}
@@ -0,0 +1,316 @@
+// Package server streams frames and detected events from a capture to
+// remote subscribers, so overlays and dashboards can run on a different
+// machine from the capture PC.
+//
+// Over gRPC, the nevrcapture.v1.CaptureStream service in capture.proto has
+// two server-streaming methods, StreamFrames and StreamEvents. Subscribers
+// narrow what they receive with a FrameFilter or an events.EventFilter,
+// applied on the server; Client sends them as the typed StreamFramesRequest
+// and StreamEventsRequest. Clients without capture.proto can send an empty
+// request (google.protobuf.Empty) and the filters as request metadata:
+//
+//	nevr-fields        frame fields to send, by proto name, e.g. "session"
+//	nevr-max-rate      maximum frames per second of capture time
+//	nevr-event-types   event types, e.g. "player_goal" or "custom:joust"
+//	nevr-player-slots  player slots, as decimal numbers
+//	nevr-teams         teams, as telemetry.v1.Role names, e.g. "ROLE_BLUE_TEAM"
+//
+// Each key may repeat. A filter set in the request takes precedence over the
+// same filter in metadata. The server sends "nevr-subscribed: true" in the
+// response header once the subscription is in place; an invalid filter ends
+// the call with InvalidArgument. With WithReflection, the service and its
+// request messages can be discovered with gRPC reflection.
+//
+// Browsers can use Server.HTTPHandler instead, which serves the same
+// streams as JSON over WebSocket or Server-Sent Events.
+package server
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-capture/v3/pkg/processing"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Default Server settings
+const (
+	// DefaultBufferSize is the number of frames or events queued per
+	// subscriber before the subscriber starts dropping them
+	DefaultBufferSize = 256
+	// DefaultBacklogSize is the number of recent events kept for backfill
+	DefaultBacklogSize = 100
+)
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithBufferSize sets the number of frames or events queued per subscriber
+func WithBufferSize(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.bufferSize = n
+		}
+	}
+}
+
+// WithBacklog sets the number of recent events kept to backfill new HTTP
+// connections. Zero keeps none.
+func WithBacklog(n int) Option {
+	return func(s *Server) {
+		if n >= 0 {
+			s.backlogSize = n
+		}
+	}
+}
+
+// WithReflection makes Register also register the gRPC reflection service,
+// so tools such as grpcurl can discover the service and its request
+// messages. Leave it off if the gRPC server registers reflection itself.
+func WithReflection() Option {
+	return func(s *Server) {
+		s.reflection = true
+	}
+}
+
+// Server fans frames and events out to gRPC subscribers. Frames are
+// published with PublishFrame or the Middleware, and events by adding the
+// Server to a detector as a sink:
+//
+//	srv := server.New()
+//	detector := events.NewWithDefaultSensors(events.WithLossyEventsChan())
+//	detector.AddSink(srv, events.EventFilter{}, 256)
+//	processor := processing.NewWithDetector(detector)
+//	processor.Use(srv.Middleware())
+//
+//	grpcServer := grpc.NewServer()
+//	srv.Register(grpcServer)
+//	go grpcServer.Serve(listener)
+//
+// With WithLossyEventsChan the processor's EventsChan need not be read; the
+// sink gets every event.
+// Each subscriber has its own queue; a slow subscriber drops its own frames
+// and events without holding up the capture or other subscribers.
+type Server struct {
+	bufferSize  int
+	backlogSize int
+	reflection  bool
+
+	mu     sync.Mutex
+	frames map[*subscriber[*telemetry.LobbySessionStateFrame]]struct{}
+	events map[*subscriber[sequencedEvent]]struct{}
+	closed bool
+	// backlog holds the most recent events, oldest first
+	backlog []sequencedEvent
+	seq     uint64
+
+	dropped atomic.Uint64
+}
+
+// New creates a Server with no subscribers
+func New(opts ...Option) *Server {
+	s := &Server{
+		bufferSize:  DefaultBufferSize,
+		backlogSize: DefaultBacklogSize,
+		frames:      make(map[*subscriber[*telemetry.LobbySessionStateFrame]]struct{}),
+		events:      make(map[*subscriber[sequencedEvent]]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// PublishFrame sends a frame to every frame subscriber whose filter it
+// passes. The frame is copied if anyone is subscribed, so the caller may
+// reuse it, as a pooled processor does, once PublishFrame returns.
+func (s *Server) PublishFrame(frame *telemetry.LobbySessionStateFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.frames) == 0 {
+		return
+	}
+
+	// Subscribers share the copy and only read it
+	frame = proto.Clone(frame).(*telemetry.LobbySessionStateFrame)
+	for sub := range s.frames {
+		if !sub.offer(frame) {
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Consume sends an event to every event subscriber whose filter it passes
+// and keeps it for backfill. It implements events.Sink.
+func (s *Server) Consume(event *telemetry.LobbySessionEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	e := sequencedEvent{seq: s.seq, event: event}
+	if s.backlogSize > 0 {
+		if len(s.backlog) == s.backlogSize {
+			s.backlog = append(s.backlog[:0], s.backlog[1:]...)
+		}
+		s.backlog = append(s.backlog, e)
+	}
+	for sub := range s.events {
+		if !sub.offer(e) {
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Middleware returns processor middleware that publishes every frame that
+// reaches it. Add it last, so frames dropped by earlier middleware are not
+// published.
+func (s *Server) Middleware() processing.Middleware {
+	return func(next processing.Handler) processing.Handler {
+		return func(frame *telemetry.LobbySessionStateFrame) error {
+			s.PublishFrame(frame)
+			return next(frame)
+		}
+	}
+}
+
+// Subscribers returns the number of frame and event subscribers
+func (s *Server) Subscribers() (frames, events int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.frames), len(s.events)
+}
+
+// Dropped returns the number of frames and events dropped because a
+// subscriber's queue was full
+func (s *Server) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close ends every stream and refuses new subscribers. Call it before
+// grpc.Server.GracefulStop, which waits for open streams to finish.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for sub := range s.frames {
+		close(sub.ch)
+	}
+	for sub := range s.events {
+		close(sub.ch)
+	}
+	clear(s.frames)
+	clear(s.events)
+}
+
+// subscribeFrames adds a frame subscriber, or returns nil if the server is closed
+func (s *Server) subscribeFrames(filter FrameFilter) *subscriber[*telemetry.LobbySessionStateFrame] {
+	var last time.Time
+	interval := filter.interval()
+	sub := newSubscriber(s.bufferSize, func(frame *telemetry.LobbySessionStateFrame) bool {
+		if interval <= 0 {
+			return true
+		}
+		// Downsample by frame timestamp, as processing.RateLimit does
+		ts := frame.GetTimestamp().AsTime()
+		if !last.IsZero() && ts.After(last) && ts.Sub(last) < interval {
+			return false
+		}
+		last = ts
+		return true
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.frames[sub] = struct{}{}
+	return sub
+}
+
+// subscribeEvents adds an event subscriber, or returns nil if the server is
+// closed. It also returns up to backfill of the kept events that pass the
+// filter and came after the event numbered after, oldest first; together
+// with the subscription they miss and repeat nothing.
+func (s *Server) subscribeEvents(filter events.EventFilter, after uint64, backfill int) (*subscriber[sequencedEvent], []sequencedEvent) {
+	match := func(e sequencedEvent) bool { return filter.Match(e.event) }
+	sub := newSubscriber(s.bufferSize, match)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, nil
+	}
+	s.events[sub] = struct{}{}
+
+	var kept []sequencedEvent
+	for i := len(s.backlog) - 1; i >= 0 && len(kept) < backfill; i-- {
+		e := s.backlog[i]
+		if e.seq <= after {
+			break
+		}
+		if match(e) {
+			kept = append(kept, e)
+		}
+	}
+	slices.Reverse(kept)
+	return sub, kept
+}
+
+// unsubscribeFrames removes a frame subscriber if Close has not already
+func (s *Server) unsubscribeFrames(sub *subscriber[*telemetry.LobbySessionStateFrame]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.frames[sub]; ok {
+		delete(s.frames, sub)
+		close(sub.ch)
+	}
+}
+
+// unsubscribeEvents removes an event subscriber if Close has not already
+func (s *Server) unsubscribeEvents(sub *subscriber[sequencedEvent]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.events[sub]; ok {
+		delete(s.events, sub)
+		close(sub.ch)
+	}
+}
+
+// sequencedEvent is an event numbered in the order the server received it
+type sequencedEvent struct {
+	seq   uint64
+	event *telemetry.LobbySessionEvent
+}
+
+// subscriber is one stream's queue. offer is only called with the server's
+// lock held, so match needs no locking of its own.
+type subscriber[T any] struct {
+	ch    chan T
+	match func(T) bool
+}
+
+func newSubscriber[T any](bufferSize int, match func(T) bool) *subscriber[T] {
+	return &subscriber[T]{ch: make(chan T, bufferSize), match: match}
+}
+
+// offer queues v if it passes the filter. It returns false if v was
+// dropped because the queue is full.
+func (s *subscriber[T]) offer(v T) bool {
+	if !s.match(v) {
+		return true
+	}
+	select {
+	case s.ch <- v:
+		return true
+	default:
+		return false
+	}
+}
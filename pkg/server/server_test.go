@@ -0,0 +1,368 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-capture/v3/pkg/processing"
+	apigame "github.com/echotools/nevr-common/v4/gen/go/apigame/v1"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// startServer serves srv over an in-process connection and returns a client for it
+func startServer(t *testing.T, srv *Server) *Client {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	srv.Register(grpcServer)
+	go grpcServer.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Close()
+		grpcServer.GracefulStop()
+	})
+	return NewClient(conn)
+}
+
+var testStart = time.Date(2026, 1, 19, 22, 50, 54, 0, time.UTC)
+
+func testFrame(ms int) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		FrameIndex:  uint32(ms),
+		Timestamp:   timestamppb.New(testStart.Add(time.Duration(ms) * time.Millisecond)),
+		Session:     &apigame.SessionResponse{SessionId: "session-a", GameStatus: "playing"},
+		PlayerBones: &apigame.PlayerBonesResponse{ErrCode: 1},
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestServer_StreamFrames(t *testing.T) {
+	srv := New()
+	client := startServer(t, srv)
+	ctx := testContext(t)
+
+	full, err := client.StreamFrames(ctx, FrameFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trimmed, err := client.StreamFrames(ctx, FrameFilter{Fields: []string{"session"}, MaxRate: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames, _ := srv.Subscribers(); frames != 2 {
+		t.Fatalf("expected 2 frame subscribers, got %d", frames)
+	}
+
+	for ms := 0; ms <= 200; ms += 50 {
+		srv.PublishFrame(testFrame(ms))
+	}
+
+	for ms := 0; ms <= 200; ms += 50 {
+		frame, err := full.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.FrameIndex != uint32(ms) || frame.GetPlayerBones().GetErrCode() != 1 {
+			t.Errorf("expected frame %d in full, got %v", ms, frame)
+		}
+	}
+
+	// 10 Hz keeps the frames at 0, 100 and 200 ms
+	for _, ms := range []int{0, 100, 200} {
+		frame, err := trimmed.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.FrameIndex != uint32(ms) {
+			t.Errorf("expected frame %d, got %d", ms, frame.FrameIndex)
+		}
+		if frame.GetSession().GetSessionId() != "session-a" || frame.GetTimestamp() == nil {
+			t.Errorf("expected the session and timestamp to be sent, got %v", frame)
+		}
+		if frame.PlayerBones != nil {
+			t.Errorf("expected player bones to be left out, got %v", frame.PlayerBones)
+		}
+	}
+}
+
+func TestServer_StreamEvents(t *testing.T) {
+	srv := New()
+	client := startServer(t, srv)
+	ctx := testContext(t)
+
+	stream, err := client.StreamEvents(ctx, events.EventFilter{Types: []string{"custom:capture_gap"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := events.NewCustomEvent("joust", map[string]any{"winner": "blue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gap, err := events.NewCustomEvent(processing.CaptureGapEventName, map[string]any{"duration_seconds": 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Consume(other)
+	srv.Consume(gap)
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := events.GetCustomEvent(event)
+	if err != nil {
+		t.Fatalf("expected the custom event to survive the wire: %v", err)
+	}
+	if custom.Name != processing.CaptureGapEventName || custom.Fields["duration_seconds"] != 1.5 {
+		t.Errorf("unexpected event %+v", custom)
+	}
+}
+
+// seenSensor reports every frame it is given
+type seenSensor struct{}
+
+func (seenSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	event, _ := events.NewCustomEvent("seen", map[string]any{"frame": float64(frame.FrameIndex)})
+	return event
+}
+
+func TestServer_FromProcessor(t *testing.T) {
+	srv := New()
+	client := startServer(t, srv)
+	ctx := testContext(t)
+
+	frames, err := client.StreamFrames(ctx, FrameFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventStream, err := client.StreamEvents(ctx, events.EventFilter{Types: []string{"custom:seen"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	detector := events.New(events.WithSensors(seenSensor{}), events.WithSink(srv, events.EventFilter{}))
+	processor := processing.NewWithDetector(detector)
+	defer processor.Stop()
+	processor.Use(
+		processing.Filter(func(frame *telemetry.LobbySessionStateFrame) bool {
+			return frame.GetSession().GetGameStatus() == "playing"
+		}),
+		srv.Middleware(),
+	)
+
+	paused := testFrame(0)
+	paused.Session.GameStatus = "paused"
+	processor.Process(paused)
+	processor.Process(testFrame(16))
+
+	frame, err := frames.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.GetSession().GetGameStatus() != "playing" || frame.FrameIndex != 0 {
+		t.Errorf("expected the first playing frame, got %v", frame)
+	}
+
+	event, err := eventStream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events.EventType(event) != "custom:seen" {
+		t.Errorf("expected the detected event, got %s", events.EventType(event))
+	}
+}
+
+func TestServer_MetadataFilters(t *testing.T) {
+	srv := New()
+	client := startServer(t, srv)
+	ctx := metadata.AppendToOutgoingContext(testContext(t), "nevr-fields", "session")
+
+	// An empty request takes its filters from the metadata
+	raw, err := client.open(ctx, StreamFramesFullMethod, &emptypb.Empty{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A filter set in the request takes precedence
+	typed, err := client.StreamFrames(ctx, FrameFilter{Fields: []string{"player_bones"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.PublishFrame(testFrame(0))
+
+	frame, err := (&FrameStream{stream: raw}).Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Session == nil || frame.PlayerBones != nil {
+		t.Errorf("expected only the session from the metadata filter, got %v", frame)
+	}
+	if frame, err = typed.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Session != nil || frame.PlayerBones == nil {
+		t.Errorf("expected only the bones from the request filter, got %v", frame)
+	}
+}
+
+func TestServer_Reflection(t *testing.T) {
+	client := startServer(t, New(WithReflection()))
+	ctx := testContext(t)
+
+	stream, err := reflectionpb.NewServerReflectionClient(client.conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: ServiceName},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+	if len(files) == 0 {
+		t.Fatalf("expected the service's file descriptor, got %v", resp)
+	}
+	file := &descriptorpb.FileDescriptorProto{}
+	if err := proto.Unmarshal(files[0], file); err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, m := range file.GetMessageType() {
+		messages = append(messages, m.GetName())
+	}
+	if file.GetName() != captureFilePath || len(file.GetService()) != 1 || len(messages) != 2 {
+		t.Errorf("expected the CaptureStream service and its two requests, got %s with %v", file.GetName(), messages)
+	}
+}
+
+func TestServer_InvalidFilter(t *testing.T) {
+	client := startServer(t, New())
+	ctx := testContext(t)
+
+	_, err := client.StreamFrames(ctx, FrameFilter{Fields: []string{"scoreboard"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an unknown field, got %v", err)
+	}
+	_, err = client.StreamEvents(ctx, events.EventFilter{Teams: []telemetry.Role{telemetry.Role(99)}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an unknown team, got %v", err)
+	}
+}
+
+func TestServer_Close(t *testing.T) {
+	srv := New()
+	client := startServer(t, srv)
+	ctx := testContext(t)
+
+	stream, err := client.StreamFrames(ctx, FrameFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if _, err := stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF once the server closes, got %v", err)
+	}
+	if _, err := client.StreamEvents(ctx, events.EventFilter{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable after Close, got %v", err)
+	}
+}
+
+func TestServer_ClientCancel(t *testing.T) {
+	srv := New()
+	client := startServer(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := client.StreamFrames(ctx, FrameFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if frames, _ := srv.Subscribers(); frames == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the subscriber to be removed after the client cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServer_SlowSubscriberDrops(t *testing.T) {
+	srv := New(WithBufferSize(2))
+	sub := srv.subscribeFrames(FrameFilter{})
+	for ms := range 5 {
+		srv.PublishFrame(testFrame(ms))
+	}
+	if len(sub.ch) != 2 || srv.Dropped() != 3 {
+		t.Errorf("expected 2 queued and 3 dropped, got %d queued and %d dropped", len(sub.ch), srv.Dropped())
+	}
+}
+
+func TestServer_AsyncDetectorWithoutEventsChanReader(t *testing.T) {
+	srv := New()
+	client := startServer(t, srv)
+	ctx := testContext(t)
+
+	stream, err := client.StreamEvents(ctx, events.EventFilter{Types: []string{"custom:seen"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As in the Server example: the detector runs asynchronously and only
+	// the sink consumes its events, so EventsChan fills up after 10 batches
+	detector := events.New(events.WithSensors(seenSensor{}), events.WithLossyEventsChan())
+	detector.AddSink(srv, events.EventFilter{}, 256)
+	processor := processing.NewWithDetector(detector)
+	defer processor.Stop()
+
+	const frames = 50
+	for ms := range frames {
+		if err := processor.Process(testFrame(ms)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range frames {
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("received %d of %d events: %v", i, frames, err)
+		}
+	}
+}
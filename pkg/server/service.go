@@ -0,0 +1,306 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Full gRPC names of the service and its methods
+const (
+	ServiceName            = "nevrcapture.v1.CaptureStream"
+	StreamFramesFullMethod = "/" + ServiceName + "/StreamFrames"
+	StreamEventsFullMethod = "/" + ServiceName + "/StreamEvents"
+)
+
+// Request metadata keys carrying subscriber filters, for clients that send
+// an empty request
+const (
+	metadataFields           = "nevr-fields"
+	metadataMaxRate          = "nevr-max-rate"
+	metadataEventTypes       = "nevr-event-types"
+	metadataEventPlayerSlots = "nevr-player-slots"
+	metadataEventTeams       = "nevr-teams"
+	// metadataSubscribed is sent in the response header once the
+	// subscription is in place
+	metadataSubscribed = "nevr-subscribed"
+)
+
+// FrameFilter selects what a frame subscriber receives. The zero value
+// receives every frame in full.
+type FrameFilter struct {
+	// Fields are the LobbySessionStateFrame fields to send, by proto name,
+	// e.g. "session" or "player_bones". frame_index and timestamp are always
+	// sent; an empty list sends every field.
+	Fields []string
+	// MaxRate downsamples the stream to at most this many frames per second
+	// of capture time. Zero sends every frame.
+	MaxRate float64
+}
+
+func (f FrameFilter) interval() time.Duration {
+	if f.MaxRate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / f.MaxRate)
+}
+
+// request encodes the filter as a StreamFramesRequest
+func (f FrameFilter) request() proto.Message {
+	req := dynamicpb.NewMessage(streamFramesRequest)
+	fields := streamFramesRequest.Fields()
+	list := req.Mutable(fields.ByName("fields")).List()
+	for _, name := range f.Fields {
+		list.Append(protoreflect.ValueOfString(name))
+	}
+	req.Set(fields.ByName("max_rate"), protoreflect.ValueOfFloat64(f.MaxRate))
+	return req
+}
+
+// frameFilterFromMetadata decodes a FrameFilter sent with FrameFilter.metadata
+func frameFilterFromMetadata(md metadata.MD) (FrameFilter, error) {
+	var f FrameFilter
+	fields := frameDescriptor.Fields()
+	for _, name := range md.Get(metadataFields) {
+		if fields.ByName(protoreflect.Name(name)) == nil {
+			return f, fmt.Errorf("unknown frame field %q", name)
+		}
+		f.Fields = append(f.Fields, name)
+	}
+	if v := md.Get(metadataMaxRate); len(v) > 0 {
+		rate, err := strconv.ParseFloat(v[0], 64)
+		if err != nil || rate < 0 {
+			return f, fmt.Errorf("invalid max rate %q", v[0])
+		}
+		f.MaxRate = rate
+	}
+	return f, nil
+}
+
+// frameFilterFromRequest decodes a StreamFramesRequest, taking each filter
+// the request leaves unset from the metadata
+func frameFilterFromRequest(req protoreflect.Message, md metadata.MD) (FrameFilter, error) {
+	f, err := frameFilterFromMetadata(md)
+	if err != nil {
+		return f, err
+	}
+	fields := streamFramesRequest.Fields()
+	if list := req.Get(fields.ByName("fields")).List(); list.Len() > 0 {
+		f.Fields = nil
+		for i := range list.Len() {
+			name := list.Get(i).String()
+			if frameDescriptor.Fields().ByName(protoreflect.Name(name)) == nil {
+				return f, fmt.Errorf("unknown frame field %q", name)
+			}
+			f.Fields = append(f.Fields, name)
+		}
+	}
+	if rate := req.Get(fields.ByName("max_rate")).Float(); rate != 0 {
+		if rate < 0 || math.IsNaN(rate) {
+			return f, fmt.Errorf("invalid max rate %v", rate)
+		}
+		f.MaxRate = rate
+	}
+	return f, nil
+}
+
+// eventFilterRequest encodes an event filter as a StreamEventsRequest
+func eventFilterRequest(f events.EventFilter) proto.Message {
+	req := dynamicpb.NewMessage(streamEventsRequest)
+	fields := streamEventsRequest.Fields()
+	types := req.Mutable(fields.ByName("types")).List()
+	for _, t := range f.Types {
+		types.Append(protoreflect.ValueOfString(t))
+	}
+	slots := req.Mutable(fields.ByName("player_slots")).List()
+	for _, slot := range f.PlayerSlots {
+		slots.Append(protoreflect.ValueOfInt32(slot))
+	}
+	teams := req.Mutable(fields.ByName("teams")).List()
+	for _, team := range f.Teams {
+		teams.Append(protoreflect.ValueOfEnum(team.Number()))
+	}
+	return req
+}
+
+// eventFilterFromRequest decodes a StreamEventsRequest, taking each filter
+// the request leaves unset from the metadata
+func eventFilterFromRequest(req protoreflect.Message, md metadata.MD) (events.EventFilter, error) {
+	f, err := eventFilterFromMetadata(md)
+	if err != nil {
+		return f, err
+	}
+	fields := streamEventsRequest.Fields()
+	if list := req.Get(fields.ByName("types")).List(); list.Len() > 0 {
+		f.Types = nil
+		for i := range list.Len() {
+			f.Types = append(f.Types, list.Get(i).String())
+		}
+	}
+	if list := req.Get(fields.ByName("player_slots")).List(); list.Len() > 0 {
+		f.PlayerSlots = nil
+		for i := range list.Len() {
+			f.PlayerSlots = append(f.PlayerSlots, int32(list.Get(i).Int()))
+		}
+	}
+	if list := req.Get(fields.ByName("teams")).List(); list.Len() > 0 {
+		f.Teams = nil
+		for i := range list.Len() {
+			team := telemetry.Role(list.Get(i).Enum())
+			if _, ok := telemetry.Role_name[int32(team)]; !ok {
+				return f, fmt.Errorf("unknown team %d", team)
+			}
+			f.Teams = append(f.Teams, team)
+		}
+	}
+	return f, nil
+}
+
+// eventFilterFromMetadata decodes an event filter sent with eventFilterMetadata
+func eventFilterFromMetadata(md metadata.MD) (events.EventFilter, error) {
+	f := events.EventFilter{Types: md.Get(metadataEventTypes)}
+	for _, v := range md.Get(metadataEventPlayerSlots) {
+		slot, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return f, fmt.Errorf("invalid player slot %q", v)
+		}
+		f.PlayerSlots = append(f.PlayerSlots, int32(slot))
+	}
+	for _, v := range md.Get(metadataEventTeams) {
+		team, ok := telemetry.Role_value[v]
+		if !ok {
+			return f, fmt.Errorf("unknown team %q", v)
+		}
+		f.Teams = append(f.Teams, telemetry.Role(team))
+	}
+	return f, nil
+}
+
+var frameDescriptor = (&telemetry.LobbySessionStateFrame{}).ProtoReflect().Descriptor()
+
+// trimFrame returns a frame with only the given fields of frame, plus its
+// index and timestamp. The result shares frame's messages.
+func trimFrame(frame *telemetry.LobbySessionStateFrame, fields []string) *telemetry.LobbySessionStateFrame {
+	if len(fields) == 0 {
+		return frame
+	}
+	trimmed := &telemetry.LobbySessionStateFrame{
+		FrameIndex: frame.FrameIndex,
+		Timestamp:  frame.Timestamp,
+	}
+	src, dst := frame.ProtoReflect(), trimmed.ProtoReflect()
+	for _, name := range fields {
+		fd := frameDescriptor.Fields().ByName(protoreflect.Name(name))
+		if src.Has(fd) {
+			dst.Set(fd, src.Get(fd))
+		}
+	}
+	return trimmed
+}
+
+// serviceDesc describes the service in capture.proto by hand, as the module
+// has no protoc step
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Metadata:    captureFilePath,
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamFrames",
+			Handler:       streamFramesHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamEvents",
+			Handler:       streamEventsHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+// Register adds the service to a gRPC server, and with WithReflection the
+// reflection service too if registrar is a *grpc.Server
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	registrar.RegisterService(&serviceDesc, s)
+	if r, ok := registrar.(reflection.GRPCServer); ok && s.reflection {
+		reflection.Register(r)
+	}
+}
+
+func streamFramesHandler(srv any, stream grpc.ServerStream) error {
+	s := srv.(*Server)
+	req := dynamicpb.NewMessage(streamFramesRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	filter, err := frameFilterFromRequest(req, md)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub := s.subscribeFrames(filter)
+	if sub == nil {
+		return status.Error(codes.Unavailable, "server closed")
+	}
+	defer s.unsubscribeFrames(sub)
+	return forward(stream, sub.ch, func(frame *telemetry.LobbySessionStateFrame) any {
+		return trimFrame(frame, filter.Fields)
+	})
+}
+
+func streamEventsHandler(srv any, stream grpc.ServerStream) error {
+	s := srv.(*Server)
+	req := dynamicpb.NewMessage(streamEventsRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	filter, err := eventFilterFromRequest(req, md)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub, _ := s.subscribeEvents(filter, 0, 0)
+	if sub == nil {
+		return status.Error(codes.Unavailable, "server closed")
+	}
+	defer s.unsubscribeEvents(sub)
+	return forward(stream, sub.ch, func(e sequencedEvent) any {
+		return e.event
+	})
+}
+
+// forward sends everything queued for a subscriber until the client goes
+// away or the server is closed
+func forward[T any](stream grpc.ServerStream, ch <-chan T, message func(T) any) error {
+	// Headers go out straight away, so clients know they are subscribed
+	if err := stream.SendHeader(metadata.Pairs(metadataSubscribed, "true")); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case v, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.SendMsg(message(v)); err != nil {
+				return err
+			}
+		}
+	}
+}